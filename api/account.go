@@ -2,11 +2,13 @@ package api
 
 import (
 	"encoding/json"
-	"io/ioutil"
-	"log"
+	"errors"
+	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
+	"github.com/firstlane/baton/utils"
 	"github.com/spf13/viper"
 )
 
@@ -14,7 +16,7 @@ const (
 	accountsURLBase = "accounts.spotify.com/"
 )
 
-// The Tokens struct describes a combination of the items returned from Spotify's API Authorization process as well as Baton-created fields to store in your config directory 
+// The Tokens struct describes a combination of the items returned from Spotify's API Authorization process as well as Baton-created fields to store in your config directory
 type Tokens struct {
 	AccessToken    string        `json:"access_token"`
 	TokenType      string        `json:"token_type"`
@@ -32,14 +34,17 @@ func GetAuthorizationURL(id string) string {
 	v.Set("client_id", id)
 	v.Set("response_type", "code")
 	v.Set("redirect_uri", "http://localhost:15298/callback")
-	v.Set("scope", "playlist-read-private user-top-read user-library-read user-library-modify user-read-currently-playing user-read-recently-played user-modify-playback-state user-read-playback-state user-follow-read playlist-read-collaborative")
+	v.Set("scope", "playlist-read-private user-top-read user-library-read user-library-modify user-read-currently-playing user-read-recently-played user-modify-playback-state user-read-playback-state user-follow-read playlist-read-collaborative playlist-modify-public playlist-modify-private ugc-image-upload")
 
 	r := buildRequest("GET", accountsURLBase+"authorize", v, nil)
 	return r.URL.String()
 }
 
+// ErrNoToken is returned when Baton hasn't been authorized yet
+var ErrNoToken = errors.New("No valid token found, please run `baton auth` to authenticate")
+
 // AuthorizeWithCode completes the Authorization process and stores your refresh and current access tokens in the config directory for Baton
-func AuthorizeWithCode(id, secret, code string) {
+func AuthorizeWithCode(id, secret, code string) error {
 	var t Tokens
 	v := url.Values{}
 	v.Set("grant_type", "authorization_code")
@@ -53,17 +58,36 @@ func AuthorizeWithCode(id, secret, code string) {
 	err := makeRequest(r, &t)
 
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	t.ExpirationDate = time.Now().Add((t.ExpiresIn - 30) * time.Second)
 	t.ClientID = id
 	t.ClientSecret = secret
 
-	writeTokensToConfig(t)
+	tokenCache.Lock()
+	defer tokenCache.Unlock()
+	tokenCache.Tokens = t
+
+	return writeTokensToConfig(t)
 }
 
-func getAccessToken() string {
+// tokenCache keeps the tokens of the last authorization or refresh in memory. Long running commands, such as the
+// daemon, don't see the tokens written to the config, without it they would refresh the token on every call.
+// Holding the lock also makes concurrent callers wait for a single refresh.
+var tokenCache struct {
+	sync.Mutex
+	Tokens
+}
+
+func getAccessToken() (string, error) {
+	tokenCache.Lock()
+	defer tokenCache.Unlock()
+
+	if tokenCache.AccessToken != "" && tokenCache.ExpirationDate.After(time.Now()) {
+		return tokenCache.AccessToken, nil
+	}
+
 	var t Tokens
 
 	rt := viper.GetString("refresh_token")
@@ -71,8 +95,13 @@ func getAccessToken() string {
 	secret := viper.GetString("client_secret")
 	expiration := viper.GetTime("expiration_date")
 
+	// Spotify may replace the refresh token when refreshing, the newest one is in memory
+	if tokenCache.RefreshToken != "" {
+		rt = tokenCache.RefreshToken
+	}
+
 	if rt == "" {
-		log.Fatal("No valid token found, please run `baton auth` to authenticate")
+		return "", ErrNoToken
 	}
 
 	if expiration.Before(time.Now()) {
@@ -86,32 +115,52 @@ func getAccessToken() string {
 
 		err := makeRequest(r, &t)
 
+		// A rejected refresh token means the user has to authorize Baton again
+		if e, ok := err.(*Error); ok && e.Status == http.StatusBadRequest {
+			return "", &Error{Status: http.StatusUnauthorized, Message: "Couldn't refresh the access token, please run `baton auth` again: " + e.Message}
+		}
+
 		if err != nil {
-			log.Fatal(err)
+			return "", err
 		}
 
 		t.ExpirationDate = time.Now().Add((t.ExpiresIn - 30) * time.Second)
 		t.ClientID = id
 		t.ClientSecret = secret
-		t.RefreshToken = rt
 
-		writeTokensToConfig(t)
+		if t.RefreshToken == "" {
+			t.RefreshToken = rt
+		}
+
+		tokenCache.Tokens = t
 
-		return t.AccessToken
+		return t.AccessToken, writeTokensToConfig(t)
 	}
 
-	return viper.GetString("access_token")
+	tokenCache.AccessToken = viper.GetString("access_token")
+	tokenCache.ExpirationDate = expiration
+
+	return tokenCache.AccessToken, nil
 }
 
-func writeTokensToConfig(t Tokens) {
+func writeTokensToConfig(t Tokens) error {
+	var tm map[string]interface{}
+
 	ts, err := json.Marshal(t)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	err = ioutil.WriteFile(viper.ConfigFileUsed(), ts, 0666)
+	err = json.Unmarshal(ts, &tm)
 
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+
+	// Merge the tokens into the existing config so other settings (smart playlists, etc) survive a token refresh
+	return utils.UpdateJSONFile(viper.ConfigFileUsed(), func(m map[string]interface{}) {
+		for k, v := range tm {
+			m[k] = v
+		}
+	})
 }
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestGetAccessTokenRefreshesOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "baton")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	cfg := filepath.Join(dir, "baton.json")
+	expired := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	err = ioutil.WriteFile(cfg, []byte(`{"refresh_token": "rt", "access_token": "old", "expiration_date": "`+expired+`", "other": 1}`), 0600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	viper.SetConfigFile(cfg)
+
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	refreshes := 0
+	orig := client
+	client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		refreshes++
+		body := `{"access_token": "new", "expires_in": 3600}`
+
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})}
+
+	defer func() {
+		client = orig
+		tokenCache.Tokens = Tokens{}
+		viper.Reset()
+	}()
+
+	for i := 0; i < 3; i++ {
+		token, err := getAccessToken()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token != "new" {
+			t.Fatalf("got token %q, want new", token)
+		}
+	}
+
+	if refreshes != 1 {
+		t.Errorf("refreshed %d times, want 1", refreshes)
+	}
+
+	b, err := ioutil.ReadFile(cfg)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m map[string]interface{}
+
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m["access_token"] != "new" || m["refresh_token"] != "rt" || m["other"] != float64(1) {
+		t.Errorf("config after refresh = %v", m)
+	}
+}
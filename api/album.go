@@ -15,6 +15,7 @@ type SimpleAlbum struct {
 	ID               string            `json:"id"`
 	Images           []Image           `json:"images"`
 	Name             string            `json:"name"`
+	ReleaseDate      string            `json:"release_date"`
 	Type             string            `json:"type"`
 	URI              string            `json:"uri"`
 }
@@ -49,7 +50,11 @@ type SavedAlbumsPaged struct {
 
 // GetTracksForAlbum returns a list of "Simple" Track objects in a paging object for the given album
 func GetTracksForAlbum(albumID string) (pt SimpleTracksPaged, err error) {
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return pt, err
+	}
 
 	r := buildRequest("GET", apiURLBase+"albums/"+albumID+"/tracks", nil, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -61,7 +66,11 @@ func GetTracksForAlbum(albumID string) (pt SimpleTracksPaged, err error) {
 
 // GetNextTracksForAlbum takes in the Next field from the paging objects returned from GetTracksForAlbum and allows you to move forward through the tracks
 func GetNextTracksForAlbum(url string) (pt SimpleTracksPaged, err error) {
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return pt, err
+	}
 
 	r, err := http.NewRequest("GET", url, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -1,6 +1,11 @@
 package api
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/go-querystring/query"
+)
 
 // The FullArtist struct describes a "Full" Artist object as defined by the Spotify Web API
 type FullArtist struct {
@@ -39,7 +44,11 @@ type FullArtistsPaged struct {
 
 // GetAlbumsForArtist returns a list of "Simple" Album objects in a paging object for the given artist
 func GetAlbumsForArtist(artistID string) (pa SimpleAlbumsPaged, err error) {
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return pa, err
+	}
 
 	r := buildRequest("GET", apiURLBase+"artists/"+artistID+"/albums", nil, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -51,7 +60,11 @@ func GetAlbumsForArtist(artistID string) (pa SimpleAlbumsPaged, err error) {
 
 // GetNextAlbumsForArtist takes in the Next field from the paging objects returned from GetAlbumsForArtist and allows you to move forward through the albums
 func GetNextAlbumsForArtist(url string) (pa SimpleAlbumsPaged, err error) {
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return pa, err
+	}
 
 	r, err := http.NewRequest("GET", url, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -60,3 +73,128 @@ func GetNextAlbumsForArtist(url string) (pa SimpleAlbumsPaged, err error) {
 
 	return pa, err
 }
+
+// The Cursors struct describes the cursors used to move through a Spotify cursor-based paging object
+type Cursors struct {
+	After  string `json:"after"`
+	Before string `json:"before"`
+}
+
+// The FullArtistsCursorPaged struct is a slice of FullArtist objects wrapped in a Spotify cursor-based paging object
+type FullArtistsCursorPaged struct {
+	Cursors *Cursors     `json:"cursors"`
+	Href    string       `json:"href"`
+	Items   []FullArtist `json:"items"`
+	Limit   int          `json:"limit"`
+	Next    string       `json:"next"`
+	Total   int          `json:"total"`
+}
+
+// GetFollowedArtists returns the artists the user follows in a cursor-based paging object
+func GetFollowedArtists() (fa *FullArtistsCursorPaged, err error) {
+	var res struct {
+		Artists *FullArtistsCursorPaged `json:"artists"`
+	}
+
+	v, err := query.Values(nil)
+
+	if err != nil {
+		return fa, err
+	}
+
+	v.Set("type", "artist")
+	v.Set("limit", "50")
+
+	t, err := getAccessToken()
+
+	if err != nil {
+		return fa, err
+	}
+
+	r := buildRequest("GET", apiURLBase+"me/following", v, nil)
+	r.Header.Add("Authorization", "Bearer "+t)
+
+	err = makeRequest(r, &res)
+
+	return res.Artists, err
+}
+
+// GetNextFollowedArtists takes in the Next field from the paging objects returned from GetFollowedArtists and allows you to move forward through the artists
+func GetNextFollowedArtists(url string) (fa *FullArtistsCursorPaged, err error) {
+	var res struct {
+		Artists *FullArtistsCursorPaged `json:"artists"`
+	}
+
+	t, err := getAccessToken()
+
+	if err != nil {
+		return fa, err
+	}
+
+	r, err := http.NewRequest("GET", url, nil)
+	r.Header.Add("Authorization", "Bearer "+t)
+
+	err = makeRequest(r, &res)
+
+	return res.Artists, err
+}
+
+// GetArtists returns the "Full" Artist objects for up to 50 artist IDs
+func GetArtists(artistIDs []string) (a []FullArtist, err error) {
+	var res struct {
+		Artists []FullArtist `json:"artists"`
+	}
+
+	v, err := query.Values(nil)
+
+	if err != nil {
+		return a, err
+	}
+
+	v.Set("ids", strings.Join(artistIDs, ","))
+
+	t, err := getAccessToken()
+
+	if err != nil {
+		return a, err
+	}
+
+	r := buildRequest("GET", apiURLBase+"artists", v, nil)
+	r.Header.Add("Authorization", "Bearer "+t)
+
+	err = makeRequest(r, &res)
+
+	return res.Artists, err
+}
+
+// GetTopTracksForArtist returns the most popular tracks of the given artist, market defaults to the user's country when empty
+func GetTopTracksForArtist(artistID, market string) (ft []FullTrack, err error) {
+	var res struct {
+		Tracks []FullTrack `json:"tracks"`
+	}
+
+	if market == "" {
+		market = "from_token"
+	}
+
+	v, err := query.Values(nil)
+
+	if err != nil {
+		return ft, err
+	}
+
+	v.Set("market", market)
+
+	t, err := getAccessToken()
+
+	if err != nil {
+		return ft, err
+	}
+
+	r := buildRequest("GET", apiURLBase+"artists/"+artistID+"/top-tracks", v, nil)
+	r.Header.Add("Authorization", "Bearer "+t)
+
+	err = makeRequest(r, &res)
+
+	return res.Tracks, err
+}
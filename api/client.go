@@ -3,10 +3,13 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"sync"
+	"time"
 )
 
 const (
@@ -26,10 +29,50 @@ type Followers struct {
 	Total int    `json:"total"`
 }
 
+// The Error struct describes an error response from the Spotify Web API
+type Error struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status))
+	}
+
+	return fmt.Sprintf("%d %s", e.Status, e.Message)
+}
+
+// ErrNoActiveDevice is returned by GetPlayerState when Spotify isn't playing on any device
+var ErrNoActiveDevice = errors.New("no active device")
+
+// errNoContent is returned when a response was expected but Spotify answered with 204 No Content
+var errNoContent = errors.New("no content")
+
+// requestTimeout is how long a call to Spotify may take, callers that serialize their calls would otherwise all wait on
+// one request that hangs
+const requestTimeout = 30 * time.Second
+
+// settleDelay is how long Spotify is given to apply a change to the player
+const settleDelay = 300 * time.Millisecond
+
+// The CallLock struct serializes the calls of a server answering several clients at once, the token refresh goes through
+// viper which isn't safe for concurrent use
+type CallLock struct {
+	sync.Mutex
+}
+
+// Settle waits for Spotify to apply a change to the player, a state asked for right after the call may not show it yet.
+// The lock isn't held meanwhile
+func (l *CallLock) Settle() {
+	time.Sleep(settleDelay)
+}
+
 var client *http.Client
 
 func init() {
-	client = &http.Client{}
+	client = &http.Client{Timeout: requestTimeout}
 }
 
 func buildRequest(method, path string, query url.Values, b io.Reader) *http.Request {
@@ -65,7 +108,11 @@ func makeRequest(r *http.Request, d interface{}) error {
 		res.StatusCode != http.StatusCreated &&
 		res.StatusCode != http.StatusAccepted &&
 		res.StatusCode != http.StatusNoContent {
-		return errors.New(res.Status)
+		return parseError(res)
+	}
+
+	if d != nil && res.StatusCode == http.StatusNoContent {
+		return errNoContent
 	}
 
 	if d != nil {
@@ -74,3 +121,26 @@ func makeRequest(r *http.Request, d interface{}) error {
 
 	return nil
 }
+
+// parseError builds an Error from a failed response, the Web API nests the details under "error" while the accounts service uses error_description
+func parseError(res *http.Response) error {
+	var body struct {
+		Error            json.RawMessage `json:"error"`
+		ErrorDescription string          `json:"error_description"`
+	}
+
+	e := &Error{Status: res.StatusCode}
+
+	if json.NewDecoder(res.Body).Decode(&body) != nil {
+		return e
+	}
+
+	if json.Unmarshal(body.Error, e) != nil {
+		json.Unmarshal(body.Error, &e.Reason)
+		e.Message = body.ErrorDescription
+	}
+
+	e.Status = res.StatusCode
+
+	return e
+}
@@ -3,8 +3,8 @@ package api
 import (
 	"bytes"
 	"encoding/json"
-	"log"
 	"strconv"
+	"time"
 
 	"github.com/google/go-querystring/query"
 )
@@ -30,6 +30,48 @@ type PlayerState struct {
 	Item         *FullTrack     `json:"item"`
 }
 
+// ClampPosition keeps a position in milliseconds within the current track, ex. when seeking past its end
+func (ps PlayerState) ClampPosition(pos int) int {
+	if pos < 0 || ps.Item == nil {
+		return 0
+	}
+
+	if pos > ps.Item.DurationMs {
+		return ps.Item.DurationMs
+	}
+
+	return pos
+}
+
+// The PlayHistory struct describes a track the user played, as returned by the recently played endpoint
+type PlayHistory struct {
+	Track    FullTrack      `json:"track"`
+	PlayedAt time.Time      `json:"played_at"`
+	Context  *PlayerContext `json:"context"`
+}
+
+// The PlayHistoryCursorPaged struct is a slice of PlayHistory objects wrapped in a Spotify cursor-based paging object
+type PlayHistoryCursorPaged struct {
+	Cursors *Cursors      `json:"cursors"`
+	Href    string        `json:"href"`
+	Items   []PlayHistory `json:"items"`
+	Limit   int           `json:"limit"`
+	Next    string        `json:"next"`
+}
+
+// The Queue struct describes the track that is playing and the tracks that will play after it
+type Queue struct {
+	CurrentlyPlaying *FullTrack  `json:"currently_playing"`
+	Queue            []FullTrack `json:"queue"`
+}
+
+// The RecentlyPlayedOptions struct describes the options for GetRecentlyPlayed, After and Before are unix timestamps in milliseconds and only one of them can be set
+type RecentlyPlayedOptions struct {
+	Limit  int   `url:"limit,omitempty"`
+	After  int64 `url:"after,omitempty"`
+	Before int64 `url:"before,omitempty"`
+}
+
 // The Options struct describes options that can be used by the majority of API endpoints
 type Options struct {
 	DeviceID string `json:"device_id,omitempty" url:"device_id,omitempty"`
@@ -44,9 +86,10 @@ type PlayerOptions struct {
 	Offset     *PlayerOffsetOptions `json:"offset,omitempty" url:"offset,omitempty"`
 }
 
+// The TransferOptions struct describes the device to move playback to when calling TransferPlayback
 type TransferOptions struct {
-	DeviceIDs	[]string	`json:"device_ids" url:"device_ids"`
-	Play bool `json:"play,omitempty" url:"play,omitempty"`
+	DeviceIDs []string `json:"device_ids" url:"device_ids"`
+	Play      bool     `json:"play,omitempty" url:"play,omitempty"`
 }
 
 // The PlayerOffsetOptions describes how to set the offset within a context when controlling playback
@@ -59,7 +102,11 @@ type PlayerOffsetOptions struct {
 // GetDevices returns a list of available playback devices
 func GetDevices() (d []Device, err error) {
 	var ds Devices
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return d, err
+	}
 
 	r := buildRequest("GET", apiURLBase+"me/player/devices", nil, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -77,13 +124,22 @@ func GetPlayerState(opts *Options) (ps PlayerState, err error) {
 		return ps, err
 	}
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return ps, err
+	}
 
 	r := buildRequest("GET", apiURLBase+"me/player", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
 
 	err = makeRequest(r, &ps)
 
+	// Spotify answers with no content at all when nothing is playing on any device
+	if err == errNoContent {
+		err = ErrNoActiveDevice
+	}
+
 	return ps, err
 }
 
@@ -98,7 +154,11 @@ func SetRepeatMode(state string, opts *Options) error {
 
 	v.Add("state", state)
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
 
 	r := buildRequest("PUT", apiURLBase+"me/player/repeat", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -119,7 +179,11 @@ func SetVolume(vol int, opts *Options) error {
 
 	v.Add("volume_percent", strconv.Itoa(vol))
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
 
 	r := buildRequest("PUT", apiURLBase+"me/player/volume", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -137,7 +201,11 @@ func PausePlayback(opts *Options) error {
 		return err
 	}
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
 
 	r := buildRequest("PUT", apiURLBase+"me/player/pause", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -157,7 +225,11 @@ func SeekToPosition(pos int, opts *Options) error {
 
 	v.Add("position_ms", strconv.Itoa(pos))
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
 
 	r := buildRequest("PUT", apiURLBase+"me/player/seek", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -179,12 +251,16 @@ func StartPlayback(opts *PlayerOptions) error {
 	j, err := json.Marshal(opts)
 
 	if err != nil {
-		log.Fatal("fatal", err)
+		return err
 	}
 
 	b := bytes.NewBuffer(j)
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
 
 	r := buildRequest("PUT", apiURLBase+"me/player/play", v, b)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -195,17 +271,21 @@ func StartPlayback(opts *PlayerOptions) error {
 	return err
 }
 
+// TransferPlayback moves playback to the given device, Play starts playback there even if it was paused
 func TransferPlayback(opts *TransferOptions) error {
-
 	j, err := json.Marshal(opts)
 
 	if err != nil {
-		log.Fatal("fatal", err)
+		return err
 	}
 
 	b := bytes.NewBuffer(j)
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
 
 	r := buildRequest("PUT", apiURLBase+"me/player", nil, b)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -223,7 +303,11 @@ func SkipToNext(opts *Options) error {
 		return err
 	}
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
 
 	r := buildRequest("POST", apiURLBase+"me/player/next", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -241,7 +325,11 @@ func SkipToPrevious(opts *Options) error {
 		return err
 	}
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
 
 	r := buildRequest("POST", apiURLBase+"me/player/previous", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -251,6 +339,51 @@ func SkipToPrevious(opts *Options) error {
 	return err
 }
 
+// AddToQueue adds the track or episode with the given URI to the end of the queue
+func AddToQueue(uri string, opts *Options) error {
+	v, err := query.Values(opts)
+
+	if err != nil {
+		return err
+	}
+
+	v.Add("uri", uri)
+
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
+
+	r := buildRequest("POST", apiURLBase+"me/player/queue", v, nil)
+	r.Header.Add("Authorization", "Bearer "+t)
+
+	err = makeRequest(r, nil)
+
+	return err
+}
+
+// GetQueue returns the track that is playing and the tracks queued after it, including the rest of the context
+func GetQueue() (q Queue, err error) {
+	t, err := getAccessToken()
+
+	if err != nil {
+		return q, err
+	}
+
+	r := buildRequest("GET", apiURLBase+"me/player/queue", nil, nil)
+	r.Header.Add("Authorization", "Bearer "+t)
+
+	err = makeRequest(r, &q)
+
+	// Spotify answers with no content when nothing is playing
+	if err == errNoContent {
+		err = ErrNoActiveDevice
+	}
+
+	return q, err
+}
+
 // ToggleShuffle toggles the shuffle state on/off
 func ToggleShuffle(state bool, opts *Options) error {
 	v, err := query.Values(opts)
@@ -261,7 +394,11 @@ func ToggleShuffle(state bool, opts *Options) error {
 
 	v.Add("state", strconv.FormatBool(state))
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
 
 	r := buildRequest("PUT", apiURLBase+"me/player/shuffle", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -270,3 +407,25 @@ func ToggleShuffle(state bool, opts *Options) error {
 
 	return err
 }
+
+// GetRecentlyPlayed returns the tracks the user played most recently, Spotify only keeps the last 50
+func GetRecentlyPlayed(opts *RecentlyPlayedOptions) (ph PlayHistoryCursorPaged, err error) {
+	v, err := query.Values(opts)
+
+	if err != nil {
+		return ph, err
+	}
+
+	t, err := getAccessToken()
+
+	if err != nil {
+		return ph, err
+	}
+
+	r := buildRequest("GET", apiURLBase+"me/player/recently-played", v, nil)
+	r.Header.Add("Authorization", "Bearer "+t)
+
+	err = makeRequest(r, &ph)
+
+	return ph, err
+}
@@ -1,7 +1,13 @@
 package api
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"github.com/google/go-querystring/query"
 )
@@ -41,7 +47,11 @@ type SimplePlaylistsPaged struct {
 
 // GetTracksForPlaylist returns a list of PlaylistTrack objects in a paging object for the given user and playlist
 func GetTracksForPlaylist(userID, playlistID string) (pt PlaylistTracksPaged, err error) {
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return pt, err
+	}
 
 	r := buildRequest("GET", apiURLBase+"users/"+userID+"/playlists/"+playlistID+"/tracks", nil, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -53,7 +63,11 @@ func GetTracksForPlaylist(userID, playlistID string) (pt PlaylistTracksPaged, er
 
 // GetNextTracksForPlaylist takes in the Next field from the paging objects returned from GetTracksForPlaylist and allows you to move forward through the tracks
 func GetNextTracksForPlaylist(url string) (pt PlaylistTracksPaged, err error) {
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return pt, err
+	}
 
 	r, err := http.NewRequest("GET", url, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -75,7 +89,11 @@ func GetMyPlaylists() (pt *SimplePlaylistsPaged, err error) {
 	v.Set("limit", "10")
 	v.Set("offset", "0")
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return pt, err
+	}
 
 	r := buildRequest("GET", apiURLBase+"me/playlists", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -87,7 +105,11 @@ func GetMyPlaylists() (pt *SimplePlaylistsPaged, err error) {
 
 // GetNextMyPlaylists takes in the Next fields from the paging objects returned from me/playlists and allows you to move forward through the results
 func GetNextMyPlaylists(url string) (pt *SimplePlaylistsPaged, err error) {
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return pt, err
+	}
 
 	r, err := http.NewRequest("GET", url, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -96,3 +118,192 @@ func GetNextMyPlaylists(url string) (pt *SimplePlaylistsPaged, err error) {
 
 	return pt, err
 }
+
+// The PlaylistDetails struct describes the fields that can be set when creating a playlist
+type PlaylistDetails struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Public      bool   `json:"public"`
+}
+
+// The PlaylistSnapshot struct describes the version identifier Spotify returns after a playlist is modified
+type PlaylistSnapshot struct {
+	SnapshotID string `json:"snapshot_id"`
+}
+
+type playlistTrackURIs struct {
+	URIs []string `json:"uris"`
+}
+
+// GetPlaylist returns the playlist for the given playlist ID
+func GetPlaylist(playlistID string) (p SimplePlaylist, err error) {
+	t, err := getAccessToken()
+
+	if err != nil {
+		return p, err
+	}
+
+	r := buildRequest("GET", apiURLBase+"playlists/"+playlistID, nil, nil)
+	r.Header.Add("Authorization", "Bearer "+t)
+
+	err = makeRequest(r, &p)
+
+	return p, err
+}
+
+// CreatePlaylist creates an empty playlist owned by the given user
+func CreatePlaylist(userID string, details *PlaylistDetails) (p SimplePlaylist, err error) {
+	j, err := json.Marshal(details)
+
+	if err != nil {
+		return p, err
+	}
+
+	t, err := getAccessToken()
+
+	if err != nil {
+		return p, err
+	}
+
+	r := buildRequest("POST", apiURLBase+"users/"+userID+"/playlists", nil, bytes.NewBuffer(j))
+	r.Header.Add("Authorization", "Bearer "+t)
+	r.Header.Add("Content-Type", "application/json")
+
+	err = makeRequest(r, &p)
+
+	return p, err
+}
+
+// ReplacePlaylistTracks replaces every track of the playlist with the given URIs, Spotify allows at most 100 URIs per request
+func ReplacePlaylistTracks(playlistID string, uris []string) (s PlaylistSnapshot, err error) {
+	j, err := json.Marshal(playlistTrackURIs{URIs: uris})
+
+	if err != nil {
+		return s, err
+	}
+
+	t, err := getAccessToken()
+
+	if err != nil {
+		return s, err
+	}
+
+	r := buildRequest("PUT", apiURLBase+"playlists/"+playlistID+"/tracks", nil, bytes.NewBuffer(j))
+	r.Header.Add("Authorization", "Bearer "+t)
+	r.Header.Add("Content-Type", "application/json")
+
+	err = makeRequest(r, &s)
+
+	return s, err
+}
+
+// AddTracksToPlaylist appends the given URIs to the end of the playlist, Spotify allows at most 100 URIs per request
+func AddTracksToPlaylist(playlistID string, uris []string) (s PlaylistSnapshot, err error) {
+	j, err := json.Marshal(playlistTrackURIs{URIs: uris})
+
+	if err != nil {
+		return s, err
+	}
+
+	t, err := getAccessToken()
+
+	if err != nil {
+		return s, err
+	}
+
+	r := buildRequest("POST", apiURLBase+"playlists/"+playlistID+"/tracks", nil, bytes.NewBuffer(j))
+	r.Header.Add("Authorization", "Bearer "+t)
+	r.Header.Add("Content-Type", "application/json")
+
+	err = makeRequest(r, &s)
+
+	return s, err
+}
+
+// The ReorderOptions struct describes which range of tracks to move within a playlist and where to move them to
+type ReorderOptions struct {
+	RangeStart   int    `json:"range_start"`
+	InsertBefore int    `json:"insert_before"`
+	RangeLength  int    `json:"range_length,omitempty"`
+	SnapshotID   string `json:"snapshot_id,omitempty"`
+}
+
+// ReorderPlaylistTracks moves a range of tracks within the playlist, pass the snapshot from the previous change to make sure the positions refer to the expected version
+func ReorderPlaylistTracks(playlistID string, opts *ReorderOptions) (s PlaylistSnapshot, err error) {
+	j, err := json.Marshal(opts)
+
+	if err != nil {
+		return s, err
+	}
+
+	t, err := getAccessToken()
+
+	if err != nil {
+		return s, err
+	}
+
+	r := buildRequest("PUT", apiURLBase+"playlists/"+playlistID+"/tracks", nil, bytes.NewBuffer(j))
+	r.Header.Add("Authorization", "Bearer "+t)
+	r.Header.Add("Content-Type", "application/json")
+
+	err = makeRequest(r, &s)
+
+	return s, err
+}
+
+// GetPlaylistCoverImages returns the cover images of the playlist, the first image is the largest
+func GetPlaylistCoverImages(playlistID string) (i []Image, err error) {
+	t, err := getAccessToken()
+
+	if err != nil {
+		return i, err
+	}
+
+	r := buildRequest("GET", apiURLBase+"playlists/"+playlistID+"/images", nil, nil)
+	r.Header.Add("Authorization", "Bearer "+t)
+
+	err = makeRequest(r, &i)
+
+	return i, err
+}
+
+// UploadPlaylistCoverImage replaces the cover image of the playlist with the given JPEG data
+// Spotify requires the base64 encoded image to be at most 256KB and the ugc-image-upload scope
+func UploadPlaylistCoverImage(playlistID string, jpeg []byte) error {
+	b := base64.StdEncoding.EncodeToString(jpeg)
+
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
+
+	r := buildRequest("PUT", apiURLBase+"playlists/"+playlistID+"/images", nil, strings.NewReader(b))
+	r.Header.Add("Authorization", "Bearer "+t)
+	r.Header.Add("Content-Type", "image/jpeg")
+
+	return makeRequest(r, nil)
+}
+
+// DownloadImage fetches the data of an image hosted by Spotify
+func DownloadImage(i Image) ([]byte, error) {
+	r, err := http.NewRequest("GET", i.URL, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Do(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New(res.Status)
+	}
+
+	return ioutil.ReadAll(res.Body)
+}
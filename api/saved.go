@@ -14,7 +14,11 @@ func GetSavedTracks(opts *SearchOptions) (result *SavedTracksPaged, err error) {
 		return result, err
 	}
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return result, err
+	}
 
 	r := buildRequest("GET", apiURLBase+"me/tracks", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -26,7 +30,11 @@ func GetSavedTracks(opts *SearchOptions) (result *SavedTracksPaged, err error) {
 
 // GetNextSavedTracks takes in the Next fields from the paging objects returned from Saved and moves forward through the results
 func GetNextSavedTracks(url string) (sr *SavedTracksPaged, err error) {
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return sr, err
+	}
 
 	r, err := http.NewRequest("GET", url, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -46,7 +54,11 @@ func SaveTrack(trackID string) (err error) {
 
 	v.Add("ids", trackID)
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
 
 	r := buildRequest("PUT", apiURLBase+"me/tracks", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -66,7 +78,11 @@ func RemoveSavedTrack(trackID string) (err error) {
 
 	v.Add("ids", trackID)
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
 
 	r := buildRequest("DELETE", apiURLBase+"me/tracks", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -84,7 +100,11 @@ func GetSavedAlbums(opts *SearchOptions) (result *SavedAlbumsPaged, err error) {
 		return result, err
 	}
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return result, err
+	}
 
 	r := buildRequest("GET", apiURLBase+"me/albums", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -96,7 +116,11 @@ func GetSavedAlbums(opts *SearchOptions) (result *SavedAlbumsPaged, err error) {
 
 // GetNextSavedAlbums takes in the Next fields from the paging objects returned from Saved Albums and moves forward through the results
 func GetNextSavedAlbums(url string) (sr *SavedAlbumsPaged, err error) {
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return sr, err
+	}
 
 	r, err := http.NewRequest("GET", url, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -116,7 +140,11 @@ func SaveAlbum(AlbumID string) (err error) {
 
 	v.Add("ids", AlbumID)
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
 
 	r := buildRequest("PUT", apiURLBase+"me/albums", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -136,7 +164,11 @@ func RemoveSavedAlbum(AlbumID string) (err error) {
 
 	v.Add("ids", AlbumID)
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return err
+	}
 
 	r := buildRequest("DELETE", apiURLBase+"me/albums", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -21,7 +21,6 @@ type SearchOptions struct {
 	Offset int    `json:"offset,omitempty" url:"offset,omitempty"`
 }
 
-
 // Search queries the Spotify API based on the given query and options and returns the results wrapped in paging objects
 func Search(q, types string, opts *SearchOptions) (sr SearchResults, err error) {
 	v, err := query.Values(opts)
@@ -33,7 +32,11 @@ func Search(q, types string, opts *SearchOptions) (sr SearchResults, err error)
 	v.Add("q", q)
 	v.Add("type", types)
 
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return sr, err
+	}
 
 	r := buildRequest("GET", apiURLBase+"search", v, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -45,7 +48,11 @@ func Search(q, types string, opts *SearchOptions) (sr SearchResults, err error)
 
 // GetNextSearchResults takes in the Next fields from the paging objects returned from Search and allows you to move forward through the results
 func GetNextSearchResults(url string) (sr *SearchResults, err error) {
-	t := getAccessToken()
+	t, err := getAccessToken()
+
+	if err != nil {
+		return sr, err
+	}
 
 	r, err := http.NewRequest("GET", url, nil)
 	r.Header.Add("Authorization", "Bearer "+t)
@@ -10,4 +10,20 @@ type User struct {
 	Images       []Image           `json:"images"`
 	Type         string            `json:"type"`
 	URI          string            `json:"uri"`
-}
\ No newline at end of file
+}
+
+// GetCurrentUser returns the profile of the user that authorized Baton
+func GetCurrentUser() (u User, err error) {
+	t, err := getAccessToken()
+
+	if err != nil {
+		return u, err
+	}
+
+	r := buildRequest("GET", apiURLBase+"me", nil, nil)
+	r.Header.Add("Authorization", "Bearer "+t)
+
+	err = makeRequest(r, &u)
+
+	return u, err
+}
@@ -0,0 +1,705 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/daemon"
+	"github.com/firstlane/baton/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// methodReloadAlarms tells the daemon the alarms in the config changed
+const methodReloadAlarms = "alarm.reload"
+
+// alarmGrace is how late an alarm still rings, ex. when the computer wakes up from sleep after the time of the alarm
+const alarmGrace = 5 * time.Minute
+
+// alarmDefaultVolume is the volume an alarm fades in to when neither the alarm nor the device have one
+const alarmDefaultVolume = 50
+
+var alarmTime string
+var alarmDays string
+var alarmDevice string
+var alarmPlaylist string
+var alarmURI string
+var alarmVolume int
+var alarmFadeIn time.Duration
+var alarmEnable bool
+var alarmDisable bool
+
+// weekdayNames are the names of the days accepted by --days, in the order of time.Weekday
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// The alarm struct describes an alarm defined in the alarms section of the config
+type alarm struct {
+	Time     string `mapstructure:"time" json:"time"`
+	Days     string `mapstructure:"days" json:"days,omitempty"`
+	Device   string `mapstructure:"device" json:"device,omitempty"`
+	Playlist string `mapstructure:"playlist" json:"playlist,omitempty"`
+	URI      string `mapstructure:"uri" json:"uri,omitempty"`
+	Volume   int    `mapstructure:"volume" json:"volume,omitempty"`
+	FadeIn   string `mapstructure:"fade_in" json:"fade_in,omitempty"`
+	Disabled bool   `mapstructure:"disabled" json:"disabled,omitempty"`
+}
+
+// The alarmResult struct describes an alarm with its number and when it rings next
+type alarmResult struct {
+	Number int        `json:"number"`
+	Alarm  alarm      `json:"alarm"`
+	Next   *time.Time `json:"next,omitempty"`
+}
+
+func getAlarms() ([]alarm, error) {
+	var alarms []alarm
+	err := viper.UnmarshalKey("alarms", &alarms)
+
+	return alarms, err
+}
+
+// saveAlarms writes the alarms to the config and lets the daemon know
+func saveAlarms(alarms []alarm) error {
+	err := saveConfigValue("alarms", alarms)
+
+	if err != nil {
+		return err
+	}
+
+	if c, err := dialDaemon(); err == nil {
+		defer c.Close()
+		return c.Call(methodReloadAlarms, nil, nil)
+	}
+
+	if isTextOutput() {
+		fmt.Fprintln(os.Stderr, "Alarms only ring while the daemon is running, start it with 'baton daemon'")
+	}
+
+	return nil
+}
+
+// parseAlarmTime reads a time of day such as 7:00 or 21:30
+func parseAlarmTime(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time %q, use hours and minutes such as 07:00 or 21:30", s)
+	}
+
+	return t.Hour(), t.Minute(), nil
+}
+
+// parseAlarmDays reads days such as mon-fri or sat,sun, empty and "daily" are every day
+func parseAlarmDays(s string) ([7]bool, error) {
+	var days [7]bool
+
+	if s == "" || strings.EqualFold(s, "daily") {
+		for i := range days {
+			days[i] = true
+		}
+
+		return days, nil
+	}
+
+	day := func(name string) (int, error) {
+		for i, n := range weekdayNames {
+			if strings.EqualFold(strings.TrimSpace(name), n) {
+				return i, nil
+			}
+		}
+
+		return 0, fmt.Errorf("invalid day %q, use mon, tue, wed, thu, fri, sat or sun", name)
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		from, err := day(bounds[0])
+
+		if err != nil {
+			return days, err
+		}
+
+		to := from
+
+		if len(bounds) == 2 {
+			if to, err = day(bounds[1]); err != nil {
+				return days, err
+			}
+		}
+
+		// A range can wrap around the week, ex. fri-mon
+		for i := from; ; i = (i + 1) % 7 {
+			days[i] = true
+
+			if i == to {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+// validate checks the time, days and fade in of the alarm
+func (a alarm) validate() error {
+	if _, _, err := parseAlarmTime(a.Time); err != nil {
+		return err
+	}
+
+	if _, err := parseAlarmDays(a.Days); err != nil {
+		return err
+	}
+
+	if a.Volume < 0 || a.Volume > 100 {
+		return fmt.Errorf("invalid volume %d, use 0 to 100", a.Volume)
+	}
+
+	if a.FadeIn != "" {
+		if _, err := utils.ParseDuration(a.FadeIn); err != nil {
+			return fmt.Errorf("invalid fade in %q, ex. 5m", a.FadeIn)
+		}
+	}
+
+	return nil
+}
+
+// next returns the first time the alarm rings after the given time, false when it's disabled or invalid
+func (a alarm) next(after time.Time) (time.Time, bool) {
+	hour, minute, err := parseAlarmTime(a.Time)
+
+	if err != nil || a.Disabled {
+		return time.Time{}, false
+	}
+
+	days, err := parseAlarmDays(a.Days)
+
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	for i := 0; i <= 7; i++ {
+		t := time.Date(after.Year(), after.Month(), after.Day()+i, hour, minute, 0, 0, after.Location())
+
+		if t.After(after) && days[t.Weekday()] {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// fadeIn returns how long the volume takes to go up
+func (a alarm) fadeIn() time.Duration {
+	d, _ := utils.ParseDuration(a.FadeIn)
+	return d
+}
+
+// describe tells what the alarm plays and where
+func (a alarm) describe() string {
+	days := a.Days
+
+	if days == "" {
+		days = "daily"
+	}
+
+	s := fmt.Sprintf("%s %s", a.Time, days)
+
+	switch {
+	case a.Playlist != "":
+		s += ", plays " + a.Playlist
+	case a.URI != "":
+		s += ", plays " + a.URI
+	default:
+		s += ", resumes playback"
+	}
+
+	if a.Device != "" {
+		s += " on " + a.Device
+	}
+
+	if a.Volume > 0 {
+		s += fmt.Sprintf(" at %d%%", a.Volume)
+	}
+
+	if a.FadeIn != "" {
+		s += ", fades in over " + a.FadeIn
+	}
+
+	if a.Disabled {
+		s += " (disabled)"
+	}
+
+	return s
+}
+
+// resolveAlarmPlaylist finds the uri of a playlist, the user's own playlists come before search results
+func resolveAlarmPlaylist(name string) (api.SimplePlaylist, error) {
+	playlists, err := getAllMyPlaylists()
+
+	if err != nil {
+		return api.SimplePlaylist{}, err
+	}
+
+	for _, p := range playlists {
+		if strings.EqualFold(p.Name, name) {
+			return p, nil
+		}
+	}
+
+	res, err := api.Search(name, "playlist", &api.SearchOptions{Limit: 1})
+
+	if err != nil {
+		return api.SimplePlaylist{}, err
+	}
+
+	if res.Playlists == nil || len(res.Playlists.Items) == 0 {
+		return api.SimplePlaylist{}, errNotFound
+	}
+
+	return res.Playlists.Items[0], nil
+}
+
+// applyAlarmFlags changes the alarm according to the flags given on the command line
+func applyAlarmFlags(cmd *cobra.Command, a *alarm) error {
+	flags := cmd.Flags()
+
+	if flags.Changed("time") {
+		a.Time = alarmTime
+	}
+
+	if flags.Changed("days") {
+		a.Days = strings.ToLower(alarmDays)
+	}
+
+	if flags.Changed("device") {
+		a.Device = alarmDevice
+	}
+
+	if flags.Changed("volume") {
+		a.Volume = alarmVolume
+	}
+
+	if flags.Changed("fade-in") {
+		a.FadeIn = ""
+
+		if alarmFadeIn > 0 {
+			a.FadeIn = alarmFadeIn.String()
+		}
+	}
+
+	if flags.Changed("uri") {
+		a.URI, a.Playlist = alarmURI, ""
+	}
+
+	if flags.Changed("playlist") {
+		if alarmPlaylist == "" {
+			a.URI, a.Playlist = "", ""
+		} else {
+			p, err := resolveAlarmPlaylist(alarmPlaylist)
+
+			if err != nil {
+				return newError(err, "Couldn't find the playlist %s\n", alarmPlaylist)
+			}
+
+			a.URI, a.Playlist = p.URI, p.Name
+		}
+	}
+
+	if alarmEnable {
+		a.Disabled = false
+	}
+
+	if alarmDisable {
+		a.Disabled = true
+	}
+
+	if err := a.validate(); err != nil {
+		return newUsageError("%s\n", err)
+	}
+
+	hour, minute, _ := parseAlarmTime(a.Time)
+	a.Time = fmt.Sprintf("%02d:%02d", hour, minute)
+
+	return nil
+}
+
+// alarmNumber reads the number of an alarm as shown by `baton alarm list`
+func alarmNumber(alarms []alarm, arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+
+	if err != nil || n < 1 || n > len(alarms) {
+		return 0, newError(errNotFound, "No alarm %s, 'baton alarm list' shows their numbers\n", arg)
+	}
+
+	return n - 1, nil
+}
+
+func addAlarm(cmd *cobra.Command, args []string) error {
+	alarms, err := getAlarms()
+
+	if err != nil {
+		return newError(err, "Couldn't read the alarms section of your config\n")
+	}
+
+	a := alarm{Time: args[0]}
+
+	if err := applyAlarmFlags(cmd, &a); err != nil {
+		return err
+	}
+
+	alarms = append(alarms, a)
+
+	if err := saveAlarms(alarms); err != nil {
+		return newError(err, "Couldn't save the alarm\n")
+	}
+
+	printResult(alarmResult{Number: len(alarms), Alarm: a}, func() {
+		fmt.Printf("Added alarm %d: %s\n", len(alarms), a.describe())
+	})
+
+	return nil
+}
+
+func editAlarm(cmd *cobra.Command, args []string) error {
+	alarms, err := getAlarms()
+
+	if err != nil {
+		return newError(err, "Couldn't read the alarms section of your config\n")
+	}
+
+	i, err := alarmNumber(alarms, args[0])
+
+	if err != nil {
+		return err
+	}
+
+	if alarmEnable && alarmDisable {
+		return newUsageError("Use either --enable or --disable\n")
+	}
+
+	if err := applyAlarmFlags(cmd, &alarms[i]); err != nil {
+		return err
+	}
+
+	if err := saveAlarms(alarms); err != nil {
+		return newError(err, "Couldn't save the alarm\n")
+	}
+
+	printResult(alarmResult{Number: i + 1, Alarm: alarms[i]}, func() {
+		fmt.Printf("Changed alarm %d: %s\n", i+1, alarms[i].describe())
+	})
+
+	return nil
+}
+
+func removeAlarm(cmd *cobra.Command, args []string) error {
+	alarms, err := getAlarms()
+
+	if err != nil {
+		return newError(err, "Couldn't read the alarms section of your config\n")
+	}
+
+	i, err := alarmNumber(alarms, args[0])
+
+	if err != nil {
+		return err
+	}
+
+	removed := alarms[i]
+	alarms = append(alarms[:i], alarms[i+1:]...)
+
+	if err := saveAlarms(alarms); err != nil {
+		return newError(err, "Couldn't remove the alarm\n")
+	}
+
+	printMessage("Removed alarm %d: %s\n", i+1, removed.describe())
+
+	return nil
+}
+
+func listAlarms(cmd *cobra.Command, args []string) error {
+	alarms, err := getAlarms()
+
+	if err != nil {
+		return newError(err, "Couldn't read the alarms section of your config\n")
+	}
+
+	if len(alarms) == 0 && isTextOutput() {
+		fmt.Println("No alarms, add one with 'baton alarm add 07:00'")
+		return nil
+	}
+
+	results := make([]alarmResult, len(alarms))
+	now := time.Now()
+
+	for i, a := range alarms {
+		results[i] = alarmResult{Number: i + 1, Alarm: a}
+
+		if t, ok := a.next(now); ok {
+			results[i].Next = &t
+		}
+	}
+
+	printResult(results, func() {
+		for _, r := range results {
+			next := ""
+
+			if r.Next != nil {
+				next = r.Next.Format(" (next Mon Jan 2 15:04)")
+			}
+
+			fmt.Printf("%d. %s%s\n", r.Number, r.Alarm.describe(), next)
+		}
+	})
+
+	return nil
+}
+
+// ringAlarm moves playback to the alarm's device, starts playing and fades the volume in
+func (d *playerDaemon) ringAlarm(a alarm) error {
+	// The devices are fetched again, the alarm's device may have appeared since they were cached
+	d.mu.Lock()
+	d.devices = nil
+	d.mu.Unlock()
+
+	devices, err := d.getDevices()
+
+	if err != nil {
+		return err
+	}
+
+	var dev api.Device
+	name := a.Device
+
+	for _, candidate := range devices {
+		if candidate.IsActive && name == "" {
+			dev = candidate
+		}
+	}
+
+	// Without an active device the alarm plays on default_device from the config
+	if dev.ID == "" && name == "" {
+		name = viper.GetString("default_device")
+	}
+
+	if name != "" {
+		dev, err = resolveDevice(devices, name)
+
+		if err != nil {
+			return err
+		}
+	} else if dev.ID == "" {
+		return errors.New("no device is active")
+	}
+
+	target := a.Volume
+
+	if target == 0 {
+		target = dev.VolumePercent
+	}
+
+	if target == 0 {
+		target = alarmDefaultVolume
+	}
+
+	fadeIn := a.fadeIn()
+	start := target
+
+	if fadeIn > 0 {
+		start = 0
+	}
+
+	opts := &api.Options{DeviceID: dev.ID}
+
+	err = d.control(func() error { return api.TransferPlayback(&api.TransferOptions{DeviceIDs: []string{dev.ID}}) })
+
+	if err != nil {
+		return err
+	}
+
+	if err = d.control(func() error { return api.SetVolume(start, opts) }); err != nil {
+		return err
+	}
+
+	err = d.control(func() error { return api.StartPlayback(&api.PlayerOptions{DeviceID: dev.ID, ContextURI: a.URI}) })
+
+	if err != nil || fadeIn == 0 {
+		return err
+	}
+
+	// Pausing the player stops the fade, the alarm was heard
+	ringing := func(step time.Duration) bool {
+		time.Sleep(step)
+		ps, err := d.state()
+
+		return err != nil || ps.IsPlaying
+	}
+
+	ramp := volumeRamp{from: start, to: target, over: fadeIn}
+	_, err = ramp.run(func(v int) error { return d.control(func() error { return api.SetVolume(v, opts) }) }, ringing)
+
+	return err
+}
+
+// nextAlarms returns the next time alarms ring after the given time and the alarms that ring then
+func nextAlarms(alarms []alarm, after time.Time) (time.Time, []alarm) {
+	var next time.Time
+	var due []alarm
+
+	for _, a := range alarms {
+		t, ok := a.next(after)
+
+		switch {
+		case !ok:
+		case next.IsZero() || t.Before(next):
+			next, due = t, []alarm{a}
+		case t.Equal(next):
+			due = append(due, a)
+		}
+	}
+
+	return next, due
+}
+
+// runAlarms rings the alarms of the config, reload tells it they changed
+func (d *playerDaemon) runAlarms(reload chan bool) {
+	var next time.Time
+	var due []alarm
+
+	schedule := func(after time.Time) {
+		d.mu.Lock()
+		alarms, err := getAlarms()
+		d.mu.Unlock()
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't read the alarms: %s\n", err)
+		}
+
+		next, due = nextAlarms(alarms, after)
+	}
+
+	schedule(time.Now())
+
+	for {
+		now := time.Now()
+
+		if !next.IsZero() && !now.Before(next) {
+			// An alarm missed by more than the grace period, ex. while the computer was asleep, is skipped
+			if now.Sub(next) < alarmGrace {
+				for _, a := range due {
+					go func(a alarm) {
+						if err := d.ringAlarm(a); err != nil {
+							fmt.Fprintf(os.Stderr, "The alarm at %s failed: %s\n", a.Time, err)
+						}
+					}(a)
+				}
+			}
+
+			schedule(next)
+			continue
+		}
+
+		// The clock is checked every minute since timers don't count the time the computer spends asleep
+		wait := time.Minute
+
+		if !next.IsZero() && next.Sub(now) < wait {
+			wait = next.Sub(now)
+		}
+
+		select {
+		case <-reload:
+			schedule(time.Now())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// registerAlarms adds the alarm methods to the daemon
+func (d *playerDaemon) registerAlarms(s *daemon.Server, reload chan bool) {
+	s.Handle(methodReloadAlarms, func(params json.RawMessage) (interface{}, error) {
+		d.mu.Lock()
+		err := viper.ReadInConfig()
+		d.mu.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case reload <- true:
+		default:
+		}
+
+		return nil, nil
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(alarmCmd)
+	alarmCmd.AddCommand(alarmAddCmd)
+	alarmCmd.AddCommand(alarmListCmd)
+	alarmCmd.AddCommand(alarmEditCmd)
+	alarmCmd.AddCommand(alarmRemoveCmd)
+
+	for _, c := range []*cobra.Command{alarmAddCmd, alarmEditCmd} {
+		c.Flags().StringVar(&alarmDays, "days", "", "days the alarm rings, ex. mon-fri or sat,sun (default every day)")
+		c.Flags().StringVarP(&alarmDevice, "device", "d", "", "name, id or alias of the device to play on (default the active device, then default_device from the config)")
+		c.Flags().StringVarP(&alarmPlaylist, "playlist", "p", "", "name of the playlist to play, your own playlists are looked up first")
+		c.Flags().StringVar(&alarmURI, "uri", "", "uri of the album, artist or playlist to play (default resumes playback)")
+		c.Flags().IntVar(&alarmVolume, "volume", 0, "volume to play at, the device's volume when not set")
+		durationVar(c.Flags(), &alarmFadeIn, "fade-in", 0, "raise the volume gradually over this time (ex. 5m)")
+	}
+
+	alarmEditCmd.Flags().StringVar(&alarmTime, "time", "", "time the alarm rings, ex. 07:00")
+	alarmEditCmd.Flags().BoolVar(&alarmEnable, "enable", false, "turn the alarm back on")
+	alarmEditCmd.Flags().BoolVar(&alarmDisable, "disable", false, "turn the alarm off without removing it")
+}
+
+var alarmCmd = &cobra.Command{
+	Use:   "alarm",
+	Short: "Start playing at a time of day",
+	Long: `Start playing at a time of day, the alarms are kept in the alarms section of the config and rung by 'baton daemon'.
+
+An alarm moves playback to its device, starts its playlist (or resumes playback) and raises the volume over the fade
+in, pausing the player stops the fade.`,
+	Args: cobra.NoArgs,
+	RunE: listAlarms,
+}
+
+var alarmAddCmd = &cobra.Command{
+	Use:   "add <time>",
+	Short: "Add an alarm",
+	Long:  `Add an alarm ringing at the time (ex. 07:00), ex. baton alarm add 07:00 --days mon-fri --device Bedroom --playlist "Morning" --fade-in 5m`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  addAlarm,
+}
+
+var alarmListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the alarms and when they ring next",
+	Long:  `List the alarms and when they ring next`,
+	Args:  cobra.NoArgs,
+	RunE:  listAlarms,
+}
+
+var alarmEditCmd = &cobra.Command{
+	Use:   "edit <number>",
+	Short: "Change an alarm",
+	Long:  `Change an alarm, only the given flags change, ex. baton alarm edit 1 --time 06:45 --disable. An empty --playlist or --uri resumes playback instead.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  editAlarm,
+}
+
+var alarmRemoveCmd = &cobra.Command{
+	Use:   "remove <number>",
+	Short: "Remove an alarm",
+	Long:  `Remove an alarm, the alarms after it move up a number`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  removeAlarm,
+}
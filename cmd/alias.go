@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	runewidth "github.com/mattn/go-runewidth"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// aliasShortWidth is how many cells of the help listing an alias's commands may take
+const aliasShortWidth = 60
+
+// runningAliases holds the aliases being run so an alias that calls itself is stopped
+var runningAliases = map[string]bool{}
+
+// aliasSteps reads the commands of an alias, which is a list of command lines or a single one
+func aliasSteps(v interface{}) ([]string, bool) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, true
+	case []interface{}:
+		var steps []string
+
+		for _, s := range v {
+			str, ok := s.(string)
+
+			if !ok {
+				return nil, false
+			}
+
+			steps = append(steps, str)
+		}
+
+		return steps, len(steps) > 0
+	}
+
+	return nil, false
+}
+
+// registerAliases adds the aliases of the config as commands, an alias can't replace a baton command
+func registerAliases() {
+	aliases := viper.GetStringMap("aliases")
+	names := make([]string, 0, len(aliases))
+
+	for name := range aliases {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		steps, ok := aliasSteps(aliases[name])
+
+		switch {
+		case !ok:
+			fmt.Fprintf(os.Stderr, "The alias %s is ignored, it must be a command or a list of commands\n", name)
+		case strings.ContainsAny(name, " \t") || strings.HasPrefix(name, "-"):
+			fmt.Fprintf(os.Stderr, "The alias %q is ignored, its name can't start with - or contain spaces\n", name)
+		default:
+			if c, _, err := rootCmd.Find([]string{name}); err == nil && c != rootCmd {
+				fmt.Fprintf(os.Stderr, "The alias %s is ignored, it's the name of a baton command\n", name)
+				continue
+			}
+
+			rootCmd.AddCommand(newAliasCmd(name, steps))
+		}
+	}
+}
+
+func newAliasCmd(name string, steps []string) *cobra.Command {
+	return &cobra.Command{
+		Use:   name + " [args]",
+		Short: runewidth.Truncate("alias for: "+strings.Join(steps, "; "), aliasShortWidth, "…"),
+		Long: fmt.Sprintf(`Run the commands of the %s alias from the config:
+
+  %s
+
+$1, $2... are replaced by the arguments, $@ by all of them as separate words and $* by all of them as one word.`,
+			name, strings.Join(steps, "\n  ")),
+		// The arguments are passed to the commands, including the ones that look like flags
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAlias(name, steps, args)
+		},
+	}
+}
+
+// expandAliasArgs splits a command line of an alias and replaces the placeholders by the arguments
+func expandAliasArgs(step string, args []string) ([]string, error) {
+	words, err := splitShellWords(step)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var missing string
+	var expanded []string
+
+	for _, w := range words {
+		if w == "$@" {
+			expanded = append(expanded, args...)
+			continue
+		}
+
+		expanded = append(expanded, os.Expand(w, func(name string) string {
+			switch name {
+			case "$":
+				return "$"
+			case "*", "@":
+				return strings.Join(args, " ")
+			}
+
+			n, err := strconv.Atoi(name)
+
+			if err != nil {
+				return os.Getenv(name)
+			}
+
+			if n < 1 || n > len(args) {
+				missing = name
+				return ""
+			}
+
+			return args[n-1]
+		}))
+	}
+
+	if missing != "" {
+		return nil, newUsageError("The alias needs an argument for $%s\n", missing)
+	}
+
+	return expanded, nil
+}
+
+// runAlias runs the commands of an alias in order and stops at the first one that fails
+func runAlias(name string, steps []string, args []string) error {
+	if runningAliases[name] {
+		return newUsageError("The alias %s can't use itself\n", name)
+	}
+
+	runningAliases[name] = true
+	defer delete(runningAliases, name)
+
+	for _, step := range steps {
+		words, err := expandAliasArgs(step, args)
+
+		if err != nil {
+			return err
+		}
+
+		if len(words) == 0 {
+			continue
+		}
+
+		if code := runShellCommand(words); code != exitOK {
+			return &cmdError{msg: fmt.Sprintf("Stopped the alias %s at: %s", name, step), code: code}
+		}
+	}
+
+	return nil
+}
@@ -15,7 +15,7 @@ import (
 func getClientCredentials() (id, secret string) {
 	scanner := bufio.NewScanner(os.Stdin)
 
-	fmt.Println("\nFollow these instructions to authenticate the Baton CLI to change your tracks, volume, etc:\n" +
+	fmt.Print("\nFollow these instructions to authenticate the Baton CLI to change your tracks, volume, etc:\n" +
 		"1. Go to https://beta.developer.spotify.com/dashboard\n" +
 		"2. Log in with your Spotify username/password\n" +
 		"3. Create a new app\n" +
@@ -23,7 +23,7 @@ func getClientCredentials() (id, secret string) {
 		"5. Click 'Edit Settings'\n" +
 		"6. Add 'http://localhost:15298/callback' as a redirect URI, don't forget to save\n" +
 		"7. Copy the Client Id and Client Secret\n" +
-		"8. Input the items as the CLI asks for them\n")
+		"8. Input the items as the CLI asks for them\n\n")
 
 	fmt.Print("Enter Client Id: ")
 	scanner.Scan()
@@ -78,11 +78,18 @@ func getCode(id string) (c string) {
 	return c
 }
 
-func authenticate(cmd *cobra.Command, args []string) {
+func authenticate(cmd *cobra.Command, args []string) error {
 	id, secret := getClientCredentials()
 	code := getCode(id)
-	api.AuthorizeWithCode(id, secret, code)
+	err := api.AuthorizeWithCode(id, secret, code)
+
+	if err != nil {
+		return newError(err, "Couldn't exchange the code for tokens, check the client id, client secret and code and try again\n")
+	}
+
 	fmt.Println("\nAuthentication successful, setup complete, you should be able to run other commands now!")
+
+	return nil
 }
 
 func init() {
@@ -93,6 +100,6 @@ var authCmd = &cobra.Command{
 	Use:     "auth",
 	Short:   "Authorize Baton to access the Spotify Web API on your behalf",
 	Long:    `Authorize Baton to access the Spotify Web API on your behalf by obtaining a long-lasting refresh token using your client_id, client_secret, and approval`,
-	Run:     authenticate,
+	RunE:    authenticate,
 	Aliases: []string{"authenticate"},
 }
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/firstlane/baton/utils"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+)
+
+// bashCompletionFunction completes device names for --device and transfer, cobra calls __custom_func when the
+// command's own words don't complete. Names can hold spaces so they're split on lines and escaped.
+const bashCompletionFunction = `__baton_devices()
+{
+    local IFS=$'\n'
+    local names i
+    names=$(baton devices --names 2>/dev/null)
+    COMPREPLY=( $(compgen -W "${names}" -- "${cur}") )
+
+    for i in "${!COMPREPLY[@]}"; do
+        COMPREPLY[i]=$(printf '%q' "${COMPREPLY[i]}")
+    done
+}
+
+__custom_func()
+{
+    case ${last_command} in
+        baton_transfer)
+            __baton_devices
+            return
+            ;;
+    esac
+}
+`
+
+// markDeviceFlags has every --device flag of the command tree completed with the device names
+func markDeviceFlags(c *cobra.Command) {
+	mark := func(f *flag.Flag) {
+		if f.Name == "device" {
+			f.Annotations = map[string][]string{cobra.BashCompCustom: {"__baton_devices"}}
+		}
+	}
+
+	c.Flags().VisitAll(mark)
+	c.PersistentFlags().VisitAll(mark)
+
+	for _, sub := range c.Commands() {
+		markDeviceFlags(sub)
+	}
+}
+
+func generateCompletion(cmd *cobra.Command, args []string) error {
+	var err error
+
+	if args[0] == "zsh" {
+		err = rootCmd.GenZshCompletion(os.Stdout)
+	} else {
+		markDeviceFlags(rootCmd)
+		rootCmd.BashCompletionFunction = bashCompletionFunction
+		err = rootCmd.GenBashCompletion(os.Stdout)
+	}
+
+	if err != nil {
+		return newError(err, "Couldn't write the completion script\n")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
+
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh",
+	Short: "Print a completion script for bash or zsh",
+	Long: `Print a completion script for bash or zsh. The bash script also completes device names and aliases for
+--device and transfer.
+
+Load it in the current shell with:
+
+  source <(baton completion bash)
+
+or save it where your shell loads completions from, ex. /etc/bash_completion.d/baton or a directory of $fpath as
+_baton for zsh.`,
+	ValidArgs: []string{"bash", "zsh"},
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 || !utils.StringInSlice(args[0], []string{"bash", "zsh"}) {
+			return errors.New("Give the shell to complete: bash or zsh")
+		}
+
+		return nil
+	},
+	RunE: generateCompletion,
+}
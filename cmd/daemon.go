@@ -0,0 +1,416 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/daemon"
+	"github.com/firstlane/baton/watcher"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// daemonDevicesTTL is how long the daemon answers with the devices it already knows about
+const daemonDevicesTTL = 30 * time.Second
+
+var daemonWatch bool
+
+// The daemonStatus struct describes a running daemon
+type daemonStatus struct {
+	PID       int       `json:"pid"`
+	Socket    string    `json:"socket"`
+	StartedAt time.Time `json:"started_at"`
+	Watching  bool      `json:"watching"`
+}
+
+// The playerDaemon struct describes the state `baton daemon` keeps between calls
+type playerDaemon struct {
+	mu        api.CallLock
+	polled    polledState
+	fetchedAt time.Time
+	devices   []api.Device
+	devicesAt time.Time
+	cacheTTL  time.Duration
+	wake      chan bool
+	quit      chan bool
+	status    daemonStatus
+
+	sleepTimer daemonSleepTimer
+}
+
+// poll fetches the player state and remembers it for the state calls
+func (d *playerDaemon) poll() (polledState, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p, err := pollPlayerState()
+
+	if err != nil {
+		d.fetchedAt = time.Time{}
+		return p, err
+	}
+
+	d.polled = p
+	d.fetchedAt = time.Now()
+
+	return p, nil
+}
+
+// state answers from the last poll while it's recent, the progress is moved forward by the time that passed since
+func (d *playerDaemon) state() (api.PlayerState, error) {
+	d.mu.Lock()
+	p, fetchedAt := d.polled, d.fetchedAt
+	d.mu.Unlock()
+
+	if fetchedAt.IsZero() || time.Since(fetchedAt) >= d.cacheTTL {
+		var err error
+
+		p, err = d.poll()
+
+		if err != nil {
+			return api.PlayerState{}, err
+		}
+	}
+
+	if p.NoDevice {
+		return api.PlayerState{}, api.ErrNoActiveDevice
+	}
+
+	return p.progressAt(time.Now()), nil
+}
+
+func (d *playerDaemon) getDevices() ([]api.Device, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.devices != nil && time.Since(d.devicesAt) < daemonDevicesTTL {
+		return d.devices, nil
+	}
+
+	ds, err := api.GetDevices()
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.devices = ds
+	d.devicesAt = time.Now()
+
+	return ds, nil
+}
+
+// control makes a call that changes the player, the cached state is dropped and the poller wakes up to notice the change
+func (d *playerDaemon) control(f func() error) error {
+	d.mu.Lock()
+	err := f()
+	d.fetchedAt = time.Time{}
+	d.devices = nil
+	d.mu.Unlock()
+
+	select {
+	case d.wake <- true:
+	default:
+	}
+
+	return err
+}
+
+// handle registers a player method whose handler only needs the parameters
+func (d *playerDaemon) handle(s *daemon.Server, method string, h func(p playerParams) (interface{}, error)) {
+	s.Handle(method, func(params json.RawMessage) (interface{}, error) {
+		var p playerParams
+
+		if params != nil {
+			if err := json.Unmarshal(params, &p); err != nil {
+				return nil, daemon.InvalidParams(err)
+			}
+		}
+
+		return h(p)
+	})
+}
+
+func (d *playerDaemon) register(s *daemon.Server) {
+	d.handle(s, methodGetPlayerState, func(p playerParams) (interface{}, error) {
+		// Only the plain state is cached, options such as a market are passed on to Spotify
+		if p.Options != nil && *p.Options != (api.Options{}) {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+
+			return api.GetPlayerState(p.Options)
+		}
+
+		return d.state()
+	})
+	d.handle(s, methodGetDevices, func(p playerParams) (interface{}, error) {
+		return d.getDevices()
+	})
+	d.handle(s, methodStartPlayback, func(p playerParams) (interface{}, error) {
+		return nil, d.control(func() error { return api.StartPlayback(p.PlayerOptions) })
+	})
+	d.handle(s, methodPausePlayback, func(p playerParams) (interface{}, error) {
+		return nil, d.control(func() error { return api.PausePlayback(p.Options) })
+	})
+	d.handle(s, methodSkipToNext, func(p playerParams) (interface{}, error) {
+		return nil, d.control(func() error { return api.SkipToNext(p.Options) })
+	})
+	d.handle(s, methodSkipToPrevious, func(p playerParams) (interface{}, error) {
+		return nil, d.control(func() error { return api.SkipToPrevious(p.Options) })
+	})
+	d.handle(s, methodSeekToPosition, func(p playerParams) (interface{}, error) {
+		return nil, d.control(func() error { return api.SeekToPosition(p.PositionMs, p.Options) })
+	})
+	d.handle(s, methodSetVolume, func(p playerParams) (interface{}, error) {
+		return nil, d.control(func() error { return api.SetVolume(p.Volume, p.Options) })
+	})
+	d.handle(s, methodSetRepeatMode, func(p playerParams) (interface{}, error) {
+		return nil, d.control(func() error { return api.SetRepeatMode(p.State, p.Options) })
+	})
+	d.handle(s, methodToggleShuffle, func(p playerParams) (interface{}, error) {
+		return nil, d.control(func() error { return api.ToggleShuffle(p.Shuffle, p.Options) })
+	})
+	d.handle(s, methodTransferPlayback, func(p playerParams) (interface{}, error) {
+		if p.TransferOptions == nil {
+			return nil, daemon.InvalidParams(fmt.Errorf("transfer_options is required"))
+		}
+
+		return nil, d.control(func() error { return api.TransferPlayback(p.TransferOptions) })
+	})
+	d.handle(s, methodAddToQueue, func(p playerParams) (interface{}, error) {
+		return nil, d.control(func() error { return api.AddToQueue(p.URI, p.Options) })
+	})
+	d.handle(s, methodGetQueue, func(p playerParams) (interface{}, error) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		return api.GetQueue()
+	})
+	d.registerSleepTimer(s)
+	s.Handle("daemon.status", func(params json.RawMessage) (interface{}, error) {
+		return d.status, nil
+	})
+	s.Handle("daemon.stop", func(params json.RawMessage) (interface{}, error) {
+		// Stop a moment later so the answer reaches the client before the socket goes away
+		time.AfterFunc(100*time.Millisecond, func() {
+			select {
+			case d.quit <- true:
+			default:
+			}
+		})
+
+		return nil, nil
+	})
+}
+
+// run polls the player until the daemon is stopped, handlers get the events when watching
+func (d *playerDaemon) run(handlers *eventHandlers) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	var w watcher.Watcher
+	failures := 0
+
+	for {
+		p, err := d.poll()
+
+		if err != nil {
+			failures++
+			fmt.Fprintf(os.Stderr, "Couldn't get the player state, retrying: %s\n", err)
+		} else {
+			failures = 0
+
+			if handlers != nil {
+				for _, e := range w.Update(p.current(), time.Now()) {
+					handlers.handle(e)
+				}
+			}
+		}
+
+		select {
+		case <-interrupt:
+			return
+		case <-d.quit:
+			return
+		case <-d.wake:
+			d.mu.Settle()
+		case <-time.After(nextPollInterval(p, failures)):
+		}
+	}
+}
+
+// listenDaemonSocket listens on the control socket, a socket left behind by a daemon that crashed is replaced
+func listenDaemonSocket(path string) (net.Listener, error) {
+	if c, err := dialDaemon(); err == nil {
+		c.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", path)
+	}
+
+	if dir := filepath.Dir(path); dir == fallbackSocketDir() {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+
+		// Another user could have created the directory first to take the place of the daemon
+		if owned, err := ownedByUser(dir); err != nil || !owned {
+			return nil, fmt.Errorf("%s doesn't belong to you, remove it or set daemon.socket in the config", dir)
+		}
+	}
+
+	if owned, err := ownedByUser(path); err == nil && !owned {
+		return nil, fmt.Errorf("%s belongs to another user", path)
+	}
+
+	os.Remove(path)
+
+	// Only the user running the daemon may control it
+	return listenPrivateSocket(path)
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	// The daemon is the one talking to Spotify, it must never forward calls to itself
+	activePlayer = webAPIPlayer{}
+
+	var handlers *eventHandlers
+
+	if daemonWatch {
+		var err error
+
+		handlers, err = startEventHandlers()
+
+		if err != nil {
+			return newUsageError("%s\n", err)
+		}
+
+		defer handlers.stop()
+	}
+
+	path := daemonSocketPath()
+	l, err := listenDaemonSocket(path)
+
+	if err != nil {
+		return newError(err, "Couldn't listen on %s\n", path)
+	}
+
+	defer os.Remove(path)
+	defer l.Close()
+
+	d := &playerDaemon{
+		cacheTTL: viper.GetDuration("daemon.cache_ttl"),
+		wake:     make(chan bool, 1),
+		quit:     make(chan bool, 1),
+		status:   daemonStatus{PID: os.Getpid(), Socket: path, StartedAt: time.Now(), Watching: daemonWatch},
+	}
+
+	if d.cacheTTL <= 0 {
+		d.cacheTTL = 5 * time.Second
+	}
+
+	reload := make(chan bool, 1)
+
+	s := daemon.NewServer()
+	d.register(s)
+	d.registerAlarms(s, reload)
+	go s.Serve(l)
+	go d.runAlarms(reload)
+
+	if isTextOutput() {
+		fmt.Fprintf(os.Stderr, "Listening on %s\n", path)
+	}
+
+	d.run(handlers)
+
+	// A timer in the middle of its fade puts the volume back
+	d.sleepTimer.stop()
+
+	return nil
+}
+
+func getDaemonStatus(cmd *cobra.Command, args []string) error {
+	c, err := dialDaemon()
+
+	if err != nil {
+		return newError(errNotFound, "The daemon isn't running, start it with 'baton daemon'\n")
+	}
+
+	defer c.Close()
+
+	var status daemonStatus
+
+	err = c.Call("daemon.status", nil, &status)
+
+	if err != nil {
+		return newError(err, "Couldn't get the status of the daemon\n")
+	}
+
+	printResult(status, func() {
+		fmt.Printf("Running since %s (pid %d) on %s\n", status.StartedAt.Format("2006-01-02 15:04:05"), status.PID, status.Socket)
+	})
+
+	return nil
+}
+
+func stopDaemon(cmd *cobra.Command, args []string) error {
+	c, err := dialDaemon()
+
+	if err != nil {
+		return newError(errNotFound, "The daemon isn't running\n")
+	}
+
+	defer c.Close()
+
+	err = c.Call("daemon.stop", nil, nil)
+
+	if err != nil {
+		return newError(err, "Couldn't stop the daemon\n")
+	}
+
+	printMessage("Stopped the daemon\n")
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	daemonCmd.AddCommand(daemonStatusCmd)
+	daemonCmd.AddCommand(daemonStopCmd)
+
+	daemonCmd.Flags().BoolVarP(&daemonWatch, "watch", "w", false, "also run the hooks, history recording and scrobbling of 'baton watch'")
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run a background process that answers baton commands instantly",
+	Long: `Run a background process that keeps the token, the player state and the devices in memory and answers the player
+commands (play, pause, next, prev, seek, vol, shuffle, repeat, transfer, status, devices) on a Unix socket. Commands use
+the daemon whenever it's running, set BATON_NO_DAEMON=1 to bypass it.
+
+The socket is $XDG_RUNTIME_DIR/baton.sock (daemon.socket in the config overrides it) and speaks JSON-RPC 2.0, one
+object per line, ex. {"jsonrpc": "2.0", "id": 1, "method": "player.next"}. The player state is answered from memory
+for daemon.cache_ttl (5s by default) and refreshed right after every command.`,
+	Args: cobra.NoArgs,
+	RunE: runDaemon,
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the daemon is running",
+	Long:  `Show whether the daemon is running`,
+	Args:  cobra.NoArgs,
+	RunE:  getDaemonStatus,
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running daemon",
+	Long:  `Stop the running daemon`,
+	Args:  cobra.NoArgs,
+	RunE:  stopDaemon,
+}
@@ -0,0 +1,104 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// socketEnv points the daemon socket at a temporary directory, XDG_RUNTIME_DIR is left empty to use the fallback path
+func socketEnv(t *testing.T, runtimeDir bool) string {
+	dir, err := ioutil.TempDir("", "socket")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xdg, tmp := os.Getenv("XDG_RUNTIME_DIR"), os.Getenv("TMPDIR")
+
+	t.Cleanup(func() {
+		os.Setenv("XDG_RUNTIME_DIR", xdg)
+		os.Setenv("TMPDIR", tmp)
+		os.RemoveAll(dir)
+	})
+
+	os.Setenv("TMPDIR", dir)
+	os.Setenv("XDG_RUNTIME_DIR", "")
+
+	if runtimeDir {
+		os.Setenv("XDG_RUNTIME_DIR", dir)
+	}
+
+	return dir
+}
+
+func TestDaemonSocketIsPrivate(t *testing.T) {
+	for _, runtimeDir := range []bool{true, false} {
+		socketEnv(t, runtimeDir)
+		l, err := listenDaemonSocket(daemonSocketPath())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, path := range []string{daemonSocketPath(), filepath.Dir(daemonSocketPath())} {
+			if fi, err := os.Stat(path); err != nil || fi.Mode().Perm()&0077 != 0 {
+				t.Errorf("%s can be used by other users: %v, %v", path, fi.Mode(), err)
+			}
+		}
+
+		l.Close()
+	}
+}
+
+func TestSocketsOfOtherUsersAreRefused(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("giving files to another user needs root")
+	}
+
+	const other = 4242
+
+	// Another user created the fallback directory before the daemon first ran
+	socketEnv(t, false)
+
+	if err := os.Mkdir(fallbackSocketDir(), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chown(fallbackSocketDir(), other, other); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := listenDaemonSocket(daemonSocketPath()); err == nil || !strings.Contains(err.Error(), "doesn't belong to you") {
+		t.Errorf("listening in a directory of another user returned %v", err)
+	}
+
+	// Another user listens where the daemon should
+	dir := socketEnv(t, true)
+	l, err := net.Listen("unix", filepath.Join(dir, "baton.sock"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	if err := os.Chown(daemonSocketPath(), other, other); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, err := dialDaemon(); err == nil {
+		c.Close()
+		t.Errorf("connected to a socket of another user")
+	}
+
+	if _, err := listenDaemonSocket(daemonSocketPath()); err == nil || !strings.Contains(err.Error(), "belongs to another user") {
+		t.Errorf("listening over a socket of another user returned %v", err)
+	}
+}
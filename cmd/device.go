@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/firstlane/baton/api"
+	"github.com/spf13/viper"
+)
+
+// deviceAlias returns what an alias of device_aliases in the config stands for, a device name or id
+func deviceAlias(name string) (string, bool) {
+	target, ok := viper.GetStringMapString("device_aliases")[strings.ToLower(name)]
+	return target, ok && target != ""
+}
+
+// resolveDevice finds a device by its id, an alias from the config, its name or the start of its name, names are
+// compared ignoring case. A start of a name shared by several devices is refused.
+func resolveDevice(devices []api.Device, name string) (api.Device, error) {
+	if target, ok := deviceAlias(name); ok {
+		name = target
+	}
+
+	for _, d := range devices {
+		if d.ID == name {
+			return d, nil
+		}
+	}
+
+	for _, d := range devices {
+		if strings.EqualFold(d.Name, name) {
+			return d, nil
+		}
+	}
+
+	var matches []string
+	var found api.Device
+
+	for _, d := range devices {
+		if strings.HasPrefix(strings.ToLower(d.Name), strings.ToLower(name)) {
+			matches = append(matches, fmt.Sprintf("'%s'", d.Name))
+			found = d
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return api.Device{}, newError(errNotFound, "No device named '%s' is available, 'baton devices' lists them\n", name)
+	case 1:
+		return found, nil
+	}
+
+	return api.Device{}, newUsageError("'%s' could be any of %s, give more of the name\n", name, strings.Join(matches, ", "))
+}
+
+// deviceCompletions returns what a device can be named by when completing, the names of the devices and the device
+// aliases of the config
+func deviceCompletions(devices []api.Device) []string {
+	var names []string
+
+	for _, d := range devices {
+		names = append(names, d.Name)
+	}
+
+	for alias := range viper.GetStringMapString("device_aliases") {
+		names = append(names, alias)
+	}
+
+	return names
+}
+
+// lookupDevice resolves a device name given on the command line among the available devices
+func lookupDevice(name string) (api.Device, error) {
+	devices, err := getPlayer().GetDevices()
+
+	if err != nil {
+		return api.Device{}, newError(err, "Couldn't retrieve devices. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
+	}
+
+	return resolveDevice(devices, name)
+}
+
+// deviceID matches the shape of the ids Spotify gives devices
+var deviceID = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+// resolveDeviceFlags replaces the name or alias given to a --device flag by the id of the device. An id is passed on
+// as it is without asking Spotify for the devices, a device that's asleep can be missing from the list.
+func resolveDeviceFlags() error {
+	var devices []api.Device
+
+	for _, id := range []*string{&options.DeviceID, &playerOptions.DeviceID} {
+		if *id == "" {
+			continue
+		}
+
+		name := *id
+
+		if target, ok := deviceAlias(name); ok {
+			name = target
+		}
+
+		if deviceID.MatchString(name) {
+			*id = name
+			continue
+		}
+
+		if devices == nil {
+			var err error
+			devices, err = getPlayer().GetDevices()
+
+			if err != nil {
+				return newError(err, "Couldn't retrieve devices. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
+			}
+		}
+
+		d, err := resolveDevice(devices, name)
+
+		if err != nil {
+			return err
+		}
+
+		*id = d.ID
+	}
+
+	return nil
+}
+
+// startPlayback starts playback, when no device is active and none is targeted it's moved to default_device from
+// the config first
+func startPlayback(opts *api.PlayerOptions) error {
+	name := viper.GetString("default_device")
+
+	if opts.DeviceID != "" || name == "" {
+		return getPlayer().StartPlayback(opts)
+	}
+
+	// Spotify answers that no device is active rather than with an empty state
+	ps, err := getPlayer().GetPlayerState(&api.Options{})
+
+	if err != nil && err != api.ErrNoActiveDevice {
+		return newError(err, "Couldn't get the player state. Have you authenticated with the 'auth' command?\n")
+	}
+
+	if err == nil && ps.Device != nil {
+		return getPlayer().StartPlayback(opts)
+	}
+
+	d, err := lookupDevice(name)
+
+	if err != nil {
+		return err
+	}
+
+	err = getPlayer().TransferPlayback(&api.TransferOptions{DeviceIDs: []string{d.ID}})
+
+	if err != nil {
+		return newError(err, "Couldn't transfer playback to the default device %s '%s'\n", d.Type, d.Name)
+	}
+
+	fmt.Fprintf(os.Stderr, "No device was active, playing on the default device %s '%s'\n", d.Type, d.Name)
+
+	o := *opts
+	o.DeviceID = d.ID
+
+	return getPlayer().StartPlayback(&o)
+}
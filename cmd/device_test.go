@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/firstlane/baton/api"
+	"github.com/spf13/viper"
+)
+
+// devicesPlayer lists the devices it's given and counts how often it's asked for them
+type devicesPlayer struct {
+	playerAPI
+	devices []api.Device
+	calls   int
+}
+
+func (p *devicesPlayer) GetDevices() ([]api.Device, error) {
+	p.calls++
+	return p.devices, nil
+}
+
+func TestResolveDeviceFlags(t *testing.T) {
+	const kitchen = "0123456789abcdef0123456789abcdef01234567"
+	const asleep = "fedcba9876543210fedcba9876543210fedcba98"
+
+	viper.Set("device_aliases", map[string]string{"k": "Kitchen", "bed": asleep})
+	defer viper.Set("device_aliases", nil)
+
+	p := &devicesPlayer{devices: []api.Device{{ID: kitchen, Name: "Kitchen"}, {ID: "short", Name: "Phone"}}}
+	activePlayer = p
+	defer func() { activePlayer = nil }()
+
+	tests := []struct {
+		flag  string
+		want  string
+		calls int
+	}{
+		{"Kitchen", kitchen, 1},
+		{"k", kitchen, 1},
+		{"pho", "short", 1},
+		{kitchen, kitchen, 0},
+		{asleep, asleep, 0},
+		{"bed", asleep, 0},
+	}
+
+	for _, tt := range tests {
+		p.calls = 0
+		options.DeviceID, playerOptions.DeviceID = tt.flag, tt.flag
+
+		if err := resolveDeviceFlags(); err != nil || options.DeviceID != tt.want || playerOptions.DeviceID != tt.want || p.calls != tt.calls {
+			t.Errorf("%s: resolved to %q and %q with %d calls, %v, want %q with %d calls", tt.flag, options.DeviceID, playerOptions.DeviceID, p.calls, err, tt.want, tt.calls)
+		}
+	}
+
+	options.DeviceID, playerOptions.DeviceID = "Living room", ""
+
+	if err := resolveDeviceFlags(); exitCode(err) != exitNotFound {
+		t.Errorf("an unknown device returned %v", err)
+	}
+
+	options.DeviceID = ""
+}
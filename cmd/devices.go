@@ -2,36 +2,73 @@ package cmd
 
 import (
 	"fmt"
-	"strings"
 
-	"github.com/firstlane/baton/api"
+	runewidth "github.com/mattn/go-runewidth"
 	"github.com/spf13/cobra"
 )
 
-func reportDevices(cmd *cobra.Command, args []string) {
-	devices, err := api.GetDevices()
+// devicesNames lists only what devices can be named by, one per line, for the shell completion scripts
+var devicesNames bool
+
+func reportDevices(cmd *cobra.Command, args []string) error {
+	devices, err := getPlayer().GetDevices()
 
 	if err != nil {
-		fmt.Printf("Couldn't retrieve devices. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
-	} else if len(devices) > 0 {
-		var o []string
-		for _, d := range devices {
-			s := fmt.Sprintf("Name: %s\nType: %s\nID: %s\nActive: %v\n", d.Name, d.Type, d.ID, d.IsActive)
-			o = append(o, s)
+		return newError(err, "Couldn't retrieve devices. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
+	}
+
+	if devicesNames {
+		for _, name := range deviceCompletions(devices) {
+			fmt.Println(name)
 		}
-		fmt.Print(strings.Join(o, "\n"))
+
+		return nil
+	}
+
+	if len(devices) > 0 || !isTextOutput() {
+		printResult(devices, func() {
+			nameWidth := runewidth.StringWidth("NAME")
+
+			for _, d := range devices {
+				if w := runewidth.StringWidth(d.Name); w > nameWidth {
+					nameWidth = w
+				}
+			}
+
+			// The active device is marked with a *, restricted ones can't be controlled through the web api
+			fmt.Printf("  %s  %-11s %6s  %s\n", runewidth.FillRight("NAME", nameWidth), "TYPE", "VOLUME", "ID")
+
+			for _, d := range devices {
+				marker, note := " ", ""
+
+				if d.IsActive {
+					marker = "*"
+				}
+
+				if d.IsRestricted {
+					note = "  (restricted)"
+				}
+
+				fmt.Printf("%s %s  %-11s %5d%%  %s%s\n", marker, runewidth.FillRight(d.Name, nameWidth), d.Type, d.VolumePercent, d.ID, note)
+			}
+		})
 	} else {
 		fmt.Printf("No devices currently available\n")
 	}
+
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(devicesCmd)
+
+	devicesCmd.Flags().BoolVar(&devicesNames, "names", false, "only list the names of the devices and the device aliases")
+	devicesCmd.Flags().MarkHidden("names")
 }
 
 var devicesCmd = &cobra.Command{
 	Use:   "devices",
 	Short: "List all available playback devices",
-	Long:  `List all available playback devices`,
-	Run:   reportDevices,
+	Long:  `List all available playback devices with their type and volume, the active one is marked with a *. 'baton transfer' without a device picks one of them in the CUI.`,
+	RunE:  reportDevices,
 }
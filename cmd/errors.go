@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/firstlane/baton/api"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes returned by baton, they're documented in the help of the root command and the README
+const (
+	exitOK = iota
+	exitGeneral
+	exitUsage
+	exitAuth
+	exitNoDevice
+	exitPremium
+	exitNotFound
+	exitNetwork
+	exitRateLimited
+)
+
+// exitCodeHelp is appended to the help of the root command
+const exitCodeHelp = `Exit codes:
+  0  success
+  1  general error
+  2  invalid usage (unknown command, bad flag or argument)
+  3  not authenticated, run 'baton auth'
+  4  no active device
+  5  Spotify Premium required
+  6  not found
+  7  network error or Spotify unavailable
+  8  rate limited by Spotify`
+
+// errNotFound marks failures caused by a search or lookup that came up empty
+var errNotFound = errors.New("not found")
+
+// The cmdError struct describes a failed command, msg is the sentence shown to the user and err the underlying cause
+type cmdError struct {
+	msg  string
+	err  error
+	code int
+}
+
+func (e *cmdError) Error() string {
+	if e.err == nil {
+		return e.msg
+	}
+
+	return e.msg + ": " + e.err.Error()
+}
+
+// newError wraps the cause of a failed command with a sentence describing what the command was trying to do
+func newError(err error, format string, a ...interface{}) error {
+	return &cmdError{msg: strings.TrimSpace(fmt.Sprintf(format, a...)), err: err}
+}
+
+// newUsageError reports invalid arguments noticed while a command was already running
+func newUsageError(format string, a ...interface{}) error {
+	return &cmdError{msg: strings.TrimSpace(fmt.Sprintf(format, a...)), code: exitUsage}
+}
+
+// The usageError struct describes a command line cobra couldn't make sense of: an unknown command, a bad flag or arguments
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+// markUsageErrors makes the flags and arguments of every command in the tree report their errors as usage errors
+// It runs once the alias commands are registered, cobra itself doesn't tell its errors apart from the ones of commands
+func markUsageErrors(c *cobra.Command) {
+	if !c.HasParent() {
+		c.SetFlagErrorFunc(func(c *cobra.Command, err error) error {
+			return asUsageError(err)
+		})
+	}
+
+	if args := c.Args; args != nil {
+		c.Args = func(c *cobra.Command, a []string) error {
+			return asUsageError(args(c, a))
+		}
+	}
+
+	for _, sub := range c.Commands() {
+		markUsageErrors(sub)
+	}
+}
+
+// asUsageError marks an error about the command line, validators that already return a cmdError keep their own message
+func asUsageError(err error) error {
+	switch err.(type) {
+	case nil, *cmdError, *usageError:
+		return err
+	}
+
+	return &usageError{err: err}
+}
+
+// unknownCommand rejects arguments given to the root command, they can only be the name of a command that doesn't exist
+func unknownCommand(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("unknown command %q for %q", args[0], cmd.CommandPath())
+
+	if suggestions := cmd.SuggestionsFor(args[0]); len(suggestions) > 0 {
+		msg += "\n\nDid you mean this?\n\t" + strings.Join(suggestions, "\n\t") + "\n"
+	}
+
+	return errors.New(msg)
+}
+
+// exitCode picks the documented exit code for an error returned by a command
+func exitCode(err error) int {
+	switch e := err.(type) {
+	case *usageError:
+		return exitUsage
+	case *cmdError:
+		if e.code != 0 {
+			return e.code
+		}
+
+		return causeExitCode(e.err)
+	}
+
+	return causeExitCode(err)
+}
+
+// causeExitCode picks the exit code for the underlying cause of a failure, ex. an error returned by the api package
+func causeExitCode(err error) int {
+	switch cause := err.(type) {
+	case nil:
+		return exitGeneral
+	case *api.Error:
+		switch {
+		case cause.Status == http.StatusUnauthorized:
+			return exitAuth
+		case cause.Reason == "NO_ACTIVE_DEVICE":
+			return exitNoDevice
+		case cause.Reason == "PREMIUM_REQUIRED":
+			return exitPremium
+		case cause.Status == http.StatusNotFound:
+			return exitNotFound
+		case cause.Status == http.StatusTooManyRequests:
+			return exitRateLimited
+		case cause.Status >= 500:
+			return exitNetwork
+		}
+	case *url.Error, net.Error:
+		return exitNetwork
+	}
+
+	switch err {
+	case api.ErrNoToken:
+		return exitAuth
+	case api.ErrNoActiveDevice:
+		return exitNoDevice
+	case errNotFound:
+		return exitNotFound
+	}
+
+	return exitGeneral
+}
+
+// reportError is the single place failed commands are reported, the message always goes to stderr so stdout only carries results
+func reportError(cmd *cobra.Command, err error) int {
+	code := exitCode(err)
+
+	if e, ok := err.(*cmdError); ok {
+		m := e.msg
+
+		// Machine readable output already carries the cause in the detail field
+		if isTextOutput() && e.err != nil && e.err != errNotFound {
+			m += " (" + e.err.Error() + ")"
+		}
+
+		printError(e.err, "%s\n", m)
+
+		return code
+	}
+
+	if _, ok := err.(*usageError); ok {
+		printError(err, "%s\nRun '%s --help' for usage.\n", err, cmd.CommandPath())
+
+		return code
+	}
+
+	printError(err, "%s\n", err)
+
+	return code
+}
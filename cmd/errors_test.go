@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/firstlane/baton/api"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"plain error", errors.New("boom"), exitGeneral},
+		{"interrupted script", errScriptInterrupted, exitGeneral},
+		{"network error", &url.Error{Op: "Get", URL: "https://api.spotify.com", Err: errors.New("timeout")}, exitNetwork},
+		{"unwrapped api error", &api.Error{Status: http.StatusNotFound}, exitNotFound},
+		{"usage error", &usageError{err: errors.New("unknown flag: --bogus")}, exitUsage},
+		{"command error", newError(api.ErrNoToken, "Couldn't play\n"), exitAuth},
+		{"command usage error", newUsageError("Bad step\n"), exitUsage},
+	}
+
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCommandLineErrorsAreUsageErrors(t *testing.T) {
+	markUsageErrors(rootCmd)
+	defer rootCmd.SetArgs(nil)
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"unknown command", []string{"bogus"}},
+		{"unknown flag", []string{"vol", "mute", "--bogus"}},
+		{"bad flag value", []string{"vol", "--output"}},
+		{"extra argument", []string{"vol", "mute", "extra"}},
+	}
+
+	for _, tt := range tests {
+		resetFlags(rootCmd)
+		rootCmd.SetArgs(tt.args)
+
+		if _, err := rootCmd.ExecuteC(); exitCode(err) != exitUsage {
+			t.Errorf("%s: got %v with exit code %d", tt.name, err, exitCode(err))
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/history"
+	"github.com/firstlane/baton/utils"
+	"github.com/firstlane/baton/watcher"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var historySince string
+var historyUntil string
+var historyArtist string
+var historyContext string
+var historyLimit int
+var historyExportFormat string
+var historyExportFile string
+var historySkipped bool
+
+// dataDir is the directory next to the config file where baton keeps its own files (ex. the listening history)
+func dataDir() string {
+	return filepath.Join(filepath.Dir(viper.ConfigFileUsed()), "baton")
+}
+
+func openHistory() *history.Store {
+	return history.Open(filepath.Join(dataDir(), "history.jsonl"))
+}
+
+// historyEnabled reports whether `baton watch` should record played tracks, set with history.enabled in the config
+func historyEnabled() bool {
+	return viper.GetBool("history.enabled")
+}
+
+// syncRecentlyPlayed copies Spotify's recently played tracks into the local history
+func syncRecentlyPlayed() ([]history.Entry, error) {
+	res, err := api.GetRecentlyPlayed(&api.RecentlyPlayedOptions{Limit: 50})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []history.Entry
+
+	for k := range res.Items {
+		i := res.Items[k]
+		entries = append(entries, history.NewEntry(&i.Track, i.PlayedAt, i.Context, history.SourceRecentlyPlayed))
+	}
+
+	return openHistory().Append(entries)
+}
+
+// recordPlayedTrack adds the track that was playing before the event to the history, it only counts as played once it was listened to for 30 seconds (or half of a shorter track) like Spotify does, otherwise it's recorded as skipped
+func recordPlayedTrack(e watcher.Event) error {
+	if e.Type != watcher.TrackChanged && e.Type != watcher.Stopped {
+		return nil
+	}
+
+	prev := e.Previous
+
+	// The watcher describes how long the track was listened to, without it the play can't be told from a skip
+	if prev == nil || prev.Item == nil || prev.Item.URI == "" || e.Played == nil {
+		return nil
+	}
+
+	played := 30000
+
+	if prev.Item.DurationMs/2 < played {
+		played = prev.Item.DurationMs / 2
+	}
+
+	entry := history.NewEntry(prev.Item, e.Played.StartedAt, prev.Context, history.SourceWatch)
+	entry.ListenedMs = e.Played.ListenedMs
+	entry.Skipped = e.Played.ListenedMs < played
+
+	// Stopping playback early isn't skipping the track
+	if entry.Skipped && e.Type == watcher.Stopped {
+		return nil
+	}
+
+	_, err := openHistory().Append([]history.Entry{entry})
+
+	return err
+}
+
+func getHistoryQuery(limit int) (q history.Query, err error) {
+	now := time.Now()
+
+	if historySince != "" {
+		q.Since, err = utils.ParseTime(historySince, now)
+
+		if err != nil {
+			return q, err
+		}
+	}
+
+	if historyUntil != "" {
+		q.Until, err = utils.ParseTime(historyUntil, now)
+
+		if err != nil {
+			return q, err
+		}
+	}
+
+	q.Artist = historyArtist
+	q.Context = historyContext
+	q.Limit = limit
+	q.Skipped = historySkipped
+
+	return q, nil
+}
+
+// queryHistory loads the plays matching the query flags, limit keeps only the most recent ones when it's not 0
+func queryHistory(limit int) ([]history.Entry, error) {
+	q, err := getHistoryQuery(limit)
+
+	if err != nil {
+		return nil, newUsageError("%s\n", err)
+	}
+
+	entries, err := openHistory().Load()
+
+	if err != nil {
+		return nil, newError(err, "Couldn't read the listening history\n")
+	}
+
+	return history.Filter(entries, q), nil
+}
+
+func listHistory(cmd *cobra.Command, args []string) error {
+	entries, err := queryHistory(historyLimit)
+
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 && isTextOutput() {
+		fmt.Printf("No plays found, run 'baton history sync' or enable history in the config and run 'baton watch' to record them\n")
+		return nil
+	}
+
+	printResult(entries, func() {
+		for _, e := range entries {
+			skipped := ""
+
+			if e.Skipped {
+				skipped = " (skipped)"
+			}
+
+			fmt.Printf("%s  %s - %s%s\n", e.PlayedAt.Local().Format("2006-01-02 15:04"), strings.Join(e.Artists, ", "), e.Track, skipped)
+		}
+	})
+
+	return nil
+}
+
+func syncHistory(cmd *cobra.Command, args []string) error {
+	added, err := syncRecentlyPlayed()
+
+	if err != nil {
+		return newError(err, "Couldn't sync your recently played tracks, you may need to run 'auth' again to grant the user-read-recently-played scope\n")
+	}
+
+	printResult(added, func() {
+		fmt.Printf("Added %d plays to the listening history\n", len(added))
+	})
+
+	return nil
+}
+
+func writeHistoryCSV(w io.Writer, entries []history.Entry) error {
+	cw := csv.NewWriter(w)
+
+	cw.Write([]string{"played_at", "track", "artists", "album", "duration_ms", "track_uri", "context_uri", "context_type", "source", "listened_ms", "skipped"})
+
+	for _, e := range entries {
+		cw.Write([]string{
+			e.PlayedAt.Format(time.RFC3339),
+			e.Track,
+			strings.Join(e.Artists, "; "),
+			e.Album,
+			strconv.Itoa(e.DurationMs),
+			e.TrackURI,
+			e.ContextURI,
+			e.ContextType,
+			e.Source,
+			strconv.FormatInt(int64(e.Listened()/time.Millisecond), 10),
+			strconv.FormatBool(e.Skipped),
+		})
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func exportHistory(cmd *cobra.Command, args []string) error {
+	entries, err := queryHistory(0)
+
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+
+	if historyExportFile != "" {
+		f, err := os.Create(historyExportFile)
+
+		if err != nil {
+			return newError(err, "Couldn't create %s\n", historyExportFile)
+		}
+
+		defer f.Close()
+		w = f
+	}
+
+	switch historyExportFormat {
+	case "csv":
+		err = writeHistoryCSV(w, entries)
+	case "jsonl":
+		enc := json.NewEncoder(w)
+
+		for _, e := range entries {
+			if err = enc.Encode(e); err != nil {
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		return newError(err, "Couldn't export the listening history\n")
+	}
+
+	if historyExportFile != "" {
+		fmt.Fprintf(os.Stderr, "Exported %d plays to %s\n", len(entries), historyExportFile)
+	}
+
+	return nil
+}
+
+func addHistoryQueryFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&historySince, "since", "", "only plays from this time on (2006-01-02, 2006-01-02 15:04, today, yesterday or a duration ago like 7d)")
+	cmd.Flags().StringVar(&historyUntil, "until", "", "only plays before this time, same formats as --since")
+	cmd.Flags().StringVar(&historyArtist, "artist", "", "only plays of artists whose name or uri contains this")
+	cmd.Flags().StringVar(&historyContext, "context", "", "only plays from a context (playlist, album, artist) whose uri or type contains this")
+	cmd.Flags().BoolVar(&historySkipped, "skipped", false, "include tracks skipped within 30 seconds, recorded by 'baton watch'")
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historySyncCmd)
+	historyCmd.AddCommand(historyExportCmd)
+
+	addHistoryQueryFlags(historyCmd)
+	addHistoryQueryFlags(historyExportCmd)
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "l", 50, "show at most this many of the most recent plays, 0 shows all of them")
+	historyExportCmd.Flags().StringVarP(&historyExportFormat, "format", "f", "csv", "csv or jsonl")
+	historyExportCmd.Flags().StringVarP(&historyExportFile, "output-file", "o", "", "file to write the export to instead of stdout")
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Browse your local listening history",
+	Long: `Browse your local listening history
+
+Spotify only remembers your last 50 tracks, baton keeps every play in ~/.config/baton/history.jsonl.
+Plays are added by 'baton history sync' (run it at least every 50 tracks, ex. from cron) and by 'baton watch' when
+history is enabled in the config:
+
+  "history": {
+    "enabled": true
+  }`,
+	Args: cobra.NoArgs,
+	RunE: listHistory,
+}
+
+var historySyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Add Spotify's recently played tracks to the listening history",
+	Long:  `Add Spotify's recently played tracks to the listening history, plays that are already recorded are skipped`,
+	Args:  cobra.NoArgs,
+	RunE:  syncHistory,
+}
+
+var historyExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the listening history as CSV or JSON lines",
+	Long:  `Export the listening history as CSV or JSON lines`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if historyExportFormat != "csv" && historyExportFormat != "jsonl" {
+			return fmt.Errorf("--format must be csv or jsonl")
+		}
+		return cobra.NoArgs(cmd, args)
+	},
+	RunE: exportHistory,
+}
@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/firstlane/baton/utils"
+	"github.com/firstlane/baton/watcher"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// allEventsHook is the key in the hooks section of the config whose commands run for every event
+const allEventsHook = "all"
+
+var watchQuiet bool
+
+// getHooks reads the hooks section of the config, it maps an event type (or all) to the commands to run
+func getHooks() (map[string][]string, error) {
+	hooks := make(map[string][]string)
+	err := viper.UnmarshalKey("hooks", &hooks)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for k := range hooks {
+		if k != allEventsHook && !utils.StringInSlice(k, watcher.Types) {
+			return nil, fmt.Errorf("unknown event '%s' in the hooks section of your config, events are %s or %s", k, strings.Join(watcher.Types, ", "), allEventsHook)
+		}
+	}
+
+	return hooks, nil
+}
+
+// runHooks executes the hooks for each event in order until events is closed, done is closed once the last hook finished
+func runHooks(hooks map[string][]string, events <-chan watcher.Event, done chan<- bool) {
+	for e := range events {
+		commands := append(append([]string{}, hooks[e.Type]...), hooks[allEventsHook]...)
+
+		for _, c := range commands {
+			out, err := watcher.RunHook(c, e)
+
+			if len(out) > 0 {
+				os.Stderr.Write(out)
+			}
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Hook '%s' for %s failed: %s\n", c, e.Type, err)
+			}
+		}
+	}
+
+	close(done)
+}
+
+// describeEvent returns the sentence printed for an event by `baton watch`
+func describeEvent(e watcher.Event) string {
+	f := statusFields{}
+
+	if e.Current != nil {
+		f = newStatusFields(*e.Current)
+	}
+
+	switch e.Type {
+	case watcher.TrackChanged:
+		return fmt.Sprintf("%s - %s", f.Artist, f.Track)
+	case watcher.DeviceChanged:
+		return fmt.Sprintf("%s (%s)", f.Device, f.DeviceType)
+	case watcher.VolumeChanged:
+		return fmt.Sprintf("%d%%", f.Volume)
+	case watcher.ShuffleChanged:
+		return fmt.Sprintf("%v", f.Shuffle)
+	case watcher.RepeatChanged:
+		return f.Repeat
+	case watcher.ContextChanged:
+		return f.Context
+	case watcher.Paused, watcher.Resumed:
+		return fmt.Sprintf("%s - %s at %s", f.Artist, f.Track, f.Progress)
+	}
+
+	return ""
+}
+
+func printEvent(e watcher.Event) {
+	if outputFormat == "json" {
+		// One compact object per line so the output can be consumed as it arrives
+		b, err := json.Marshal(e)
+
+		if err == nil {
+			fmt.Printf("%s\n", b)
+		}
+
+		return
+	}
+
+	printResult(e, func() {
+		fmt.Printf("%s %s %s\n", e.Time.Format("15:04:05"), e.Type, describeEvent(e))
+	})
+}
+
+// The eventHandlers struct describes what reacts to player events besides printing them: hooks, history recording and scrobbling
+type eventHandlers struct {
+	hooks     chan watcher.Event
+	hooksDone chan bool
+	scrobbles chan watcher.Event
+	scrobbled chan bool
+}
+
+// eventQueueSize is how many events a worker can fall behind by before new events are dropped
+const eventQueueSize = 100
+
+// startEventHandlers reads the hooks and scrobbling services from the config and starts their workers, they run in the
+// background and events are dropped when a worker falls too far behind so a stuck hook never delays polling
+func startEventHandlers() (*eventHandlers, error) {
+	hooks, err := getHooks()
+
+	if err != nil {
+		return nil, err
+	}
+
+	scrobblers, err := getScrobblers()
+
+	if err != nil {
+		return nil, err
+	}
+
+	h := &eventHandlers{
+		hooks:     make(chan watcher.Event, eventQueueSize),
+		hooksDone: make(chan bool),
+	}
+
+	go runHooks(hooks, h.hooks, h.hooksDone)
+
+	if len(scrobblers) > 0 {
+		h.scrobbles = make(chan watcher.Event, eventQueueSize)
+		h.scrobbled = make(chan bool)
+		go runScrobbler(scrobblers, h.scrobbles, h.scrobbled)
+	}
+
+	return h, nil
+}
+
+func (h *eventHandlers) handle(e watcher.Event) {
+	if historyEnabled() {
+		if err := recordPlayedTrack(e); err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't record the play in the listening history: %s\n", err)
+		}
+	}
+
+	queueEvent(h.hooks, e, "hooks")
+
+	if h.scrobbles != nil {
+		queueEvent(h.scrobbles, e, "scrobbler")
+	}
+}
+
+// queueEvent hands the event to a worker without waiting for it, the event is dropped when the worker's queue is full
+func queueEvent(worker chan<- watcher.Event, e watcher.Event, name string) {
+	select {
+	case worker <- e:
+	default:
+		fmt.Fprintf(os.Stderr, "Dropped the %s event, %d earlier events are still waiting for the %s\n", e.Type, cap(worker), name)
+	}
+}
+
+// stop waits for the hooks and scrobbles of the events handled so far to finish
+func (h *eventHandlers) stop() {
+	close(h.hooks)
+	<-h.hooksDone
+
+	if h.scrobbles != nil {
+		close(h.scrobbles)
+		<-h.scrobbled
+	}
+}
+
+func watchEvents(cmd *cobra.Command, args []string) error {
+	handlers, err := startEventHandlers()
+
+	if err != nil {
+		return newUsageError("%s\n", err)
+	}
+
+	defer handlers.stop()
+
+	sleep, stop := newInterruptibleSleep()
+	defer stop()
+
+	var w watcher.Watcher
+	failures := 0
+
+	for {
+		p, err := pollPlayerState()
+
+		if err != nil {
+			if causeExitCode(err) == exitAuth {
+				return newError(err, "Couldn't get the player state. Have you authenticated with the 'auth' command?\n")
+			}
+
+			failures++
+			fmt.Fprintf(os.Stderr, "Couldn't get the player state, retrying: %s\n", err)
+		} else {
+			failures = 0
+
+			for _, e := range w.Update(p.current(), time.Now()) {
+				if !watchQuiet {
+					printEvent(e)
+				}
+
+				handlers.handle(e)
+			}
+		}
+
+		if !sleep(nextPollInterval(p, failures)) {
+			return nil
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().BoolVarP(&watchQuiet, "quiet", "q", false, "only run the hooks, don't print the events")
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Print player events and run the hooks from the config",
+	Long: `Watch the player and print an event whenever something changes, running the commands configured for the event in the hooks section of the config:
+
+  "hooks": {
+    "track_changed": ["notify-send \"$BATON_ARTIST\" \"$BATON_TRACK\""],
+    "all": ["cat >> ~/baton-events.log"]
+  }
+
+Events: ` + strings.Join(watcher.Types, ", ") + `
+
+Hooks run with the system shell. The event is written to their stdin as JSON and described by environment variables:
+BATON_EVENT, BATON_TIME, BATON_TRACK, BATON_TRACK_URI, BATON_ARTIST, BATON_ALBUM, BATON_DURATION_MS, BATON_PROGRESS_MS,
+BATON_IS_PLAYING, BATON_DEVICE, BATON_DEVICE_ID, BATON_DEVICE_TYPE, BATON_VOLUME, BATON_SHUFFLE, BATON_REPEAT, BATON_CONTEXT
+and BATON_CONTEXT_TYPE. The same variables prefixed with BATON_PREVIOUS_ describe the state before the event.`,
+	Args: cobra.NoArgs,
+	RunE: watchEvents,
+}
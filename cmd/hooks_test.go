@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/firstlane/baton/watcher"
+)
+
+func TestHandleDoesntWaitForBusyWorkers(t *testing.T) {
+	// Nothing reads the queues, like hooks and a scrobbler that are stuck
+	h := &eventHandlers{
+		hooks:     make(chan watcher.Event, 2),
+		scrobbles: make(chan watcher.Event, 2),
+	}
+
+	handled := make(chan bool)
+
+	go func() {
+		for _, typ := range []string{watcher.TrackChanged, watcher.Paused, watcher.Resumed, watcher.Stopped} {
+			h.handle(watcher.Event{Type: typ})
+		}
+
+		close(handled)
+	}()
+
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handling events waited for the workers")
+	}
+
+	for _, queue := range []chan watcher.Event{h.hooks, h.scrobbles} {
+		if len(queue) != 2 || (<-queue).Type != watcher.TrackChanged || (<-queue).Type != watcher.Paused {
+			t.Errorf("the queue should keep the first events and drop the others")
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/firstlane/baton/api"
+)
+
+// getAllSavedTracks pages through the user's library and returns every saved track
+func getAllSavedTracks() ([]api.SavedTrack, error) {
+	res, err := api.GetSavedTracks(&api.SearchOptions{Limit: 50})
+
+	if err != nil {
+		return nil, err
+	}
+
+	tracks := res.Items
+
+	for res.Next != "" {
+		res, err = api.GetNextSavedTracks(res.Next)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tracks = append(tracks, res.Items...)
+	}
+
+	return tracks, nil
+}
+
+// getAllMyPlaylists pages through and returns every playlist the user owns or follows
+func getAllMyPlaylists() ([]api.SimplePlaylist, error) {
+	res, err := api.GetMyPlaylists()
+
+	if err != nil {
+		return nil, err
+	}
+
+	playlists := res.Items
+
+	for res.Next != "" {
+		res, err = api.GetNextMyPlaylists(res.Next)
+
+		if err != nil {
+			return nil, err
+		}
+
+		playlists = append(playlists, res.Items...)
+	}
+
+	return playlists, nil
+}
+
+// getAllPlaylistTracks pages through and returns every track of the given playlist
+func getAllPlaylistTracks(p api.SimplePlaylist) ([]api.PlaylistTrack, error) {
+	var ownerID string
+
+	if p.Owner != nil {
+		ownerID = p.Owner.ID
+	}
+
+	res, err := api.GetTracksForPlaylist(ownerID, p.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tracks := res.Items
+
+	for res.Next != "" {
+		res, err = api.GetNextTracksForPlaylist(res.Next)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tracks = append(tracks, res.Items...)
+	}
+
+	return tracks, nil
+}
+
+// getAllFollowedArtists pages through and returns every artist the user follows
+func getAllFollowedArtists() ([]api.FullArtist, error) {
+	res, err := api.GetFollowedArtists()
+
+	if err != nil {
+		return nil, err
+	}
+
+	artists := res.Items
+
+	for res.Next != "" {
+		res, err = api.GetNextFollowedArtists(res.Next)
+
+		if err != nil {
+			return nil, err
+		}
+
+		artists = append(artists, res.Items...)
+	}
+
+	return artists, nil
+}
+
+// getArtistGenres looks up the genres for the given artist IDs in batches, known holds genres that have already been looked up and is filled in
+func getArtistGenres(artistIDs []string, known map[string][]string) error {
+	var missing []string
+
+	for _, id := range artistIDs {
+		if _, ok := known[id]; !ok && id != "" {
+			missing = append(missing, id)
+			known[id] = nil
+		}
+	}
+
+	for len(missing) > 0 {
+		n := len(missing)
+
+		if n > 50 {
+			n = 50
+		}
+
+		artists, err := api.GetArtists(missing[:n])
+
+		if err != nil {
+			return err
+		}
+
+		for _, a := range artists {
+			known[a.ID] = a.Genres
+		}
+
+		missing = missing[n:]
+	}
+
+	return nil
+}
+
+// findOrCreateMyPlaylist returns the playlist owned by the user with the given name, creating it when it doesn't exist yet
+func findOrCreateMyPlaylist(details *api.PlaylistDetails) (api.SimplePlaylist, error) {
+	user, err := api.GetCurrentUser()
+
+	if err != nil {
+		return api.SimplePlaylist{}, err
+	}
+
+	playlists, err := getAllMyPlaylists()
+
+	if err != nil {
+		return api.SimplePlaylist{}, err
+	}
+
+	for _, p := range playlists {
+		if p.Owner != nil && p.Owner.ID == user.ID && p.Name == details.Name {
+			return p, nil
+		}
+	}
+
+	return api.CreatePlaylist(user.ID, details)
+}
+
+// addPlaylistTracks appends any number of URIs to a playlist, working around the 100 URIs per request limit
+func addPlaylistTracks(playlistID string, uris []string) error {
+	for len(uris) > 0 {
+		n := len(uris)
+
+		if n > 100 {
+			n = 100
+		}
+
+		_, err := api.AddTracksToPlaylist(playlistID, uris[:n])
+
+		if err != nil {
+			return err
+		}
+
+		uris = uris[n:]
+	}
+
+	return nil
+}
+
+// replacePlaylistTracks replaces the tracks of a playlist with any number of URIs, working around the 100 URIs per request limit
+func replacePlaylistTracks(playlistID string, uris []string) (snapshot string, err error) {
+	n := len(uris)
+
+	if n > 100 {
+		n = 100
+	}
+
+	s, err := api.ReplacePlaylistTracks(playlistID, uris[:n])
+
+	if err != nil {
+		return "", err
+	}
+
+	return s.SnapshotID, addPlaylistTracks(playlistID, uris[n:])
+}
+
+// getPlaylistFromArg looks up the playlist referred to by a URI, URL or ID given on the command line
+func getPlaylistFromArg(s string) (api.SimplePlaylist, error) {
+	return api.GetPlaylist(parsePlaylistID(s))
+}
+
+// parsePlaylistID extracts the playlist ID from a playlist URI (spotify:playlist:ID or spotify:user:USER:playlist:ID), an open.spotify.com URL or a bare ID
+func parsePlaylistID(s string) string {
+	if u, err := url.Parse(s); err == nil && u.Host != "" {
+		s = strings.Trim(u.Path, "/")
+		s = strings.Replace(s, "/", ":", -1)
+	}
+
+	parts := strings.Split(s, ":")
+
+	for i, p := range parts {
+		if p == "playlist" && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+
+	return parts[len(parts)-1]
+}
+
+func artistNames(artists []api.SimpleArtist) string {
+	var names []string
+
+	for _, a := range artists {
+		names = append(names, a.Name)
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -1,9 +1,6 @@
 package cmd
 
 import (
-	"fmt"
-	"log"
-
 	"github.com/firstlane/baton/api"
 	"github.com/firstlane/baton/ui"
 	"github.com/spf13/cobra"
@@ -22,15 +19,17 @@ var meCmd = &cobra.Command{
 	Aliases: []string{"my"},
 }
 
-func browsePlayLists(cmd *cobra.Command, args []string) {
+func browsePlayLists(cmd *cobra.Command, args []string) error {
 
 	res, err := api.GetMyPlaylists()
 
 	if err != nil {
+		return newError(err, "Couldn't get your playlists from spotify. Have you authenticated with the 'auth' command?\n")
+	}
 
-		fmt.Printf("Couldn't get your playlists from spotify. Have you authenticated with the 'auth' command?\n")
-		fmt.Println("err", res, err)
-		return
+	if !isTextOutput() {
+		printResult(res, nil)
+		return nil
 	}
 
 	at := ui.NewPlaylistTable(res)
@@ -38,13 +37,15 @@ func browsePlayLists(cmd *cobra.Command, args []string) {
 	err = ui.Run(at)
 
 	if err != nil {
-		log.Fatal(err)
+		return newError(err, "Couldn't start the interactive browser\n")
 	}
+
+	return nil
 }
 
 var myPlaylistsCmd = &cobra.Command{
 	Use:   `playlists`,
 	Short: "Browse your playlists",
 	Long:  `Browse your playlists`,
-	Run:   browsePlayLists,
+	RunE:  browsePlayLists,
 }
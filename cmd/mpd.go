@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/mpd"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var mpdListen string
+
+// mpdBackend gives the MPD server the player and the library calls it needs
+type mpdBackend struct {
+	playerAPI
+}
+
+func (mpdBackend) Search(query string) ([]api.FullTrack, error) {
+	res, err := api.Search(query, "track", &api.SearchOptions{Limit: 50})
+
+	if err != nil || res.Tracks == nil {
+		return nil, err
+	}
+
+	return res.Tracks.Items, nil
+}
+
+func (mpdBackend) Playlists() ([]api.SimplePlaylist, error) {
+	return getAllMyPlaylists()
+}
+
+func (mpdBackend) PlaylistTracks(p api.SimplePlaylist) ([]api.FullTrack, error) {
+	items, err := getAllPlaylistTracks(p)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var tracks []api.FullTrack
+
+	for _, t := range items {
+		if !t.IsLocal {
+			tracks = append(tracks, t.Track)
+		}
+	}
+
+	return tracks, nil
+}
+
+func serveMPD(cmd *cobra.Command, args []string) error {
+	l, err := net.Listen("tcp", mpdListen)
+
+	if err != nil {
+		return newError(err, "Couldn't listen on %s\n", mpdListen)
+	}
+
+	s := mpd.NewServer(mpdBackend{getPlayer()})
+	s.Password = viper.GetString("mpd.password")
+
+	fmt.Fprintf(os.Stderr, "Serving the MPD protocol on %s\n", l.Addr())
+
+	return newError(s.Serve(l), "The server stopped\n")
+}
+
+func init() {
+	rootCmd.AddCommand(mpdCmd)
+
+	mpdCmd.Flags().StringVarP(&mpdListen, "listen", "l", "127.0.0.1:6600", "address to listen on, use 0.0.0.0:6600 to allow other machines on the network")
+}
+
+var mpdCmd = &cobra.Command{
+	Use:   "mpd",
+	Short: "Serve the MPD protocol so MPD clients can control the player",
+	Long: `Serve a subset of the Music Player Daemon protocol so MPD clients (mpc, ncmpcpp, phone apps, status bar
+widgets) can control Spotify.
+
+The MPD queue is the current track followed by Spotify's queue. Tracks can be searched for, added to the queue and
+played from playlists, but Spotify doesn't allow removing or reordering queued tracks. Set mpd.password in the config
+to require clients to send a password first.`,
+	Args: cobra.NoArgs,
+	RunE: serveMPD,
+}
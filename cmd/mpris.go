@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/firstlane/baton/dbus"
+	"github.com/firstlane/baton/mpris"
+	"github.com/spf13/cobra"
+)
+
+func serveMPRIS(cmd *cobra.Command, args []string) error {
+	addr, err := dbus.SessionBusAddress()
+
+	if err != nil {
+		return newError(err, "Couldn't find the session bus\n")
+	}
+
+	conn, err := dbus.Dial(addr)
+
+	if err != nil {
+		return newError(err, "Couldn't connect to the session bus\n")
+	}
+
+	defer conn.Close()
+
+	s := mpris.NewService(conn, getPlayer())
+
+	fmt.Fprintln(os.Stderr, "Serving org.mpris.MediaPlayer2.baton on the session bus")
+
+	return newError(s.Serve("baton"), "Lost the connection to the session bus\n")
+}
+
+func init() {
+	rootCmd.AddCommand(mprisCmd)
+}
+
+var mprisCmd = &cobra.Command{
+	Use:   "mpris",
+	Short: "Control the player from the Linux desktop through MPRIS",
+	Long: `Offer the player on the D-Bus session bus as org.mpris.MediaPlayer2.baton so media keys, desktop widgets and
+tools such as playerctl control the Spotify Connect device baton controls.
+
+The playback status, metadata, volume, shuffle, loop status and position follow Spotify and changes are signaled as
+they happen. Run it from your session's autostart.`,
+	Args: cobra.NoArgs,
+	RunE: serveMPRIS,
+}
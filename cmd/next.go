@@ -1,32 +1,30 @@
 package cmd
 
 import (
-	"fmt"
-
-	"github.com/firstlane/baton/api"
 	"github.com/spf13/cobra"
 )
 
-func skipToNext(cmd *cobra.Command, args []string) {
-	err := api.SkipToNext(&options)
+func skipToNext(cmd *cobra.Command, args []string) error {
+	err := getPlayer().SkipToNext(&options)
 
 	if err != nil {
-		fmt.Printf("Couldn't skip to the next track. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't skip to the next track. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
 	}
 
-	fmt.Printf("Skipped to next track\n")
+	printMessage("Skipped to next track\n")
+
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(skipToNextCmd)
 
-	skipToNextCmd.Flags().StringVarP(&options.DeviceID, "device", "d", "", "id of the device this command is targeting")
+	skipToNextCmd.Flags().StringVarP(&options.DeviceID, "device", "d", "", "name, id or alias of the device this command is targeting")
 }
 
 var skipToNextCmd = &cobra.Command{
 	Use:   "next",
 	Short: "Skip to next track",
 	Long:  `Skip to next track`,
-	Run:   skipToNext,
+	RunE:  skipToNext,
 }
@@ -0,0 +1,363 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/firstlane/baton/utils"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// outputFormat is set with the global --output flag, text keeps the usual human readable sentences
+var outputFormat string
+
+// The messageResult struct is emitted by commands that change the player but have no api struct to show
+type messageResult struct {
+	Message string `json:"message"`
+}
+
+// The errorResult struct is emitted on stderr when a command fails and a machine readable output was requested
+type errorResult struct {
+	Error  string `json:"error"`
+	Detail string `json:"detail,omitempty"`
+}
+
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"time": utils.MillisecondsToFormattedTime,
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func validateOutputFormat() error {
+	switch {
+	case outputFormat == "text", outputFormat == "json", outputFormat == "yaml", outputFormat == "tsv":
+		return nil
+	case strings.HasPrefix(outputFormat, "template="):
+		_, err := template.New("output").Funcs(templateFuncs).Parse(strings.TrimPrefix(outputFormat, "template="))
+		return asUsageError(err)
+	}
+
+	return asUsageError(fmt.Errorf("Unknown output format '%s', use text, json, yaml, tsv or template=<go template>", outputFormat))
+}
+
+func isTextOutput() bool {
+	return outputFormat == "" || outputFormat == "text"
+}
+
+// printResult writes v in the format chosen with --output, for text output the text function prints the usual sentences instead
+func printResult(v interface{}, text func()) {
+	if isTextOutput() {
+		text()
+		return
+	}
+
+	err := writeFormatted(os.Stdout, v)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't format output: %s\n", err)
+	}
+}
+
+// printMessage prints a sentence describing what a command did, wrapped in a messageResult for machine readable output
+func printMessage(format string, a ...interface{}) {
+	m := fmt.Sprintf(format, a...)
+
+	printResult(messageResult{Message: strings.TrimSpace(m)}, func() {
+		fmt.Print(m)
+	})
+}
+
+// printError writes a failure to stderr, as the sentence for text output and as an errorResult for other formats
+func printError(err error, format string, a ...interface{}) {
+	m := fmt.Sprintf(format, a...)
+
+	if isTextOutput() {
+		fmt.Fprint(os.Stderr, m)
+		return
+	}
+
+	e := errorResult{Error: strings.TrimSpace(m)}
+
+	if err != nil {
+		e.Detail = err.Error()
+	}
+
+	if strings.HasPrefix(outputFormat, "template=") || outputFormat == "tsv" {
+		fmt.Fprintf(os.Stderr, "%s\n", e.Error)
+		return
+	}
+
+	// The error can be about the output format itself, it's then shown as text
+	if writeFormatted(os.Stderr, e) != nil {
+		fmt.Fprint(os.Stderr, m)
+	}
+}
+
+func writeFormatted(w io.Writer, v interface{}) error {
+	switch {
+	case outputFormat == "json":
+		b, err := json.MarshalIndent(v, "", "  ")
+
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	case outputFormat == "yaml":
+		// Round trip through JSON so the keys match the json tags of the api structs
+		var generic interface{}
+
+		b, err := json.Marshal(v)
+
+		if err != nil {
+			return err
+		}
+
+		err = json.Unmarshal(b, &generic)
+
+		if err != nil {
+			return err
+		}
+
+		b, err = yaml.Marshal(generic)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(b)
+		return err
+	case outputFormat == "tsv":
+		return writeTSV(w, v)
+	case strings.HasPrefix(outputFormat, "template="):
+		return writeTemplate(w, strings.TrimPrefix(outputFormat, "template="), v)
+	}
+
+	return validateOutputFormat()
+}
+
+// writeTemplate executes the template against v, slices execute the template once per element
+func writeTemplate(w io.Writer, text string, v interface{}) error {
+	t, err := template.New("output").Funcs(templateFuncs).Parse(text)
+
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	items := []interface{}{v}
+
+	if rv.Kind() == reflect.Slice {
+		items = nil
+		for i := 0; i < rv.Len(); i++ {
+			items = append(items, rv.Index(i).Interface())
+		}
+	}
+
+	for _, item := range items {
+		var sb strings.Builder
+
+		err = t.Execute(&sb, item)
+
+		if err != nil {
+			return err
+		}
+
+		s := sb.String()
+
+		if !strings.HasSuffix(s, "\n") {
+			s += "\n"
+		}
+
+		_, err = io.WriteString(w, s)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeTSV writes a header row and one row per element (or a single row for a struct), nested structs are flattened into dotted columns
+func writeTSV(w io.Writer, v interface{}) error {
+	rv := reflect.ValueOf(v)
+
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	rows := []reflect.Value{rv}
+	t := rv.Type()
+
+	if rv.Kind() == reflect.Slice {
+		rows = nil
+		t = t.Elem()
+		for i := 0; i < rv.Len(); i++ {
+			rows = append(rows, rv.Index(i))
+		}
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.Map {
+		return writeTSVMap(w, rv)
+	}
+
+	if t.Kind() != reflect.Struct {
+		for _, r := range rows {
+			fmt.Fprintf(w, "%s\n", tsvCell(r))
+		}
+		return nil
+	}
+
+	var header []string
+	tsvFlatten(reflect.Zero(t), "", 0, &header, nil)
+	fmt.Fprintf(w, "%s\n", strings.Join(header, "\t"))
+
+	for _, r := range rows {
+		var cells []string
+		tsvFlatten(r, "", 0, nil, &cells)
+		_, err := fmt.Fprintf(w, "%s\n", strings.Join(cells, "\t"))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeTSVMap(w io.Writer, rv reflect.Value) error {
+	var keys []string
+	values := make(map[string]reflect.Value)
+
+	for _, k := range rv.MapKeys() {
+		keys = append(keys, fmt.Sprint(k.Interface()))
+		values[fmt.Sprint(k.Interface())] = rv.MapIndex(k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		_, err := fmt.Fprintf(w, "%s\t%s\n", k, tsvCell(values[k]))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func tsvFlatten(v reflect.Value, prefix string, depth int, header, cells *[]string) {
+	t := v.Type()
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+
+		if v.IsNil() {
+			v = reflect.Zero(t)
+		} else {
+			v = v.Elem()
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+
+		if name == "-" || f.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		fv := v.Field(i)
+		ft := f.Type
+
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if ft.Kind() == reflect.Struct && ft != reflect.TypeOf(time.Time{}) {
+			if depth < 2 {
+				tsvFlatten(fv, prefix+name+".", depth+1, header, cells)
+			}
+			continue
+		}
+
+		if ft.Kind() == reflect.Map {
+			continue
+		}
+
+		if header != nil {
+			*header = append(*header, prefix+name)
+		}
+
+		if cells != nil {
+			*cells = append(*cells, tsvCell(fv))
+		}
+	}
+}
+
+// tsvCell formats a single value, slices of structs are shown by their names (ex. the artists of a track)
+func tsvCell(v reflect.Value) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	var s string
+
+	switch v.Kind() {
+	case reflect.Slice:
+		var parts []string
+
+		for i := 0; i < v.Len(); i++ {
+			e := v.Index(i)
+
+			if e.Kind() == reflect.Struct {
+				if n := e.FieldByName("Name"); n.IsValid() {
+					parts = append(parts, n.String())
+				}
+				continue
+			}
+
+			parts = append(parts, tsvCell(e))
+		}
+
+		s = strings.Join(parts, ",")
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			s = t.Format(time.RFC3339)
+		} else {
+			b, _ := json.Marshal(v.Interface())
+			s = string(b)
+		}
+	default:
+		s = fmt.Sprint(v.Interface())
+	}
+
+	return strings.NewReplacer("\t", " ", "\n", " ").Replace(s)
+}
@@ -7,42 +7,52 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func pausePlayer(cmd *cobra.Command, args []string) {
-	ctx, err := api.GetPlayerState(&options)
+// The pauseResult struct describes whether the player is playing after toggling pause
+type pauseResult struct {
+	IsPlaying bool `json:"is_playing"`
+}
+
+func pausePlayer(cmd *cobra.Command, args []string) error {
+	ctx, err := getPlayer().GetPlayerState(&options)
 
 	if err != nil {
-		fmt.Printf("Couldn't get pause information from the spotify player. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't get pause information from the spotify player. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
 	}
 
 	if ctx.IsPlaying {
-		err = api.PausePlayback(&options)
+		err = getPlayer().PausePlayback(&options)
 
 		if err != nil {
-			fmt.Printf("Failed to pause\n")
-		} else {
-			fmt.Printf("Spotify has been paused\n")
+			return newError(err, "Failed to pause\n")
 		}
+
+		printResult(pauseResult{IsPlaying: false}, func() {
+			fmt.Printf("Spotify has been paused\n")
+		})
 	} else {
-		err = api.StartPlayback(&playerOptions)
+		err = startPlayback(&api.PlayerOptions{DeviceID: options.DeviceID})
 
 		if err != nil {
-			fmt.Printf("Failed to unpause\n")
-		} else {
-			fmt.Printf("Spotify has been unpaused\n")
+			return newError(err, "Failed to unpause\n")
 		}
+
+		printResult(pauseResult{IsPlaying: true}, func() {
+			fmt.Printf("Spotify has been unpaused\n")
+		})
 	}
+
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(pauseCmd)
 
-	pauseCmd.Flags().StringVarP(&options.DeviceID, "device", "d", "", "id of the device this command is targeting")
+	pauseCmd.Flags().StringVarP(&options.DeviceID, "device", "d", "", "name, id or alias of the device this command is targeting")
 }
 
 var pauseCmd = &cobra.Command{
 	Use:   "pause",
 	Short: "Toggle spotify pause state",
 	Long:  `Toggle spotify pause state`,
-	Run:   pausePlayer,
+	RunE:  pausePlayer,
 }
@@ -8,74 +8,74 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func playURI(cmd *cobra.Command, args []string) {
+func playURI(cmd *cobra.Command, args []string) error {
 	if len(args) > 0 {
 		playerOptions.ContextURI = args[0]
-		err := api.StartPlayback(&playerOptions)
+		err := startPlayback(&playerOptions)
 
 		if err != nil {
-			fmt.Printf("Couldn't start playback. Is that URI proper? Is Spotify active on a device? Have you authenticated with the 'auth' command?\n")
-		} else {
-			fmt.Printf("Playing uri: %s\n", args[0])
+			return newError(err, "Couldn't start playback. Is that URI proper? Is Spotify active on a device? Have you authenticated with the 'auth' command?\n")
 		}
+
+		printMessage("Playing uri: %s\n", args[0])
 	} else {
-		err := api.StartPlayback(&playerOptions)
+		err := startPlayback(&playerOptions)
 
 		if err != nil {
-			fmt.Printf("Couldn't start playback. Is Spotify already playing? Is Spotify active on a device? Have you authenticated with the 'auth' command?\n")
-		} else {
-			fmt.Printf("Resuming playback\n")
+			return newError(err, "Couldn't start playback. Is Spotify already playing? Is Spotify active on a device? Have you authenticated with the 'auth' command?\n")
 		}
+
+		printMessage("Resuming playback\n")
 	}
+
+	return nil
 }
 
-func playArtist(cmd *cobra.Command, args []string) {
+func playArtist(cmd *cobra.Command, args []string) error {
 	searchQuery := strings.Join(args, " ")
 	res, err := api.Search(searchQuery, "artist", &searchOptions)
 
 	if err != nil {
-		fmt.Printf("Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
 	}
 
 	if res.Artists == nil || len(res.Artists.Items) == 0 {
-		fmt.Printf("No artists found matching search query: %s\n", searchQuery)
-		return
+		return newError(errNotFound, "No artists found matching search query: %s\n", searchQuery)
 	}
 
 	playerOptions.ContextURI = res.Artists.Items[0].URI
 
-	err = api.StartPlayback(&playerOptions)
+	err = startPlayback(&playerOptions)
 
 	if err != nil {
-		fmt.Printf("Couldn't play search result.  Attempted to play top songs for artist: %s\n", res.Artists.Items[0].Name)
-		return
+		return newError(err, "Couldn't play search result.  Attempted to play top songs for artist: %s\n", res.Artists.Items[0].Name)
 	}
 
-	fmt.Printf("Playing top songs for artist: %s\n", res.Artists.Items[0].Name)
+	printResult(res.Artists.Items[0], func() {
+		fmt.Printf("Playing top songs for artist: %s\n", res.Artists.Items[0].Name)
+	})
+
+	return nil
 }
 
-func playAlbum(cmd *cobra.Command, args []string) {
+func playAlbum(cmd *cobra.Command, args []string) error {
 	searchQuery := strings.Join(args, " ")
 	res, err := api.Search(searchQuery, "album", &searchOptions)
 
 	if err != nil {
-		fmt.Printf("Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
 	}
 
 	if res.Albums == nil || len(res.Albums.Items) == 0 {
-		fmt.Printf("No albums found matching search query: %s\n", searchQuery)
-		return
+		return newError(errNotFound, "No albums found matching search query: %s\n", searchQuery)
 	}
 
 	playerOptions.ContextURI = res.Albums.Items[0].URI
 
-	err = api.StartPlayback(&playerOptions)
+	err = startPlayback(&playerOptions)
 
 	if err != nil {
-		fmt.Printf("Couldn't start playback for top matching album: %s\n", res.Albums.Items[0].Name)
-		return
+		return newError(err, "Couldn't start playback for top matching album: %s\n", res.Albums.Items[0].Name)
 	}
 
 	var artistNames []string
@@ -84,47 +84,50 @@ func playAlbum(cmd *cobra.Command, args []string) {
 		artistNames = append(artistNames, artist.Name)
 	}
 
-	fmt.Printf("Playing: %s by %s\n", res.Albums.Items[0].Name, strings.Join(artistNames, ", "))
+	printResult(res.Albums.Items[0], func() {
+		fmt.Printf("Playing: %s by %s\n", res.Albums.Items[0].Name, strings.Join(artistNames, ", "))
+	})
+
+	return nil
 }
 
-func playPlaylist(cmd *cobra.Command, args []string) {
+func playPlaylist(cmd *cobra.Command, args []string) error {
 	searchQuery := strings.Join(args, " ")
 	res, err := api.Search(searchQuery, "playlist", &searchOptions)
 
 	if err != nil {
-		fmt.Printf("Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
 	}
 
 	if res.Playlists == nil || len(res.Playlists.Items) == 0 {
-		fmt.Printf("No playlists found matching search query: %s\n", searchQuery)
-		return
+		return newError(errNotFound, "No playlists found matching search query: %s\n", searchQuery)
 	}
 
 	playerOptions.ContextURI = res.Playlists.Items[0].URI
 
-	err = api.StartPlayback(&playerOptions)
+	err = startPlayback(&playerOptions)
 
 	if err != nil {
-		fmt.Printf("Couldn't start playback for top matching playlist: %s\n", res.Playlists.Items[0].Name)
-		return
+		return newError(err, "Couldn't start playback for top matching playlist: %s\n", res.Playlists.Items[0].Name)
 	}
 
-	fmt.Printf("Playing playlist: %s by user %s\n", res.Playlists.Items[0].Name, res.Playlists.Items[0].Owner.DisplayName)
+	printResult(res.Playlists.Items[0], func() {
+		fmt.Printf("Playing playlist: %s by user %s\n", res.Playlists.Items[0].Name, res.Playlists.Items[0].Owner.DisplayName)
+	})
+
+	return nil
 }
 
-func playTrack(cmd *cobra.Command, args []string) {
+func playTrack(cmd *cobra.Command, args []string) error {
 	searchQuery := strings.Join(args, " ")
 	res, err := api.Search(searchQuery, "track", &searchOptions)
 
 	if err != nil {
-		fmt.Printf("Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
 	}
 
 	if res.Tracks == nil || len(res.Tracks.Items) == 0 {
-		fmt.Printf("No tracks found matching search query: %s\n", searchQuery)
-		return
+		return newError(errNotFound, "No tracks found matching search query: %s\n", searchQuery)
 	}
 
 	track := res.Tracks.Items[0]
@@ -132,15 +135,14 @@ func playTrack(cmd *cobra.Command, args []string) {
 	if track.Album != nil {
 		playerOptions.ContextURI = track.Album.URI
 		playerOptions.Offset = &api.PlayerOffsetOptions{URI: track.URI}
-		err = api.StartPlayback(&playerOptions)
+		err = startPlayback(&playerOptions)
 	} else {
 		playerOptions.ContextURI = track.URI
-		err = api.StartPlayback(&playerOptions)
+		err = startPlayback(&playerOptions)
 	}
 
 	if err != nil {
-		fmt.Printf("Couldn't start playback for top matching track/album: %s - %s\n", res.Tracks.Items[0].Name, res.Tracks.Items[0].Album.Name)
-		return
+		return newError(err, "Couldn't start playback for top matching track/album: %s - %s\n", res.Tracks.Items[0].Name, res.Tracks.Items[0].Album.Name)
 	}
 
 	var artistNames []string
@@ -149,28 +151,34 @@ func playTrack(cmd *cobra.Command, args []string) {
 		artistNames = append(artistNames, artist.Name)
 	}
 
-	fmt.Printf("Playing '%s' by %s from album %s\n", res.Tracks.Items[0].Name, strings.Join(artistNames, ", "), res.Tracks.Items[0].Album.Name)
+	printResult(res.Tracks.Items[0], func() {
+		fmt.Printf("Playing '%s' by %s from album %s\n", res.Tracks.Items[0].Name, strings.Join(artistNames, ", "), res.Tracks.Items[0].Album.Name)
+	})
+
+	return nil
 }
 
-func playMultiple(cmd *cobra.Command, args []string) {
+func playMultiple(cmd *cobra.Command, args []string) error {
 	if len(args) > 0 {
 		playerOptions.URIs = args
-		err := api.StartPlayback(&playerOptions)
+		err := startPlayback(&playerOptions)
 
 		if err != nil {
-			fmt.Printf("Couldn't start playback. Is that URI proper? Is Spotify active on a device? Have you authenticated with the 'auth' command?\n")
-		} else {
-			fmt.Printf("Playing uri: %s\n", args[0])
+			return newError(err, "Couldn't start playback. Is that URI proper? Is Spotify active on a device? Have you authenticated with the 'auth' command?\n")
 		}
+
+		printMessage("Playing uri: %s\n", args[0])
 	} else {
-		err := api.StartPlayback(&playerOptions)
+		err := startPlayback(&playerOptions)
 
 		if err != nil {
-			fmt.Printf("Couldn't start playback. Is Spotify already playing? Is Spotify active on a device? Have you authenticated with the 'auth' command?\n")
-		} else {
-			fmt.Printf("Resuming playback\n")
+			return newError(err, "Couldn't start playback. Is Spotify already playing? Is Spotify active on a device? Have you authenticated with the 'auth' command?\n")
 		}
+
+		printMessage("Resuming playback\n")
 	}
+
+	return nil
 }
 
 func init() {
@@ -181,7 +189,7 @@ func init() {
 	playCmd.AddCommand(playTrackCmd)
 	playCmd.AddCommand(playMultipleCmd)
 
-	playCmd.PersistentFlags().StringVarP(&playerOptions.DeviceID, "device", "d", "", "id of the device this command is targeting")
+	playCmd.PersistentFlags().StringVarP(&playerOptions.DeviceID, "device", "d", "", "name, id or alias of the device this command is targeting")
 }
 
 var playCmd = &cobra.Command{
@@ -189,7 +197,7 @@ var playCmd = &cobra.Command{
 	Short: "Play top result for specified artist, album, playlist, track, or uri",
 	Long:  `Play top result for specified artist, album, playlist, track, or uri`,
 	Args:  cobra.MaximumNArgs(1),
-	Run:   playURI,
+	RunE:  playURI,
 }
 
 var playArtistCmd = &cobra.Command{
@@ -197,7 +205,7 @@ var playArtistCmd = &cobra.Command{
 	Short: "Play top result for specified artist",
 	Long:  `Play top result for specified artist`,
 	Args:  cobra.MinimumNArgs(1),
-	Run:   playArtist,
+	RunE:  playArtist,
 }
 
 var playAlbumCmd = &cobra.Command{
@@ -205,7 +213,7 @@ var playAlbumCmd = &cobra.Command{
 	Short: "Play top result for specified album",
 	Long:  `Play top result for specified album`,
 	Args:  cobra.MinimumNArgs(1),
-	Run:   playAlbum,
+	RunE:  playAlbum,
 }
 
 var playPlaylistCmd = &cobra.Command{
@@ -213,7 +221,7 @@ var playPlaylistCmd = &cobra.Command{
 	Short: "Play top result for specified playlist",
 	Long:  `Play top result for specified playlist`,
 	Args:  cobra.MinimumNArgs(1),
-	Run:   playPlaylist,
+	RunE:  playPlaylist,
 }
 
 var playTrackCmd = &cobra.Command{
@@ -221,7 +229,7 @@ var playTrackCmd = &cobra.Command{
 	Short: "Play top result for specified track",
 	Long:  `Play top result for specified track`,
 	Args:  cobra.MinimumNArgs(1),
-	Run:   playTrack,
+	RunE:  playTrack,
 }
 
 var playMultipleCmd = &cobra.Command{
@@ -229,5 +237,5 @@ var playMultipleCmd = &cobra.Command{
 	Short: "Play each item in the list of uris",
 	Long:  `Play each item in the list of uris`,
 	Args:  cobra.MaximumNArgs(10),
-	Run:   playMultiple,
+	RunE:  playMultiple,
 }
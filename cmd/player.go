@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/daemon"
+	"github.com/spf13/viper"
+)
+
+// The playerAPI interface describes the playback controls, commands use it instead of the api package so they can be answered by a running daemon
+type playerAPI interface {
+	GetPlayerState(opts *api.Options) (api.PlayerState, error)
+	GetDevices() ([]api.Device, error)
+	StartPlayback(opts *api.PlayerOptions) error
+	PausePlayback(opts *api.Options) error
+	SkipToNext(opts *api.Options) error
+	SkipToPrevious(opts *api.Options) error
+	SeekToPosition(pos int, opts *api.Options) error
+	SetVolume(vol int, opts *api.Options) error
+	SetRepeatMode(state string, opts *api.Options) error
+	ToggleShuffle(state bool, opts *api.Options) error
+	TransferPlayback(opts *api.TransferOptions) error
+	AddToQueue(uri string, opts *api.Options) error
+	GetQueue() (api.Queue, error)
+}
+
+// webAPIPlayer makes the calls to the Spotify Web API itself
+type webAPIPlayer struct{}
+
+func (webAPIPlayer) GetPlayerState(opts *api.Options) (api.PlayerState, error) {
+	return api.GetPlayerState(opts)
+}
+
+func (webAPIPlayer) GetDevices() ([]api.Device, error) {
+	return api.GetDevices()
+}
+
+func (webAPIPlayer) StartPlayback(opts *api.PlayerOptions) error {
+	return api.StartPlayback(opts)
+}
+
+func (webAPIPlayer) PausePlayback(opts *api.Options) error {
+	return api.PausePlayback(opts)
+}
+
+func (webAPIPlayer) SkipToNext(opts *api.Options) error {
+	return api.SkipToNext(opts)
+}
+
+func (webAPIPlayer) SkipToPrevious(opts *api.Options) error {
+	return api.SkipToPrevious(opts)
+}
+
+func (webAPIPlayer) SeekToPosition(pos int, opts *api.Options) error {
+	return api.SeekToPosition(pos, opts)
+}
+
+func (webAPIPlayer) SetVolume(vol int, opts *api.Options) error {
+	return api.SetVolume(vol, opts)
+}
+
+func (webAPIPlayer) SetRepeatMode(state string, opts *api.Options) error {
+	return api.SetRepeatMode(state, opts)
+}
+
+func (webAPIPlayer) ToggleShuffle(state bool, opts *api.Options) error {
+	return api.ToggleShuffle(state, opts)
+}
+
+func (webAPIPlayer) TransferPlayback(opts *api.TransferOptions) error {
+	return api.TransferPlayback(opts)
+}
+
+func (webAPIPlayer) AddToQueue(uri string, opts *api.Options) error {
+	return api.AddToQueue(uri, opts)
+}
+
+func (webAPIPlayer) GetQueue() (api.Queue, error) {
+	return api.GetQueue()
+}
+
+// Methods of the daemon's player API
+const (
+	methodGetPlayerState   = "player.state"
+	methodGetDevices       = "player.devices"
+	methodStartPlayback    = "player.play"
+	methodPausePlayback    = "player.pause"
+	methodSkipToNext       = "player.next"
+	methodSkipToPrevious   = "player.previous"
+	methodSeekToPosition   = "player.seek"
+	methodSetVolume        = "player.volume"
+	methodSetRepeatMode    = "player.repeat"
+	methodToggleShuffle    = "player.shuffle"
+	methodTransferPlayback = "player.transfer"
+	methodAddToQueue       = "player.queue.add"
+	methodGetQueue         = "player.queue"
+)
+
+// The playerParams struct describes the parameters of the daemon's player methods, each method only uses the fields it needs
+type playerParams struct {
+	Options         *api.Options         `json:"options,omitempty"`
+	PlayerOptions   *api.PlayerOptions   `json:"player_options,omitempty"`
+	TransferOptions *api.TransferOptions `json:"transfer_options,omitempty"`
+	PositionMs      int                  `json:"position_ms,omitempty"`
+	Volume          int                  `json:"volume,omitempty"`
+	State           string               `json:"state,omitempty"`
+	Shuffle         bool                 `json:"shuffle,omitempty"`
+	URI             string               `json:"uri,omitempty"`
+}
+
+// daemonPlayer forwards the calls to `baton daemon`
+type daemonPlayer struct {
+	client *daemon.Client
+}
+
+func (d daemonPlayer) GetPlayerState(opts *api.Options) (ps api.PlayerState, err error) {
+	err = d.client.Call(methodGetPlayerState, playerParams{Options: opts}, &ps)
+	return ps, err
+}
+
+func (d daemonPlayer) GetDevices() (ds []api.Device, err error) {
+	err = d.client.Call(methodGetDevices, nil, &ds)
+	return ds, err
+}
+
+func (d daemonPlayer) StartPlayback(opts *api.PlayerOptions) error {
+	return d.client.Call(methodStartPlayback, playerParams{PlayerOptions: opts}, nil)
+}
+
+func (d daemonPlayer) PausePlayback(opts *api.Options) error {
+	return d.client.Call(methodPausePlayback, playerParams{Options: opts}, nil)
+}
+
+func (d daemonPlayer) SkipToNext(opts *api.Options) error {
+	return d.client.Call(methodSkipToNext, playerParams{Options: opts}, nil)
+}
+
+func (d daemonPlayer) SkipToPrevious(opts *api.Options) error {
+	return d.client.Call(methodSkipToPrevious, playerParams{Options: opts}, nil)
+}
+
+func (d daemonPlayer) SeekToPosition(pos int, opts *api.Options) error {
+	return d.client.Call(methodSeekToPosition, playerParams{PositionMs: pos, Options: opts}, nil)
+}
+
+func (d daemonPlayer) SetVolume(vol int, opts *api.Options) error {
+	return d.client.Call(methodSetVolume, playerParams{Volume: vol, Options: opts}, nil)
+}
+
+func (d daemonPlayer) SetRepeatMode(state string, opts *api.Options) error {
+	return d.client.Call(methodSetRepeatMode, playerParams{State: state, Options: opts}, nil)
+}
+
+func (d daemonPlayer) ToggleShuffle(state bool, opts *api.Options) error {
+	return d.client.Call(methodToggleShuffle, playerParams{Shuffle: state, Options: opts}, nil)
+}
+
+func (d daemonPlayer) TransferPlayback(opts *api.TransferOptions) error {
+	return d.client.Call(methodTransferPlayback, playerParams{TransferOptions: opts}, nil)
+}
+
+func (d daemonPlayer) AddToQueue(uri string, opts *api.Options) error {
+	return d.client.Call(methodAddToQueue, playerParams{URI: uri, Options: opts}, nil)
+}
+
+func (d daemonPlayer) GetQueue() (q api.Queue, err error) {
+	err = d.client.Call(methodGetQueue, nil, &q)
+	return q, err
+}
+
+var activePlayer playerAPI
+
+// daemonSocketPath is where `baton daemon` listens, daemon.socket in the config overrides it
+func daemonSocketPath() string {
+	if p := viper.GetString("daemon.socket"); p != "" {
+		return p
+	}
+
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "baton.sock")
+	}
+
+	return filepath.Join(fallbackSocketDir(), "baton.sock")
+}
+
+// fallbackSocketDir holds the socket without XDG_RUNTIME_DIR, other users can create files in the temporary directory so
+// the socket goes in a directory of its own that only the user may use
+func fallbackSocketDir() string {
+	return filepath.Join(os.TempDir(), "baton-"+strconv.Itoa(os.Getuid()))
+}
+
+// dialDaemon connects to the running daemon, it fails quickly when there's none. A socket of another user is never
+// used, it could be someone pretending to be the daemon.
+func dialDaemon() (*daemon.Client, error) {
+	path := daemonSocketPath()
+	owned, err := ownedByUser(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !owned {
+		return nil, fmt.Errorf("%s belongs to another user", path)
+	}
+
+	return daemon.Dial(path, 200*time.Millisecond)
+}
+
+// getPlayer returns the daemon when one is running and the Web API otherwise, BATON_NO_DAEMON=1 always uses the Web API
+func getPlayer() playerAPI {
+	if activePlayer != nil {
+		return activePlayer
+	}
+
+	activePlayer = webAPIPlayer{}
+
+	if os.Getenv("BATON_NO_DAEMON") == "" {
+		if c, err := dialDaemon(); err == nil {
+			activePlayer = daemonPlayer{client: c}
+		}
+	}
+
+	return activePlayer
+}
@@ -0,0 +1,392 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/utils"
+	"github.com/spf13/cobra"
+)
+
+var mergeInto string
+var splitBy string
+var sortBy string
+var sortReverse bool
+
+// playlistSortKeys maps the keys accepted by `playlist sort --by` to a function comparing two playlist tracks
+var playlistSortKeys = map[string]func(a, b *api.PlaylistTrack) int{
+	"artist": func(a, b *api.PlaylistTrack) int {
+		return strings.Compare(strings.ToLower(artistNames(a.Track.Artists)), strings.ToLower(artistNames(b.Track.Artists)))
+	},
+	"album": func(a, b *api.PlaylistTrack) int {
+		return strings.Compare(strings.ToLower(albumName(a.Track.Album)), strings.ToLower(albumName(b.Track.Album)))
+	},
+	"name": func(a, b *api.PlaylistTrack) int {
+		return strings.Compare(strings.ToLower(a.Track.Name), strings.ToLower(b.Track.Name))
+	},
+	"track_number": func(a, b *api.PlaylistTrack) int {
+		if a.Track.DiscNumber != b.Track.DiscNumber {
+			return a.Track.DiscNumber - b.Track.DiscNumber
+		}
+		return a.Track.TrackNumber - b.Track.TrackNumber
+	},
+	"added_at": func(a, b *api.PlaylistTrack) int {
+		switch {
+		case a.AddedAt == nil || b.AddedAt == nil:
+			return 0
+		case a.AddedAt.Before(*b.AddedAt):
+			return -1
+		case a.AddedAt.After(*b.AddedAt):
+			return 1
+		}
+		return 0
+	},
+	"duration": func(a, b *api.PlaylistTrack) int {
+		return a.Track.DurationMs - b.Track.DurationMs
+	},
+	"popularity": func(a, b *api.PlaylistTrack) int {
+		return a.Track.Popularity - b.Track.Popularity
+	},
+}
+
+// playlistSplitKeys maps the keys accepted by `playlist split --by` to a function returning the group of a playlist track
+var playlistSplitKeys = map[string]func(t *api.PlaylistTrack) string{
+	"artist": func(t *api.PlaylistTrack) string {
+		if len(t.Track.Artists) == 0 {
+			return ""
+		}
+		return t.Track.Artists[0].Name
+	},
+	"album": func(t *api.PlaylistTrack) string {
+		return albumName(t.Track.Album)
+	},
+	"year": func(t *api.PlaylistTrack) string {
+		if t.Track.Album == nil || len(t.Track.Album.ReleaseDate) < 4 {
+			return ""
+		}
+		return t.Track.Album.ReleaseDate[:4]
+	},
+	"added-month": func(t *api.PlaylistTrack) string {
+		if t.AddedAt == nil {
+			return ""
+		}
+		return t.AddedAt.Format("2006-01")
+	},
+}
+
+// The playlistResult struct describes a playlist changed by one of the playlist commands
+type playlistResult struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Tracks     int    `json:"tracks"`
+	SnapshotID string `json:"snapshot_id,omitempty"`
+}
+
+func albumName(a *api.SimpleAlbum) string {
+	if a == nil {
+		return ""
+	}
+
+	return a.Name
+}
+
+func isPlaylistArg(s string) bool {
+	return strings.HasPrefix(s, "spotify:") || strings.Contains(s, "open.spotify.com/")
+}
+
+func mergePlaylists(cmd *cobra.Command, args []string) error {
+	var dest api.SimplePlaylist
+	var err error
+
+	// The destination can be an existing playlist or the name of a playlist to create
+	if isPlaylistArg(mergeInto) {
+		dest, err = getPlaylistFromArg(mergeInto)
+	} else {
+		dest, err = findOrCreateMyPlaylist(&api.PlaylistDetails{Name: mergeInto})
+	}
+
+	if err != nil {
+		return newError(err, "Couldn't find or create the destination playlist '%s'\n", mergeInto)
+	}
+
+	seen := make(map[string]bool)
+	existing, err := getAllPlaylistTracks(dest)
+
+	if err != nil {
+		return newError(err, "Couldn't get the tracks of the destination playlist '%s'\n", dest.Name)
+	}
+
+	for _, t := range existing {
+		seen[t.Track.URI] = true
+	}
+
+	var uris []string
+
+	for _, arg := range args {
+		src, err := getPlaylistFromArg(arg)
+
+		if err != nil {
+			return newError(err, "Couldn't find the playlist '%s'\n", arg)
+		}
+
+		tracks, err := getAllPlaylistTracks(src)
+
+		if err != nil {
+			return newError(err, "Couldn't get the tracks of the playlist '%s'\n", src.Name)
+		}
+
+		for _, t := range tracks {
+			if t.IsLocal || t.Track.URI == "" || seen[t.Track.URI] {
+				continue
+			}
+
+			seen[t.Track.URI] = true
+			uris = append(uris, t.Track.URI)
+		}
+	}
+
+	err = addPlaylistTracks(dest.ID, uris)
+
+	if err != nil {
+		return newError(err, "Couldn't add tracks to the playlist '%s'\n", dest.Name)
+	}
+
+	printResult(playlistResult{ID: dest.ID, Name: dest.Name, Tracks: len(uris)}, func() {
+		fmt.Printf("Added %d tracks to the playlist '%s'\n", len(uris), dest.Name)
+	})
+
+	return nil
+}
+
+func splitPlaylist(cmd *cobra.Command, args []string) error {
+	key := playlistSplitKeys[splitBy]
+
+	src, err := getPlaylistFromArg(args[0])
+
+	if err != nil {
+		return newError(err, "Couldn't find the playlist '%s'\n", args[0])
+	}
+
+	tracks, err := getAllPlaylistTracks(src)
+
+	if err != nil {
+		return newError(err, "Couldn't get the tracks of the playlist '%s'\n", src.Name)
+	}
+
+	groups := make(map[string][]string)
+
+	for k := range tracks {
+		if tracks[k].IsLocal || tracks[k].Track.URI == "" {
+			continue
+		}
+
+		g := key(&tracks[k])
+
+		if g == "" {
+			g = "Unknown"
+		}
+
+		if !utils.StringInSlice(tracks[k].Track.URI, groups[g]) {
+			groups[g] = append(groups[g], tracks[k].Track.URI)
+		}
+	}
+
+	var names []string
+	var results []playlistResult
+	for g := range groups {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+
+	for _, g := range names {
+		name := fmt.Sprintf("%s - %s", src.Name, g)
+		p, err := findOrCreateMyPlaylist(&api.PlaylistDetails{Name: name})
+
+		if err != nil {
+			return newError(err, "Couldn't create the playlist '%s'\n", name)
+		}
+
+		snapshot, err := replacePlaylistTracks(p.ID, groups[g])
+
+		if err != nil {
+			return newError(err, "Couldn't add tracks to the playlist '%s'\n", name)
+		}
+
+		results = append(results, playlistResult{ID: p.ID, Name: name, Tracks: len(groups[g]), SnapshotID: snapshot})
+	}
+
+	printResult(results, func() {
+		for _, r := range results {
+			fmt.Printf("Created '%s' with %d tracks\n", r.Name, r.Tracks)
+		}
+	})
+
+	return nil
+}
+
+// planPlaylistMoves returns the range moves needed to turn the current order of positions into target, grouping runs that are already in order into a single move
+func planPlaylistMoves(target []int) []api.ReorderOptions {
+	var moves []api.ReorderOptions
+
+	current := make([]int, len(target))
+	for k := range current {
+		current[k] = k
+	}
+
+	for i := 0; i < len(target); i++ {
+		j := i
+		for current[j] != target[i] {
+			j++
+		}
+
+		if j == i {
+			continue
+		}
+
+		l := 1
+		for j+l < len(current) && i+l < len(target) && current[j+l] == target[i+l] {
+			l++
+		}
+
+		moves = append(moves, api.ReorderOptions{RangeStart: j, InsertBefore: i, RangeLength: l})
+
+		moved := append([]int{}, current[j:j+l]...)
+		current = append(current[:j], current[j+l:]...)
+		current = append(current[:i], append(moved, current[i:]...)...)
+
+		i += l - 1
+	}
+
+	return moves
+}
+
+func sortPlaylist(cmd *cobra.Command, args []string) error {
+	var keys []func(a, b *api.PlaylistTrack) int
+
+	for _, k := range strings.Split(sortBy, ",") {
+		keys = append(keys, playlistSortKeys[strings.TrimSpace(k)])
+	}
+
+	p, err := getPlaylistFromArg(args[0])
+
+	if err != nil {
+		return newError(err, "Couldn't find the playlist '%s'\n", args[0])
+	}
+
+	tracks, err := getAllPlaylistTracks(p)
+
+	if err != nil {
+		return newError(err, "Couldn't get the tracks of the playlist '%s'\n", p.Name)
+	}
+
+	target := make([]int, len(tracks))
+	for k := range target {
+		target[k] = k
+	}
+
+	sort.SliceStable(target, func(i, j int) bool {
+		for _, key := range keys {
+			c := key(&tracks[target[i]], &tracks[target[j]])
+
+			if sortReverse {
+				c = -c
+			}
+
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	moves := planPlaylistMoves(target)
+	snapshot := p.SnapshotID
+
+	for _, m := range moves {
+		m.SnapshotID = snapshot
+		s, err := api.ReorderPlaylistTracks(p.ID, &m)
+
+		if err != nil {
+			return newError(err, "Couldn't reorder the playlist '%s'\n", p.Name)
+		}
+
+		snapshot = s.SnapshotID
+	}
+
+	printResult(playlistResult{ID: p.ID, Name: p.Name, Tracks: len(tracks), SnapshotID: snapshot}, func() {
+		fmt.Printf("Sorted the playlist '%s' by %s using %d moves\n", p.Name, sortBy, len(moves))
+	})
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(playlistCmd)
+	playlistCmd.AddCommand(playlistMergeCmd)
+	playlistCmd.AddCommand(playlistSplitCmd)
+	playlistCmd.AddCommand(playlistSortCmd)
+
+	playlistMergeCmd.Flags().StringVar(&mergeInto, "into", "", "uri of the destination playlist or the name of one of your playlists (created if missing)")
+	playlistSplitCmd.Flags().StringVar(&splitBy, "by", "artist", "group tracks by artist, album, year or added-month")
+	playlistSortCmd.Flags().StringVar(&sortBy, "by", "artist,album,track_number", "comma separated list of artist, album, name, track_number, added_at, duration or popularity")
+	playlistSortCmd.Flags().BoolVarP(&sortReverse, "reverse", "r", false, "sort in descending order")
+}
+
+var playlistCmd = &cobra.Command{
+	Use:   "playlist",
+	Short: "Merge, split and sort playlists",
+	Long:  `Merge, split and sort playlists`,
+}
+
+var playlistMergeCmd = &cobra.Command{
+	Use:   "merge [uri...] --into [uri|name]",
+	Short: "Add the tracks of several playlists to another without duplicates",
+	Long:  `Add the tracks of several playlists to another without duplicates`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return errors.New("Specify at least one playlist to merge")
+		}
+		if mergeInto == "" {
+			return errors.New("Specify the destination playlist with --into")
+		}
+		return nil
+	},
+	RunE: mergePlaylists,
+}
+
+var playlistSplitCmd = &cobra.Command{
+	Use:   "split [uri]",
+	Short: "Split a playlist into one playlist per artist, album, year or month added",
+	Long:  `Split a playlist into one playlist per artist, album, year or month added`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Specify the playlist to split")
+		}
+		if _, ok := playlistSplitKeys[splitBy]; !ok {
+			return errors.New("--by must be artist, album, year or added-month")
+		}
+		return nil
+	},
+	RunE: splitPlaylist,
+}
+
+var playlistSortCmd = &cobra.Command{
+	Use:   "sort [uri]",
+	Short: "Reorder the tracks of a playlist",
+	Long:  `Reorder the tracks of a playlist in place, keeping the date each track was added`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Specify the playlist to sort")
+		}
+		for _, k := range strings.Split(sortBy, ",") {
+			if _, ok := playlistSortKeys[strings.TrimSpace(k)]; !ok {
+				return fmt.Errorf("Unknown sort key '%s'", k)
+			}
+		}
+		return nil
+	},
+	RunE: sortPlaylist,
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+
+	"github.com/firstlane/baton/api"
+	"github.com/spf13/cobra"
+)
+
+// maxCoverImageSize is the largest base64 encoded cover image Spotify accepts
+const maxCoverImageSize = 256 * 1024
+
+var coverOutput string
+
+// downscaleImage shrinks the image by the given factor, averaging the source pixels that fall into each destination pixel
+func downscaleImage(src image.Image, factor float64) image.Image {
+	b := src.Bounds()
+	w := int(float64(b.Dx()) * factor)
+	h := int(float64(b.Dy()) * factor)
+
+	if w < 1 {
+		w = 1
+	}
+
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := b.Min.Y + (y+1)*b.Dy()/h
+
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := b.Min.X + (x+1)*b.Dx()/w
+
+			var r, g, bl, n uint32
+
+			for sy := y0; sy < y1 || sy == y0; sy++ {
+				for sx := x0; sx < x1 || sx == x0; sx++ {
+					cr, cg, cb, _ := src.At(sx, sy).RGBA()
+					r += cr
+					g += cg
+					bl += cb
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: 0xffff})
+		}
+	}
+
+	return dst
+}
+
+// fitCoverImage returns JPEG data small enough to upload as a cover, re-encoding and downscaling the image when needed
+func fitCoverImage(data []byte) ([]byte, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+
+	if err != nil || format != "jpeg" {
+		return nil, errors.New("cover images must be JPEG files")
+	}
+
+	if base64.StdEncoding.EncodedLen(len(data)) <= maxCoverImageSize {
+		return data, nil
+	}
+
+	for i := 0; i < 10; i++ {
+		var buf bytes.Buffer
+
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if base64.StdEncoding.EncodedLen(buf.Len()) <= maxCoverImageSize {
+			return buf.Bytes(), nil
+		}
+
+		img = downscaleImage(img, 0.75)
+	}
+
+	return nil, errors.New("couldn't shrink the image below Spotify's 256KB limit")
+}
+
+func getPlaylistCover(cmd *cobra.Command, args []string) error {
+	p, err := getPlaylistFromArg(args[0])
+
+	if err != nil {
+		return newError(err, "Couldn't find the playlist '%s'\n", args[0])
+	}
+
+	images, err := api.GetPlaylistCoverImages(p.ID)
+
+	if err != nil {
+		return newError(err, "Couldn't get the cover images of the playlist '%s'\n", p.Name)
+	}
+
+	if len(images) == 0 {
+		return newError(errNotFound, "Couldn't find a cover image for the playlist '%s'\n", p.Name)
+	}
+
+	if coverOutput == "" {
+		printResult(images, func() {
+			for _, i := range images {
+				if i.Width > 0 {
+					fmt.Printf("%s (%dx%d)\n", i.URL, i.Width, i.Height)
+				} else {
+					fmt.Printf("%s\n", i.URL)
+				}
+			}
+		})
+		return nil
+	}
+
+	data, err := api.DownloadImage(images[0])
+
+	if err != nil {
+		return newError(err, "Couldn't download the cover image\n")
+	}
+
+	err = ioutil.WriteFile(coverOutput, data, 0644)
+
+	if err != nil {
+		return newError(err, "Couldn't write the cover image\n")
+	}
+
+	printMessage("Saved the cover of '%s' to %s\n", p.Name, coverOutput)
+
+	return nil
+}
+
+func setPlaylistCover(cmd *cobra.Command, args []string) error {
+	data, err := ioutil.ReadFile(args[1])
+
+	if err != nil {
+		return newError(err, "Couldn't read the image\n")
+	}
+
+	data, err = fitCoverImage(data)
+
+	if err != nil {
+		return newError(err, "Couldn't use '%s' as a cover image\n", args[1])
+	}
+
+	p, err := getPlaylistFromArg(args[0])
+
+	if err != nil {
+		return newError(err, "Couldn't find the playlist '%s'\n", args[0])
+	}
+
+	err = api.UploadPlaylistCoverImage(p.ID, data)
+
+	if err != nil {
+		return newError(err, "Couldn't upload the cover image, you may need to run 'auth' again to grant the ugc-image-upload scope\n")
+	}
+
+	printMessage("Updated the cover of '%s'\n", p.Name)
+
+	return nil
+}
+
+func init() {
+	playlistCmd.AddCommand(playlistCoverCmd)
+	playlistCoverCmd.AddCommand(playlistCoverGetCmd)
+	playlistCoverCmd.AddCommand(playlistCoverSetCmd)
+
+	playlistCoverGetCmd.Flags().StringVarP(&coverOutput, "output-file", "o", "", "file to save the cover image to, prints the image urls when empty")
+}
+
+var playlistCoverCmd = &cobra.Command{
+	Use:   "cover",
+	Short: "Download or upload playlist cover images",
+	Long:  `Download or upload playlist cover images`,
+}
+
+var playlistCoverGetCmd = &cobra.Command{
+	Use:   "get [uri]",
+	Short: "Show or download the cover image of a playlist",
+	Long:  `Show or download the cover image of a playlist`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  getPlaylistCover,
+}
+
+var playlistCoverSetCmd = &cobra.Command{
+	Use:   "set [uri] [image.jpg]",
+	Short: "Upload a JPEG image as the cover of a playlist",
+	Long:  `Upload a JPEG image as the cover of a playlist, images over Spotify's 256KB limit are downscaled`,
+	Args:  cobra.ExactArgs(2),
+	RunE:  setPlaylistCover,
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/firstlane/baton/api"
+)
+
+// reorder applies a move the way Spotify does, insert_before is a position in the playlist before the range is taken out
+func reorder(list []int, m api.ReorderOptions) ([]int, error) {
+	if m.RangeLength < 1 || m.RangeStart < 0 || m.RangeStart+m.RangeLength > len(list) || m.InsertBefore < 0 || m.InsertBefore > len(list) {
+		return nil, fmt.Errorf("move %+v is out of a playlist of %d tracks", m, len(list))
+	}
+
+	moved := append([]int{}, list[m.RangeStart:m.RangeStart+m.RangeLength]...)
+	rest := append(append([]int{}, list[:m.RangeStart]...), list[m.RangeStart+m.RangeLength:]...)
+	at := m.InsertBefore
+
+	if at > m.RangeStart {
+		at -= m.RangeLength
+	}
+
+	return append(append(rest[:at:at], moved...), rest[at:]...), nil
+}
+
+func TestPlanPlaylistMoves(t *testing.T) {
+	tests := []struct {
+		name   string
+		target []int
+		moves  int
+	}{
+		{"empty", []int{}, 0},
+		{"one track", []int{0}, 0},
+		{"sorted", []int{0, 1, 2, 3}, 0},
+		{"swap", []int{1, 0}, 1},
+		{"last to first", []int{4, 0, 1, 2, 3}, 1},
+		{"first to last", []int{1, 2, 3, 4, 0}, 1},
+		{"halves swapped", []int{3, 4, 5, 0, 1, 2}, 1},
+		{"reversed", []int{4, 3, 2, 1, 0}, 4},
+		{"two runs", []int{2, 3, 0, 1, 5, 4}, 2},
+	}
+
+	for _, tt := range tests {
+		moves := planPlaylistMoves(tt.target)
+
+		if len(moves) != tt.moves {
+			t.Errorf("%s: planned %d moves, want %d", tt.name, len(moves), tt.moves)
+		}
+
+		checkMoves(t, tt.name, tt.target, moves)
+	}
+
+	// Random orders of every size up to a few pages of tracks
+	r := rand.New(rand.NewSource(1))
+
+	for n := 0; n < 250; n += 7 {
+		target := r.Perm(n)
+		checkMoves(t, fmt.Sprintf("random order of %d", n), target, planPlaylistMoves(target))
+	}
+}
+
+// checkMoves applies the moves to the playlist in its current order and checks the result is the target order
+func checkMoves(t *testing.T, name string, target []int, moves []api.ReorderOptions) {
+	list := make([]int, len(target))
+
+	for k := range list {
+		list[k] = k
+	}
+
+	var err error
+
+	for _, m := range moves {
+		if list, err = reorder(list, m); err != nil {
+			t.Errorf("%s: %v", name, err)
+			return
+		}
+	}
+
+	if fmt.Sprint(list) != fmt.Sprint(target) {
+		t.Errorf("%s: the moves give %v, want %v", name, list, target)
+	}
+}
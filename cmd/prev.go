@@ -1,33 +1,31 @@
 package cmd
 
 import (
-	"fmt"
-
-	"github.com/firstlane/baton/api"
 	"github.com/spf13/cobra"
 )
 
-func skipToPrev(cmd *cobra.Command, args []string) {
-	err := api.SkipToPrevious(&options)
+func skipToPrev(cmd *cobra.Command, args []string) error {
+	err := getPlayer().SkipToPrevious(&options)
 
 	if err != nil {
-		fmt.Printf("Couldn't skip to previous track. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't skip to previous track. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
 	}
 
-	fmt.Printf("Skipped to previous track\n")
+	printMessage("Skipped to previous track\n")
+
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(skipToPrevCmd)
 
-	skipToPrevCmd.Flags().StringVarP(&options.DeviceID, "device", "d", "", "id of the device this command is targeting")
+	skipToPrevCmd.Flags().StringVarP(&options.DeviceID, "device", "d", "", "name, id or alias of the device this command is targeting")
 }
 
 var skipToPrevCmd = &cobra.Command{
 	Use:     "prev",
 	Short:   "Skip to previous track",
 	Long:    `Skip to previous track`,
-	Run:     skipToPrev,
+	RunE:    skipToPrev,
 	Aliases: []string{"previous"},
 }
@@ -1,30 +1,29 @@
 package cmd
 
 import (
-	"fmt"
-
 	"github.com/firstlane/baton/api"
 	"github.com/spf13/cobra"
 )
 
-func removeTrack(cmd *cobra.Command, args []string) {
-	ctx, err := api.GetPlayerState(nil)
+func removeTrack(cmd *cobra.Command, args []string) error {
+	ctx, err := getPlayer().GetPlayerState(nil)
 
 	if err != nil {
-		fmt.Printf("Couldn't get the player state. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't get the player state. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
 	}
 
 	if ctx.Item == nil {
-		fmt.Printf("Couldn't find information about the current status\n")
-		return
+		return newError(errNotFound, "Couldn't find information about the current status\n")
 	}
 
 	err = api.RemoveSavedTrack(ctx.Item.ID)
 	if err != nil {
-		fmt.Printf("Couldn't remove the track from saved. %s\n", err)
-		return
+		return newError(err, "Couldn't remove the track from saved\n")
 	}
+
+	printResult(ctx.Item, func() {})
+
+	return nil
 }
 
 func init() {
@@ -35,5 +34,5 @@ var removeTrackCmd = &cobra.Command{
 	Use:   "remove",
 	Short: "Remove current playing track from saved",
 	Long:  `Remove current playing track from saved`,
-	Run:   removeTrack,
+	RunE:  removeTrack,
 }
@@ -4,46 +4,64 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/firstlane/baton/api"
 	"github.com/firstlane/baton/utils"
 	"github.com/spf13/cobra"
 )
 
-func setRepeatMode(cmd *cobra.Command, args []string) {
+// The repeatResult struct describes the repeat mode of the player
+type repeatResult struct {
+	RepeatState string `json:"repeat_state"`
+}
+
+func setRepeatMode(cmd *cobra.Command, args []string) error {
+	// on repeats the album or playlist, like the repeat button does first
+	if len(args) > 0 && args[0] == "on" {
+		args[0] = "context"
+	}
+
 	if len(args) > 0 {
-		err := api.SetRepeatMode(args[0], &options)
+		err := getPlayer().SetRepeatMode(args[0], &options)
 
 		if err != nil {
-			fmt.Printf("Couldn't set repeat mode. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
-		} else {
-			fmt.Printf("Repeat mode set to %s\n", args[0])
+			return newError(err, "Couldn't set repeat mode. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
 		}
+
+		printResult(repeatResult{RepeatState: args[0]}, func() {
+			fmt.Printf("Repeat mode set to %s\n", args[0])
+		})
 	} else {
-		ctx, err := api.GetPlayerState(&options)
+		ctx, err := getPlayer().GetPlayerState(&options)
 
 		if err != nil {
-			fmt.Printf("Couldn't get information about the spotify player. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
-		} else {
-			fmt.Printf("Repeat mode is currently set to %s\n", ctx.RepeatState)
+			return newError(err, "Couldn't get information about the spotify player. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
 		}
+
+		printResult(repeatResult{RepeatState: ctx.RepeatState}, func() {
+			fmt.Printf("Repeat mode is currently set to %s\n", ctx.RepeatState)
+		})
 	}
+
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(repeatCmd)
 
-	repeatCmd.Flags().StringVarP(&options.DeviceID, "device", "d", "", "id of the device this command is targeting")
+	repeatCmd.Flags().StringVarP(&options.DeviceID, "device", "d", "", "name, id or alias of the device this command is targeting")
 }
 
 var repeatCmd = &cobra.Command{
-	Use:   "repeat [track|context|off]",
+	Use:   "repeat [track|context|on|off]",
 	Short: "Get/Set repeat mode",
-	Long:  `Get/Set repeat mode`,
+	Long:  `Get/Set repeat mode, on is the same as context and repeats the album or playlist`,
 	Args: func(cmd *cobra.Command, args []string) error {
-		if len(args) > 0 && !utils.StringInSlice(args[0], []string{"track", "context", "off"}) {
-			return errors.New("Mode must be track, context, or off")
+		if err := cobra.MaximumNArgs(1)(cmd, args); err != nil {
+			return err
+		}
+		if len(args) > 0 && !utils.StringInSlice(args[0], []string{"track", "context", "on", "off"}) {
+			return errors.New("Mode must be track, context, on, or off")
 		}
 		return nil
 	},
-	Run: setRepeatMode,
+	RunE: setRepeatMode,
 }
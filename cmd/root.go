@@ -3,12 +3,14 @@ package cmd
 import (
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
+	"time"
 
 	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/utils"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -19,25 +21,46 @@ var searchOptions api.SearchOptions
 var rootCmd = &cobra.Command{
 	Use:   "baton",
 	Short: "A CLI tool to orchestrate your Spotify",
-	Long:  `A CLI tool to orchestrate your Spotify`,
+	Long:  "A CLI tool to orchestrate your Spotify\n\n" + exitCodeHelp,
+	// Running the root command lets cobra validate its arguments, which are unknown commands, instead of showing the help
+	Args:                       unknownCommand,
+	SuggestionsMinimumDistance: 2,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateOutputFormat(); err != nil {
+			return err
+		}
+
+		return resolveDeviceFlags()
+	},
+	// Failures are reported by Execute so every command prints them the same way
+	SilenceErrors: true,
+	SilenceUsage:  true,
 }
 
 // Execute is the entrypoint for the CLI called from the main function
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	// Aliases are commands, so the config is read before cobra looks for the command to run
+	initConfig()
+	registerAliases()
+	markUsageErrors(rootCmd)
+
+	if cmd, err := rootCmd.ExecuteC(); err != nil {
+		os.Exit(reportError(cmd, err))
 	}
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "output format: text, json, yaml, tsv or template=<go template> (ex. template='{{.Item.Name}}')")
 }
 
 func initConfig() {
 	home, err := homedir.Dir()
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitGeneral)
 	}
 
 	viper.AddConfigPath(home + "/.config")
@@ -47,13 +70,56 @@ func initConfig() {
 	if _, err := os.Stat(cfgFile); os.IsNotExist(err) {
 		err := ioutil.WriteFile(cfgFile, []byte("{}"), 0666)
 		if err != nil {
-			log.Fatal(err)
+			fmt.Fprintf(os.Stderr, "Couldn't create the config file %s: %s\n", cfgFile, err)
+			os.Exit(exitGeneral)
 		}
 	}
 
 	err = viper.ReadInConfig()
 
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintf(os.Stderr, "Couldn't read the config file %s: %s\n", cfgFile, err)
+		os.Exit(exitGeneral)
+	}
+}
+
+// saveConfigValue persists a single setting at the dot separated key to the config file and reloads the config
+func saveConfigValue(key string, value interface{}) error {
+	err := utils.SetJSONValue(viper.ConfigFileUsed(), key, value)
+
+	if err != nil {
+		return err
 	}
+
+	return viper.ReadInConfig()
+}
+
+// durationValue is a flag holding a duration read by utils.ParseDuration, flags then take the same durations as
+// arguments (ex. 90, 1:30 or 1m30s)
+type durationValue time.Duration
+
+func (d *durationValue) Set(s string) error {
+	v, err := utils.ParseDuration(s)
+
+	if err != nil {
+		return err
+	}
+
+	*d = durationValue(v)
+
+	return nil
+}
+
+func (d *durationValue) String() string {
+	return time.Duration(*d).String()
+}
+
+func (d *durationValue) Type() string {
+	return "duration"
+}
+
+// durationVar defines a duration flag read by utils.ParseDuration, it's used in place of the DurationVar of pflag
+func durationVar(fs *flag.FlagSet, p *time.Duration, name string, value time.Duration, usage string) {
+	*p = value
+	fs.Var((*durationValue)(p), name, usage)
 }
@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/firstlane/baton/utils"
+	"github.com/spf13/cobra"
+)
+
+// scriptVariableName matches the names `set` accepts
+var scriptVariableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// The scriptLine struct describes a line of a script with its words before variables are expanded
+type scriptLine struct {
+	number int
+	words  []string
+}
+
+// The scriptRunner struct describes a script being run
+type scriptRunner struct {
+	vars        map[string]string
+	stopOnError bool
+	// interrupt receives Ctrl-C, which stops the script during a wait or once the running command is over
+	interrupt chan os.Signal
+}
+
+// parseScript reads the lines of a script and checks the directives and command names before anything runs
+func parseScript(r io.Reader) ([]scriptLine, error) {
+	var lines []scriptLine
+	scanner := bufio.NewScanner(r)
+
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		words, err := splitShellWords(text)
+
+		if err != nil {
+			return nil, newUsageError("Line %d: %s\n", n, strings.TrimSpace(err.Error()))
+		}
+
+		if err := checkScriptLine(words); err != nil {
+			return nil, newUsageError("Line %d: %s\n", n, strings.TrimSpace(err.Error()))
+		}
+
+		lines = append(lines, scriptLine{number: n, words: words})
+	}
+
+	return lines, scanner.Err()
+}
+
+// checkScriptLine validates a directive or makes sure a command exists
+func checkScriptLine(words []string) error {
+	switch words[0] {
+	case "wait":
+		if len(words) != 2 {
+			return fmt.Errorf("wait takes a duration, ex. wait 30s")
+		}
+
+		if !strings.Contains(words[1], "$") {
+			if _, err := parseScriptDuration(words[1]); err != nil {
+				return err
+			}
+		}
+	case "wait-for":
+		if len(words) != 2 || words[1] != "track-end" {
+			return fmt.Errorf("wait-for only supports track-end")
+		}
+	case "on-error":
+		if len(words) != 2 || (words[1] != "continue" && words[1] != "stop") {
+			return fmt.Errorf("on-error takes continue or stop")
+		}
+	case "set":
+		if len(words) < 3 || !scriptVariableName.MatchString(words[1]) {
+			return fmt.Errorf("set takes a variable name and a command, ex. set volume status --format '{{.Volume}}'")
+		}
+
+		return checkScriptLine(words[2:])
+	case "echo":
+	default:
+		if strings.Contains(words[0], "$") {
+			return nil
+		}
+
+		if _, _, err := rootCmd.Find(words); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseScriptDuration reads the duration of wait: a number of seconds, a duration such as 1m30s or 1d, or m:ss
+func parseScriptDuration(s string) (time.Duration, error) {
+	d, err := utils.ParseDuration(s)
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, use a number of seconds or a duration such as 1m30s or 1:30", s)
+	}
+
+	return d, nil
+}
+
+// sleep waits for the duration and reports false when the script is interrupted
+func (r *scriptRunner) sleep(d time.Duration) bool {
+	select {
+	case <-r.interrupt:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// interrupted reports whether Ctrl-C was pressed since the last check
+func (r *scriptRunner) interrupted() bool {
+	select {
+	case <-r.interrupt:
+		return true
+	default:
+		return false
+	}
+}
+
+// expand replaces $name and ${name} with script variables, then environment variables, $$ is a dollar sign
+func (r *scriptRunner) expand(words []string) []string {
+	expanded := make([]string, len(words))
+
+	for k, w := range words {
+		expanded[k] = os.Expand(w, func(name string) string {
+			if name == "$" {
+				return "$"
+			}
+
+			if v, ok := r.vars[name]; ok {
+				return v
+			}
+
+			return os.Getenv(name)
+		})
+	}
+
+	return expanded
+}
+
+// captureOutput runs a command with its standard output redirected and returns what it printed
+func captureOutput(args []string) (string, int, error) {
+	rd, w, err := os.Pipe()
+
+	if err != nil {
+		return "", exitGeneral, err
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+
+	var buf bytes.Buffer
+	done := make(chan bool)
+
+	go func() {
+		io.Copy(&buf, rd)
+		close(done)
+	}()
+
+	code := runShellCommand(args)
+
+	os.Stdout = stdout
+	w.Close()
+	<-done
+	rd.Close()
+
+	return strings.TrimRight(buf.String(), "\r\n"), code, nil
+}
+
+// waitForTrackEnd polls the player until the current track is over, a paused track keeps it waiting
+func (r *scriptRunner) waitForTrackEnd() error {
+	p, err := pollPlayerState()
+
+	if err != nil {
+		return newError(err, "Couldn't get the player state\n")
+	}
+
+	uri := p.trackURI()
+
+	for uri != "" {
+		if !r.sleep(nextPollInterval(p, 0)) {
+			return errScriptInterrupted
+		}
+
+		if p, err = pollPlayerState(); err != nil {
+			return newError(err, "Couldn't get the player state\n")
+		}
+
+		// Spotify stops at the beginning of the last track of a context when it's over
+		ended := !p.State.IsPlaying && p.State.ProgressMs == 0
+
+		if p.trackURI() != uri || ended {
+			break
+		}
+	}
+
+	return nil
+}
+
+// errScriptInterrupted stops a script when baton is interrupted during a wait
+var errScriptInterrupted = &cmdError{msg: "Interrupted", code: exitGeneral}
+
+// runLine runs a directive or a command and returns the exit code of the command
+func (r *scriptRunner) runLine(l scriptLine) (int, error) {
+	words := r.expand(l.words)
+
+	switch words[0] {
+	case "wait":
+		d, err := parseScriptDuration(words[1])
+
+		if err != nil {
+			return exitUsage, newUsageError("Line %d: %s\n", l.number, err)
+		}
+
+		if !r.sleep(d) {
+			return exitGeneral, errScriptInterrupted
+		}
+	case "wait-for":
+		if err := r.waitForTrackEnd(); err != nil {
+			return exitCode(err), err
+		}
+	case "on-error":
+		r.stopOnError = words[1] == "stop"
+	case "echo":
+		fmt.Println(strings.Join(words[1:], " "))
+	case "set":
+		out, code, err := captureOutput(words[2:])
+
+		if err != nil {
+			return exitGeneral, newError(err, "Couldn't capture the output of line %d\n", l.number)
+		}
+
+		if code == exitOK {
+			r.vars[words[1]] = out
+		}
+
+		return code, nil
+	default:
+		return runShellCommand(words), nil
+	}
+
+	return exitOK, nil
+}
+
+func runScript(cmd *cobra.Command, args []string) error {
+	in := os.Stdin
+
+	if len(args) > 0 && args[0] != "-" {
+		f, err := os.Open(args[0])
+
+		if err != nil {
+			return newError(err, "Couldn't open the script %s\n", args[0])
+		}
+
+		defer f.Close()
+		in = f
+	}
+
+	lines, err := parseScript(in)
+
+	if err != nil {
+		if _, ok := err.(*cmdError); ok {
+			return err
+		}
+
+		return newError(err, "Couldn't read the script\n")
+	}
+
+	r := &scriptRunner{vars: make(map[string]string), stopOnError: true, interrupt: make(chan os.Signal, 1)}
+	signal.Notify(r.interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(r.interrupt)
+
+	for _, l := range lines {
+		code, err := r.runLine(l)
+
+		// Directives fail the script whatever on-error says, they can't be fixed by going on
+		if err != nil {
+			return err
+		}
+
+		if r.interrupted() {
+			return errScriptInterrupted
+		}
+
+		if code != exitOK && r.stopOnError {
+			return &cmdError{msg: fmt.Sprintf("Stopped the script at line %d", l.number), code: code}
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run [script]",
+	Short: "Run a script of baton commands",
+	Long: `Run the baton commands of a script, one per line and without "baton", read from a file or from stdin.
+
+Lines starting with # are comments. Besides commands, a script can use:
+
+  wait <duration>        wait for a number of seconds or a duration such as 1m30s or 1:30
+  wait-for track-end     wait until the current track is over
+  set <name> <command>   run a command and keep its output in $name
+  echo <words>           print the words, ex. echo "Volume was $volume"
+  on-error continue      keep going when a command fails
+  on-error stop          stop at the first failing command (the default)
+
+Words can use $name or ${name} for variables set by the script or the environment.
+
+  # ~/morning.baton
+  transfer kitchen
+  set previous status --format "{{.Volume}}"
+  vol 30
+  play playlist "Morning Coffee"
+  wait-for track-end
+  echo "Volume was $previous"`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runScript,
+}
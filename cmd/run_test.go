@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScriptDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"5", 5 * time.Second, false},
+		{"0.5", 500 * time.Millisecond, false},
+		{"1m30s", 90 * time.Second, false},
+		{"1:30", 90 * time.Second, false},
+		{"1d", 24 * time.Hour, false},
+		{"-5", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseScriptDuration(tt.in)
+
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseScriptDuration(%q) = %v, %v, want %v (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	if _, err := parseScript(strings.NewReader("wait 1:30\nwait 1d\nwait 1x\n")); err == nil || !strings.Contains(err.Error(), "Line 3") {
+		t.Errorf("parseScript returned %v, want an error on line 3", err)
+	}
+}
@@ -1,30 +1,29 @@
 package cmd
 
 import (
-	"fmt"
-
 	"github.com/firstlane/baton/api"
 	"github.com/spf13/cobra"
 )
 
-func saveTrack(cmd *cobra.Command, args []string) {
-	ctx, err := api.GetPlayerState(nil)
+func saveTrack(cmd *cobra.Command, args []string) error {
+	ctx, err := getPlayer().GetPlayerState(nil)
 
 	if err != nil {
-		fmt.Printf("Couldn't get the player state. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't get the player state. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
 	}
 
 	if ctx.Item == nil {
-		fmt.Printf("Couldn't find information about the current status\n")
-		return
+		return newError(errNotFound, "Couldn't find information about the current status\n")
 	}
 
 	err = api.SaveTrack(ctx.Item.ID)
 	if err != nil {
-		fmt.Printf("Couldn't save the track. %s\n", err)
-		return
+		return newError(err, "Couldn't save the track\n")
 	}
+
+	printResult(ctx.Item, func() {})
+
+	return nil
 }
 
 func init() {
@@ -35,5 +34,5 @@ var saveTrackCmd = &cobra.Command{
 	Use:   "save",
 	Short: "Save current playing track",
 	Long:  `Save current playing track`,
-	Run:   saveTrack,
+	RunE:  saveTrack,
 }
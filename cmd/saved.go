@@ -1,21 +1,21 @@
 package cmd
 
 import (
-	"fmt"
-	"log"
-
 	"github.com/firstlane/baton/api"
 	"github.com/firstlane/baton/ui"
 	"github.com/spf13/cobra"
 )
 
-func browseSavedTracks(cmd *cobra.Command, args []string) {
+func browseSavedTracks(cmd *cobra.Command, args []string) error {
 	res, err := api.GetSavedTracks(&searchOptions)
 
 	if err != nil {
-		fmt.Printf("Couldn't get your saved tracks. Have you authenticated with the 'auth' command?\n")
-		fmt.Println("err", res, err)
-		return
+		return newError(err, "Couldn't get your saved tracks. Have you authenticated with the 'auth' command?\n")
+	}
+
+	if !isTextOutput() {
+		printResult(res, nil)
+		return nil
 	}
 
 	at := ui.NewSavedTrackTable(res)
@@ -23,17 +23,22 @@ func browseSavedTracks(cmd *cobra.Command, args []string) {
 	err = ui.Run(at)
 
 	if err != nil {
-		log.Fatal(err)
+		return newError(err, "Couldn't start the interactive browser\n")
 	}
+
+	return nil
 }
 
-func browseSavedAlbums(cmd *cobra.Command, args []string) {
+func browseSavedAlbums(cmd *cobra.Command, args []string) error {
 	res, err := api.GetSavedAlbums(&searchOptions)
 
 	if err != nil {
-		fmt.Printf("Couldn't get your saved albums. Have you authenticated with the 'auth' command?\n")
-		fmt.Println("err", res, err)
-		return
+		return newError(err, "Couldn't get your saved albums. Have you authenticated with the 'auth' command?\n")
+	}
+
+	if !isTextOutput() {
+		printResult(res, nil)
+		return nil
 	}
 
 	at := ui.NewSavedAlbumTable(res)
@@ -41,8 +46,10 @@ func browseSavedAlbums(cmd *cobra.Command, args []string) {
 	err = ui.Run(at)
 
 	if err != nil {
-		log.Fatal(err)
+		return newError(err, "Couldn't start the interactive browser\n")
 	}
+
+	return nil
 }
 
 func init() {
@@ -62,12 +69,12 @@ var savedTracksCmd = &cobra.Command{
 	Aliases: []string{"songs"},
 	Short:   "Browse saved tracks",
 	Long:    `Browse saved tracks`,
-	Run:     browseSavedTracks,
+	RunE:    browseSavedTracks,
 }
 
 var savedAlbumsCmd = &cobra.Command{
 	Use:   `albums`,
 	Short: "Browse saved Albums",
 	Long:  `Browse saved Albums`,
-	Run:   browseSavedAlbums,
+	RunE:  browseSavedAlbums,
 }
@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/firstlane/baton/scrobble"
+	"github.com/firstlane/baton/watcher"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// scrobbleRetryInterval is how often `baton watch` retries queued scrobbles when no track change triggers it
+var scrobbleRetryInterval = 5 * time.Minute
+
+func openScrobbleQueue() *scrobble.Queue {
+	return scrobble.OpenQueue(filepath.Join(dataDir(), "scrobble_queue.jsonl"))
+}
+
+func newLastFM() *scrobble.LastFM {
+	return &scrobble.LastFM{
+		URL:        viper.GetString("scrobble.lastfm.url"),
+		APIKey:     viper.GetString("scrobble.lastfm.api_key"),
+		Secret:     viper.GetString("scrobble.lastfm.api_secret"),
+		SessionKey: viper.GetString("scrobble.lastfm.session_key"),
+		Client:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// getScrobblers returns the services configured in the scrobble section of the config, Last.fm is only used once 'baton scrobble login' saved a session key
+func getScrobblers() ([]scrobble.Scrobbler, error) {
+	var scrobblers []scrobble.Scrobbler
+
+	lfm := newLastFM()
+
+	if lfm.SessionKey != "" {
+		if lfm.APIKey == "" || lfm.Secret == "" {
+			return nil, fmt.Errorf("scrobble.lastfm needs an api_key and an api_secret next to the session_key")
+		}
+
+		scrobblers = append(scrobblers, lfm)
+	}
+
+	if token := viper.GetString("scrobble.listenbrainz.token"); token != "" {
+		scrobblers = append(scrobblers, &scrobble.ListenBrainz{
+			URL:    viper.GetString("scrobble.listenbrainz.url"),
+			Token:  token,
+			Client: &http.Client{Timeout: 30 * time.Second},
+		})
+	}
+
+	return scrobblers, nil
+}
+
+// flushScrobbles submits the queued scrobbles and reports failures on stderr
+func flushScrobbles(q *scrobble.Queue, scrobblers []scrobble.Scrobbler) scrobble.FlushResult {
+	res, err := q.Flush(scrobblers)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't update the scrobble queue: %s\n", err)
+	}
+
+	for _, err := range res.Errors {
+		fmt.Fprintf(os.Stderr, "Couldn't scrobble, will retry later: %s\n", err)
+	}
+
+	for _, i := range res.Rejected {
+		fmt.Fprintf(os.Stderr, "Dropped the scrobble of %s - %s: %s\n", i.Listen.Artist, i.Listen.Track, i.LastError)
+	}
+
+	return res
+}
+
+// scrobbleEvent queues the track that was playing before the event once it has been played long enough and sends now playing notifications for the new one
+func scrobbleEvent(q *scrobble.Queue, scrobblers []scrobble.Scrobbler, e watcher.Event) {
+	if e.Type != watcher.TrackChanged && e.Type != watcher.Stopped && e.Type != watcher.Resumed {
+		return
+	}
+
+	prev := e.Previous
+
+	// The position of the previous snapshot isn't how long the track was listened to, the watcher counts that
+	if e.Played != nil && prev != nil && prev.Item != nil {
+		duration := time.Duration(prev.Item.DurationMs) * time.Millisecond
+		played := time.Duration(e.Played.ListenedMs) * time.Millisecond
+
+		if scrobble.Eligible(duration, played) {
+			var names []string
+
+			for _, s := range scrobblers {
+				names = append(names, s.Name())
+			}
+
+			err := q.Add(names, scrobble.NewListen(prev.Item, e.Played.StartedAt))
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Couldn't queue the scrobble: %s\n", err)
+			}
+
+			flushScrobbles(q, scrobblers)
+		}
+	}
+
+	cur := e.Current
+
+	if e.Type == watcher.Stopped || cur == nil || cur.Item == nil || !cur.IsPlaying {
+		return
+	}
+
+	l := scrobble.NewListen(cur.Item, e.Time.Add(-time.Duration(cur.ProgressMs)*time.Millisecond))
+
+	for _, s := range scrobblers {
+		if err := s.NowPlaying(l); err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't send now playing: %s\n", err)
+		}
+	}
+}
+
+// runScrobbler submits scrobbles for each event in order until events is closed, queued scrobbles are retried periodically, done is closed once it stopped
+func runScrobbler(scrobblers []scrobble.Scrobbler, events <-chan watcher.Event, done chan<- bool) {
+	q := openScrobbleQueue()
+	ticker := time.NewTicker(scrobbleRetryInterval)
+
+	defer func() {
+		ticker.Stop()
+		close(done)
+	}()
+
+	// Listens queued while baton wasn't running shouldn't wait for the next track change
+	flushScrobbles(q, scrobblers)
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+
+			scrobbleEvent(q, scrobblers, e)
+		case <-ticker.C:
+			flushScrobbles(q, scrobblers)
+		}
+	}
+}
+
+func loginLastFM(cmd *cobra.Command, args []string) error {
+	lfm := newLastFM()
+
+	if lfm.APIKey == "" || lfm.Secret == "" {
+		return newUsageError("Add the api_key and api_secret of your Last.fm API account to the scrobble.lastfm section of your config first, create one at https://www.last.fm/api/account/create\n")
+	}
+
+	token, err := lfm.GetToken()
+
+	if err != nil {
+		return newError(err, "Couldn't get a token from Last.fm\n")
+	}
+
+	fmt.Printf("Navigate to the following URL to allow Baton to scrobble to your profile:\n%s\n\nPress Enter once you've allowed access", lfm.AuthURL(viper.GetString("scrobble.lastfm.auth_url"), token))
+	bufio.NewScanner(os.Stdin).Scan()
+
+	user, key, err := lfm.GetSession(token)
+
+	if err != nil {
+		return newError(err, "Couldn't get a session from Last.fm, did you allow access?\n")
+	}
+
+	err = saveConfigValue("scrobble.lastfm.session_key", key)
+
+	if err != nil {
+		return newError(err, "Couldn't save the session key to your config\n")
+	}
+
+	printMessage("Scrobbling to Last.fm as %s\n", user)
+
+	return nil
+}
+
+func listScrobbleQueue(cmd *cobra.Command, args []string) error {
+	items, err := openScrobbleQueue().Load()
+
+	if err != nil {
+		return newError(err, "Couldn't read the scrobble queue\n")
+	}
+
+	printResult(items, func() {
+		if len(items) == 0 {
+			fmt.Printf("No scrobbles waiting to be submitted\n")
+			return
+		}
+
+		for _, i := range items {
+			failure := ""
+
+			if i.LastError != "" {
+				failure = fmt.Sprintf(" (%d attempts, %s)", i.Attempts, i.LastError)
+			}
+
+			fmt.Printf("%s  %-12s %s - %s%s\n", i.Listen.StartedAt.Local().Format("2006-01-02 15:04"), i.Service, i.Listen.Artist, i.Listen.Track, failure)
+		}
+	})
+
+	return nil
+}
+
+func flushScrobbleQueue(cmd *cobra.Command, args []string) error {
+	scrobblers, err := getScrobblers()
+
+	if err != nil {
+		return newUsageError("%s\n", err)
+	}
+
+	if len(scrobblers) == 0 {
+		return newUsageError("No scrobbling service is configured, see 'baton scrobble --help'\n")
+	}
+
+	res := flushScrobbles(openScrobbleQueue(), scrobblers)
+
+	if len(res.Errors) > 0 {
+		return newError(res.Errors[len(res.Errors)-1], "Submitted %d scrobbles, %d are still waiting\n", res.Sent, res.Pending)
+	}
+
+	printResult(res, func() {
+		fmt.Printf("Submitted %d scrobbles, %d are still waiting\n", res.Sent, res.Pending)
+	})
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(scrobbleCmd)
+	scrobbleCmd.AddCommand(scrobbleLoginCmd)
+	scrobbleCmd.AddCommand(scrobbleQueueCmd)
+	scrobbleCmd.AddCommand(scrobbleFlushCmd)
+}
+
+var scrobbleCmd = &cobra.Command{
+	Use:   "scrobble",
+	Short: "Manage scrobbling to Last.fm and ListenBrainz",
+	Long: `Manage scrobbling to Last.fm and ListenBrainz
+
+'baton watch' sends now playing notifications and scrobbles tracks played for half their length or 4 minutes to the
+services configured in the scrobble section of the config. The URLs are optional, set them to use a self-hosted server
+such as Maloja, Libre.fm or a local stand-in:
+
+  "scrobble": {
+    "lastfm": {
+      "url": "` + scrobble.LastFMURL + `",
+      "api_key": "...",
+      "api_secret": "..."
+    },
+    "listenbrainz": {
+      "url": "` + scrobble.ListenBrainzURL + `",
+      "token": "..."
+    }
+  }
+
+Run 'baton scrobble login' to connect your Last.fm profile. Scrobbles that can't be submitted are kept in
+~/.config/baton/scrobble_queue.jsonl and retried until the service accepts them.`,
+	Args: cobra.NoArgs,
+}
+
+var scrobbleLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Allow Baton to scrobble to your Last.fm profile",
+	Long: `Allow Baton to scrobble to your Last.fm profile, the session key is saved to the config.
+Servers that don't support Last.fm's web authentication accept the session_key set in the config by hand.`,
+	Args: cobra.NoArgs,
+	RunE: loginLastFM,
+}
+
+var scrobbleQueueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "List the scrobbles waiting to be submitted",
+	Long:  `List the scrobbles waiting to be submitted`,
+	Args:  cobra.NoArgs,
+	RunE:  listScrobbleQueue,
+}
+
+var scrobbleFlushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Submit the queued scrobbles now",
+	Long:  `Submit the queued scrobbles now instead of waiting for 'baton watch' to retry them`,
+	Args:  cobra.NoArgs,
+	RunE:  flushScrobbleQueue,
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/scrobble"
+	"github.com/firstlane/baton/watcher"
+)
+
+// recordingScrobbler remembers the listens it's given
+type recordingScrobbler struct {
+	listens []scrobble.Listen
+}
+
+func (s *recordingScrobbler) Name() string {
+	return "test"
+}
+
+func (s *recordingScrobbler) NowPlaying(l scrobble.Listen) error {
+	return nil
+}
+
+func (s *recordingScrobbler) Scrobble(listens []scrobble.Listen) error {
+	s.listens = append(s.listens, listens...)
+	return nil
+}
+
+func TestScrobbleEventUsesListenedTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scrobble")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	t0 := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	track := &api.FullTrack{Name: "One", URI: "spotify:track:1", DurationMs: 200000, Artists: []api.SimpleArtist{{Name: "A"}}}
+	// The position is near the end either way, only the time listened tells the plays apart
+	prev := &api.PlayerState{IsPlaying: true, ProgressMs: 190000, Item: track}
+
+	tests := []struct {
+		name   string
+		played *watcher.Play
+		want   bool
+	}{
+		{"listened to", &watcher.Play{StartedAt: t0, ListenedMs: 195000}, true},
+		{"seeked to the end", &watcher.Play{StartedAt: t0, ListenedMs: 20000}, false},
+		{"unknown play", nil, false},
+	}
+
+	for k, tt := range tests {
+		s := &recordingScrobbler{}
+		q := scrobble.OpenQueue(filepath.Join(dir, tt.name+".jsonl"))
+		e := watcher.Event{Type: watcher.TrackChanged, Time: t0.Add(time.Duration(k+4) * time.Minute), Previous: prev, Played: tt.played}
+
+		scrobbleEvent(q, []scrobble.Scrobbler{s}, e)
+
+		if got := len(s.listens) == 1; got != tt.want {
+			t.Errorf("%s: scrobbled %d listens", tt.name, len(s.listens))
+		} else if got && !s.listens[0].StartedAt.Equal(t0) {
+			t.Errorf("%s: scrobbled as started at %s, want %s", tt.name, s.listens[0].StartedAt, t0)
+		}
+	}
+}
@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"fmt"
-	"log"
 	"strings"
 
 	"github.com/firstlane/baton/api"
@@ -10,12 +8,16 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func searchForArtists(cmd *cobra.Command, args []string) {
+func searchForArtists(cmd *cobra.Command, args []string) error {
 	res, err := api.Search(strings.Join(args, " "), "artist", &searchOptions)
 
 	if err != nil {
-		fmt.Printf("Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
+	}
+
+	if !isTextOutput() {
+		printResult(res.Artists, nil)
+		return nil
 	}
 
 	at := ui.NewArtistTable(res.Artists)
@@ -23,16 +25,22 @@ func searchForArtists(cmd *cobra.Command, args []string) {
 	err = ui.Run(at)
 
 	if err != nil {
-		log.Fatal(err)
+		return newError(err, "Couldn't start the interactive browser\n")
 	}
+
+	return nil
 }
 
-func searchForPlaylists(cmd *cobra.Command, args []string) {
+func searchForPlaylists(cmd *cobra.Command, args []string) error {
 	res, err := api.Search(strings.Join(args, " "), "playlist", &searchOptions)
 
 	if err != nil {
-		fmt.Printf("Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
+	}
+
+	if !isTextOutput() {
+		printResult(res.Playlists, nil)
+		return nil
 	}
 
 	at := ui.NewPlaylistTable(res.Playlists)
@@ -40,16 +48,22 @@ func searchForPlaylists(cmd *cobra.Command, args []string) {
 	err = ui.Run(at)
 
 	if err != nil {
-		log.Fatal(err)
+		return newError(err, "Couldn't start the interactive browser\n")
 	}
+
+	return nil
 }
 
-func searchForAlbums(cmd *cobra.Command, args []string) {
+func searchForAlbums(cmd *cobra.Command, args []string) error {
 	res, err := api.Search(strings.Join(args, " "), "album", &searchOptions)
 
 	if err != nil {
-		fmt.Printf("Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
+	}
+
+	if !isTextOutput() {
+		printResult(res.Albums, nil)
+		return nil
 	}
 
 	at := ui.NewAlbumTable(res.Albums)
@@ -57,16 +71,22 @@ func searchForAlbums(cmd *cobra.Command, args []string) {
 	err = ui.Run(at)
 
 	if err != nil {
-		log.Fatal(err)
+		return newError(err, "Couldn't start the interactive browser\n")
 	}
+
+	return nil
 }
 
-func searchForTracks(cmd *cobra.Command, args []string) {
+func searchForTracks(cmd *cobra.Command, args []string) error {
 	res, err := api.Search(strings.Join(args, " "), "track", &searchOptions)
 
 	if err != nil {
-		fmt.Printf("Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't properly search Spotify. Have you authenticated with the 'auth' command?\n")
+	}
+
+	if !isTextOutput() {
+		printResult(res.Tracks, nil)
+		return nil
 	}
 
 	at := ui.NewTrackTable(res.Tracks)
@@ -74,8 +94,10 @@ func searchForTracks(cmd *cobra.Command, args []string) {
 	err = ui.Run(at)
 
 	if err != nil {
-		log.Fatal(err)
+		return newError(err, "Couldn't start the interactive browser\n")
 	}
+
+	return nil
 }
 
 func init() {
@@ -97,7 +119,7 @@ var searchArtistsCmd = &cobra.Command{
 	Short: "Search specified artists",
 	Long:  `Search specified artists`,
 	Args:  cobra.MinimumNArgs(1),
-	Run:   searchForArtists,
+	RunE:  searchForArtists,
 }
 
 var searchPlaylistsCmd = &cobra.Command{
@@ -105,7 +127,7 @@ var searchPlaylistsCmd = &cobra.Command{
 	Short: "Search specified playlists",
 	Long:  `Search specified playlists`,
 	Args:  cobra.MinimumNArgs(1),
-	Run:   searchForPlaylists,
+	RunE:  searchForPlaylists,
 }
 
 var searchAlbumsCmd = &cobra.Command{
@@ -113,7 +135,7 @@ var searchAlbumsCmd = &cobra.Command{
 	Short: "Search specified albums",
 	Long:  `Search specified albums`,
 	Args:  cobra.MinimumNArgs(1),
-	Run:   searchForAlbums,
+	RunE:  searchForAlbums,
 }
 
 var searchTracksCmd = &cobra.Command{
@@ -121,5 +143,5 @@ var searchTracksCmd = &cobra.Command{
 	Short: "Search specified tracks",
 	Long:  `Search specified tracks`,
 	Args:  cobra.MinimumNArgs(1),
-	Run:   searchForTracks,
+	RunE:  searchForTracks,
 }
@@ -2,38 +2,174 @@ package cmd
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/utils"
 	"github.com/spf13/cobra"
 )
 
-func seekToPosition(cmd *cobra.Command, args []string) {
-	pos, err := strconv.Atoi(args[0])
+// seekArg matches what looks like a position for seek: a time, optionally signed, or a percentage. The time itself is
+// read by parseSeekTime.
+var seekArg = regexp.MustCompile(`^([+-]?\d[\w.:]*|\d+(\.\d+)?%)$`)
+
+// seekStep is how far forward and rewind move without an argument
+const seekStep = 10
+
+// The seekResult struct describes the position playback was moved to
+type seekResult struct {
+	PositionMs int `json:"position_ms"`
+}
+
+// parseSeekTime reads seconds, m:ss, h:mm:ss or a duration such as 1m30s into milliseconds
+func parseSeekTime(s string) (int, error) {
+	d, err := utils.ParseDuration(s)
+
+	return int(d / time.Millisecond), err
+}
+
+// seekTarget returns the position a seek argument points to in the track, kept within the track
+func seekTarget(arg string, ps api.PlayerState) (int, error) {
+	invalid := newUsageError("Invalid position %q, use seconds (83), minutes and seconds (1:23 or 1m23s), an offset (+30, -15) or a percentage (50%%)\n", arg)
+
+	if !seekArg.MatchString(arg) {
+		return 0, invalid
+	}
+
+	duration := ps.Item.DurationMs
+	var pos int
+
+	if strings.HasSuffix(arg, "%") {
+		p, _ := strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+
+		if p > 100 {
+			return 0, newUsageError("A percentage can't be over 100%%\n")
+		}
+
+		pos = int(float64(duration) * p / 100)
+	} else {
+		ms, err := parseSeekTime(strings.TrimLeft(arg, "+-"))
+
+		if err != nil {
+			return 0, invalid
+		}
+
+		switch arg[0] {
+		case '+':
+			pos = ps.ProgressMs + ms
+		case '-':
+			pos = ps.ProgressMs - ms
+		default:
+			pos = ms
+		}
+	}
+
+	return ps.ClampPosition(pos), nil
+}
+
+// seekTo moves playback within the current track to the position the argument points to
+func seekTo(arg string) error {
+	ps, err := getPlayer().GetPlayerState(&options)
 
 	if err != nil {
-		fmt.Printf("Time specified could not be converted to seconds\n")
-		return
+		return newError(err, "Couldn't get the player state. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
+	}
+
+	if ps.Item == nil {
+		return newError(errNotFound, "Nothing is playing\n")
 	}
 
-	err = api.SeekToPosition(pos*1000, &options)
+	pos, err := seekTarget(arg, ps)
 
 	if err != nil {
-		fmt.Printf("Failed to skip to entered position\n")
-	} else {
-		fmt.Printf("Skipping to %d seconds\n", pos)
+		return err
+	}
+
+	err = getPlayer().SeekToPosition(pos, &options)
+
+	if err != nil {
+		return newError(err, "Failed to skip to entered position\n")
+	}
+
+	printResult(seekResult{PositionMs: pos}, func() {
+		fmt.Printf("Skipping to %s of %s\n", utils.MillisecondsToFormattedTime(pos), utils.MillisecondsToFormattedTime(ps.Item.DurationMs))
+	})
+
+	return nil
+}
+
+func seekToPosition(cmd *cobra.Command, args []string) error {
+	args, err := parseFlagsKeepingArgs(cmd, args, seekArg)
+
+	if err != nil {
+		return err
+	}
+
+	if help, _ := cmd.LocalFlags().GetBool("help"); help {
+		return cmd.Help()
+	}
+
+	if len(args) != 1 {
+		return asUsageError(fmt.Errorf("accepts 1 arg(s), received %d", len(args)))
+	}
+
+	return seekTo(args[0])
+}
+
+// stepSeek returns the command that moves forward or back by its argument, seekStep seconds by default
+func stepSeek(sign string) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		step := strconv.Itoa(seekStep)
+
+		if len(args) > 0 {
+			if _, err := parseSeekTime(args[0]); err != nil {
+				return newUsageError("Invalid time %q, use seconds (30) or minutes and seconds (1:30 or 1m30s)\n", args[0])
+			}
+
+			step = args[0]
+		}
+
+		return seekTo(sign + step)
 	}
 }
 
 func init() {
 	rootCmd.AddCommand(seekCmd)
+	rootCmd.AddCommand(forwardCmd)
+	rootCmd.AddCommand(rewindCmd)
 
-	seekCmd.Flags().StringVarP(&options.DeviceID, "device", "d", "", "id of the device this command is targeting")
+	for _, c := range []*cobra.Command{seekCmd, forwardCmd, rewindCmd} {
+		c.Flags().StringVarP(&options.DeviceID, "device", "d", "", "name, id or alias of the device this command is targeting")
+	}
 }
 
 var seekCmd = &cobra.Command{
-	Use:   "seek [pos]",
-	Short: "Skip to a specific time (seconds) of the current track",
-	Long:  `Skip to a specific time (seconds) of the current track`,
-	Run:   seekToPosition,
+	Use:   "seek <pos>",
+	Short: "Skip to a specific time of the current track",
+	Long: `Skip to a specific time of the current track: seconds (seek 83), minutes and seconds (seek 1:23 or seek 1m23s), an offset from
+the current position (seek +30, seek -15) or a percentage of the track (seek 50%). The position is kept within the track.`,
+	RunE: seekToPosition,
+	// The flags are parsed by seekToPosition so an offset such as -15 isn't taken for a flag
+	DisableFlagParsing: true,
+}
+
+var forwardCmd = &cobra.Command{
+	Use:     "forward [time]",
+	Short:   "Skip forward 10 seconds or the given time in the current track",
+	Long:    `Skip forward in the current track by 10 seconds or the given time, ex. forward 30 or forward 1:00`,
+	Args:    cobra.MaximumNArgs(1),
+	RunE:    stepSeek("+"),
+	Aliases: []string{"ff"},
+}
+
+var rewindCmd = &cobra.Command{
+	Use:     "rewind [time]",
+	Short:   "Skip back 10 seconds or the given time in the current track",
+	Long:    `Skip back in the current track by 10 seconds or the given time, ex. rewind 30 or rewind 1:00`,
+	Args:    cobra.MaximumNArgs(1),
+	RunE:    stepSeek("-"),
+	Aliases: []string{"rw"},
 }
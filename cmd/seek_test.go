@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/firstlane/baton/api"
+)
+
+func TestSeekTarget(t *testing.T) {
+	// 1:00 into a 4:00 track
+	ps := api.PlayerState{ProgressMs: 60000, Item: &api.FullTrack{DurationMs: 240000}}
+
+	tests := []struct {
+		arg     string
+		want    int
+		wantErr bool
+	}{
+		{"83", 83000, false},
+		{"1:23", 83000, false},
+		{"1m23s", 83000, false},
+		{"+30", 90000, false},
+		{"-1:30", 0, false},
+		{"+2m", 180000, false},
+		{"50%", 120000, false},
+		{"1:00:00", 240000, false},
+		{"150%", 0, true},
+		{"1:75", 0, true},
+		{"1x", 0, true},
+		{"end", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := seekTarget(tt.arg, ps)
+
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("seekTarget(%q) = %d, %v, want %d (error %v)", tt.arg, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
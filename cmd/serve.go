@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var serveListen string
+
+// The remoteServer struct describes the HTTP API started by `baton serve`
+type remoteServer struct {
+	token string
+	mu    api.CallLock
+}
+
+// The remoteRequest struct describes the JSON body accepted by the player endpoints, each endpoint only uses the fields it needs
+type remoteRequest struct {
+	DeviceID   string                   `json:"device_id,omitempty"`
+	ContextURI string                   `json:"context_uri,omitempty"`
+	URIs       []string                 `json:"uris,omitempty"`
+	Offset     *api.PlayerOffsetOptions `json:"offset,omitempty"`
+	URI        string                   `json:"uri,omitempty"`
+	Volume     *int                     `json:"volume,omitempty"`
+	Play       *bool                    `json:"play,omitempty"`
+}
+
+// getServeToken returns the token clients have to send, one is generated and saved to the config the first time
+func getServeToken() (string, error) {
+	if t := viper.GetString("serve.token"); t != "" {
+		return t, nil
+	}
+
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	t := hex.EncodeToString(b)
+
+	return t, saveConfigValue("serve.token", t)
+}
+
+// httpStatus picks the response status for a failed Spotify call, failures on Spotify's side are reported as a bad gateway so they can't be mistaken for a rejected token
+func httpStatus(err error) int {
+	switch causeExitCode(err) {
+	case exitNoDevice:
+		return http.StatusConflict
+	case exitPremium:
+		return http.StatusForbidden
+	case exitNotFound:
+		return http.StatusNotFound
+	case exitRateLimited:
+		return http.StatusTooManyRequests
+	case exitAuth, exitNetwork:
+		return http.StatusBadGateway
+	}
+
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeRemoteError(w http.ResponseWriter, status int, err error, msg string) {
+	e := errorResult{Error: msg}
+
+	if err != nil {
+		e.Detail = err.Error()
+	}
+
+	writeJSON(w, status, e)
+}
+
+// authorized checks the token sent in the Authorization header, or in the token parameter for links
+func (s *remoteServer) authorized(r *http.Request) bool {
+	t := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	if t == "" {
+		t = r.URL.Query().Get("token")
+	}
+
+	return subtle.ConstantTimeCompare([]byte(t), []byte(s.token)) == 1
+}
+
+// endpoint wraps a handler of the JSON API with the token check, the allowed methods and decoding of the body
+func (s *remoteServer) endpoint(methods string, h func(req remoteRequest, r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			writeRemoteError(w, http.StatusUnauthorized, nil, "Missing or wrong token")
+			return
+		}
+
+		if !utils.StringInSlice(r.Method, strings.Fields(methods)) {
+			w.Header().Set("Allow", methods)
+			writeRemoteError(w, http.StatusMethodNotAllowed, nil, fmt.Sprintf("Use %s", methods))
+			return
+		}
+
+		var req remoteRequest
+
+		if r.Body != nil && r.ContentLength != 0 {
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+				writeRemoteError(w, http.StatusBadRequest, err, "The body must be a JSON object")
+				return
+			}
+		}
+
+		if req.DeviceID == "" {
+			req.DeviceID = r.URL.Query().Get("device_id")
+		}
+
+		s.mu.Lock()
+		v, err := h(req, r)
+		s.mu.Unlock()
+
+		if e, ok := err.(*cmdError); ok && e.code == exitUsage {
+			writeRemoteError(w, http.StatusBadRequest, nil, e.msg)
+			return
+		}
+
+		if err != nil {
+			writeRemoteError(w, httpStatus(err), err, "Spotify couldn't complete the request")
+			return
+		}
+
+		if v == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+func (s *remoteServer) handler() http.Handler {
+	p := getPlayer()
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		// The page asks for the token itself, it holds nothing worth protecting
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, remotePage)
+	})
+
+	mux.HandleFunc("/api/status", s.endpoint("GET", func(req remoteRequest, r *http.Request) (interface{}, error) {
+		ps, err := p.GetPlayerState(nil)
+
+		if err == api.ErrNoActiveDevice {
+			return struct {
+				IsPlaying bool `json:"is_playing"`
+			}{}, nil
+		}
+
+		return ps, err
+	}))
+	mux.HandleFunc("/api/devices", s.endpoint("GET", func(req remoteRequest, r *http.Request) (interface{}, error) {
+		return p.GetDevices()
+	}))
+	mux.HandleFunc("/api/play", s.endpoint("POST", func(req remoteRequest, r *http.Request) (interface{}, error) {
+		return nil, p.StartPlayback(&api.PlayerOptions{DeviceID: req.DeviceID, ContextURI: req.ContextURI, URIs: req.URIs, Offset: req.Offset})
+	}))
+	mux.HandleFunc("/api/pause", s.endpoint("POST", func(req remoteRequest, r *http.Request) (interface{}, error) {
+		return nil, p.PausePlayback(&api.Options{DeviceID: req.DeviceID})
+	}))
+	mux.HandleFunc("/api/next", s.endpoint("POST", func(req remoteRequest, r *http.Request) (interface{}, error) {
+		return nil, p.SkipToNext(&api.Options{DeviceID: req.DeviceID})
+	}))
+	mux.HandleFunc("/api/previous", s.endpoint("POST", func(req remoteRequest, r *http.Request) (interface{}, error) {
+		return nil, p.SkipToPrevious(&api.Options{DeviceID: req.DeviceID})
+	}))
+	mux.HandleFunc("/api/volume", s.endpoint("PUT POST", func(req remoteRequest, r *http.Request) (interface{}, error) {
+		if req.Volume == nil || *req.Volume < 0 || *req.Volume > 100 {
+			return nil, newUsageError("volume must be between 0 and 100")
+		}
+
+		return nil, p.SetVolume(*req.Volume, &api.Options{DeviceID: req.DeviceID})
+	}))
+	mux.HandleFunc("/api/transfer", s.endpoint("POST", func(req remoteRequest, r *http.Request) (interface{}, error) {
+		if req.DeviceID == "" {
+			return nil, newUsageError("device_id is required")
+		}
+
+		play := true
+
+		if req.Play != nil {
+			play = *req.Play
+		}
+
+		return nil, p.TransferPlayback(&api.TransferOptions{DeviceIDs: []string{req.DeviceID}, Play: play})
+	}))
+	mux.HandleFunc("/api/queue", s.endpoint("GET POST", func(req remoteRequest, r *http.Request) (interface{}, error) {
+		if r.Method == "GET" {
+			return p.GetQueue()
+		}
+
+		if req.URI == "" {
+			return nil, newUsageError("uri is required")
+		}
+
+		return nil, p.AddToQueue(req.URI, &api.Options{DeviceID: req.DeviceID})
+	}))
+	mux.HandleFunc("/api/search", s.endpoint("GET", func(req remoteRequest, r *http.Request) (interface{}, error) {
+		q := r.URL.Query()
+
+		if q.Get("q") == "" {
+			return nil, newUsageError("q is required")
+		}
+
+		types := q.Get("type")
+
+		if types == "" {
+			types = "track,album,artist,playlist"
+		}
+
+		opts := api.SearchOptions{Limit: 10}
+
+		if l, err := strconv.Atoi(q.Get("limit")); err == nil {
+			opts.Limit = l
+		}
+
+		return api.Search(q.Get("q"), types, &opts)
+	}))
+
+	return mux
+}
+
+func serveRemote(cmd *cobra.Command, args []string) error {
+	token, err := getServeToken()
+
+	if err != nil {
+		return newError(err, "Couldn't create a token for the remote\n")
+	}
+
+	l, err := net.Listen("tcp", serveListen)
+
+	if err != nil {
+		return newError(err, "Couldn't listen on %s\n", serveListen)
+	}
+
+	s := &remoteServer{token: token}
+	srv := &http.Server{Handler: s.handler(), ReadTimeout: 10 * time.Second, WriteTimeout: time.Minute}
+
+	fmt.Fprintf(os.Stderr, "Serving the remote on http://%s/#token=%s\n", l.Addr(), token)
+
+	return newError(srv.Serve(l), "The server stopped\n")
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVarP(&serveListen, "listen", "l", "127.0.0.1:8099", "address to listen on, use 0.0.0.0:8099 to allow other machines on the network")
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve an HTTP API and a web remote for the player",
+	Long: `Serve a JSON API and a web remote so anyone on the network can control playback without Spotify credentials.
+
+Every API request needs the token from serve.token in the config (generated the first time) as a bearer token or a
+token parameter. Open the printed URL to use the web remote.
+
+  GET  /api/status                     player state
+  GET  /api/devices                    available devices
+  POST /api/play                       {"context_uri": "...", "uris": ["..."], "device_id": "..."}, empty to resume
+  POST /api/pause, /api/next, /api/previous
+  PUT  /api/volume                     {"volume": 40}
+  POST /api/transfer                   {"device_id": "...", "play": true}
+  GET  /api/search?q=...&type=track    search results
+  GET  /api/queue                      upcoming tracks
+  POST /api/queue                      {"uri": "spotify:track:..."}`,
+	Args: cobra.NoArgs,
+	RunE: serveRemote,
+}
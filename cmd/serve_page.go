@@ -0,0 +1,120 @@
+package cmd
+
+// remotePage is the web remote served by `baton serve`, it only uses the JSON API
+const remotePage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Baton</title>
+<style>
+  body { font-family: sans-serif; max-width: 32em; margin: 1em auto; padding: 0 1em; color: #222; }
+  button { font-size: 1.2em; padding: .3em .8em; }
+  input, select { font-size: 1em; }
+  #track { font-size: 1.3em; font-weight: bold; }
+  #controls, #devices, #volume, form { margin: 1em 0; }
+  li { margin: .3em 0; }
+  .error { color: #b00; }
+</style>
+</head>
+<body>
+<div id="track">Nothing playing</div>
+<div id="artist"></div>
+<div id="controls">
+  <button onclick="call('POST', '/api/previous')">&#9198;</button>
+  <button id="toggle" onclick="toggle()">&#9199;</button>
+  <button onclick="call('POST', '/api/next')">&#9197;</button>
+</div>
+<div id="volume">Volume <input id="vol" type="range" min="0" max="100" onchange="call('PUT', '/api/volume', {volume: +this.value})"></div>
+<div id="devices">Device <select id="device" onchange="call('POST', '/api/transfer', {device_id: this.value})"></select></div>
+<form onsubmit="search(); return false">
+  <input id="q" placeholder="Search tracks, albums, playlists"> <button>Search</button>
+</form>
+<ul id="results"></ul>
+<div id="error" class="error"></div>
+<script>
+var m = location.hash.match(/token=([^&]+)/);
+if (m) { localStorage.setItem("baton-token", m[1]); history.replaceState(null, "", location.pathname); }
+var token = localStorage.getItem("baton-token") || prompt("Token (serve.token in the baton config)");
+localStorage.setItem("baton-token", token);
+var state = {};
+
+function call(method, path, body) {
+  return fetch(path, {
+    method: method,
+    headers: {"Authorization": "Bearer " + token, "Content-Type": "application/json"},
+    body: body ? JSON.stringify(body) : undefined
+  }).then(function (r) {
+    if (r.status == 204) { setTimeout(refresh, 500); return null; }
+    return r.json().then(function (v) {
+      if (!r.ok) throw new Error(v.error + (v.detail ? " (" + v.detail + ")" : ""));
+      document.getElementById("error").textContent = "";
+      return v;
+    });
+  }).catch(function (e) { document.getElementById("error").textContent = e.message; });
+}
+
+function toggle() {
+  call("POST", state.is_playing ? "/api/pause" : "/api/play");
+}
+
+function text(tag, s) {
+  var e = document.createElement(tag);
+  e.textContent = s;
+  return e;
+}
+
+function refresh() {
+  call("GET", "/api/status").then(function (s) {
+    if (!s) return;
+    state = s;
+    var item = s.item;
+    document.getElementById("track").textContent = item ? item.name : "Nothing playing";
+    document.getElementById("artist").textContent = item ? item.artists.map(function (a) { return a.name; }).join(", ") : "";
+    document.getElementById("toggle").innerHTML = s.is_playing ? "&#9208;" : "&#9654;";
+    if (s.device) document.getElementById("vol").value = s.device.volume_percent;
+  });
+  call("GET", "/api/devices").then(function (ds) {
+    if (!ds) return;
+    var sel = document.getElementById("device");
+    sel.innerHTML = "";
+    ds.forEach(function (d) {
+      var o = text("option", d.name + " (" + d.type + ")");
+      o.value = d.id;
+      o.selected = d.is_active;
+      sel.appendChild(o);
+    });
+  });
+}
+
+function result(label, uri, playable) {
+  var li = text("li", label + " ");
+  var play = text("button", "Play");
+  play.onclick = function () { call("POST", "/api/play", playable ? {uris: [uri]} : {context_uri: uri}); };
+  li.appendChild(play);
+  if (playable) {
+    var queue = text("button", "Queue");
+    queue.onclick = function () { call("POST", "/api/queue", {uri: uri}); };
+    li.appendChild(queue);
+  }
+  return li;
+}
+
+function search() {
+  var q = document.getElementById("q").value;
+  call("GET", "/api/search?type=track,album,playlist&limit=5&q=" + encodeURIComponent(q)).then(function (r) {
+    if (!r) return;
+    var ul = document.getElementById("results");
+    ul.innerHTML = "";
+    (r.tracks ? r.tracks.items : []).forEach(function (t) { ul.appendChild(result(t.name + " - " + t.artists[0].name, t.uri, true)); });
+    (r.albums ? r.albums.items : []).forEach(function (a) { ul.appendChild(result("Album: " + a.name, a.uri, false)); });
+    (r.playlists ? r.playlists.items : []).forEach(function (p) { if (p) ul.appendChild(result("Playlist: " + p.name, p.uri, false)); });
+  });
+}
+
+refresh();
+setInterval(refresh, 5000);
+</script>
+</body>
+</html>
+`
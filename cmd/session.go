@@ -0,0 +1,389 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// The session struct describes where playback was when a session was saved, enough to resume it exactly there.
+// VolumeKnown tells a volume of 0 apart from a device whose volume the web api doesn't report.
+type session struct {
+	Name        string    `json:"name"`
+	SavedAt     time.Time `json:"saved_at"`
+	ContextURI  string    `json:"context_uri,omitempty"`
+	ContextType string    `json:"context_type,omitempty"`
+	TrackURI    string    `json:"track_uri"`
+	TrackName   string    `json:"track_name"`
+	Artists     string    `json:"artists"`
+	PositionMs  int       `json:"position_ms"`
+	Shuffle     bool      `json:"shuffle"`
+	Repeat      string    `json:"repeat"`
+	DeviceID    string    `json:"device_id"`
+	DeviceName  string    `json:"device_name"`
+	Volume      int       `json:"volume"`
+	VolumeKnown bool      `json:"volume_known"`
+}
+
+// sessionStartTimeout is how long restore waits for the device to play the session's track before seeking within it
+const sessionStartTimeout = 5 * time.Second
+
+// sessionStartPoll is how often restore checks whether the session's track started
+const sessionStartPoll = 250 * time.Millisecond
+
+// volumeControllable reports whether the volume of the device can be read and changed through the web api, it can't
+// for restricted devices, phones and cast video devices
+func volumeControllable(d api.Device) bool {
+	return !d.IsRestricted && !utils.StringInSlice(d.Type, []string{"CastVideo", "Phone"})
+}
+
+// describe tells where the session resumes, ex. 'Chapter 3' by Someone at 12:05 on 'Kitchen'
+func (s session) describe() string {
+	return fmt.Sprintf("'%s' by %s at %s on '%s'", s.TrackName, s.Artists, utils.MillisecondsToFormattedTime(s.PositionMs), s.DeviceName)
+}
+
+// sessionsPath is where the saved sessions are kept, by name
+func sessionsPath() string {
+	return filepath.Join(dataDir(), "sessions.json")
+}
+
+func getSessions() (map[string]session, error) {
+	sessions := make(map[string]session)
+	b, err := ioutil.ReadFile(sessionsPath())
+
+	if os.IsNotExist(err) {
+		return sessions, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, &sessions)
+
+	return sessions, err
+}
+
+// getSession returns the saved session with the given name
+func getSession(name string) (session, error) {
+	sessions, err := getSessions()
+
+	if err != nil {
+		return session{}, newError(err, "Couldn't read the saved sessions\n")
+	}
+
+	s, ok := sessions[name]
+
+	if !ok {
+		return session{}, newError(errNotFound, "No session is named '%s', 'baton session list' lists them\n", name)
+	}
+
+	return s, nil
+}
+
+func saveSession(cmd *cobra.Command, args []string) error {
+	ps, err := getPlayer().GetPlayerState(&options)
+
+	if err != nil {
+		return newError(err, "Couldn't get the player state. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
+	}
+
+	if ps.Item == nil || ps.Device == nil {
+		return newError(api.ErrNoActiveDevice, "Nothing is playing, there's no session to save\n")
+	}
+
+	var artists []string
+
+	for _, a := range ps.Item.Artists {
+		artists = append(artists, a.Name)
+	}
+
+	s := session{
+		Name:        args[0],
+		SavedAt:     time.Now(),
+		TrackURI:    ps.Item.URI,
+		TrackName:   ps.Item.Name,
+		Artists:     strings.Join(artists, ", "),
+		PositionMs:  ps.ProgressMs,
+		Shuffle:     ps.ShuffleState,
+		Repeat:      ps.RepeatState,
+		DeviceID:    ps.Device.ID,
+		DeviceName:  ps.Device.Name,
+		Volume:      ps.Device.VolumePercent,
+		VolumeKnown: volumeControllable(*ps.Device),
+	}
+
+	if ps.Context != nil {
+		s.ContextURI = ps.Context.URI
+		s.ContextType = ps.Context.Type
+	}
+
+	if err := os.MkdirAll(dataDir(), 0700); err != nil {
+		return newError(err, "Couldn't save the session\n")
+	}
+
+	err = utils.UpdateJSONFile(sessionsPath(), func(m map[string]interface{}) {
+		m[s.Name] = s
+	})
+
+	if err != nil {
+		return newError(err, "Couldn't save the session\n")
+	}
+
+	printResult(s, func() {
+		fmt.Printf("Saved the session %s: %s\n", s.Name, s.describe())
+	})
+
+	return nil
+}
+
+// sessionDevice picks the device to restore a session on: --device, the session's device, the active device and
+// then default_device from the config
+func sessionDevice(s session) (api.Device, error) {
+	devices, err := getPlayer().GetDevices()
+
+	if err != nil {
+		return api.Device{}, newError(err, "Couldn't retrieve devices. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
+	}
+
+	if options.DeviceID != "" {
+		return resolveDevice(devices, options.DeviceID)
+	}
+
+	for _, d := range devices {
+		if d.ID == s.DeviceID {
+			return d, nil
+		}
+	}
+
+	// Some devices get a new id when they restart, their name is kept. Another device of the same name is only
+	// picked when the session's device isn't there.
+	for _, d := range devices {
+		if strings.EqualFold(d.Name, s.DeviceName) {
+			return d, nil
+		}
+	}
+
+	for _, d := range devices {
+		if d.IsActive {
+			fmt.Fprintf(os.Stderr, "The device '%s' of the session isn't available, restoring on %s '%s'\n", s.DeviceName, d.Type, d.Name)
+			return d, nil
+		}
+	}
+
+	if name := viper.GetString("default_device"); name != "" {
+		d, err := resolveDevice(devices, name)
+
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "The device '%s' of the session isn't available, restoring on the default device %s '%s'\n", s.DeviceName, d.Type, d.Name)
+		}
+
+		return d, err
+	}
+
+	return api.Device{}, newError(api.ErrNoActiveDevice, "The device '%s' of the session isn't available, choose another one with --device\n", s.DeviceName)
+}
+
+func restoreSession(cmd *cobra.Command, args []string) error {
+	s, err := getSession(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	d, err := sessionDevice(s)
+
+	if err != nil {
+		return err
+	}
+
+	if !d.IsActive {
+		err = getPlayer().TransferPlayback(&api.TransferOptions{DeviceIDs: []string{d.ID}})
+
+		if err != nil {
+			return newError(err, "Couldn't transfer playback to %s '%s'\n", d.Type, d.Name)
+		}
+	}
+
+	opts := &api.Options{DeviceID: d.ID}
+
+	if err := getPlayer().ToggleShuffle(s.Shuffle, opts); err != nil {
+		return newError(err, "Couldn't set shuffle\n")
+	}
+
+	if s.Repeat != "" {
+		if err := getPlayer().SetRepeatMode(s.Repeat, opts); err != nil {
+			return newError(err, "Couldn't set the repeat mode\n")
+		}
+	}
+
+	// Only albums and playlists can start at a given track, anything else plays the track on its own
+	po := &api.PlayerOptions{DeviceID: d.ID, URIs: []string{s.TrackURI}}
+
+	if s.ContextType == "album" || s.ContextType == "playlist" {
+		po = &api.PlayerOptions{DeviceID: d.ID, ContextURI: s.ContextURI, Offset: &api.PlayerOffsetOptions{URI: s.TrackURI}}
+	}
+
+	if err := getPlayer().StartPlayback(po); err != nil {
+		return newError(err, "Couldn't start playback\n")
+	}
+
+	at := s.PositionMs
+
+	if s.PositionMs > 0 {
+		// Seeking before the device switched to the track would seek within whatever it was playing
+		if !waitForTrack(s.TrackURI) {
+			fmt.Fprintf(os.Stderr, "'%s' didn't start within %s, playing it from the start\n", s.TrackName, sessionStartTimeout)
+			at = 0
+		} else if err := getPlayer().SeekToPosition(s.PositionMs, opts); err != nil {
+			return newError(err, "Couldn't skip to where the session was\n")
+		}
+	}
+
+	// The volume belongs to the session's device, sessions saved before volume_known existed only know non-zero volumes
+	sameDevice := d.ID == s.DeviceID || strings.EqualFold(d.Name, s.DeviceName)
+
+	if sameDevice && (s.VolumeKnown || s.Volume > 0) && volumeControllable(d) {
+		if err := getPlayer().SetVolume(s.Volume, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't set the volume back to %d%%: %s\n", s.Volume, err)
+		}
+	}
+
+	printResult(s, func() {
+		fmt.Printf("Restored the session %s: '%s' by %s at %s on %s '%s'\n", s.Name, s.TrackName, s.Artists, utils.MillisecondsToFormattedTime(at), d.Type, d.Name)
+	})
+
+	return nil
+}
+
+// waitForTrack polls the player until it plays the track, it returns false when it didn't within sessionStartTimeout
+func waitForTrack(uri string) bool {
+	deadline := time.Now().Add(sessionStartTimeout)
+
+	for {
+		ps, err := getPlayer().GetPlayerState(&api.Options{})
+
+		if err == nil && ps.Item != nil && ps.Item.URI == uri {
+			return true
+		}
+
+		if time.Now().Add(sessionStartPoll).After(deadline) {
+			return false
+		}
+
+		time.Sleep(sessionStartPoll)
+	}
+}
+
+func listSessions(cmd *cobra.Command, args []string) error {
+	sessions, err := getSessions()
+
+	if err != nil {
+		return newError(err, "Couldn't read the saved sessions\n")
+	}
+
+	if len(sessions) == 0 && isTextOutput() {
+		fmt.Println("No saved sessions, save one with 'baton session save <name>'")
+		return nil
+	}
+
+	list := make([]session, 0, len(sessions))
+
+	for _, s := range sessions {
+		list = append(list, s)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	printResult(list, func() {
+		for _, s := range list {
+			fmt.Printf("%s: %s (saved %s)\n", s.Name, s.describe(), s.SavedAt.Local().Format("Mon Jan 2 15:04"))
+		}
+	})
+
+	return nil
+}
+
+func removeSession(cmd *cobra.Command, args []string) error {
+	s, err := getSession(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	err = utils.UpdateJSONFile(sessionsPath(), func(m map[string]interface{}) {
+		delete(m, s.Name)
+	})
+
+	if err != nil {
+		return newError(err, "Couldn't remove the session\n")
+	}
+
+	printMessage("Removed the session %s\n", s.Name)
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(sessionCmd)
+	sessionCmd.AddCommand(sessionSaveCmd)
+	sessionCmd.AddCommand(sessionRestoreCmd)
+	sessionCmd.AddCommand(sessionListCmd)
+	sessionCmd.AddCommand(sessionRemoveCmd)
+
+	sessionRestoreCmd.Flags().StringVarP(&options.DeviceID, "device", "d", "", "name, id or alias of the device to restore on (default the session's device)")
+}
+
+var sessionCmd = &cobra.Command{
+	Use:   "session",
+	Short: "Save where playback is and resume it later",
+	Long: `Save where playback is, the album or playlist, track, position, shuffle, repeat, device and volume, and resume
+it exactly there later. The sessions are kept in sessions.json next to the listening history.`,
+	Args: cobra.NoArgs,
+	RunE: listSessions,
+}
+
+var sessionSaveCmd = &cobra.Command{
+	Use:   "save <name>",
+	Short: "Save where playback is as a session",
+	Long:  `Save where playback is as a session, a session with the same name is replaced`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  saveSession,
+}
+
+var sessionRestoreCmd = &cobra.Command{
+	Use:   "restore <name>",
+	Short: "Resume playback where a session was saved",
+	Long: `Resume playback on the session's device at the track and position it was saved at, with its shuffle, repeat and
+volume. When the device isn't available the active device is used, then default_device from the config.`,
+	Args: cobra.ExactArgs(1),
+	RunE: restoreSession,
+}
+
+var sessionListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the saved sessions",
+	Long:  `List the saved sessions`,
+	Args:  cobra.NoArgs,
+	RunE:  listSessions,
+}
+
+var sessionRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a saved session",
+	Long:  `Remove a saved session`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  removeSession,
+}
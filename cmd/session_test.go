@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/firstlane/baton/api"
+)
+
+func TestSessionDevicePrefersTheID(t *testing.T) {
+	// Two speakers share a name, the one the session was saved on comes second
+	p := &devicesPlayer{devices: []api.Device{
+		{ID: "other", Name: "Speaker", IsActive: true},
+		{ID: "saved", Name: "Speaker"},
+	}}
+	activePlayer = p
+	defer func() { activePlayer = nil }()
+
+	tests := []struct {
+		name string
+		s    session
+		want string
+	}{
+		{"same id", session{DeviceID: "saved", DeviceName: "Speaker"}, "saved"},
+		{"restarted with a new id", session{DeviceID: "gone", DeviceName: "speaker"}, "other"},
+		{"device missing", session{DeviceID: "gone", DeviceName: "Kitchen"}, "other"},
+	}
+
+	for _, tt := range tests {
+		if d, err := sessionDevice(tt.s); err != nil || d.ID != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.name, d.ID, err, tt.want)
+		}
+	}
+}
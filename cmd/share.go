@@ -3,55 +3,70 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/firstlane/baton/api"
 	"github.com/spf13/cobra"
 )
 
-func getURIAndURL(cmd *cobra.Command, args []string) {
-	ctx, err := api.GetPlayerState(nil)
+// The shareResult struct describes the ways to share the current track
+type shareResult struct {
+	URI      string `json:"uri,omitempty"`
+	URL      string `json:"url,omitempty"`
+	ShareURL string `json:"share_url,omitempty"`
+}
+
+func getURIAndURL(cmd *cobra.Command, args []string) error {
+	ctx, err := getPlayer().GetPlayerState(nil)
 
 	if err != nil {
-		fmt.Printf("Couldn't get the player state to retrieve share information. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't get the player state to retrieve share information. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
+	}
+
+	if ctx.Item == nil {
+		return newError(errNotFound, "There doesn't appear to be a track playing currently\n")
 	}
 
-	if ctx.Item != nil {
+	printResult(shareResult{URI: ctx.Item.URI, URL: ctx.Item.Href, ShareURL: ctx.Item.ExternalUrls["spotify"]}, func() {
 		fmt.Printf("URI: %s\n", ctx.Item.URI)
 		fmt.Printf("URL: %s\n", ctx.Item.Href)
 		fmt.Printf("Share URL: %s\n", ctx.Item.ExternalUrls["spotify"])
-	} else {
-		fmt.Printf("There doesn't appear to be a track playing currently\n")
-	}
+	})
+
+	return nil
 }
 
-func getURI(cmd *cobra.Command, args []string) {
-	ctx, err := api.GetPlayerState(nil)
+func getURI(cmd *cobra.Command, args []string) error {
+	ctx, err := getPlayer().GetPlayerState(nil)
 
 	if err != nil {
-		fmt.Printf("Couldn't get the player state to retrieve share information. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't get the player state to retrieve share information. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
 	}
 
-	if ctx.Item != nil {
-		fmt.Printf("%s\n", ctx.Item.URI)
-	} else {
-		fmt.Printf("There doesn't appear to be a track playing currently\n")
+	if ctx.Item == nil {
+		return newError(errNotFound, "There doesn't appear to be a track playing currently\n")
 	}
+
+	printResult(shareResult{URI: ctx.Item.URI}, func() {
+		fmt.Printf("%s\n", ctx.Item.URI)
+	})
+
+	return nil
 }
 
-func getURL(cmd *cobra.Command, args []string) {
-	ctx, err := api.GetPlayerState(nil)
+func getURL(cmd *cobra.Command, args []string) error {
+	ctx, err := getPlayer().GetPlayerState(nil)
 
 	if err != nil {
-		fmt.Printf("Couldn't get the player state to retrieve share information. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't get the player state to retrieve share information. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
 	}
 
-	if ctx.Item != nil {
-		fmt.Printf("%s\n", ctx.Item.Href)
-	} else {
-		fmt.Printf("There doesn't appear to be a track playing currently\n")
+	if ctx.Item == nil {
+		return newError(errNotFound, "There doesn't appear to be a track playing currently\n")
 	}
+
+	printResult(shareResult{URL: ctx.Item.Href}, func() {
+		fmt.Printf("%s\n", ctx.Item.Href)
+	})
+
+	return nil
 }
 
 func init() {
@@ -64,19 +79,19 @@ var shareCmd = &cobra.Command{
 	Use:   "share",
 	Short: "Get URI and URL for current track",
 	Long:  `Get URI and URL for current track`,
-	Run:   getURIAndURL,
+	RunE:  getURIAndURL,
 }
 
 var shareURICmd = &cobra.Command{
 	Use:   "uri",
 	Short: "Get URI for current track",
 	Long:  `Get URI for current track`,
-	Run:   getURI,
+	RunE:  getURI,
 }
 
 var shareURLCmd = &cobra.Command{
 	Use:   "url",
 	Short: "Get URL for the current track",
 	Long:  `Get URL for the current track`,
-	Run:   getURL,
+	RunE:  getURL,
 }
@@ -0,0 +1,460 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/lineedit"
+	runewidth "github.com/mattn/go-runewidth"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+)
+
+// shellTrackWidth is how many cells of the prompt the current track may take
+const shellTrackWidth = 40
+
+// shellPromptTTL is how old the player state shown in the prompt may be, the state is shared with other baton
+// processes such as status bars through the cache of getCachedPlayerState
+const shellPromptTTL = 5 * time.Second
+
+// shellRecentLimit is how many recently seen artists are offered for completion
+const shellRecentLimit = 200
+
+// The shellSession struct describes what the shell remembers between commands for the prompt and completion
+type shellSession struct {
+	// artists holds the recently seen artists, most recent first
+	artists   []string
+	playlists []string
+	devices   []api.Device
+	devicesAt time.Time
+}
+
+// splitShellWords splits a command line into words the way a POSIX shell does for quotes and backslashes
+func splitShellWords(s string) ([]string, error) {
+	words, _, open := scanShellWords([]rune(s))
+
+	if open {
+		return nil, newUsageError("Unterminated quote in: %s\n", s)
+	}
+
+	return words, nil
+}
+
+// scanShellWords splits the runes into words, start is where the last word begins (len(rs) after a trailing space)
+// and open reports an unterminated quote
+func scanShellWords(rs []rune) (words []string, start int, open bool) {
+	var cur []rune
+	var quote rune
+	inWord := false
+
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != '\'' && r == '\\' && i+1 < len(rs):
+			i++
+			cur = append(cur, rs[i])
+		case quote != 0:
+			cur = append(cur, r)
+		case r == '"' || r == '\'':
+			if !inWord {
+				inWord, start = true, i
+			}
+
+			quote = r
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, string(cur))
+				cur, inWord = nil, false
+			}
+		default:
+			if !inWord {
+				inWord, start = true, i
+			}
+
+			cur = append(cur, r)
+		}
+	}
+
+	if inWord {
+		words = append(words, string(cur))
+	} else {
+		start = len(rs)
+	}
+
+	return words, start, quote != 0
+}
+
+// quoteShellWord quotes a word when it holds characters the shell would split on or interpret
+func quoteShellWord(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// resetFlags puts every flag of the command tree back to its default so one command's flags don't leak into the next
+func resetFlags(c *cobra.Command) {
+	reset := func(f *flag.Flag) {
+		f.Value.Set(f.DefValue)
+		f.Changed = false
+	}
+
+	c.Flags().VisitAll(reset)
+	c.PersistentFlags().VisitAll(reset)
+
+	for _, sub := range c.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// runShellCommand runs a line of arguments as a baton command in the current process and returns its exit code, the
+// shell and scripts use it
+func runShellCommand(args []string) int {
+	if c, _, err := rootCmd.Find(args); err == nil && c.CommandPath() == "baton shell" {
+		printError(nil, "The shell can't be started from the shell or a script\n")
+		return exitUsage
+	}
+
+	// Commands also fill the shared options themselves, ex. play sets the uris to play, the flags then put their
+	// defaults back
+	options = api.Options{}
+	playerOptions = api.PlayerOptions{}
+	searchOptions = api.SearchOptions{}
+	resetFlags(rootCmd)
+	rootCmd.SetArgs(args)
+
+	// Ctrl-C stops commands that wait for it, such as watch, without leaving the shell
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	cmd, err := rootCmd.ExecuteC()
+
+	if err != nil {
+		return reportError(cmd, err)
+	}
+
+	return exitOK
+}
+
+// remember records the artists of a track as recently seen
+func (s *shellSession) remember(artists ...string) {
+	for _, a := range artists {
+		for i, known := range s.artists {
+			if known == a {
+				s.artists = append(s.artists[:i], s.artists[i+1:]...)
+				break
+			}
+		}
+
+		s.artists = append([]string{a}, s.artists...)
+	}
+
+	if len(s.artists) > shellRecentLimit {
+		s.artists = s.artists[:shellRecentLimit]
+	}
+}
+
+// loadRecentArtists fills the recently seen artists from the local listening history
+func (s *shellSession) loadRecentArtists() {
+	entries, err := openHistory().Load()
+
+	if err != nil {
+		return
+	}
+
+	if len(entries) > shellRecentLimit {
+		entries = entries[len(entries)-shellRecentLimit:]
+	}
+
+	for _, e := range entries {
+		s.remember(e.Artists...)
+	}
+}
+
+// prompt shows the state of the player and the current track, it also records the artists that are playing
+func (s *shellSession) prompt() string {
+	ps, err := getCachedPlayerState(shellPromptTTL)
+
+	if err != nil || ps.Item == nil {
+		return "baton> "
+	}
+
+	for _, a := range ps.Item.Artists {
+		s.remember(a.Name)
+	}
+
+	state := "⏸"
+
+	if ps.IsPlaying {
+		state = "▶"
+	}
+
+	track := runewidth.Truncate(ps.Item.Name+" - "+artistNames(ps.Item.Artists), shellTrackWidth, "…")
+
+	return fmt.Sprintf("%s %s baton> ", state, track)
+}
+
+// deviceNames returns the names of the available devices and the device aliases of the config, the devices are
+// fetched again after 30 seconds
+func (s *shellSession) deviceNames() []string {
+	if time.Since(s.devicesAt) > 30*time.Second {
+		if devices, err := getPlayer().GetDevices(); err == nil {
+			s.devices, s.devicesAt = devices, time.Now()
+		}
+	}
+
+	return deviceCompletions(s.devices)
+}
+
+// playlistNames returns the names of the user's playlists, they're fetched the first time they're needed
+func (s *shellSession) playlistNames() []string {
+	if s.playlists == nil {
+		playlists, err := getAllMyPlaylists()
+
+		if err != nil {
+			return nil
+		}
+
+		s.playlists = []string{}
+
+		for _, p := range playlists {
+			s.playlists = append(s.playlists, p.Name)
+		}
+	}
+
+	return s.playlists
+}
+
+// lookupFlag finds a flag of the command by its long or short name, inherited flags included
+func lookupFlag(c *cobra.Command, name string) *flag.Flag {
+	for _, fs := range []*flag.FlagSet{c.LocalFlags(), c.InheritedFlags()} {
+		if strings.HasPrefix(name, "--") {
+			if f := fs.Lookup(strings.TrimPrefix(name, "--")); f != nil {
+				return f
+			}
+		} else if len(name) == 2 && name[0] == '-' {
+			if f := fs.ShorthandLookup(name[1:]); f != nil {
+				return f
+			}
+		}
+	}
+
+	return nil
+}
+
+// complete offers commands, flags, devices, artists and playlists for the word at the cursor
+func (s *shellSession) complete(line []rune, pos int) ([]string, int) {
+	words, start, _ := scanShellWords(line[:pos])
+	word := ""
+
+	if start < pos && len(words) > 0 {
+		word = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	// Find the command the words so far name, flags and their values are skipped
+	c := rootCmd
+	var path []string
+	var prevFlag *flag.Flag
+
+	for _, w := range words {
+		if prevFlag != nil {
+			prevFlag = nil
+			continue
+		}
+
+		if strings.HasPrefix(w, "-") {
+			if f := lookupFlag(c, w); f != nil && f.NoOptDefVal == "" && !strings.Contains(w, "=") {
+				prevFlag = f
+			}
+
+			continue
+		}
+
+		for _, sub := range c.Commands() {
+			if sub.Name() == w || sub.HasAlias(w) {
+				c = sub
+				path = append(path, sub.Name())
+				break
+			}
+		}
+	}
+
+	var options []string
+
+	switch {
+	case prevFlag != nil:
+		switch prevFlag.Name {
+		case "device":
+			options = s.deviceNames()
+		case "artist":
+			options = s.artists
+		case "output":
+			options = []string{"text", "json", "yaml", "tsv"}
+		}
+	case strings.HasPrefix(word, "-"):
+		add := func(f *flag.Flag) {
+			if !f.Hidden {
+				options = append(options, "--"+f.Name)
+			}
+		}
+
+		c.LocalFlags().VisitAll(add)
+		c.InheritedFlags().VisitAll(add)
+	default:
+		switch strings.Join(path, " ") {
+		case "play artist", "search artist":
+			options = s.artists
+		case "play playlist", "search playlist":
+			options = s.playlistNames()
+		case "transfer":
+			options = s.deviceNames()
+		default:
+			for _, sub := range c.Commands() {
+				if sub.IsAvailableCommand() || sub.Name() == "help" {
+					options = append(options, sub.Name())
+				}
+			}
+
+			if c == rootCmd {
+				options = append(options, "exit")
+			}
+		}
+	}
+
+	var candidates []string
+
+	for _, o := range options {
+		if strings.HasPrefix(strings.ToLower(o), strings.ToLower(word)) {
+			candidates = append(candidates, quoteShellWord(o))
+		}
+	}
+
+	sort.Strings(candidates)
+
+	return candidates, start
+}
+
+// shellHistoryPath is where the lines entered in the shell are kept
+func shellHistoryPath() string {
+	return filepath.Join(dataDir(), "shell_history")
+}
+
+func loadShellHistory(e *lineedit.Editor) {
+	f, err := os.Open(shellHistoryPath())
+
+	if err != nil {
+		return
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		e.AddHistory(scanner.Text())
+	}
+}
+
+// saveShellHistory rewrites the history file with the editor's history, which is already capped to its maximum size
+func saveShellHistory(e *lineedit.Editor) error {
+	if err := os.MkdirAll(dataDir(), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(shellHistoryPath(), []byte(strings.Join(e.History, "\n")+"\n"), 0600)
+}
+
+func runShell(cmd *cobra.Command, args []string) error {
+	s := &shellSession{}
+	s.loadRecentArtists()
+
+	e := lineedit.New(os.Stdin, os.Stdout)
+	e.Complete = s.complete
+	interactive := isTerminal(os.Stdin)
+
+	if interactive {
+		loadShellHistory(e)
+		fmt.Println("Type a baton command without \"baton\", \"help\" to list them and \"exit\" or Ctrl-D to leave")
+	}
+
+	for {
+		prompt := ""
+
+		if interactive {
+			prompt = s.prompt()
+		}
+
+		line, err := e.ReadLine(prompt)
+
+		if err == lineedit.ErrInterrupted {
+			continue
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return newError(err, "Couldn't read the command\n")
+		}
+
+		args, err := splitShellWords(line)
+
+		if err != nil {
+			reportError(cmd, err)
+			continue
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		if interactive {
+			e.AddHistory(line)
+			saveShellHistory(e)
+		}
+
+		if args[0] == "exit" || args[0] == "quit" {
+			break
+		}
+
+		runShellCommand(args)
+
+		// The command may have changed what's playing, the next prompt asks Spotify again
+		forgetPlayerState()
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(shellCmd)
+}
+
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Run baton commands in an interactive shell",
+	Long: `Run baton commands in an interactive shell without starting baton for each of them.
+
+Commands are typed without "baton" and quoted like in a shell. The prompt shows the current track, Tab completes
+commands, flags, device names, recently played artists and your playlists, the arrow keys browse the history kept in
+~/.config/baton/shell_history. Ctrl-C stops a running command such as watch, "exit" or Ctrl-D leaves the shell.`,
+	Args: cobra.NoArgs,
+	RunE: runShell,
+}
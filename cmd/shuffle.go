@@ -1,43 +1,70 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
-	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/utils"
 	"github.com/spf13/cobra"
 )
 
-func toggleShuffle(cmd *cobra.Command, args []string) {
-	ctx, err := api.GetPlayerState(&options)
+// The shuffleResult struct describes the shuffle state of the player
+type shuffleResult struct {
+	ShuffleState bool `json:"shuffle_state"`
+}
 
-	if err != nil {
-		fmt.Printf("Couldn't get the player state to retrieve shuffle status. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
-		return
+func toggleShuffle(cmd *cobra.Command, args []string) error {
+	var state bool
+
+	if len(args) > 0 {
+		state = args[0] == "on"
+	} else {
+		ctx, err := getPlayer().GetPlayerState(&options)
+
+		if err != nil {
+			return newError(err, "Couldn't get the player state to retrieve shuffle status. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
+		}
+
+		state = !ctx.ShuffleState
 	}
 
-	err = api.ToggleShuffle(!ctx.ShuffleState, &options)
+	err := getPlayer().ToggleShuffle(state, &options)
 
 	if err != nil {
-		fmt.Printf("Failed to toggle shuffle\n")
-		return
+		return newError(err, "Failed to toggle shuffle\n")
 	}
 
-	if ctx.ShuffleState {
-		fmt.Printf("Shuffle has been toggled off\n")
-	} else {
-		fmt.Printf("Shuffle has been toggled on\n")
-	}
+	printResult(shuffleResult{ShuffleState: state}, func() {
+		if state {
+			fmt.Printf("Shuffle has been turned on\n")
+		} else {
+			fmt.Printf("Shuffle has been turned off\n")
+		}
+	})
+
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(shuffleCmd)
 
-	shuffleCmd.Flags().StringVarP(&options.DeviceID, "device", "d", "", "id of the device this command is targeting")
+	shuffleCmd.Flags().StringVarP(&options.DeviceID, "device", "d", "", "name, id or alias of the device this command is targeting")
 }
 
 var shuffleCmd = &cobra.Command{
-	Use:   "shuffle",
+	Use:   "shuffle [on|off]",
 	Short: "Toggle shuffle on/off",
-	Long:  `Toggle shuffle on/off`,
-	Run:   toggleShuffle,
+	Long:  `Turn shuffle on or off, without a state it's toggled`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.MaximumNArgs(1)(cmd, args); err != nil {
+			return err
+		}
+
+		if len(args) > 0 && !utils.StringInSlice(args[0], []string{"on", "off"}) {
+			return errors.New("State must be on or off")
+		}
+
+		return nil
+	},
+	RunE: toggleShuffle,
 }
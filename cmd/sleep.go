@@ -0,0 +1,677 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/daemon"
+	"github.com/firstlane/baton/utils"
+	"github.com/spf13/cobra"
+)
+
+// Methods of the daemon's sleep timer
+const (
+	methodStartSleepTimer  = "sleep.start"
+	methodSleepTimerStatus = "sleep.status"
+	methodCancelSleepTimer = "sleep.cancel"
+)
+
+// sleepPollInterval is how often the player is checked while waiting for the end of a track or context
+const sleepPollInterval = 5 * time.Second
+
+var sleepFade time.Duration
+var sleepAfterTrack bool
+var sleepAfterContext bool
+var sleepForeground bool
+
+// errSleepCancelled is returned when the sleep timer is cancelled before it paused the player
+var errSleepCancelled = errors.New("sleep timer cancelled")
+
+// The sleepTimer struct describes when the sleep timer pauses the player and how long the volume takes to fade out
+type sleepTimer struct {
+	Duration     time.Duration `json:"duration,omitempty"`
+	Fade         time.Duration `json:"fade,omitempty"`
+	AfterTrack   bool          `json:"after_track,omitempty"`
+	AfterContext bool          `json:"after_context,omitempty"`
+}
+
+// The sleepStatus struct describes the sleep timer running in the daemon
+type sleepStatus struct {
+	Active    bool       `json:"active"`
+	Mode      string     `json:"mode,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	FadeMs    int64      `json:"fade_ms,omitempty"`
+}
+
+// sleepPlayer is the part of the player the sleep timer uses
+type sleepPlayer interface {
+	GetPlayerState(opts *api.Options) (api.PlayerState, error)
+	SetVolume(vol int, opts *api.Options) error
+	PausePlayback(opts *api.Options) error
+}
+
+// The sleepRun struct describes a sleep timer being run
+type sleepRun struct {
+	timer  sleepTimer
+	player sleepPlayer
+	// lastTrack returns the last track of the context that is playing, "" when it can't be known
+	lastTrack func(ps api.PlayerState) (string, error)
+	// sleep waits for the duration and returns false when the timer is cancelled
+	sleep     func(d time.Duration) bool
+	startedAt time.Time
+	track     string
+	context   string
+
+	// The volume before the fade, restored once the player is paused
+	fading  bool
+	volume  int
+	options *api.Options
+}
+
+// mode names what the timer waits for
+func (t sleepTimer) mode() string {
+	switch {
+	case t.AfterTrack:
+		return "after-track"
+	case t.AfterContext:
+		return "after-context"
+	}
+
+	return "duration"
+}
+
+// status describes the timer, the end is only known in advance for a duration
+func (r *sleepRun) status() sleepStatus {
+	s := sleepStatus{Active: true, Mode: r.timer.mode(), StartedAt: &r.startedAt, FadeMs: int64(r.timer.Fade / time.Millisecond)}
+
+	if r.timer.Duration > 0 {
+		end := r.startedAt.Add(r.timer.Duration)
+		s.EndsAt = &end
+	}
+
+	return s
+}
+
+// contextLastTrack returns the uri of the last track of the album or playlist that is playing, it can't be known
+// for other contexts or with shuffle on
+func contextLastTrack(ps api.PlayerState) (string, error) {
+	if ps.Context == nil || ps.ShuffleState {
+		return "", nil
+	}
+
+	parts := strings.Split(ps.Context.URI, ":")
+	id := parts[len(parts)-1]
+
+	switch ps.Context.Type {
+	case "album":
+		res, err := api.GetTracksForAlbum(id)
+
+		for err == nil && res.Next != "" {
+			res, err = api.GetNextTracksForAlbum(res.Next)
+		}
+
+		if err != nil || len(res.Items) == 0 {
+			return "", err
+		}
+
+		return res.Items[len(res.Items)-1].URI, nil
+	case "playlist":
+		p, err := api.GetPlaylist(id)
+
+		if err != nil {
+			return "", err
+		}
+
+		tracks, err := getAllPlaylistTracks(p)
+
+		if err != nil || len(tracks) == 0 {
+			return "", err
+		}
+
+		return tracks[len(tracks)-1].Track.URI, nil
+	}
+
+	return "", nil
+}
+
+// contextURI returns the uri of the context of the player state, "" when there's none
+func contextURI(ps api.PlayerState) string {
+	if ps.Context == nil {
+		return ""
+	}
+
+	return ps.Context.URI
+}
+
+// trackEnd returns when the track that is playing will be over
+func trackEnd(ps api.PlayerState, now time.Time) time.Time {
+	return now.Add(time.Duration(ps.Item.DurationMs-ps.ProgressMs) * time.Millisecond)
+}
+
+// getState fetches the player state, no active device is reported as a stopped player
+func (r *sleepRun) getState() (api.PlayerState, error) {
+	ps, err := r.player.GetPlayerState(nil)
+
+	if err == api.ErrNoActiveDevice {
+		return api.PlayerState{}, nil
+	}
+
+	return ps, err
+}
+
+// begin starts the timer, the track and context that must end are the ones playing now
+func (r *sleepRun) begin() error {
+	r.startedAt = time.Now()
+
+	if r.timer.AfterTrack || r.timer.AfterContext {
+		ps, err := r.getState()
+
+		if err != nil {
+			return err
+		}
+
+		if !ps.IsPlaying || ps.Item == nil {
+			return newError(errNotFound, "Nothing is playing\n")
+		}
+
+		r.track, r.context = ps.Item.URI, contextURI(ps)
+	}
+
+	return nil
+}
+
+// run waits for the end of the timer, fades the volume out, pauses the player and restores the volume
+func (r *sleepRun) run() error {
+	var last string
+	var lastFetched bool
+
+	for {
+		now := time.Now()
+		var end time.Time
+
+		if r.timer.Duration > 0 {
+			end = r.startedAt.Add(r.timer.Duration)
+		} else {
+			ps, err := r.getState()
+
+			if err != nil {
+				// A failed poll is tried again, the timer must not give up while the music is still playing
+				fmt.Fprintf(os.Stderr, "Couldn't get the player state, retrying: %s\n", err)
+
+				if !r.sleep(sleepPollInterval) {
+					return r.cancel()
+				}
+
+				continue
+			}
+
+			stopped := ps.Item == nil || (!ps.IsPlaying && ps.ProgressMs == 0)
+
+			if r.timer.AfterTrack {
+				if stopped || ps.Item.URI != r.track {
+					break
+				}
+
+				if ps.IsPlaying {
+					end = trackEnd(ps, now)
+				}
+			} else {
+				if stopped || contextURI(ps) != r.context {
+					break
+				}
+
+				if !lastFetched {
+					if last, err = r.lastTrack(ps); err == nil {
+						lastFetched = true
+					}
+				}
+
+				if ps.IsPlaying && last != "" && ps.Item.URI == last {
+					end = trackEnd(ps, now)
+				}
+			}
+		}
+
+		wait := sleepPollInterval
+
+		if !end.IsZero() {
+			if !now.Before(end) {
+				break
+			}
+
+			if !now.Before(end.Add(-r.timer.Fade)) {
+				if err := r.fade(end.Sub(now)); err != nil {
+					return err
+				}
+
+				wait = end.Sub(now)
+
+				if step := r.ramp().step(); wait > step {
+					wait = step
+				}
+			} else {
+				wait = end.Add(-r.timer.Fade).Sub(now)
+			}
+
+			// The end of a track moves when it's paused, skipped or seeked so it's checked again regularly
+			if r.timer.Duration == 0 && wait > sleepPollInterval {
+				wait = sleepPollInterval
+			}
+		}
+
+		if !r.sleep(wait) {
+			return r.cancel()
+		}
+	}
+
+	return r.pause()
+}
+
+// ramp is the fade out of the volume the player had when the fade began
+func (r *sleepRun) ramp() volumeRamp {
+	return volumeRamp{from: r.volume, over: r.timer.Fade}
+}
+
+// fade lowers the volume in proportion to the time left, the volume it started from is remembered to restore it
+func (r *sleepRun) fade(left time.Duration) error {
+	if !r.fading {
+		ps, err := r.getState()
+
+		if err != nil {
+			return err
+		}
+
+		// Nothing to fade while the player is paused or can't change its volume
+		if !ps.IsPlaying || ps.Device == nil {
+			return nil
+		}
+
+		r.fading = true
+		r.volume = ps.Device.VolumePercent
+		r.options = &api.Options{DeviceID: ps.Device.ID}
+	}
+
+	return r.player.SetVolume(r.ramp().at(r.timer.Fade-left), r.options)
+}
+
+// pause pauses the player when it's playing and restores the volume from before the fade
+func (r *sleepRun) pause() error {
+	ps, err := r.getState()
+
+	if err != nil {
+		return err
+	}
+
+	if ps.IsPlaying {
+		err = r.player.PausePlayback(r.options)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.fading {
+		return r.player.SetVolume(r.volume, r.options)
+	}
+
+	return nil
+}
+
+// cancel restores the volume when the timer is cancelled during the fade
+func (r *sleepRun) cancel() error {
+	if r.fading {
+		if err := r.player.SetVolume(r.volume, r.options); err != nil {
+			return err
+		}
+	}
+
+	return errSleepCancelled
+}
+
+// The daemonSleepPlayer struct describes the player of the daemon's sleep timer, the calls go through the daemon's
+// cache and lock
+type daemonSleepPlayer struct {
+	d *playerDaemon
+}
+
+func (p daemonSleepPlayer) GetPlayerState(opts *api.Options) (api.PlayerState, error) {
+	return p.d.state()
+}
+
+func (p daemonSleepPlayer) SetVolume(vol int, opts *api.Options) error {
+	return p.d.control(func() error { return api.SetVolume(vol, opts) })
+}
+
+func (p daemonSleepPlayer) PausePlayback(opts *api.Options) error {
+	return p.d.control(func() error { return api.PausePlayback(opts) })
+}
+
+// The daemonSleepTimer struct describes the sleep timer the daemon is running
+type daemonSleepTimer struct {
+	mu     sync.Mutex
+	run    *sleepRun
+	cancel chan bool
+	done   chan bool
+}
+
+// start runs a timer in the background, it replaces the timer that was running
+func (t *daemonSleepTimer) start(d *playerDaemon, timer sleepTimer) (sleepStatus, error) {
+	t.stop()
+
+	cancel, done := make(chan bool), make(chan bool)
+	r := &sleepRun{
+		timer:  timer,
+		player: daemonSleepPlayer{d: d},
+		lastTrack: func(ps api.PlayerState) (string, error) {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+
+			return contextLastTrack(ps)
+		},
+		sleep: func(d time.Duration) bool {
+			select {
+			case <-cancel:
+				return false
+			case <-time.After(d):
+				return true
+			}
+		},
+	}
+
+	if err := r.begin(); err != nil {
+		return sleepStatus{}, err
+	}
+
+	t.mu.Lock()
+	t.run, t.cancel, t.done = r, cancel, done
+	t.mu.Unlock()
+
+	go func() {
+		err := r.run()
+
+		if err != nil && err != errSleepCancelled {
+			fmt.Fprintf(os.Stderr, "The sleep timer failed: %s\n", err)
+		}
+
+		t.mu.Lock()
+
+		if t.run == r {
+			t.run = nil
+		}
+
+		t.mu.Unlock()
+		close(done)
+	}()
+
+	return r.status(), nil
+}
+
+// status describes the running timer
+func (t *daemonSleepTimer) status() sleepStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.run == nil {
+		return sleepStatus{}
+	}
+
+	return t.run.status()
+}
+
+// stop cancels the running timer and waits for the volume to be restored, it reports whether a timer was running
+func (t *daemonSleepTimer) stop() bool {
+	t.mu.Lock()
+	r, cancel, done := t.run, t.cancel, t.done
+	t.run = nil
+	t.mu.Unlock()
+
+	if r == nil {
+		return false
+	}
+
+	close(cancel)
+	<-done
+
+	return true
+}
+
+// registerSleepTimer adds the sleep timer methods to the daemon
+func (d *playerDaemon) registerSleepTimer(s *daemon.Server) {
+	s.Handle(methodStartSleepTimer, func(params json.RawMessage) (interface{}, error) {
+		var timer sleepTimer
+
+		if err := json.Unmarshal(params, &timer); err != nil {
+			return nil, daemon.InvalidParams(err)
+		}
+
+		if err := timer.validate(); err != nil {
+			return nil, daemon.InvalidParams(err)
+		}
+
+		return d.sleepTimer.start(d, timer)
+	})
+	s.Handle(methodSleepTimerStatus, func(params json.RawMessage) (interface{}, error) {
+		return d.sleepTimer.status(), nil
+	})
+	s.Handle(methodCancelSleepTimer, func(params json.RawMessage) (interface{}, error) {
+		return d.sleepTimer.stop(), nil
+	})
+}
+
+// validate checks that the timer waits for exactly one thing
+func (t sleepTimer) validate() error {
+	n := 0
+
+	for _, set := range []bool{t.Duration > 0, t.AfterTrack, t.AfterContext} {
+		if set {
+			n++
+		}
+	}
+
+	if n != 1 {
+		return fmt.Errorf("give a duration, --after-track or --after-context")
+	}
+
+	if t.Fade < 0 {
+		return fmt.Errorf("the fade can't be negative")
+	}
+
+	return nil
+}
+
+// describe tells when the timer pauses the player
+func (t sleepTimer) describe() string {
+	var when string
+
+	switch t.mode() {
+	case "after-track":
+		when = "at the end of the track"
+	case "after-context":
+		when = "at the end of the album or playlist"
+	default:
+		when = "in " + t.Duration.String()
+	}
+
+	if t.Fade > 0 {
+		return fmt.Sprintf("Pausing %s, fading out over %s", when, t.Fade)
+	}
+
+	return "Pausing " + when
+}
+
+func startSleepTimer(cmd *cobra.Command, args []string) error {
+	timer := sleepTimer{Fade: sleepFade, AfterTrack: sleepAfterTrack, AfterContext: sleepAfterContext}
+
+	if len(args) > 0 {
+		d, err := utils.ParseDuration(args[0])
+
+		if err != nil || d <= 0 {
+			return newUsageError("Invalid duration %q, ex. 30m, 1h15m or 1:15:00\n", args[0])
+		}
+
+		timer.Duration = d
+	}
+
+	if err := timer.validate(); err != nil {
+		return newUsageError("Invalid sleep timer, %s\n", err)
+	}
+
+	// The daemon keeps the timer running after baton exits
+	if _, ok := getPlayer().(daemonPlayer); ok && !sleepForeground {
+		c, err := dialDaemon()
+
+		if err != nil {
+			return newError(err, "Couldn't connect to the daemon\n")
+		}
+
+		defer c.Close()
+
+		var status sleepStatus
+
+		if err := c.Call(methodStartSleepTimer, timer, &status); err != nil {
+			return newError(err, "Couldn't start the sleep timer in the daemon\n")
+		}
+
+		printResult(status, func() {
+			fmt.Printf("%s, 'baton sleep cancel' stops the timer\n", timer.describe())
+		})
+
+		return nil
+	}
+
+	sleep, stop := newInterruptibleSleep()
+	defer stop()
+
+	r := &sleepRun{timer: timer, player: getPlayer(), lastTrack: contextLastTrack, sleep: sleep}
+	err := r.begin()
+
+	if err == nil {
+		if isTextOutput() {
+			fmt.Fprintf(os.Stderr, "%s, Ctrl-C cancels\n", timer.describe())
+		}
+
+		err = r.run()
+	}
+
+	if err == errSleepCancelled {
+		printMessage("Cancelled the sleep timer\n")
+		return nil
+	}
+
+	if err != nil {
+		if _, ok := err.(*cmdError); ok {
+			return err
+		}
+
+		return newError(err, "The sleep timer failed\n")
+	}
+
+	printMessage("Spotify has been paused\n")
+
+	return nil
+}
+
+func getSleepTimerStatus(cmd *cobra.Command, args []string) error {
+	c, err := dialDaemon()
+
+	if err != nil {
+		return newError(errNotFound, "The daemon isn't running, only its sleep timers keep running in the background\n")
+	}
+
+	defer c.Close()
+
+	var status sleepStatus
+
+	if err := c.Call(methodSleepTimerStatus, nil, &status); err != nil {
+		return newError(err, "Couldn't get the sleep timer from the daemon\n")
+	}
+
+	printResult(status, func() {
+		switch {
+		case !status.Active:
+			fmt.Println("No sleep timer is running")
+		case status.EndsAt != nil:
+			fmt.Printf("Pausing at %s (in %s)\n", status.EndsAt.Format("15:04:05"), time.Until(*status.EndsAt).Round(time.Second))
+		case status.Mode == "after-track":
+			fmt.Println("Pausing at the end of the track")
+		default:
+			fmt.Println("Pausing at the end of the album or playlist")
+		}
+	})
+
+	return nil
+}
+
+func cancelSleepTimer(cmd *cobra.Command, args []string) error {
+	c, err := dialDaemon()
+
+	if err != nil {
+		return newError(errNotFound, "The daemon isn't running, only its sleep timers keep running in the background\n")
+	}
+
+	defer c.Close()
+
+	var cancelled bool
+
+	if err := c.Call(methodCancelSleepTimer, nil, &cancelled); err != nil {
+		return newError(err, "Couldn't cancel the sleep timer\n")
+	}
+
+	if !cancelled {
+		return newError(errNotFound, "No sleep timer is running\n")
+	}
+
+	printMessage("Cancelled the sleep timer\n")
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(sleepCmd)
+	sleepCmd.AddCommand(sleepStatusCmd)
+	sleepCmd.AddCommand(sleepCancelCmd)
+
+	durationVar(sleepCmd.Flags(), &sleepFade, "fade", 0, "lower the volume gradually over this time before pausing (ex. 2m)")
+	sleepCmd.Flags().BoolVar(&sleepAfterTrack, "after-track", false, "pause at the end of the current track")
+	sleepCmd.Flags().BoolVar(&sleepAfterContext, "after-context", false, "pause at the end of the current album or playlist")
+	sleepCmd.Flags().BoolVarP(&sleepForeground, "foreground", "f", false, "wait in this process even when the daemon is running")
+}
+
+var sleepCmd = &cobra.Command{
+	Use:   "sleep [duration]",
+	Short: "Pause the player after a while, fading the volume out",
+	Long: `Pause the player after the duration (ex. 30m), at the end of the current track with --after-track or at the end of
+the current album or playlist with --after-context. With --fade the volume goes down gradually over the end of that
+time and it's restored once the player is paused, ready for next time.
+
+When the daemon is running it keeps the timer and baton exits right away, 'baton sleep status' shows the timer and
+'baton sleep cancel' stops it. Otherwise baton waits until the player is paused and Ctrl-C cancels the timer.
+
+The end of an album or playlist is only known in advance with shuffle off, otherwise the player is paused when the
+context is over and there's no fade.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: startSleepTimer,
+}
+
+var sleepStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the sleep timer running in the daemon",
+	Long:  `Show the sleep timer running in the daemon`,
+	Args:  cobra.NoArgs,
+	RunE:  getSleepTimerStatus,
+}
+
+var sleepCancelCmd = &cobra.Command{
+	Use:   "cancel",
+	Short: "Cancel the sleep timer running in the daemon",
+	Long:  `Cancel the sleep timer running in the daemon, the volume is restored if it was fading out`,
+	Args:  cobra.NoArgs,
+	RunE:  cancelSleepTimer,
+}
@@ -0,0 +1,388 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/filter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// The smartPlaylist struct describes a smart playlist defined in the smart_playlists section of the config
+type smartPlaylist struct {
+	Name        string   `mapstructure:"name" json:"name"`
+	Description string   `mapstructure:"description" json:"description,omitempty"`
+	Sources     []string `mapstructure:"sources" json:"sources"`
+	Filter      string   `mapstructure:"filter" json:"filter"`
+	Limit       int      `mapstructure:"limit" json:"limit,omitempty"`
+	Public      bool     `mapstructure:"public" json:"public"`
+	PlaylistID  string   `mapstructure:"playlist_id" json:"playlist_id,omitempty"`
+}
+
+var smartDryRun bool
+var smartSyncAll bool
+
+func getSmartPlaylists() (map[string]smartPlaylist, error) {
+	sps := make(map[string]smartPlaylist)
+	err := viper.UnmarshalKey("smart_playlists", &sps)
+
+	for k, sp := range sps {
+		if sp.Name == "" {
+			sp.Name = k
+		}
+
+		if len(sp.Sources) == 0 {
+			sp.Sources = []string{"saved"}
+		}
+
+		sps[k] = sp
+	}
+
+	return sps, err
+}
+
+// collectSmartPlaylistItems gathers the candidate tracks from every source of the smart playlist, the first occurrence of a track wins
+func collectSmartPlaylistItems(sp smartPlaylist, needGenres bool) ([]filter.Item, error) {
+	var items []filter.Item
+	seen := make(map[string]bool)
+	genres := make(map[string][]string)
+
+	add := func(t api.FullTrack, i filter.Item) {
+		if t.URI == "" || seen[t.URI] || strings.HasPrefix(t.URI, "spotify:local:") {
+			return
+		}
+
+		seen[t.URI] = true
+		track := t
+		i.Track = &track
+		items = append(items, i)
+	}
+
+	for _, source := range sp.Sources {
+		switch {
+		case source == "saved":
+			tracks, err := getAllSavedTracks()
+
+			if err != nil {
+				return nil, err
+			}
+
+			for _, t := range tracks {
+				add(t.Track, filter.Item{AddedAt: t.AddedAt, Source: "saved"})
+			}
+		case source == "followed":
+			artists, err := getAllFollowedArtists()
+
+			if err != nil {
+				return nil, err
+			}
+
+			for _, a := range artists {
+				genres[a.ID] = a.Genres
+
+				tracks, err := api.GetTopTracksForArtist(a.ID, "")
+
+				if err != nil {
+					return nil, err
+				}
+
+				for _, t := range tracks {
+					add(t, filter.Item{Source: "followed"})
+				}
+			}
+		case source == "playlists" || strings.HasPrefix(source, "playlist:"):
+			var playlists []api.SimplePlaylist
+
+			if source == "playlists" {
+				ps, err := getAllMyPlaylists()
+
+				if err != nil {
+					return nil, err
+				}
+
+				playlists = ps
+			} else {
+				p, err := getPlaylistFromArg(strings.TrimPrefix(source, "playlist:"))
+
+				if err != nil {
+					return nil, err
+				}
+
+				playlists = append(playlists, p)
+			}
+
+			for _, p := range playlists {
+				// Never feed a smart playlist with its own tracks
+				if p.ID == sp.PlaylistID {
+					continue
+				}
+
+				tracks, err := getAllPlaylistTracks(p)
+
+				if err != nil {
+					return nil, err
+				}
+
+				for _, t := range tracks {
+					add(t.Track, filter.Item{AddedAt: t.AddedAt, Source: p.Name})
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown source '%s', sources can be saved, followed, playlists or playlist:<uri>", source)
+		}
+	}
+
+	if needGenres {
+		var ids []string
+
+		for _, i := range items {
+			for _, a := range i.Track.Artists {
+				ids = append(ids, a.ID)
+			}
+		}
+
+		err := getArtistGenres(ids, genres)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for k := range items {
+			for _, a := range items[k].Track.Artists {
+				items[k].Genres = append(items[k].Genres, genres[a.ID]...)
+			}
+		}
+	}
+
+	return items, nil
+}
+
+func findOrCreateSmartPlaylist(key string, sp smartPlaylist) (string, error) {
+	if sp.PlaylistID != "" {
+		return sp.PlaylistID, nil
+	}
+
+	p, err := findOrCreateMyPlaylist(&api.PlaylistDetails{
+		Name:        sp.Name,
+		Description: sp.Description,
+		Public:      sp.Public,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	// Remember the playlist so renaming it in Spotify doesn't create a new one on the next sync
+	return p.ID, saveConfigValue("smart_playlists."+key+".playlist_id", p.ID)
+}
+
+// The smartSyncResult struct describes the outcome of syncing a smart playlist
+type smartSyncResult struct {
+	Name       string          `json:"name"`
+	PlaylistID string          `json:"playlist_id,omitempty"`
+	Candidates int             `json:"candidates"`
+	Matched    int             `json:"matched"`
+	Tracks     []api.FullTrack `json:"tracks,omitempty"`
+}
+
+func syncSmartPlaylist(key string, sp smartPlaylist) (res smartSyncResult, err error) {
+	res.Name = sp.Name
+
+	expr, err := filter.Parse(sp.Filter)
+
+	if err != nil {
+		return res, newUsageError("Invalid filter for smart playlist '%s': %s", key, err)
+	}
+
+	candidates, err := collectSmartPlaylistItems(sp, expr.Uses("genre"))
+
+	if err != nil {
+		return res, newError(err, "Couldn't gather tracks for smart playlist '%s'", key)
+	}
+
+	var uris []string
+
+	for k := range candidates {
+		if expr.Match(&candidates[k]) {
+			res.Tracks = append(res.Tracks, *candidates[k].Track)
+			uris = append(uris, candidates[k].Track.URI)
+		}
+	}
+
+	if sp.Limit > 0 && len(uris) > sp.Limit {
+		res.Tracks = res.Tracks[:sp.Limit]
+		uris = uris[:sp.Limit]
+	}
+
+	res.Candidates = len(candidates)
+	res.Matched = len(uris)
+
+	if smartDryRun {
+		return res, nil
+	}
+
+	// Only dry runs list the tracks, a sync can easily contain thousands of them
+	res.Tracks = nil
+
+	res.PlaylistID, err = findOrCreateSmartPlaylist(key, sp)
+
+	if err != nil {
+		return res, newError(err, "Couldn't find or create the playlist for smart playlist '%s'", key)
+	}
+
+	_, err = replacePlaylistTracks(res.PlaylistID, uris)
+
+	if err != nil {
+		return res, newError(err, "Couldn't update the tracks of smart playlist '%s'", key)
+	}
+
+	return res, nil
+}
+
+func syncSmartPlaylists(cmd *cobra.Command, args []string) error {
+	var results []smartSyncResult
+	var failed []error
+
+	sps, err := getSmartPlaylists()
+
+	if err != nil {
+		return newError(err, "Couldn't read the smart_playlists section of your config\n")
+	}
+
+	if smartSyncAll {
+		args = nil
+		for k := range sps {
+			args = append(args, k)
+		}
+		sort.Strings(args)
+	}
+
+	for _, name := range args {
+		sp, ok := sps[strings.ToLower(name)]
+
+		if !ok {
+			failed = append(failed, newError(errNotFound, "No smart playlist named '%s' in your config\n", name))
+			continue
+		}
+
+		res, err := syncSmartPlaylist(strings.ToLower(name), sp)
+
+		if err != nil {
+			failed = append(failed, err)
+			continue
+		}
+
+		results = append(results, res)
+	}
+
+	printResult(results, func() {
+		for _, res := range results {
+			if smartDryRun {
+				for _, t := range res.Tracks {
+					fmt.Printf("%s - %s\n", artistNames(t.Artists), t.Name)
+				}
+
+				fmt.Printf("%d of %d tracks match smart playlist '%s'\n", res.Matched, res.Candidates, res.Name)
+			} else {
+				fmt.Printf("Synced smart playlist '%s' with %d tracks\n", res.Name, res.Matched)
+			}
+		}
+	})
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	// Every failure is reported but the exit code comes from the last one
+	for _, err := range failed[:len(failed)-1] {
+		reportError(cmd, err)
+	}
+
+	return failed[len(failed)-1]
+}
+
+func listSmartPlaylists(cmd *cobra.Command, args []string) error {
+	sps, err := getSmartPlaylists()
+
+	if err != nil {
+		return newError(err, "Couldn't read the smart_playlists section of your config\n")
+	}
+
+	if len(sps) == 0 && isTextOutput() {
+		fmt.Printf("No smart playlists defined, add them to the smart_playlists section of %s\n", viper.ConfigFileUsed())
+		return nil
+	}
+
+	var keys []string
+	for k := range sps {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	printResult(sps, func() {
+		var o []string
+		for _, k := range keys {
+			sp := sps[k]
+			o = append(o, fmt.Sprintf("Name: %s\nPlaylist: %s\nSources: %s\nFilter: %s\n", k, sp.Name, strings.Join(sp.Sources, ", "), sp.Filter))
+		}
+		fmt.Print(strings.Join(o, "\n"))
+	})
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(smartCmd)
+	smartCmd.AddCommand(smartListCmd)
+	smartCmd.AddCommand(smartSyncCmd)
+
+	smartSyncCmd.Flags().BoolVar(&smartDryRun, "dry-run", false, "print the matching tracks instead of updating the playlist")
+	smartSyncCmd.Flags().BoolVarP(&smartSyncAll, "all", "a", false, "sync every smart playlist in the config")
+}
+
+var smartCmd = &cobra.Command{
+	Use:   "smart",
+	Short: "Rule-based playlists generated from your library",
+	Long: `Rule-based playlists generated from your library
+
+Smart playlists are defined in the smart_playlists section of the config, for example:
+
+  "smart_playlists": {
+    "fresh": {
+      "name": "Fresh and short",
+      "sources": ["saved", "followed"],
+      "filter": "age < 30d && genre ~ \"house\" && duration < 5m && !explicit",
+      "limit": 100
+    }
+  }
+
+Sources can be saved, followed (top tracks of followed artists), playlists (all of your playlists) or playlist:<uri>.`,
+}
+
+var smartListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the smart playlists defined in the config",
+	Long:  `List the smart playlists defined in the config`,
+	RunE:  listSmartPlaylists,
+}
+
+var smartSyncCmd = &cobra.Command{
+	Use:   "sync [name...]",
+	Short: "Create or update the Spotify playlist for a smart playlist",
+	Long: `Create or update the Spotify playlist for a smart playlist
+
+Filters compare fields with ==, !=, <, <=, >, >=, ~ (contains) and !~ (doesn't contain) and combine them with &&, || and !
+Fields: ` + strings.Join(filter.Fields(), ", ") + `
+Durations (duration, age) are written like 90s, 4m30s, 30d or 3:30`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && !smartSyncAll {
+			return errors.New("Specify the smart playlists to sync or use --all")
+		}
+		return nil
+	},
+	RunE: syncSmartPlaylists,
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"net"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// listenPrivateSocket listens on a Unix socket only the user can connect to, the socket is created with a umask that
+// leaves nobody else any permission so there's no moment when another user could connect
+func listenPrivateSocket(path string) (net.Listener, error) {
+	old := unix.Umask(0077)
+	defer unix.Umask(old)
+
+	return net.Listen("unix", path)
+}
+
+// ownedByUser reports whether the file belongs to the user running baton
+func ownedByUser(path string) (bool, error) {
+	fi, err := os.Lstat(path)
+
+	if err != nil {
+		return false, err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+
+	return ok && int(st.Uid) == os.Getuid(), nil
+}
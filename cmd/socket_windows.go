@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"net"
+	"os"
+)
+
+// listenPrivateSocket listens on a Unix socket, on Windows the socket is as private as the directory it's created in
+func listenPrivateSocket(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
+
+// ownedByUser isn't implemented on Windows, files only need to exist
+func ownedByUser(path string) (bool, error) {
+	_, err := os.Lstat(path)
+	return err == nil, err
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/firstlane/baton/api"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// The cachedPlayerState struct is stored in the cache directory so commands polled by status bars can share a single Spotify request
+type cachedPlayerState struct {
+	FetchedAt time.Time       `json:"fetched_at"`
+	NoDevice  bool            `json:"no_device,omitempty"`
+	State     api.PlayerState `json:"state"`
+}
+
+func playerStateCachePath() (string, error) {
+	dir := os.Getenv("XDG_CACHE_HOME")
+
+	if dir == "" {
+		home, err := homedir.Dir()
+
+		if err != nil {
+			return "", err
+		}
+
+		dir = filepath.Join(home, ".cache")
+	}
+
+	return filepath.Join(dir, "baton", "player_state.json"), nil
+}
+
+// getCachedPlayerState returns the player state fetched by any baton process within the last ttl, the progress of a cached state is moved forward by the time that passed since it was fetched
+func getCachedPlayerState(ttl time.Duration) (api.PlayerState, error) {
+	if ttl <= 0 {
+		return getPlayer().GetPlayerState(nil)
+	}
+
+	path, err := playerStateCachePath()
+
+	if err != nil {
+		return getPlayer().GetPlayerState(nil)
+	}
+
+	var c cachedPlayerState
+
+	if b, err := ioutil.ReadFile(path); err == nil && json.Unmarshal(b, &c) == nil {
+		age := time.Since(c.FetchedAt)
+
+		if age >= 0 && age < ttl {
+			if c.NoDevice {
+				return c.State, api.ErrNoActiveDevice
+			}
+
+			return interpolatePlayerState(c.State, age), nil
+		}
+	}
+
+	ps, err := getPlayer().GetPlayerState(nil)
+
+	if err != nil && err != api.ErrNoActiveDevice {
+		return ps, err
+	}
+
+	// The cache is only an optimization, failing to write it shouldn't fail the command
+	writePlayerStateCache(path, cachedPlayerState{FetchedAt: time.Now(), NoDevice: err != nil, State: ps})
+
+	return ps, err
+}
+
+// forgetPlayerState removes the cached player state, the next read asks Spotify again
+func forgetPlayerState() {
+	if path, err := playerStateCachePath(); err == nil {
+		os.Remove(path)
+	}
+}
+
+// interpolatePlayerState advances the progress of a playing track by elapsed without going past the end of the track
+func interpolatePlayerState(ps api.PlayerState, elapsed time.Duration) api.PlayerState {
+	if !ps.IsPlaying || ps.Item == nil {
+		return ps
+	}
+
+	ps.ProgressMs += int(elapsed / time.Millisecond)
+
+	if ps.ProgressMs > ps.Item.DurationMs {
+		ps.ProgressMs = ps.Item.DurationMs
+	}
+
+	return ps
+}
+
+func writePlayerStateCache(path string, c cachedPlayerState) error {
+	b, err := json.Marshal(c)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so concurrent readers never see a partial cache
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "player_state")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(b)
+	tmp.Close()
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
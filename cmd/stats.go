@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/firstlane/baton/history"
+	"github.com/spf13/cobra"
+)
+
+// heatmapShades are the characters used to draw the heatmap from no listening to the busiest hour
+var heatmapShades = []rune(" ░▒▓█")
+
+var statsLimit int
+
+// The statsResult struct describes a listening statistics report, only the fields of the requested report are set
+type statsResult struct {
+	Since         *time.Time       `json:"since,omitempty"`
+	Until         *time.Time       `json:"until,omitempty"`
+	Plays         int              `json:"plays"`
+	Skips         int              `json:"skips"`
+	ListenedMs    int64            `json:"listened_ms"`
+	TopArtists    []history.Count  `json:"top_artists,omitempty"`
+	TopTracks     []history.Count  `json:"top_tracks,omitempty"`
+	TopAlbums     []history.Count  `json:"top_albums,omitempty"`
+	TopGenres     []history.Count  `json:"top_genres,omitempty"`
+	MostSkipped   []history.Count  `json:"most_skipped,omitempty"`
+	Heatmap       *history.Heatmap `json:"heatmap,omitempty"`
+	MinutesPerDay map[string]int   `json:"minutes_per_day,omitempty"`
+	CurrentStreak *history.Streak  `json:"current_streak,omitempty"`
+	LongestStreak *history.Streak  `json:"longest_streak,omitempty"`
+}
+
+// loadStats reads the plays (and skips) matching the query flags and fills in the totals of a report
+func loadStats() ([]history.Entry, statsResult, error) {
+	var res statsResult
+
+	q, err := getHistoryQuery(0)
+
+	if err != nil {
+		return nil, res, newUsageError("%s\n", err)
+	}
+
+	q.Skipped = true
+
+	entries, err := openHistory().Load()
+
+	if err != nil {
+		return nil, res, newError(err, "Couldn't read the listening history\n")
+	}
+
+	entries = history.Filter(entries, q)
+
+	if len(entries) == 0 {
+		return nil, res, newError(errNotFound, "No plays found, run 'baton history sync' or enable history in the config and run 'baton watch' to record them\n")
+	}
+
+	if !q.Since.IsZero() {
+		res.Since = &q.Since
+	}
+
+	if !q.Until.IsZero() {
+		res.Until = &q.Until
+	}
+
+	for _, e := range entries {
+		if e.Skipped {
+			res.Skips++
+			continue
+		}
+
+		res.Plays++
+		res.ListenedMs += int64(e.Listened() / time.Millisecond)
+	}
+
+	return entries, res, nil
+}
+
+// formatListened formats a listening time in hours and minutes, ex. 12h 05m
+func formatListened(ms int64) string {
+	m := (ms + 30000) / 60000
+
+	if m < 60 {
+		return fmt.Sprintf("%dm", m)
+	}
+
+	return fmt.Sprintf("%dh %02dm", m/60, m%60)
+}
+
+func printStatsTotals(res statsResult) {
+	period := "all time"
+
+	switch {
+	case res.Since != nil && res.Until != nil:
+		period = fmt.Sprintf("%s to %s", res.Since.Format("2006-01-02 15:04"), res.Until.Format("2006-01-02 15:04"))
+	case res.Since != nil:
+		period = fmt.Sprintf("since %s", res.Since.Format("2006-01-02 15:04"))
+	case res.Until != nil:
+		period = fmt.Sprintf("until %s", res.Until.Format("2006-01-02 15:04"))
+	}
+
+	fmt.Printf("%d plays, %s listened (%s)\n", res.Plays, formatListened(res.ListenedMs), period)
+}
+
+func printCounts(title, unit string, counts []history.Count) {
+	if len(counts) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%s\n", title)
+
+	for k, c := range counts {
+		fmt.Printf("%3d. %s (%d %s, %s)\n", k+1, c.Name, c.Plays, unit, formatListened(c.ListenedMs))
+	}
+}
+
+func printStreaks(current, longest history.Streak) {
+	fmt.Printf("\nCurrent streak: %d days", current.Days)
+
+	if current.Days > 0 {
+		fmt.Printf(" (since %s)", current.Start)
+	}
+
+	fmt.Printf("\nLongest streak: %d days", longest.Days)
+
+	if longest.Days > 0 {
+		fmt.Printf(" (%s to %s)", longest.Start, longest.End)
+	}
+
+	fmt.Printf("\n")
+}
+
+// renderHeatmap draws the minutes listened per weekday and hour, each cell is shaded relative to the busiest hour
+func renderHeatmap(h history.Heatmap) string {
+	max := 0
+
+	for d := range h.Minutes {
+		for hr := range h.Minutes[d] {
+			if h.Minutes[d][hr] > max {
+				max = h.Minutes[d][hr]
+			}
+		}
+	}
+
+	var b strings.Builder
+
+	b.WriteString("     ")
+
+	for hr := 0; hr < 24; hr += 3 {
+		fmt.Fprintf(&b, "%-6d", hr)
+	}
+
+	b.WriteString("\n")
+
+	// Weeks start on Monday, time.Weekday starts on Sunday
+	for i := 1; i <= 7; i++ {
+		d := time.Weekday(i % 7)
+		total := 0
+
+		fmt.Fprintf(&b, "%s  ", d.String()[:3])
+
+		for _, m := range h.Minutes[d] {
+			shade := 0
+
+			if m > 0 {
+				// Any listening gets at least the lightest shade so it can't be mistaken for none
+				shade = 1 + m*(len(heatmapShades)-2)/max
+			}
+
+			b.WriteString(strings.Repeat(string(heatmapShades[shade]), 2))
+			total += m
+		}
+
+		fmt.Fprintf(&b, "  %s\n", formatListened(int64(total)*60000))
+	}
+
+	return b.String()
+}
+
+func reportStatsOverview(cmd *cobra.Command, args []string) error {
+	entries, res, err := loadStats()
+
+	if err != nil {
+		return err
+	}
+
+	current, longest := history.Streaks(entries, time.Local, time.Now())
+	res.TopArtists = history.TopArtists(entries, statsLimit)
+	res.TopTracks = history.TopTracks(entries, statsLimit)
+	res.TopAlbums = history.TopAlbums(entries, statsLimit)
+	res.CurrentStreak = &current
+	res.LongestStreak = &longest
+
+	printResult(res, func() {
+		printStatsTotals(res)
+		printCounts("Top artists", "plays", res.TopArtists)
+		printCounts("Top tracks", "plays", res.TopTracks)
+		printCounts("Top albums", "plays", res.TopAlbums)
+		printStreaks(current, longest)
+	})
+
+	return nil
+}
+
+// newStatsCountCmd returns a subcommand printing one of the top lists
+func newStatsCountCmd(use, short, title, unit string, count func([]history.Entry, *statsResult, int) ([]history.Count, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  short,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, res, err := loadStats()
+
+			if err != nil {
+				return err
+			}
+
+			counts, err := count(entries, &res, statsLimit)
+
+			if err != nil {
+				return err
+			}
+
+			printResult(res, func() {
+				printStatsTotals(res)
+				printCounts(title, unit, counts)
+			})
+
+			return nil
+		},
+	}
+}
+
+// countGenres looks up the genres of every artist in the entries, Spotify doesn't tag tracks or albums with genres
+func countGenres(entries []history.Entry, res *statsResult, n int) ([]history.Count, error) {
+	var ids []string
+
+	for _, e := range entries {
+		for _, uri := range e.ArtistURIs {
+			ids = append(ids, strings.TrimPrefix(uri, "spotify:artist:"))
+		}
+	}
+
+	known := make(map[string][]string)
+
+	err := getArtistGenres(ids, known)
+
+	if err != nil {
+		return nil, newError(err, "Couldn't look up the genres of your artists\n")
+	}
+
+	genres := make(map[string][]string)
+
+	for id, g := range known {
+		genres["spotify:artist:"+id] = g
+	}
+
+	res.TopGenres = history.TopGenres(entries, genres, n)
+
+	return res.TopGenres, nil
+}
+
+func reportStatsHeatmap(cmd *cobra.Command, args []string) error {
+	entries, res, err := loadStats()
+
+	if err != nil {
+		return err
+	}
+
+	h := history.ListeningHeatmap(entries, time.Local)
+	res.Heatmap = &h
+	res.MinutesPerDay = history.ListeningPerDay(entries, time.Local)
+
+	printResult(res, func() {
+		printStatsTotals(res)
+		fmt.Printf("\n%s", renderHeatmap(h))
+	})
+
+	return nil
+}
+
+func reportStatsStreaks(cmd *cobra.Command, args []string) error {
+	entries, res, err := loadStats()
+
+	if err != nil {
+		return err
+	}
+
+	current, longest := history.Streaks(entries, time.Local, time.Now())
+	res.CurrentStreak = &current
+	res.LongestStreak = &longest
+
+	printResult(res, func() {
+		printStatsTotals(res)
+		printStreaks(current, longest)
+	})
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.AddCommand(newStatsCountCmd("artists", "Show the artists you listened to the most", "Top artists", "plays", func(entries []history.Entry, res *statsResult, n int) ([]history.Count, error) {
+		res.TopArtists = history.TopArtists(entries, n)
+		return res.TopArtists, nil
+	}))
+	statsCmd.AddCommand(newStatsCountCmd("tracks", "Show the tracks you listened to the most", "Top tracks", "plays", func(entries []history.Entry, res *statsResult, n int) ([]history.Count, error) {
+		res.TopTracks = history.TopTracks(entries, n)
+		return res.TopTracks, nil
+	}))
+	statsCmd.AddCommand(newStatsCountCmd("albums", "Show the albums you listened to the most", "Top albums", "plays", func(entries []history.Entry, res *statsResult, n int) ([]history.Count, error) {
+		res.TopAlbums = history.TopAlbums(entries, n)
+		return res.TopAlbums, nil
+	}))
+	statsCmd.AddCommand(newStatsCountCmd("genres", "Show the genres you listened to the most, based on the genres of the artists", "Top genres", "plays", countGenres))
+	statsCmd.AddCommand(newStatsCountCmd("skipped", "Show the tracks you skipped the most within 30 seconds, recorded by 'baton watch'", "Most skipped", "skips", func(entries []history.Entry, res *statsResult, n int) ([]history.Count, error) {
+		res.MostSkipped = history.MostSkipped(entries, n)
+		return res.MostSkipped, nil
+	}))
+	statsCmd.AddCommand(statsHeatmapCmd)
+	statsCmd.AddCommand(statsStreaksCmd)
+
+	statsCmd.PersistentFlags().StringVar(&historySince, "since", "", "only plays from this time on (2006-01-02, 2006-01-02 15:04, today, yesterday or a duration ago like 30d)")
+	statsCmd.PersistentFlags().StringVar(&historyUntil, "until", "", "only plays before this time, same formats as --since")
+	statsCmd.PersistentFlags().StringVar(&historyArtist, "artist", "", "only plays of artists whose name or uri contains this")
+	statsCmd.PersistentFlags().StringVar(&historyContext, "context", "", "only plays from a context (playlist, album, artist) whose uri or type contains this")
+	statsCmd.PersistentFlags().IntVarP(&statsLimit, "limit", "l", 10, "length of the top lists, 0 shows all of them")
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show statistics about your local listening history",
+	Long: `Show statistics about your local listening history: the total listening time, your top artists, tracks and albums and
+your listening streaks. The subcommands show a single report, every report accepts --since and --until to pick the period,
+ex. 'baton stats artists --since 2024-01-01 --until 2025-01-01' or 'baton stats --since 7d'.
+
+Use --output json to feed the reports to a dashboard.`,
+	Args: cobra.NoArgs,
+	RunE: reportStatsOverview,
+}
+
+var statsHeatmapCmd = &cobra.Command{
+	Use:   "heatmap",
+	Short: "Show when you listen to music as a weekday and hour heatmap",
+	Long:  `Show when you listen to music as a weekday and hour heatmap, the JSON output also has the minutes listened per day`,
+	Args:  cobra.NoArgs,
+	RunE:  reportStatsHeatmap,
+}
+
+var statsStreaksCmd = &cobra.Command{
+	Use:   "streaks",
+	Short: "Show your current and longest run of days with music",
+	Long:  `Show your current and longest run of days with music`,
+	Args:  cobra.NoArgs,
+	RunE:  reportStatsStreaks,
+}
@@ -3,56 +3,165 @@ package cmd
 import (
 	"fmt"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/firstlane/baton/api"
 	"github.com/firstlane/baton/utils"
+	runewidth "github.com/mattn/go-runewidth"
 	"github.com/spf13/cobra"
 )
 
-func reportStatus(cmd *cobra.Command, args []string) {
-	ctx, err := api.GetPlayerState(nil)
+var statusFormat string
+var statusMaxWidth int
+var statusCacheTTL time.Duration
+var statusWatch bool
+
+// The statusFields struct describes the values available to `status --format` templates
+type statusFields struct {
+	Track       string
+	Artist      string
+	Album       string
+	URI         string
+	Progress    string
+	Duration    string
+	ProgressMs  int
+	DurationMs  int
+	Device      string
+	DeviceType  string
+	Volume      int
+	Shuffle     bool
+	Repeat      string
+	Context     string
+	ContextType string
+	IsPlaying   bool
+	State       string
+}
+
+func newStatusFields(ps api.PlayerState) statusFields {
+	f := statusFields{
+		ProgressMs: ps.ProgressMs,
+		Progress:   utils.MillisecondsToFormattedTime(ps.ProgressMs),
+		Shuffle:    ps.ShuffleState,
+		Repeat:     ps.RepeatState,
+		IsPlaying:  ps.IsPlaying,
+		State:      "Paused",
+	}
+
+	if ps.IsPlaying {
+		f.State = "Playing"
+	}
+
+	if ps.Item != nil {
+		f.Track = ps.Item.Name
+		f.Artist = artistNames(ps.Item.Artists)
+		f.Album = albumName(ps.Item.Album)
+		f.URI = ps.Item.URI
+		f.DurationMs = ps.Item.DurationMs
+		f.Duration = utils.MillisecondsToFormattedTime(ps.Item.DurationMs)
+	}
+
+	if ps.Device != nil {
+		f.Device = ps.Device.Name
+		f.DeviceType = ps.Device.Type
+		f.Volume = ps.Device.VolumePercent
+	}
+
+	if ps.Context != nil {
+		f.Context = ps.Context.URI
+		f.ContextType = ps.Context.Type
+	}
+
+	return f
+}
+
+// truncateLines shortens every line of s to at most width terminal cells, counting wide characters (CJK, emoji) as two cells
+func truncateLines(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+
+	lines := strings.Split(s, "\n")
+
+	for k, l := range lines {
+		lines[k] = runewidth.Truncate(l, width, "…")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func reportStatus(cmd *cobra.Command, args []string) error {
+	if statusWatch {
+		return watchStatus()
+	}
+
+	ctx, err := getCachedPlayerState(statusCacheTTL)
 
 	if err != nil {
-		fmt.Printf("Couldn't get the player state. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't get the player state. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
 	}
 
-	if ctx.Item != nil {
-		track := ctx.Item.Name
-		album := ctx.Item.Album.Name
-		var playingState string
-		var artistNames []string
+	if ctx.Item == nil {
+		return newError(errNotFound, "Couldn't find information about the current status\n")
+	}
+
+	f := newStatusFields(ctx)
+
+	if statusFormat != "" {
+		t, err := template.New("status").Funcs(templateFuncs).Parse(statusFormat)
 
-		if ctx.IsPlaying {
-			playingState = "Playing"
-		} else {
-			playingState = "Paused"
+		if err != nil {
+			return newUsageError("Invalid --format template: %s\n", err)
 		}
 
-		for _, artist := range ctx.Item.Artists {
-			artistNames = append(artistNames, artist.Name)
+		var sb strings.Builder
+
+		err = t.Execute(&sb, f)
+
+		if err != nil {
+			return newUsageError("Couldn't execute the --format template: %s\n", err)
 		}
 
-		progress := utils.MillisecondsToFormattedTime(ctx.ProgressMs)
-		duration := utils.MillisecondsToFormattedTime(ctx.Item.DurationMs)
+		fmt.Println(truncateLines(strings.TrimRight(sb.String(), "\n"), statusMaxWidth))
 
-		fmt.Printf("Track: %s\n", track)
-		fmt.Printf("Artist: %s\n", strings.Join(artistNames, ", "))
-		fmt.Printf("Album: %s\n", album)
-		fmt.Printf("Time Elapsed: %s - %s\n", progress, duration)
-		fmt.Printf("State: %s\n", playingState)
-	} else {
-		fmt.Printf("Couldn't find information about the current status\n")
+		return nil
 	}
+
+	printResult(ctx, func() {
+		o := fmt.Sprintf("Track: %s\n", f.Track) +
+			fmt.Sprintf("Artist: %s\n", f.Artist) +
+			fmt.Sprintf("Album: %s\n", f.Album) +
+			fmt.Sprintf("Time Elapsed: %s - %s\n", f.Progress, f.Duration) +
+			fmt.Sprintf("State: %s\n", f.State)
+
+		fmt.Print(truncateLines(o, statusMaxWidth))
+	})
+
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().StringVarP(&statusFormat, "format", "f", "", "go template for the status line (ex. '{{.Artist}} - {{.Track}} [{{.Progress}}/{{.Duration}}]')")
+	statusCmd.Flags().IntVar(&statusMaxWidth, "max-width", 0, "truncate each line to this many terminal columns, 0 disables truncation")
+	statusCmd.Flags().BoolVarP(&statusWatch, "watch", "w", false, "keep updating the status until interrupted, prints a line per track change when stdout isn't a terminal")
+	statusCmd.Flags().DurationVar(&statusCacheTTL, "cache-ttl", time.Second, "reuse a player state fetched by another baton process within this duration, 0 always asks Spotify")
 }
 
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show information about the current track",
-	Long:  `Show information about the current track`,
-	Run:   reportStatus,
+	Long: `Show information about the current track
+
+Use --format to print a single line for status bars (tmux, polybar, i3blocks, etc), for example:
+
+  baton status --format '{{.Artist}} - {{.Track}} [{{.Progress}}/{{.Duration}}]' --max-width 60
+
+Use --watch to keep the status on screen, it redraws in place in a terminal and prints one line per track change when piped:
+
+  baton status --watch | while read -r line; do notify-send "$line"; done
+
+Fields: Track, Artist, Album, URI, Progress, Duration, ProgressMs, DurationMs, Device, DeviceType, Volume, Shuffle, Repeat, Context, ContextType, IsPlaying and State (Playing or Paused)`,
+	RunE: reportStatus,
 }
@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminalWidth returns the number of columns of the terminal attached to f, or 0 when f isn't a terminal
+func terminalWidth(f *os.File) int {
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+
+	if err != nil {
+		return 0
+	}
+
+	return int(ws.Col)
+}
@@ -0,0 +1,8 @@
+package cmd
+
+import "os"
+
+// terminalWidth isn't implemented on Windows, callers fall back to a default width
+func terminalWidth(f *os.File) int {
+	return 0
+}
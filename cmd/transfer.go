@@ -4,41 +4,74 @@ import (
 	"fmt"
 
 	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/ui"
 	"github.com/spf13/cobra"
 )
 
-func transferDevice(cmd *cobra.Command, args []string) {
-	p := api.TransferOptions{DeviceIDs: args, Play: true}
-	err := api.TransferPlayback(&p)
+var transferNoPlay bool
+
+// pickDevice lets the user choose the device to transfer playback to in the CUI
+func pickDevice() error {
+	devices, err := getPlayer().GetDevices()
 
 	if err != nil {
-		fmt.Printf("Couldn't transfer playback. Is the device ID supplied correct? Is Spotify active on that device?  Have you authenticated with the 'auth' command?\n")
-		return
+		return newError(err, "Couldn't retrieve devices. Is Spotify active on a device?  Have you authenticated with the 'auth' command?\n")
+	}
+
+	if len(devices) == 0 {
+		return newError(errNotFound, "No devices currently available\n")
 	}
 
-	devices, err := api.GetDevices()
+	err = ui.Run(ui.NewDeviceTable(devices, !transferNoPlay, volumeStep()))
 
 	if err != nil {
-		fmt.Printf("Transferred playback successfully\n")
-	} else {
-		for _, d := range devices {
-			if d.ID == args[0] {
-				fmt.Printf("Transferred playback to %s '%s'\n", d.Type, d.Name)
-				return
-			}
+		return newError(err, "Couldn't start the interactive browser\n")
+	}
+
+	return nil
+}
+
+func transferDevice(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		if !isTextOutput() {
+			return newUsageError("Give the device to transfer playback to, the device picker only works with text output\n")
 		}
-		fmt.Printf("Transferred playback successfully\n")
+
+		return pickDevice()
 	}
+
+	d, err := lookupDevice(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	p := api.TransferOptions{DeviceIDs: []string{d.ID}, Play: !transferNoPlay}
+	err = getPlayer().TransferPlayback(&p)
+
+	if err != nil {
+		return newError(err, "Couldn't transfer playback. Is Spotify active on that device?  Have you authenticated with the 'auth' command?\n")
+	}
+
+	d.IsActive = true
+	printResult(d, func() {
+		fmt.Printf("Transferred playback to %s '%s'\n", d.Type, d.Name)
+	})
+
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(transferCmd)
+
+	transferCmd.Flags().BoolVar(&transferNoPlay, "no-play", false, "move playback without starting it on the device")
 }
 
 var transferCmd = &cobra.Command{
 	Use:   "transfer [device]",
-	Short: "Transfer playback to another device by id",
-	Long:  `Transfer playback to another device by id`,
-	Run:   transferDevice,
-	Args:  cobra.ExactArgs(1),
+	Short: "Transfer playback to another device",
+	Long: `Transfer playback to another device, given by its id, its name or the start of its name ignoring case, or an
+alias from device_aliases in the config. Without a device the devices are listed in the CUI to pick one.`,
+	RunE: transferDevice,
+	Args: cobra.MaximumNArgs(1),
 }
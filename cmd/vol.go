@@ -2,104 +2,398 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/firstlane/baton/api"
 	"github.com/firstlane/baton/utils"
 	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
 )
 
-func increaseVolume(cmd *cobra.Command, args []string) {
-	ctx, err := api.GetPlayerState(&options)
+// volumeRampStep is the shortest time between two volume changes of a fade, it keeps the calls under the rate limit
+const volumeRampStep = time.Second
+
+// volumeArg matches the volume given to vol and vol fade, a sign makes it relative to the current volume
+var volumeArg = regexp.MustCompile(`^[+-]?\d+$`)
+
+var volumeFadeOver time.Duration
+
+// getVolumeDevice returns the device targeted by the vol commands, making sure it supports changing the volume
+func getVolumeDevice() (*api.Device, error) {
+	ctx, err := getPlayer().GetPlayerState(&options)
 
 	if err != nil {
-		fmt.Printf("Couldn't get the player state to retrieve current volume information\n")
-	} else {
-		if ctx.Device != nil {
-			if utils.StringInSlice(ctx.Device.Type, []string{"CastVideo", "Phone"}) {
-				fmt.Printf("Can't get/set volume for %s '%s', this type of device doesn't support volume commands in the web api beta\n", ctx.Device.Type, ctx.Device.Name)
-			} else {
-				v := ctx.Device.VolumePercent + 10
+		return nil, newError(err, "Couldn't get the player state to retrieve current volume information\n")
+	}
 
-				if v > 100 {
-					v = 100
-				}
+	if ctx.Device == nil {
+		return nil, newError(api.ErrNoActiveDevice, "No device currently playing\n")
+	}
 
-				err = api.SetVolume(v, &options)
+	if utils.StringInSlice(ctx.Device.Type, []string{"CastVideo", "Phone"}) {
+		return nil, newError(nil, "Can't get/set volume for %s '%s', this type of device doesn't support volume commands in the web api beta\n", ctx.Device.Type, ctx.Device.Name)
+	}
 
-				if err != nil {
-					fmt.Printf("Failed to set volume\n")
-				} else {
-					fmt.Printf("Volume for %s '%s' increased to %d%%\n", ctx.Device.Type, ctx.Device.Name, v)
-				}
-			}
+	return ctx.Device, nil
+}
+
+// volumeStep is how much vol up and vol down change the volume, volume.step in the config overrides it
+func volumeStep() int {
+	if s := viper.GetInt("volume.step"); s > 0 {
+		return s
+	}
+
+	return 10
+}
+
+// parseVolume reads an absolute volume or, with a sign, a change of the current one, the result is kept within 0-100
+func parseVolume(s string, current int) (int, error) {
+	if !volumeArg.MatchString(s) {
+		return 0, newUsageError("Volume must be a number between 0-100, or a change such as +5 or -5\n")
+	}
+
+	v, _ := strconv.Atoi(s)
+
+	if s[0] == '+' || s[0] == '-' {
+		v += current
+	} else if v > 100 {
+		return 0, newUsageError("Volume must be a number between 0-100, or a change such as +5 or -5\n")
+	}
+
+	if v < 0 {
+		v = 0
+	}
+
+	if v > 100 {
+		v = 100
+	}
+
+	return v, nil
+}
+
+// setDeviceVolume changes the volume of the device and prints the result
+func setDeviceVolume(d *api.Device, v int, verb string) error {
+	err := getPlayer().SetVolume(v, &options)
+
+	if err != nil {
+		return newError(err, "Failed to set volume\n")
+	}
+
+	d.VolumePercent = v
+	printResult(d, func() {
+		fmt.Printf("Volume for %s '%s' %s %d%%\n", d.Type, d.Name, verb, v)
+	})
+
+	return nil
+}
+
+// stepArg reads the optional step of vol up and vol down
+func stepArg(args []string) (int, error) {
+	if len(args) == 0 {
+		return volumeStep(), nil
+	}
+
+	s, err := strconv.Atoi(args[0])
+
+	if err != nil || s < 0 || s > 100 {
+		return 0, newUsageError("The step must be a number between 0-100\n")
+	}
+
+	return s, nil
+}
+
+func increaseVolume(cmd *cobra.Command, args []string) error {
+	step, err := stepArg(args)
+
+	if err != nil {
+		return err
+	}
+
+	d, err := getVolumeDevice()
+
+	if err != nil {
+		return err
+	}
+
+	v, _ := parseVolume("+"+strconv.Itoa(step), d.VolumePercent)
+
+	return setDeviceVolume(d, v, "increased to")
+}
+
+func decreaseVolume(cmd *cobra.Command, args []string) error {
+	step, err := stepArg(args)
+
+	if err != nil {
+		return err
+	}
+
+	d, err := getVolumeDevice()
+
+	if err != nil {
+		return err
+	}
+
+	v, _ := parseVolume("-"+strconv.Itoa(step), d.VolumePercent)
+
+	return setDeviceVolume(d, v, "decreased to")
+}
+
+// parseFlagsKeepingArgs parses the flags of a command that disables cobra's flag parsing because some of its
+// arguments look like flags, such as the -5 of vol -5. The arguments matching keep are never taken for flags.
+func parseFlagsKeepingArgs(cmd *cobra.Command, args []string, keep *regexp.Regexp) ([]string, error) {
+	var kept, rest []string
+
+	for i, a := range args {
+		isValue := i > 0 && (args[i-1] == "-d" || args[i-1] == "--device")
+
+		if keep.MatchString(a) && !isValue {
+			kept = append(kept, a)
 		} else {
-			fmt.Printf("No device currently playing\n")
+			rest = append(rest, a)
 		}
 	}
+
+	fs := flag.NewFlagSet(cmd.Name(), flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.AddFlagSet(cmd.LocalFlags())
+	fs.AddFlagSet(cmd.InheritedFlags())
+
+	if err := fs.Parse(rest); err != nil {
+		return nil, cmd.FlagErrorFunc()(cmd, err)
+	}
+
+	if err := validateOutputFormat(); err != nil {
+		return nil, err
+	}
+
+	if err := resolveDeviceFlags(); err != nil {
+		return nil, err
+	}
+
+	return append(fs.Args(), kept...), nil
 }
 
-func decreaseVolume(cmd *cobra.Command, args []string) {
-	ctx, err := api.GetPlayerState(&options)
+func getSetVolume(cmd *cobra.Command, args []string) error {
+	args, err := parseFlagsKeepingArgs(cmd, args, volumeArg)
 
 	if err != nil {
-		fmt.Printf("Couldn't get the player state to retrieve current volume information\n")
-	} else {
-		if ctx.Device != nil {
-			if utils.StringInSlice(ctx.Device.Type, []string{"CastVideo", "Phone"}) {
-				fmt.Printf("Can't get/set volume for %s '%s', this type of device doesn't support volume commands in the web api beta\n", ctx.Device.Type, ctx.Device.Name)
-			} else {
-				v := ctx.Device.VolumePercent - 10
+		return err
+	}
 
-				if v < 0 {
-					v = 0
-				}
+	if help, _ := cmd.LocalFlags().GetBool("help"); help {
+		return cmd.Help()
+	}
 
-				err = api.SetVolume(v, &options)
+	if len(args) > 1 {
+		return asUsageError(fmt.Errorf("accepts at most 1 arg(s), received %d", len(args)))
+	}
 
-				if err != nil {
-					fmt.Printf("Failed to set volume\n")
-				} else {
-					fmt.Printf("Volume for %s '%s' decreased to %d%%.\n", ctx.Device.Type, ctx.Device.Name, v)
-				}
-			}
-		} else {
-			fmt.Printf("No device currently playing\n")
+	d, err := getVolumeDevice()
+
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		printResult(d, func() {
+			fmt.Printf("Volume for %s %s is %d%%\n", d.Type, d.Name, d.VolumePercent)
+		})
+
+		return nil
+	}
+
+	v, err := parseVolume(args[0], d.VolumePercent)
+
+	if err != nil {
+		return err
+	}
+
+	return setDeviceVolume(d, v, "changed to")
+}
+
+// mutedVolumesPath is where the volume of muted devices is kept until they're unmuted
+func mutedVolumesPath() string {
+	return filepath.Join(dataDir(), "muted.json")
+}
+
+func muteVolume(cmd *cobra.Command, args []string) error {
+	d, err := getVolumeDevice()
+
+	if err != nil {
+		return err
+	}
+
+	if d.VolumePercent == 0 {
+		return newUsageError("%s '%s' is already muted\n", d.Type, d.Name)
+	}
+
+	if err := os.MkdirAll(dataDir(), 0700); err != nil {
+		return newError(err, "Couldn't remember the volume\n")
+	}
+
+	err = utils.UpdateJSONFile(mutedVolumesPath(), func(m map[string]interface{}) {
+		m[d.ID] = d.VolumePercent
+	})
+
+	if err != nil {
+		return newError(err, "Couldn't remember the volume\n")
+	}
+
+	err = getPlayer().SetVolume(0, &options)
+
+	if err != nil {
+		return newError(err, "Failed to mute\n")
+	}
+
+	prior := d.VolumePercent
+	d.VolumePercent = 0
+	printResult(d, func() {
+		fmt.Printf("Muted %s '%s', 'baton vol unmute' puts the volume back to %d%%\n", d.Type, d.Name, prior)
+	})
+
+	return nil
+}
+
+func unmuteVolume(cmd *cobra.Command, args []string) error {
+	d, err := getVolumeDevice()
+
+	if err != nil {
+		return err
+	}
+
+	v := -1
+
+	err = utils.UpdateJSONFile(mutedVolumesPath(), func(m map[string]interface{}) {
+		if prior, ok := m[d.ID].(float64); ok {
+			v = int(prior)
+			delete(m, d.ID)
 		}
+	})
+
+	if err != nil {
+		return newError(err, "Couldn't read the volume from before muting\n")
 	}
+
+	if v < 0 {
+		if d.VolumePercent > 0 {
+			return newUsageError("%s '%s' isn't muted\n", d.Type, d.Name)
+		}
+
+		// Muted some other way, the step gives it back some volume
+		v = volumeStep()
+	}
+
+	return setDeviceVolume(d, v, "unmuted at")
 }
 
-func getSetVolume(cmd *cobra.Command, args []string) {
-	ctx, err := api.GetPlayerState(&options)
+func fadeVolume(cmd *cobra.Command, args []string) error {
+	args, err := parseFlagsKeepingArgs(cmd, args, volumeArg)
+
+	if err != nil {
+		return err
+	}
+
+	if help, _ := cmd.LocalFlags().GetBool("help"); help {
+		return cmd.Help()
+	}
+
+	if len(args) != 1 {
+		return asUsageError(fmt.Errorf("accepts 1 arg(s), received %d", len(args)))
+	}
+
+	if volumeFadeOver < 0 {
+		return newUsageError("--over can't be negative\n")
+	}
+
+	d, err := getVolumeDevice()
 
 	if err != nil {
-		fmt.Printf("Couldn't get the player state to retrieve current volume information\n")
-	} else {
-		if ctx.Device != nil {
-			if utils.StringInSlice(ctx.Device.Type, []string{"CastVideo", "Phone"}) {
-				fmt.Printf("Can't get/set volume for %s '%s', this type of device doesn't support volume commands in the web api beta\n", ctx.Device.Type, ctx.Device.Name)
-			} else {
-				if len(args) > 0 {
-					p, err := strconv.Atoi(args[0])
+		return err
+	}
+
+	target, err := parseVolume(args[0], d.VolumePercent)
+
+	if err != nil {
+		return err
+	}
+
+	sleep, stop := newInterruptibleSleep()
+	defer stop()
+
+	// Ctrl-C leaves the volume where the fade got to
+	ramp := volumeRamp{from: d.VolumePercent, to: target, over: volumeFadeOver}
+	v, err := ramp.run(func(v int) error { return getPlayer().SetVolume(v, &options) }, sleep)
+
+	if err != nil {
+		return newError(err, "Failed to set volume\n")
+	}
+
+	d.VolumePercent = v
+	printResult(d, func() {
+		fmt.Printf("Volume for %s '%s' faded to %d%%\n", d.Type, d.Name, v)
+	})
+
+	return nil
+}
+
+// The volumeRamp struct describes a fade of the volume, used by vol fade, the fade out of sleep timers and the fade in of alarms
+type volumeRamp struct {
+	from, to int
+	over     time.Duration
+}
+
+// at returns the volume the ramp reached after elapsed
+func (r volumeRamp) at(elapsed time.Duration) int {
+	if elapsed >= r.over {
+		return r.to
+	}
 
-					if err != nil {
-						fmt.Printf("Volume must be a number between 0-100\n")
-						return
-					}
+	return r.from + int(int64(r.to-r.from)*int64(elapsed)/int64(r.over))
+}
+
+// step returns the time between two volume changes, the volume changes by about one percent at a time
+func (r volumeRamp) step() time.Duration {
+	step := r.over
+	diff := r.to - r.from
+
+	if diff < 0 {
+		diff = -diff
+	}
 
-					err = api.SetVolume(p, &options)
+	if diff > 0 {
+		step /= time.Duration(diff)
+	}
 
-					if err != nil {
-						fmt.Printf("Failed to set volume\n")
-					} else {
-						fmt.Printf("Volume for %s '%s' changed to %s%%\n", ctx.Device.Type, ctx.Device.Name, args[0])
-					}
-				} else {
-					fmt.Printf("Volume for %s %s is %d%%\n", ctx.Device.Type, ctx.Device.Name, ctx.Device.VolumePercent)
-				}
+	if step < volumeRampStep {
+		step = volumeRampStep
+	}
+
+	return step
+}
+
+// run changes the volume with set until the ramp is over, waiting between two changes with sleep. The ramp stops
+// early when sleep returns false, run returns the last volume set.
+func (r volumeRamp) run(set func(v int) error, sleep func(time.Duration) bool) (int, error) {
+	began := time.Now()
+	last := r.from
+
+	for {
+		if v := r.at(time.Since(began)); v != last {
+			if err := set(v); err != nil {
+				return last, err
 			}
-		} else {
-			fmt.Printf("No device currently playing")
+
+			last = v
+		}
+
+		if last == r.to || !sleep(r.step()) {
+			return last, nil
 		}
 	}
 }
@@ -108,29 +402,63 @@ func init() {
 	rootCmd.AddCommand(volumeCmd)
 	volumeCmd.AddCommand(volumeUpCmd)
 	volumeCmd.AddCommand(volumeDownCmd)
+	volumeCmd.AddCommand(volumeMuteCmd)
+	volumeCmd.AddCommand(volumeUnmuteCmd)
+	volumeCmd.AddCommand(volumeFadeCmd)
 
-	volumeCmd.PersistentFlags().StringVarP(&options.DeviceID, "device", "d", "", "id of the device this command is targeting")
+	volumeCmd.PersistentFlags().StringVarP(&options.DeviceID, "device", "d", "", "name, id or alias of the device this command is targeting")
+	durationVar(volumeFadeCmd.Flags(), &volumeFadeOver, "over", 5*time.Second, "how long the fade takes")
 }
 
 var volumeCmd = &cobra.Command{
-	Use:     "vol [0-100]",
-	Short:   "Get/Set volume",
-	Long:    `Get/Set volume`,
-	Args:    cobra.MaximumNArgs(1),
-	Run:     getSetVolume,
-	Aliases: []string{"volume"},
+	Use:   "vol [0-100|+N|-N]",
+	Short: "Get/Set volume",
+	Long: `Get the volume, set it (ex. vol 40) or change it (ex. vol +5, vol -5).
+
+The volume of phones and cast video devices can't be changed through the web api.`,
+	RunE: getSetVolume,
+	// The flags are parsed by getSetVolume so a volume change such as -5 isn't taken for a flag
+	DisableFlagParsing: true,
+	Aliases:            []string{"volume"},
 }
 
 var volumeUpCmd = &cobra.Command{
-	Use:   "up",
-	Short: "Increase volume by 10%",
-	Long:  `Increase volume by 10%`,
-	Run:   increaseVolume,
+	Use:   "up [step]",
+	Short: "Increase volume by 10% or the given step",
+	Long:  `Increase volume by the given step, by volume.step in the config (10% by default) otherwise`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  increaseVolume,
 }
 
 var volumeDownCmd = &cobra.Command{
-	Use:   "down",
-	Short: "Decrease volume by 10%",
-	Long:  `Decrease volume by 10%`,
-	Run:   decreaseVolume,
+	Use:   "down [step]",
+	Short: "Decrease volume by 10% or the given step",
+	Long:  `Decrease volume by the given step, by volume.step in the config (10% by default) otherwise`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  decreaseVolume,
+}
+
+var volumeMuteCmd = &cobra.Command{
+	Use:   "mute",
+	Short: "Mute the device, remembering its volume",
+	Long:  `Set the volume of the device to 0, its volume is remembered for 'baton vol unmute'`,
+	Args:  cobra.NoArgs,
+	RunE:  muteVolume,
+}
+
+var volumeUnmuteCmd = &cobra.Command{
+	Use:   "unmute",
+	Short: "Put the volume back to where it was before muting",
+	Long:  `Put the volume of the device back to where it was before 'baton vol mute'`,
+	Args:  cobra.NoArgs,
+	RunE:  unmuteVolume,
+}
+
+var volumeFadeCmd = &cobra.Command{
+	Use:   "fade <0-100|+N|-N>",
+	Short: "Change the volume gradually",
+	Long:  `Change the volume gradually to the target over the time given with --over, ex. baton vol fade 20 --over 10s. Ctrl-C stops the fade where it is.`,
+	RunE:  fadeVolume,
+	// Like vol, the flags are parsed by fadeVolume
+	DisableFlagParsing: true,
 }
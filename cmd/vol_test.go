@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVolumeRamp(t *testing.T) {
+	tests := []struct {
+		name string
+		ramp volumeRamp
+		at   time.Duration
+		want int
+		step time.Duration
+	}{
+		{"fade in halfway", volumeRamp{from: 0, to: 50, over: 100 * time.Second}, 50 * time.Second, 25, 2 * time.Second},
+		{"fade out halfway", volumeRamp{from: 80, to: 0, over: 40 * time.Second}, 20 * time.Second, 40, volumeRampStep},
+		{"over", volumeRamp{from: 0, to: 50, over: time.Minute}, 2 * time.Minute, 50, volumeRampStep + 200*time.Millisecond},
+		{"instant", volumeRamp{from: 20, to: 60}, 0, 60, volumeRampStep},
+		{"nothing to fade", volumeRamp{from: 30, to: 30, over: 10 * time.Second}, 0, 30, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := tt.ramp.at(tt.at); got != tt.want {
+			t.Errorf("%s: at(%s) = %d, want %d", tt.name, tt.at, got, tt.want)
+		}
+
+		if got := tt.ramp.step(); got != tt.step {
+			t.Errorf("%s: step = %s, want %s", tt.name, got, tt.step)
+		}
+	}
+}
+
+func TestVolumeRampRunStopsWithSleep(t *testing.T) {
+	var set []int
+
+	// Sleeping is interrupted right away, like Ctrl-C during vol fade
+	ramp := volumeRamp{from: 10, to: 90, over: time.Hour}
+	v, err := ramp.run(func(v int) error { set = append(set, v); return nil }, func(time.Duration) bool { return false })
+
+	if err != nil || v != 10 || len(set) != 0 {
+		t.Errorf("interrupted ramp set %v and returned %d, %v", set, v, err)
+	}
+
+	// Nothing to wait for when the ramp takes no time
+	ramp = volumeRamp{from: 10, to: 90}
+	v, err = ramp.run(func(v int) error { set = append(set, v); return nil }, func(time.Duration) bool { return true })
+
+	if err != nil || v != 90 || len(set) != 1 {
+		t.Errorf("instant ramp set %v and returned %d, %v", set, v, err)
+	}
+}
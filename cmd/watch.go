@@ -0,0 +1,343 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/utils"
+	runewidth "github.com/mattn/go-runewidth"
+)
+
+// The polledState struct describes a player state along with the moment its progress was measured
+type polledState struct {
+	State    api.PlayerState
+	At       time.Time
+	NoDevice bool
+}
+
+// pollPlayerState fetches the player state, ErrNoActiveDevice is not an error while watching so it's reported through NoDevice
+func pollPlayerState() (polledState, error) {
+	ps, err := getPlayer().GetPlayerState(nil)
+	now := time.Now()
+
+	if err == api.ErrNoActiveDevice {
+		return polledState{At: now, NoDevice: true}, nil
+	}
+
+	if err != nil {
+		return polledState{}, err
+	}
+
+	p := polledState{State: ps, At: now}
+
+	// Timestamp is when Spotify measured the progress, it's only trusted when it agrees with the local clock since it can also be the time playback last changed
+	if ps.Timestamp > 0 {
+		at := time.Unix(0, int64(ps.Timestamp)*int64(time.Millisecond))
+
+		if d := now.Sub(at); d >= 0 && d < 5*time.Second {
+			p.At = at
+		}
+	}
+
+	return p, nil
+}
+
+// progressAt interpolates the progress of the track at the given time, a paused track doesn't move
+func (p polledState) progressAt(now time.Time) api.PlayerState {
+	return interpolatePlayerState(p.State, now.Sub(p.At))
+}
+
+// current returns the state to give the watcher, nil when nothing is playing on any device
+func (p polledState) current() *api.PlayerState {
+	if p.NoDevice {
+		return nil
+	}
+
+	return &p.State
+}
+
+// trackURI returns the URI of what's playing, or an empty string when nothing is
+func (p polledState) trackURI() string {
+	if p.NoDevice || p.State.Item == nil {
+		return ""
+	}
+
+	return p.State.Item.URI
+}
+
+// nextPollInterval decides how long to wait before asking Spotify again, polling soon after the current track should end so track changes show up quickly
+func nextPollInterval(p polledState, failures int) time.Duration {
+	switch {
+	case failures > 0:
+		d := time.Duration(1<<uint(failures)) * time.Second
+
+		if d > 30*time.Second {
+			d = 30 * time.Second
+		}
+
+		return d
+	case p.NoDevice || p.State.Item == nil:
+		return 10 * time.Second
+	case !p.State.IsPlaying:
+		return 5 * time.Second
+	}
+
+	remaining := time.Duration(p.State.Item.DurationMs-p.State.ProgressMs)*time.Millisecond + 500*time.Millisecond
+
+	if remaining < time.Second {
+		return time.Second
+	}
+
+	if remaining < 5*time.Second {
+		return remaining
+	}
+
+	return 5 * time.Second
+}
+
+// progressBar draws the progress of a track as a bar of the given width in cells
+func progressBar(progress, duration, width int) string {
+	if width < 3 {
+		return ""
+	}
+
+	inner := width - 2
+	filled := 0
+
+	if duration > 0 {
+		filled = progress * inner / duration
+	}
+
+	if filled > inner {
+		filled = inner
+	}
+
+	if filled == inner {
+		return "[" + strings.Repeat("=", inner) + "]"
+	}
+
+	return "[" + strings.Repeat("=", filled) + ">" + strings.Repeat(" ", inner-filled-1) + "]"
+}
+
+// renderStatusBlock builds the multi-line now playing view shown by `status --watch` in a terminal
+func renderStatusBlock(p polledState, now time.Time, width int) []string {
+	if p.NoDevice || p.State.Item == nil {
+		return []string{"Nothing is playing"}
+	}
+
+	f := newStatusFields(p.progressAt(now))
+
+	onOff := map[bool]string{true: "on", false: "off"}
+	times := fmt.Sprintf(" %s / %s", f.Progress, f.Duration)
+
+	lines := []string{
+		fmt.Sprintf("%s: %s", f.State, f.Track),
+		fmt.Sprintf("%s - %s", f.Artist, f.Album),
+		progressBar(f.ProgressMs, f.DurationMs, width-runewidth.StringWidth(times)) + times,
+		fmt.Sprintf("%s (%s), volume %d%%, shuffle %s, repeat %s", f.Device, f.DeviceType, f.Volume, onOff[f.Shuffle], f.Repeat),
+	}
+
+	for k := range lines {
+		lines[k] = runewidth.Truncate(lines[k], width, "…")
+	}
+
+	return lines
+}
+
+// watchStatus keeps the status up to date until interrupted, redrawing in place on a terminal and printing a line per track change otherwise
+func watchStatus() error {
+	var format *template.Template
+
+	if statusFormat != "" {
+		t, err := template.New("status").Funcs(templateFuncs).Parse(statusFormat)
+
+		if err != nil {
+			return newUsageError("Invalid --format template: %s\n", err)
+		}
+
+		format = t
+	}
+
+	tty := isTerminal(os.Stdout) && isTextOutput()
+	drawn := 0
+	failures := 0
+	lastURI := ""
+
+	var p polledState
+	var nextPoll time.Time
+
+	sleep, stop := newInterruptibleSleep()
+	defer stop()
+
+	for {
+		now := time.Now()
+
+		if !now.Before(nextPoll) {
+			np, err := pollPlayerState()
+
+			if err != nil {
+				if causeExitCode(err) == exitAuth {
+					return newError(err, "Couldn't get the player state. Have you authenticated with the 'auth' command?\n")
+				}
+
+				failures++
+				fmt.Fprintf(os.Stderr, "Couldn't get the player state, retrying: %s\n", err)
+			} else {
+				failures = 0
+				p = np
+			}
+
+			nextPoll = now.Add(nextPollInterval(p, failures))
+		}
+
+		if tty {
+			width := statusMaxWidth
+
+			if width <= 0 {
+				width = terminalWidth(os.Stdout)
+			}
+
+			if width <= 0 {
+				width = 80
+			}
+
+			var lines []string
+
+			if format != nil {
+				lines = []string{runewidth.Truncate(renderStatusLine(format, p, now), width, "…")}
+			} else {
+				lines = renderStatusBlock(p, now, width)
+			}
+
+			// Move back to the start of the previous block and overwrite it
+			if drawn > 1 {
+				fmt.Printf("\033[%dA", drawn-1)
+			}
+
+			fmt.Print("\r")
+
+			for k, l := range lines {
+				if k > 0 {
+					fmt.Print("\n")
+				}
+
+				fmt.Printf("\033[2K%s", l)
+			}
+
+			// Clear what's left of a taller previous block
+			for k := len(lines); k < drawn; k++ {
+				fmt.Print("\n\033[2K")
+			}
+
+			if drawn > len(lines) {
+				fmt.Printf("\033[%dA", drawn-len(lines))
+			}
+
+			drawn = len(lines)
+
+			// Redraw every second so the progress keeps moving between polls
+			wait := time.Second - time.Duration(now.Nanosecond())
+
+			if d := nextPoll.Sub(now); d < wait {
+				wait = d
+			}
+
+			if !sleep(wait) {
+				fmt.Println()
+				return nil
+			}
+
+			continue
+		}
+
+		if uri := p.trackURI(); uri != lastURI {
+			lastURI = uri
+
+			if uri != "" {
+				err := printWatchedTrack(format, p, now)
+
+				if err != nil {
+					return newError(err, "Couldn't write the status\n")
+				}
+			}
+		}
+
+		if !sleep(nextPoll.Sub(time.Now())) {
+			return nil
+		}
+	}
+}
+
+// renderStatusLine executes the --format template against the interpolated state, an empty line is shown while nothing is playing
+func renderStatusLine(t *template.Template, p polledState, now time.Time) string {
+	if p.NoDevice || p.State.Item == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	err := t.Execute(&sb, newStatusFields(p.progressAt(now)))
+
+	if err != nil {
+		return err.Error()
+	}
+
+	return strings.Replace(strings.TrimRight(sb.String(), "\n"), "\n", " ", -1)
+}
+
+// printWatchedTrack writes a single line for a new track when the output isn't a terminal
+func printWatchedTrack(t *template.Template, p polledState, now time.Time) error {
+	var line string
+
+	switch {
+	case outputFormat == "json":
+		b, err := json.Marshal(p.progressAt(now))
+
+		if err != nil {
+			return err
+		}
+
+		line = string(b)
+	case !isTextOutput():
+		return writeFormatted(os.Stdout, p.progressAt(now))
+	case t != nil:
+		line = renderStatusLine(t, p, now)
+	default:
+		f := newStatusFields(p.progressAt(now))
+		line = fmt.Sprintf("%s - %s (%s)", f.Artist, f.Track, utils.MillisecondsToFormattedTime(f.DurationMs))
+	}
+
+	_, err := fmt.Println(truncateLines(line, statusMaxWidth))
+
+	return err
+}
+
+// newInterruptibleSleep returns a sleep function that returns false when baton is interrupted (Ctrl-C, SIGTERM) instead of waiting, stop restores the default signal handling
+func newInterruptibleSleep() (sleep func(d time.Duration) bool, stop func()) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	sleep = func(d time.Duration) bool {
+		select {
+		case <-interrupt:
+			return false
+		case <-time.After(d):
+			return true
+		}
+	}
+
+	return sleep, func() { signal.Stop(interrupt) }
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,316 @@
+// Package daemon implements the JSON-RPC 2.0 protocol spoken on baton's control socket
+//
+// Requests and responses are single JSON objects separated by newlines, a connection carries any number of them
+// one after the other. Errors from the api package are sent with enough detail for the client to rebuild them so
+// commands fail the same way whether they ran in the daemon or not.
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/firstlane/baton/api"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeServerError    = -32000
+)
+
+// Kinds of errors carried in ErrorData, they tell the client which error to rebuild
+const (
+	KindAPI            = "api"
+	KindNoToken        = "no_token"
+	KindNoActiveDevice = "no_active_device"
+	KindNetwork        = "network"
+)
+
+// The Request struct describes a JSON-RPC call, ID is left out for notifications
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// The Response struct describes the answer to a call, only one of Result and Error is set
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// The Error struct describes a failed call
+type Error struct {
+	Code    int        `json:"code"`
+	Message string     `json:"message"`
+	Data    *ErrorData `json:"data,omitempty"`
+}
+
+// The ErrorData struct describes the error a handler returned
+type ErrorData struct {
+	Kind     string     `json:"kind,omitempty"`
+	APIError *api.Error `json:"api_error,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NetworkError is returned by the client when the daemon couldn't reach Spotify, it's a net.Error like the original
+type NetworkError struct {
+	Message string
+}
+
+func (e *NetworkError) Error() string   { return e.Message }
+func (e *NetworkError) Timeout() bool   { return false }
+func (e *NetworkError) Temporary() bool { return true }
+
+// InvalidParams wraps an error about the parameters of a call so it's reported with CodeInvalidParams
+func InvalidParams(err error) error {
+	return &Error{Code: CodeInvalidParams, Message: err.Error()}
+}
+
+// encodeError turns an error returned by a handler into its JSON-RPC form
+func encodeError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+
+	e := &Error{Code: CodeServerError, Message: err.Error()}
+
+	switch cause := err.(type) {
+	case *api.Error:
+		e.Data = &ErrorData{Kind: KindAPI, APIError: cause}
+	case *url.Error, net.Error:
+		e.Data = &ErrorData{Kind: KindNetwork}
+	}
+
+	switch err {
+	case api.ErrNoToken:
+		e.Data = &ErrorData{Kind: KindNoToken}
+	case api.ErrNoActiveDevice:
+		e.Data = &ErrorData{Kind: KindNoActiveDevice}
+	}
+
+	return e
+}
+
+// decodeError rebuilds the error a handler returned
+func decodeError(e *Error) error {
+	if e.Data == nil {
+		return e
+	}
+
+	switch e.Data.Kind {
+	case KindAPI:
+		if e.Data.APIError != nil {
+			return e.Data.APIError
+		}
+	case KindNoToken:
+		return api.ErrNoToken
+	case KindNoActiveDevice:
+		return api.ErrNoActiveDevice
+	case KindNetwork:
+		return &NetworkError{Message: e.Message}
+	}
+
+	return e
+}
+
+// Handler answers a call, params is nil when the call had none
+type Handler func(params json.RawMessage) (interface{}, error)
+
+// Server dispatches calls read from its connections to the registered handlers
+type Server struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// NewServer returns a server without any methods
+func NewServer() *Server {
+	return &Server{handlers: make(map[string]Handler)}
+}
+
+// Handle registers the handler for a method, replacing any previous one
+func (s *Server) Handle(method string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[method] = h
+}
+
+// Serve accepts connections until the listener is closed, each connection is served on its own goroutine
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+
+		if err != nil {
+			return err
+		}
+
+		go s.ServeConn(c)
+	}
+}
+
+// ServeConn answers the calls read from c in order until it's closed
+func (s *Server) ServeConn(c net.Conn) {
+	defer c.Close()
+
+	scanner := bufio.NewScanner(c)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	enc := json.NewEncoder(c)
+
+	for scanner.Scan() {
+		res := s.call(scanner.Bytes())
+
+		if res == nil {
+			continue
+		}
+
+		if enc.Encode(res) != nil {
+			return
+		}
+	}
+}
+
+// call runs a single request, notifications don't get a response
+func (s *Server) call(b []byte) *Response {
+	var req Request
+
+	if err := json.Unmarshal(b, &req); err != nil {
+		return &Response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: CodeParseError, Message: err.Error()}}
+	}
+
+	res := &Response{JSONRPC: "2.0", ID: req.ID}
+
+	if res.ID == nil {
+		res.ID = json.RawMessage("null")
+	}
+
+	s.mu.RLock()
+	h, ok := s.handlers[req.Method]
+	s.mu.RUnlock()
+
+	switch {
+	case req.JSONRPC != "2.0" || req.Method == "":
+		res.Error = &Error{Code: CodeInvalidRequest, Message: "invalid request"}
+	case !ok:
+		res.Error = &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("unknown method %s", req.Method)}
+	default:
+		v, err := h(req.Params)
+
+		if err == nil {
+			res.Result, err = json.Marshal(v)
+		}
+
+		if err != nil {
+			res.Error = encodeError(err)
+		}
+	}
+
+	if req.ID == nil {
+		return nil
+	}
+
+	return res
+}
+
+// Client makes calls over a single connection, one at a time
+type Client struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	scanner *bufio.Scanner
+	id      int
+	// Timeout bounds every call, a daemon busy talking to Spotify can take a while
+	Timeout time.Duration
+}
+
+// Dial connects to the daemon listening on the Unix socket at path
+func Dial(path string, timeout time.Duration) (*Client, error) {
+	c, err := net.DialTimeout("unix", path, timeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(c)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	return &Client{conn: c, scanner: scanner, Timeout: time.Minute}, nil
+}
+
+// ErrClosed is returned when the daemon closed the connection before answering
+var ErrClosed = errors.New("the daemon closed the connection")
+
+// Call invokes method with params and decodes the result into result unless it's nil
+func (c *Client) Call(method string, params, result interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.id++
+
+	req := Request{JSONRPC: "2.0", ID: json.RawMessage(fmt.Sprint(c.id)), Method: method}
+
+	if params != nil {
+		b, err := json.Marshal(params)
+
+		if err != nil {
+			return err
+		}
+
+		req.Params = b
+	}
+
+	if c.Timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+
+	err := json.NewEncoder(c.conn).Encode(req)
+
+	if err != nil {
+		return err
+	}
+
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return err
+		}
+
+		return ErrClosed
+	}
+
+	var res Response
+
+	err = json.Unmarshal(c.scanner.Bytes(), &res)
+
+	if err != nil {
+		return err
+	}
+
+	if res.Error != nil {
+		return decodeError(res.Error)
+	}
+
+	if result != nil && res.Result != nil {
+		return json.Unmarshal(res.Result, result)
+	}
+
+	return nil
+}
+
+// Close closes the connection to the daemon
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,421 @@
+// Package dbus implements the small part of the D-Bus protocol baton needs to offer a service on the session bus
+//
+// It connects to Unix socket addresses with the EXTERNAL authentication mechanism, calls methods, answers method
+// calls and emits signals. Values are marshalled from their Go types: string, ObjectPath, Signature, bool, the fixed
+// size integers, float64, Variant, slices, maps and []interface{} for structs.
+package dbus
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Names of the standard errors
+const (
+	ErrorFailed           = "org.freedesktop.DBus.Error.Failed"
+	ErrorUnknownMethod    = "org.freedesktop.DBus.Error.UnknownMethod"
+	ErrorUnknownObject    = "org.freedesktop.DBus.Error.UnknownObject"
+	ErrorUnknownInterface = "org.freedesktop.DBus.Error.UnknownInterface"
+	ErrorUnknownProperty  = "org.freedesktop.DBus.Error.UnknownProperty"
+	ErrorPropertyReadOnly = "org.freedesktop.DBus.Error.PropertyReadOnly"
+	ErrorInvalidArgs      = "org.freedesktop.DBus.Error.InvalidArgs"
+)
+
+// Replies to RequestName
+const (
+	nameFlagDoNotQueue   = 4
+	nameReplyPrimary     = 1
+	nameReplyAlreadyOwns = 4
+)
+
+// ErrClosed is returned by calls made after the connection was closed
+var ErrClosed = errors.New("dbus: connection closed")
+
+// The Error struct describes an error reply, the message is the first argument of the reply
+type Error struct {
+	Name    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Name
+	}
+
+	return e.Name + ": " + e.Message
+}
+
+// NewError returns an error that's sent back to the caller with the given name
+func NewError(name, format string, a ...interface{}) *Error {
+	return &Error{Name: name, Message: fmt.Sprintf(format, a...)}
+}
+
+// Handler answers a method call, the returned values are the arguments of the reply
+type Handler func(m *Message) ([]interface{}, error)
+
+// Conn is a connection to a message bus
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	name string
+
+	// mu protects the serial counter and the writes to the socket
+	mu     sync.Mutex
+	serial uint32
+
+	pendingMu sync.Mutex
+	pending   map[uint32]chan *Message
+	closed    bool
+
+	// The method calls waiting for Serve, the read loop never waits for them to be handled since it has to deliver the
+	// replies a handler may be waiting for. callReady is signalled when a call is queued and closed with the connection.
+	callsMu   sync.Mutex
+	calls     []*Message
+	callReady chan struct{}
+}
+
+// SessionBusAddress returns the address of the session bus from the environment
+func SessionBusAddress() (string, error) {
+	if a := os.Getenv("DBUS_SESSION_BUS_ADDRESS"); a != "" {
+		return a, nil
+	}
+
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		if _, err := os.Stat(dir + "/bus"); err == nil {
+			return "unix:path=" + dir + "/bus", nil
+		}
+	}
+
+	return "", errors.New("dbus: DBUS_SESSION_BUS_ADDRESS isn't set")
+}
+
+// dialAddress connects to the first address of a server address list that works, only unix transports are supported
+func dialAddress(address string) (net.Conn, error) {
+	err := fmt.Errorf("dbus: no supported transport in %q", address)
+
+	for _, a := range strings.Split(address, ";") {
+		if !strings.HasPrefix(a, "unix:") {
+			continue
+		}
+
+		params := map[string]string{}
+
+		for _, kv := range strings.Split(strings.TrimPrefix(a, "unix:"), ",") {
+			if i := strings.Index(kv, "="); i > 0 {
+				params[kv[:i]] = unescapeAddress(kv[i+1:])
+			}
+		}
+
+		var path string
+
+		switch {
+		case params["path"] != "":
+			path = params["path"]
+		case params["abstract"] != "":
+			path = "@" + params["abstract"]
+		default:
+			continue
+		}
+
+		var c net.Conn
+
+		if c, err = net.Dial("unix", path); err == nil {
+			return c, nil
+		}
+	}
+
+	return nil, err
+}
+
+// unescapeAddress decodes the %XX escapes of an address value
+func unescapeAddress(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 2
+				continue
+			}
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// Dial connects to a bus, authenticates and registers with it
+func Dial(address string) (*Conn, error) {
+	nc, err := dialAddress(address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Conn{conn: nc, r: bufio.NewReader(nc), pending: make(map[uint32]chan *Message), callReady: make(chan struct{}, 1)}
+
+	if err := c.auth(); err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	go c.read()
+
+	reply, err := c.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello")
+
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	if len(reply) > 0 {
+		c.name, _ = reply[0].(string)
+	}
+
+	return c, nil
+}
+
+// auth goes through the EXTERNAL authentication, the bus checks the uid against the peer credentials of the socket
+func (c *Conn) auth() error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+
+	if _, err := fmt.Fprintf(c.conn, "\x00AUTH EXTERNAL %s\r\n", uid); err != nil {
+		return err
+	}
+
+	line, err := c.r.ReadString('\n')
+
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("dbus: authentication failed: %s", strings.TrimSpace(line))
+	}
+
+	_, err = fmt.Fprint(c.conn, "BEGIN\r\n")
+
+	return err
+}
+
+// Name returns the unique name the bus gave the connection
+func (c *Conn) Name() string {
+	return c.name
+}
+
+// Close closes the connection, pending calls fail with ErrClosed
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// read dispatches the incoming messages until the connection fails
+func (c *Conn) read() {
+	for {
+		m, err := readMessage(c.r)
+
+		if err != nil {
+			break
+		}
+
+		switch m.Type {
+		case TypeMethodReturn, TypeError:
+			c.pendingMu.Lock()
+			ch := c.pending[m.ReplySerial]
+			delete(c.pending, m.ReplySerial)
+			c.pendingMu.Unlock()
+
+			if ch != nil {
+				ch <- m
+			}
+		case TypeMethodCall:
+			c.callsMu.Lock()
+			c.calls = append(c.calls, m)
+			c.callsMu.Unlock()
+
+			select {
+			case c.callReady <- struct{}{}:
+			default:
+			}
+		}
+	}
+
+	c.conn.Close()
+
+	c.pendingMu.Lock()
+	c.closed = true
+
+	for serial, ch := range c.pending {
+		close(ch)
+		delete(c.pending, serial)
+	}
+
+	c.pendingMu.Unlock()
+
+	close(c.callReady)
+}
+
+// nextCall waits for a method call to answer, it returns nil once the connection is closed and every queued call was
+// taken
+func (c *Conn) nextCall() *Message {
+	for open := true; ; {
+		c.callsMu.Lock()
+
+		if len(c.calls) > 0 {
+			m := c.calls[0]
+			c.calls = c.calls[1:]
+			c.callsMu.Unlock()
+
+			return m
+		}
+
+		c.callsMu.Unlock()
+
+		if !open {
+			return nil
+		}
+
+		_, open = <-c.callReady
+	}
+}
+
+// send assigns the next serial to a message and writes it
+func (c *Conn) send(m *Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.serial++
+	m.Serial = c.serial
+
+	b, err := m.marshal()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = c.conn.Write(b)
+
+	return err
+}
+
+// Call calls a method and waits for the reply, an error reply is returned as an *Error
+func (c *Conn) Call(dest string, path ObjectPath, iface, member string, args ...interface{}) ([]interface{}, error) {
+	m := &Message{Type: TypeMethodCall, Destination: dest, Path: path, Interface: iface, Member: member, Body: args}
+	ch := make(chan *Message, 1)
+
+	// The call is registered before the message is written so the reply always finds it
+	c.mu.Lock()
+	c.serial++
+	m.Serial = c.serial
+
+	b, err := m.marshal()
+
+	if err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	c.pendingMu.Lock()
+
+	if c.closed {
+		c.pendingMu.Unlock()
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+
+	c.pending[m.Serial] = ch
+	c.pendingMu.Unlock()
+
+	_, err = c.conn.Write(b)
+	c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	reply, ok := <-ch
+
+	if !ok {
+		return nil, ErrClosed
+	}
+
+	if reply.Type == TypeError {
+		e := &Error{Name: reply.ErrorName}
+
+		if len(reply.Body) > 0 {
+			e.Message, _ = reply.Body[0].(string)
+		}
+
+		return nil, e
+	}
+
+	return reply.Body, nil
+}
+
+// RequestName asks the bus for a well-known name, it fails when another connection owns it
+func (c *Conn) RequestName(name string) error {
+	reply, err := c.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "RequestName", name, uint32(nameFlagDoNotQueue))
+
+	if err != nil {
+		return err
+	}
+
+	if len(reply) != 1 {
+		return fmt.Errorf("dbus: unexpected reply to RequestName")
+	}
+
+	if code, _ := reply[0].(uint32); code != nameReplyPrimary && code != nameReplyAlreadyOwns {
+		return fmt.Errorf("dbus: %s is already taken", name)
+	}
+
+	return nil
+}
+
+// Emit sends a signal
+func (c *Conn) Emit(path ObjectPath, iface, member string, args ...interface{}) error {
+	return c.send(&Message{Type: TypeSignal, Path: path, Interface: iface, Member: member, Body: args})
+}
+
+// Serve answers method calls with the handler until the connection closes, calls are handled one at a time
+func (c *Conn) Serve(h Handler) error {
+	for m := c.nextCall(); m != nil; m = c.nextCall() {
+		var reply []interface{}
+		var err error
+
+		// Every connection has to answer pings, there's nothing to do for them
+		if m.Interface != "org.freedesktop.DBus.Peer" || m.Member != "Ping" {
+			reply, err = h(m)
+		}
+
+		if m.Flags&FlagNoReplyExpected != 0 {
+			continue
+		}
+
+		r := &Message{Type: TypeMethodReturn, ReplySerial: m.Serial, Destination: m.Sender, Body: reply}
+
+		if err != nil {
+			e, ok := err.(*Error)
+
+			if !ok {
+				e = &Error{Name: ErrorFailed, Message: err.Error()}
+			}
+
+			r = &Message{Type: TypeError, ErrorName: e.Name, ReplySerial: m.Serial, Destination: m.Sender, Body: []interface{}{e.Message}}
+		}
+
+		if err := c.send(r); err != nil {
+			// The reply couldn't be encoded, the caller still deserves an answer
+			c.send(&Message{Type: TypeError, ErrorName: ErrorFailed, ReplySerial: m.Serial, Destination: m.Sender, Body: []interface{}{err.Error()}})
+		}
+	}
+
+	return ErrClosed
+}
@@ -0,0 +1,218 @@
+package dbus
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// busConfig lets every connection of a private bus own names and talk to the others, the rules are the ones of the
+// standard session bus, allowing eavesdropping is what allows receiving
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startBus runs a private dbus-daemon for the test and returns its address, the test is skipped without dbus-daemon
+func startBus(t *testing.T) string {
+	daemon, err := exec.LookPath("dbus-daemon")
+
+	if err != nil {
+		t.Skip("dbus-daemon isn't installed")
+	}
+
+	dir, err := ioutil.TempDir("", "dbus")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := filepath.Join(dir, "bus.conf")
+
+	if err := ioutil.WriteFile(cfg, []byte(fmt.Sprintf(busConfig, filepath.Join(dir, "bus"))), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+cfg, "--nofork", "--print-address=1")
+	out, err := cmd.StdoutPipe()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		os.RemoveAll(dir)
+	})
+
+	address, err := bufio.NewReader(out).ReadString('\n')
+
+	if err != nil {
+		t.Fatalf("dbus-daemon didn't start: %v", err)
+	}
+
+	return strings.TrimSpace(address)
+}
+
+func dial(t *testing.T, address string) *Conn {
+	c, err := Dial(address)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		c.Close()
+	})
+
+	return c
+}
+
+func TestCallOnPrivateBus(t *testing.T) {
+	address := startBus(t)
+	server, client := dial(t, address), dial(t, address)
+
+	if err := server.RequestName("org.baton.Test"); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan *Message, 1)
+
+	go server.Serve(func(m *Message) ([]interface{}, error) {
+		if m.Member == "Fail" {
+			return nil, NewError(ErrorInvalidArgs, "bad %s", "argument")
+		}
+
+		received <- m
+
+		return []interface{}{m.Signature, m.Sender}, nil
+	})
+
+	args := []interface{}{"name", map[string]Variant{"volume": {Value: 0.5}}, []int32{1, 2}}
+	reply, err := client.Call("org.baton.Test", "/org/baton", "org.baton.Test", "Echo", args...)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reply) != 2 || reply[0] != Signature("sa{sv}ai") || reply[1] != client.Name() {
+		t.Errorf("Echo replied %#v", reply)
+	}
+
+	m := <-received
+
+	if m.Path != "/org/baton" || m.Interface != "org.baton.Test" || m.Member != "Echo" || len(m.Body) != 3 || m.Body[0] != "name" {
+		t.Errorf("the server got %+v", m)
+	} else if v, _ := m.Body[1].(map[interface{}]interface{})["volume"].(Variant); v.Value != 0.5 {
+		t.Errorf("the dict was read as %#v", m.Body[1])
+	}
+
+	_, err = client.Call("org.baton.Test", "/org/baton", "org.baton.Test", "Fail")
+
+	if e, ok := err.(*Error); !ok || e.Name != ErrorInvalidArgs || e.Message != "bad argument" {
+		t.Errorf("Fail returned %v, want an %s error", err, ErrorInvalidArgs)
+	}
+
+	if _, err := client.Call("org.baton.Test", "/org/baton", "org.freedesktop.DBus.Peer", "Ping"); err != nil {
+		t.Errorf("Ping returned %v", err)
+	}
+
+	if err := server.RequestName("org.baton.Test"); err != nil {
+		t.Errorf("requesting a name the connection owns returned %v", err)
+	}
+
+	if err := client.RequestName("org.baton.Test"); err == nil {
+		t.Errorf("requesting a name another connection owns should fail")
+	}
+}
+
+func TestHandlersCanCallWhileCallsQueue(t *testing.T) {
+	address := startBus(t)
+	server, client := dial(t, address), dial(t, address)
+
+	if err := server.RequestName("org.baton.Test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The handler waits for a reply from the bus while more calls than ever fitted the old queue come in
+	go server.Serve(func(m *Message) ([]interface{}, error) {
+		time.Sleep(time.Millisecond)
+		return server.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "GetId")
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := client.Call("org.baton.Test", "/org/baton", "org.baton.Test", "Id"); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	done := make(chan bool)
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the calls weren't answered, the connection is stuck")
+	}
+
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestCallAfterClose(t *testing.T) {
+	c := dial(t, startBus(t))
+	served := make(chan error)
+
+	go func() {
+		served <- c.Serve(func(m *Message) ([]interface{}, error) {
+			return nil, nil
+		})
+	}()
+
+	c.Close()
+
+	if err := <-served; err != ErrClosed {
+		t.Errorf("Serve returned %v, want ErrClosed", err)
+	}
+
+	if _, err := c.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "GetId"); err == nil {
+		t.Errorf("a call on a closed connection should fail")
+	}
+}
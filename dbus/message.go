@@ -0,0 +1,828 @@
+package dbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// Message types
+const (
+	TypeMethodCall   = 1
+	TypeMethodReturn = 2
+	TypeError        = 3
+	TypeSignal       = 4
+)
+
+// FlagNoReplyExpected marks a method call the caller doesn't want an answer to
+const FlagNoReplyExpected = 1
+
+// Header fields
+const (
+	fieldPath        = 1
+	fieldInterface   = 2
+	fieldMember      = 3
+	fieldErrorName   = 4
+	fieldReplySerial = 5
+	fieldDestination = 6
+	fieldSender      = 7
+	fieldSignature   = 8
+)
+
+// maxMessageSize is the largest message the specification allows
+const maxMessageSize = 128 * 1024 * 1024
+
+// ObjectPath is a D-Bus object path such as /org/mpris/MediaPlayer2
+type ObjectPath string
+
+// Signature is a D-Bus type signature such as a{sv}
+type Signature string
+
+// The Variant struct describes a value along with its type, the type is worked out from the Go value when Signature is empty
+type Variant struct {
+	Signature Signature
+	Value     interface{}
+}
+
+// The Message struct describes a D-Bus message, Body holds the arguments in the order of Signature
+type Message struct {
+	Type        byte
+	Flags       byte
+	Serial      uint32
+	Path        ObjectPath
+	Interface   string
+	Member      string
+	ErrorName   string
+	ReplySerial uint32
+	Destination string
+	Sender      string
+	Signature   Signature
+	Body        []interface{}
+}
+
+var errShortMessage = errors.New("dbus: message is truncated")
+
+// nextType splits the first complete type off a signature
+func nextType(sig string) (string, string, error) {
+	if sig == "" {
+		return "", "", errors.New("dbus: empty signature")
+	}
+
+	switch sig[0] {
+	case 'a':
+		t, rest, err := nextType(sig[1:])
+		return "a" + t, rest, err
+	case '(', '{':
+		end := byte(')')
+
+		if sig[0] == '{' {
+			end = '}'
+		}
+
+		depth := 0
+
+		for i := 0; i < len(sig); i++ {
+			switch sig[i] {
+			case '(', '{':
+				depth++
+			case ')', '}':
+				depth--
+			}
+
+			if depth == 0 {
+				if sig[i] != end {
+					return "", "", fmt.Errorf("dbus: unbalanced signature %q", sig)
+				}
+
+				return sig[:i+1], sig[i+1:], nil
+			}
+		}
+
+		return "", "", fmt.Errorf("dbus: unbalanced signature %q", sig)
+	case 'y', 'b', 'n', 'q', 'i', 'u', 'x', 't', 'd', 's', 'o', 'g', 'v', 'h':
+		return sig[:1], sig[1:], nil
+	}
+
+	return "", "", fmt.Errorf("dbus: unknown type %q in signature", sig[0])
+}
+
+// splitSignature splits a signature into its complete types
+func splitSignature(sig string) ([]string, error) {
+	var types []string
+
+	for sig != "" {
+		t, rest, err := nextType(sig)
+
+		if err != nil {
+			return nil, err
+		}
+
+		types = append(types, t)
+		sig = rest
+	}
+
+	return types, nil
+}
+
+// alignment returns the boundary values of a type start on
+func alignment(t byte) int {
+	switch t {
+	case 'y', 'g', 'v':
+		return 1
+	case 'n', 'q':
+		return 2
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+
+	return 4
+}
+
+// SignatureOf works out the D-Bus type of a Go value
+func SignatureOf(v interface{}) (Signature, error) {
+	switch v := v.(type) {
+	case byte:
+		return "y", nil
+	case bool:
+		return "b", nil
+	case int16:
+		return "n", nil
+	case uint16:
+		return "q", nil
+	case int32:
+		return "i", nil
+	case uint32:
+		return "u", nil
+	case int64:
+		return "x", nil
+	case uint64:
+		return "t", nil
+	case float64:
+		return "d", nil
+	case string:
+		return "s", nil
+	case ObjectPath:
+		return "o", nil
+	case Signature:
+		return "g", nil
+	case Variant:
+		return "v", nil
+	case []interface{}:
+		sig := "("
+
+		for _, f := range v {
+			s, err := SignatureOf(f)
+
+			if err != nil {
+				return "", err
+			}
+
+			sig += string(s)
+		}
+
+		return Signature(sig + ")"), nil
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Slice:
+		elem, err := SignatureOf(reflect.Zero(rv.Type().Elem()).Interface())
+
+		if err != nil {
+			return "", err
+		}
+
+		return "a" + elem, nil
+	case reflect.Map:
+		key, err := SignatureOf(reflect.Zero(rv.Type().Key()).Interface())
+
+		if err != nil {
+			return "", err
+		}
+
+		elem, err := SignatureOf(reflect.Zero(rv.Type().Elem()).Interface())
+
+		if err != nil {
+			return "", err
+		}
+
+		return "a{" + key + elem + "}", nil
+	}
+
+	return "", fmt.Errorf("dbus: can't send values of type %T", v)
+}
+
+// The encoder struct describes a message being marshalled, offsets are relative to the start of the message
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) align(n int) {
+	for e.buf.Len()%n != 0 {
+		e.buf.WriteByte(0)
+	}
+}
+
+func (e *encoder) uint32(n uint32) {
+	e.align(4)
+	binary.Write(&e.buf, binary.LittleEndian, n)
+}
+
+func (e *encoder) string(s string) {
+	e.uint32(uint32(len(s)))
+	e.buf.WriteString(s)
+	e.buf.WriteByte(0)
+}
+
+func (e *encoder) signature(s string) {
+	e.buf.WriteByte(byte(len(s)))
+	e.buf.WriteString(s)
+	e.buf.WriteByte(0)
+}
+
+func wrongType(sig string, v interface{}) error {
+	return fmt.Errorf("dbus: can't send %T as %s", v, sig)
+}
+
+// encode writes a value of a single complete type
+func (e *encoder) encode(sig string, v interface{}) error {
+	switch sig[0] {
+	case 'y', 'n', 'q', 'i', 'u', 'x', 't', 'd':
+		return e.fixed(sig, v)
+	case 'b':
+		b, ok := v.(bool)
+
+		if !ok {
+			return wrongType(sig, v)
+		}
+
+		var n uint32
+
+		if b {
+			n = 1
+		}
+
+		e.uint32(n)
+	case 's', 'o':
+		s, ok := stringValue(v)
+
+		if !ok {
+			return wrongType(sig, v)
+		}
+
+		e.string(s)
+	case 'g':
+		s, ok := stringValue(v)
+
+		if !ok {
+			return wrongType(sig, v)
+		}
+
+		e.signature(s)
+	case 'v':
+		vv, ok := v.(Variant)
+
+		if !ok {
+			return wrongType(sig, v)
+		}
+
+		s := vv.Signature
+
+		if s == "" {
+			var err error
+
+			if s, err = SignatureOf(vv.Value); err != nil {
+				return err
+			}
+		}
+
+		e.signature(string(s))
+
+		return e.encode(string(s), vv.Value)
+	case '(':
+		fields, ok := v.([]interface{})
+		types, err := splitSignature(sig[1 : len(sig)-1])
+
+		if err != nil {
+			return err
+		}
+
+		if !ok || len(fields) != len(types) {
+			return wrongType(sig, v)
+		}
+
+		e.align(8)
+
+		for i, t := range types {
+			if err := e.encode(t, fields[i]); err != nil {
+				return err
+			}
+		}
+	case 'a':
+		return e.array(sig, v)
+	default:
+		return fmt.Errorf("dbus: can't send values of type %s", sig)
+	}
+
+	return nil
+}
+
+func stringValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case ObjectPath:
+		return string(v), true
+	case Signature:
+		return string(v), true
+	}
+
+	return "", false
+}
+
+// fixed writes a number, the value must have the exact Go type matching the signature
+func (e *encoder) fixed(sig string, v interface{}) error {
+	want := map[byte]reflect.Kind{
+		'y': reflect.Uint8, 'n': reflect.Int16, 'q': reflect.Uint16, 'i': reflect.Int32,
+		'u': reflect.Uint32, 'x': reflect.Int64, 't': reflect.Uint64, 'd': reflect.Float64,
+	}[sig[0]]
+
+	if reflect.ValueOf(v).Kind() != want {
+		return wrongType(sig, v)
+	}
+
+	e.align(alignment(sig[0]))
+
+	return binary.Write(&e.buf, binary.LittleEndian, v)
+}
+
+// array writes a slice, or a map when the elements are dict entries
+func (e *encoder) array(sig string, v interface{}) error {
+	elem := sig[1:]
+	rv := reflect.ValueOf(v)
+
+	e.uint32(0)
+	lenAt := e.buf.Len() - 4
+	e.align(alignment(elem[0]))
+	start := e.buf.Len()
+
+	if elem[0] == '{' {
+		types, err := splitSignature(elem[1 : len(elem)-1])
+
+		if err != nil {
+			return err
+		}
+
+		if rv.Kind() != reflect.Map || len(types) != 2 {
+			return wrongType(sig, v)
+		}
+
+		keys := rv.MapKeys()
+
+		// Sorted keys keep messages reproducible
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+
+		for _, k := range keys {
+			e.align(8)
+
+			if err := e.encode(types[0], k.Interface()); err != nil {
+				return err
+			}
+
+			if err := e.encode(types[1], rv.MapIndex(k).Interface()); err != nil {
+				return err
+			}
+		}
+	} else {
+		if rv.Kind() != reflect.Slice {
+			return wrongType(sig, v)
+		}
+
+		for i := 0; i < rv.Len(); i++ {
+			if err := e.encode(elem, rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	}
+
+	binary.LittleEndian.PutUint32(e.buf.Bytes()[lenAt:], uint32(e.buf.Len()-start))
+
+	return nil
+}
+
+// The decoder struct describes a message being unmarshalled, pos is relative to the start of the message
+type decoder struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+func (d *decoder) align(n int) error {
+	for d.pos%n != 0 {
+		d.pos++
+	}
+
+	if d.pos > len(d.data) {
+		return errShortMessage
+	}
+
+	return nil
+}
+
+func (d *decoder) take(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, errShortMessage
+	}
+
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+
+	return b, nil
+}
+
+func (d *decoder) uint32() (uint32, error) {
+	if err := d.align(4); err != nil {
+		return 0, err
+	}
+
+	b, err := d.take(4)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return d.order.Uint32(b), nil
+}
+
+func (d *decoder) string() (string, error) {
+	n, err := d.uint32()
+
+	if err != nil {
+		return "", err
+	}
+
+	b, err := d.take(int(n) + 1)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(b[:n]), nil
+}
+
+func (d *decoder) signature() (string, error) {
+	n, err := d.take(1)
+
+	if err != nil {
+		return "", err
+	}
+
+	b, err := d.take(int(n[0]) + 1)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(b[:n[0]]), nil
+}
+
+// decode reads a value of a single complete type, arrays and structs become []interface{} and dicts map[interface{}]interface{}
+func (d *decoder) decode(sig string) (interface{}, error) {
+	switch sig[0] {
+	case 'y':
+		b, err := d.take(1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return b[0], nil
+	case 'n', 'q':
+		if err := d.align(2); err != nil {
+			return nil, err
+		}
+
+		b, err := d.take(2)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if sig[0] == 'n' {
+			return int16(d.order.Uint16(b)), nil
+		}
+
+		return d.order.Uint16(b), nil
+	case 'b', 'i', 'u', 'h':
+		n, err := d.uint32()
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch sig[0] {
+		case 'b':
+			return n != 0, nil
+		case 'i':
+			return int32(n), nil
+		}
+
+		return n, nil
+	case 'x', 't', 'd':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+
+		b, err := d.take(8)
+
+		if err != nil {
+			return nil, err
+		}
+
+		n := d.order.Uint64(b)
+
+		switch sig[0] {
+		case 'x':
+			return int64(n), nil
+		case 'd':
+			var f float64
+			binary.Read(bytes.NewReader(b), d.order, &f)
+			return f, nil
+		}
+
+		return n, nil
+	case 's':
+		return d.string()
+	case 'o':
+		s, err := d.string()
+		return ObjectPath(s), err
+	case 'g':
+		s, err := d.signature()
+		return Signature(s), err
+	case 'v':
+		s, err := d.signature()
+
+		if err != nil {
+			return nil, err
+		}
+
+		t, rest, err := nextType(s)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if rest != "" {
+			return nil, fmt.Errorf("dbus: variant holds more than one value: %q", s)
+		}
+
+		v, err := d.decode(t)
+
+		return Variant{Signature: Signature(s), Value: v}, err
+	case '(', '{':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+
+		types, err := splitSignature(sig[1 : len(sig)-1])
+
+		if err != nil {
+			return nil, err
+		}
+
+		fields := make([]interface{}, len(types))
+
+		for i, t := range types {
+			if fields[i], err = d.decode(t); err != nil {
+				return nil, err
+			}
+		}
+
+		return fields, nil
+	case 'a':
+		return d.array(sig[1:])
+	}
+
+	return nil, fmt.Errorf("dbus: can't read values of type %s", sig)
+}
+
+func (d *decoder) array(elem string) (interface{}, error) {
+	n, err := d.uint32()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.align(alignment(elem[0])); err != nil {
+		return nil, err
+	}
+
+	end := d.pos + int(n)
+
+	if end > len(d.data) {
+		return nil, errShortMessage
+	}
+
+	if elem[0] == '{' {
+		m := make(map[interface{}]interface{})
+
+		for d.pos < end {
+			v, err := d.decode(elem)
+
+			if err != nil {
+				return nil, err
+			}
+
+			entry := v.([]interface{})
+
+			if len(entry) != 2 {
+				return nil, fmt.Errorf("dbus: dict entry %s doesn't have two fields", elem)
+			}
+
+			m[entry[0]] = entry[1]
+		}
+
+		return m, nil
+	}
+
+	items := []interface{}{}
+
+	for d.pos < end {
+		v, err := d.decode(elem)
+
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, v)
+	}
+
+	return items, nil
+}
+
+// marshal encodes a message, the serial has to be set already
+func (m *Message) marshal() ([]byte, error) {
+	var body encoder
+	sig := ""
+
+	for _, a := range m.Body {
+		s, err := SignatureOf(a)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := body.encode(string(s), a); err != nil {
+			return nil, err
+		}
+
+		sig += string(s)
+	}
+
+	fields := map[byte]Variant{}
+
+	add := func(code byte, v interface{}, empty bool) {
+		if !empty {
+			fields[code] = Variant{Value: v}
+		}
+	}
+
+	add(fieldPath, m.Path, m.Path == "")
+	add(fieldInterface, m.Interface, m.Interface == "")
+	add(fieldMember, m.Member, m.Member == "")
+	add(fieldErrorName, m.ErrorName, m.ErrorName == "")
+	add(fieldReplySerial, m.ReplySerial, m.ReplySerial == 0)
+	add(fieldDestination, m.Destination, m.Destination == "")
+	add(fieldSignature, Signature(sig), sig == "")
+
+	var codes []int
+
+	for code := range fields {
+		codes = append(codes, int(code))
+	}
+
+	sort.Ints(codes)
+
+	var headerFields [][]interface{}
+
+	for _, code := range codes {
+		headerFields = append(headerFields, []interface{}{byte(code), fields[byte(code)]})
+	}
+
+	var e encoder
+	e.buf.Write([]byte{'l', m.Type, m.Flags, 1})
+	e.uint32(uint32(body.buf.Len()))
+	e.uint32(m.Serial)
+
+	if err := e.encode("a(yv)", headerFields); err != nil {
+		return nil, err
+	}
+
+	e.align(8)
+	e.buf.Write(body.buf.Bytes())
+
+	if e.buf.Len() > maxMessageSize {
+		return nil, errors.New("dbus: message is too large")
+	}
+
+	return e.buf.Bytes(), nil
+}
+
+// readMessage reads and decodes the next message
+func readMessage(r io.Reader) (*Message, error) {
+	fixed := make([]byte, 16)
+
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, err
+	}
+
+	var order binary.ByteOrder
+
+	switch fixed[0] {
+	case 'l':
+		order = binary.LittleEndian
+	case 'B':
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("dbus: unknown byte order %q", fixed[0])
+	}
+
+	bodyLen := int(order.Uint32(fixed[4:]))
+	fieldsLen := int(order.Uint32(fixed[12:]))
+	headerLen := 16 + fieldsLen
+
+	for headerLen%8 != 0 {
+		headerLen++
+	}
+
+	if bodyLen < 0 || fieldsLen < 0 || headerLen+bodyLen > maxMessageSize {
+		return nil, errors.New("dbus: message is too large")
+	}
+
+	data := make([]byte, headerLen+bodyLen)
+	copy(data, fixed)
+
+	if _, err := io.ReadFull(r, data[16:]); err != nil {
+		return nil, err
+	}
+
+	m := &Message{Type: fixed[1], Flags: fixed[2], Serial: order.Uint32(fixed[8:])}
+	d := &decoder{data: data[:headerLen], pos: 12, order: order}
+	v, err := d.decode("a(yv)")
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range v.([]interface{}) {
+		field := f.([]interface{})
+		value := field[1].(Variant).Value
+
+		switch field[0].(byte) {
+		case fieldPath:
+			m.Path, _ = value.(ObjectPath)
+		case fieldInterface:
+			m.Interface, _ = value.(string)
+		case fieldMember:
+			m.Member, _ = value.(string)
+		case fieldErrorName:
+			m.ErrorName, _ = value.(string)
+		case fieldReplySerial:
+			m.ReplySerial, _ = value.(uint32)
+		case fieldDestination:
+			m.Destination, _ = value.(string)
+		case fieldSender:
+			m.Sender, _ = value.(string)
+		case fieldSignature:
+			m.Signature, _ = value.(Signature)
+		}
+	}
+
+	// The body is decoded on its own, its offsets start at 0 since the header is padded to 8 bytes
+	types, err := splitSignature(string(m.Signature))
+
+	if err != nil {
+		return nil, err
+	}
+
+	d = &decoder{data: data[headerLen:], order: order}
+
+	for _, t := range types {
+		a, err := d.decode(t)
+
+		if err != nil {
+			return nil, err
+		}
+
+		m.Body = append(m.Body, a)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,166 @@
+package dbus
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSignatureOf(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want Signature
+	}{
+		{byte(1), "y"},
+		{true, "b"},
+		{int16(1), "n"},
+		{uint16(1), "q"},
+		{int32(1), "i"},
+		{uint32(1), "u"},
+		{int64(1), "x"},
+		{uint64(1), "t"},
+		{1.5, "d"},
+		{"s", "s"},
+		{ObjectPath("/a"), "o"},
+		{Signature("s"), "g"},
+		{Variant{Value: 1}, "v"},
+		{[]string{}, "as"},
+		{map[string]Variant{}, "a{sv}"},
+		{[]interface{}{"s", int32(1)}, "(si)"},
+		{[]map[string]int64{}, "aa{sx}"},
+	}
+
+	for _, tt := range tests {
+		got, err := SignatureOf(tt.v)
+
+		if err != nil || got != tt.want {
+			t.Errorf("SignatureOf(%#v) = %q, %v, want %q", tt.v, got, err, tt.want)
+		}
+	}
+
+	if _, err := SignatureOf(1); err == nil {
+		t.Errorf("SignatureOf(int) should fail, D-Bus integers have a size")
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		body []interface{}
+		// want is the body as it's read back, arrays come back as []interface{} and dicts as maps of interface{}
+		want []interface{}
+	}{
+		{"no body", nil, nil},
+		{"basic types",
+			[]interface{}{byte(7), true, int16(-2), uint16(3), int32(-4), uint32(5), int64(-6), uint64(7), 8.25, "ü", ObjectPath("/o"), Signature("a{sv}")},
+			[]interface{}{byte(7), true, int16(-2), uint16(3), int32(-4), uint32(5), int64(-6), uint64(7), 8.25, "ü", ObjectPath("/o"), Signature("a{sv}")},
+		},
+		{"alignment after a byte",
+			[]interface{}{byte(1), int64(2), byte(3), 4.5},
+			[]interface{}{byte(1), int64(2), byte(3), 4.5},
+		},
+		{"arrays",
+			[]interface{}{[]string{"a", "b"}, []int64{}, []byte{1, 2}},
+			[]interface{}{[]interface{}{"a", "b"}, []interface{}{}, []interface{}{byte(1), byte(2)}},
+		},
+		{"dict of variants",
+			[]interface{}{map[string]Variant{"n": {Value: int32(1)}, "s": {Value: []string{"x"}}}},
+			[]interface{}{map[interface{}]interface{}{"n": Variant{Signature: "i", Value: int32(1)}, "s": Variant{Signature: "as", Value: []interface{}{"x"}}}},
+		},
+		{"struct",
+			[]interface{}{[]interface{}{"s", []interface{}{int64(1), true}}},
+			[]interface{}{[]interface{}{"s", []interface{}{int64(1), true}}},
+		},
+		{"variant with a given signature",
+			[]interface{}{Variant{Signature: "x", Value: int64(9)}},
+			[]interface{}{Variant{Signature: "x", Value: int64(9)}},
+		},
+	}
+
+	for _, tt := range tests {
+		m := &Message{Type: TypeMethodCall, Serial: 3, Path: "/org/test", Interface: "org.test", Member: "Do", Destination: "org.test.Dest", Body: tt.body}
+		b, err := m.marshal()
+
+		if err != nil {
+			t.Errorf("%s: marshal: %v", tt.name, err)
+			continue
+		}
+
+		if len(b)%8 != 0 && len(tt.body) == 0 {
+			t.Errorf("%s: a message without a body must end on the 8-byte aligned header", tt.name)
+		}
+
+		got, err := readMessage(bytes.NewReader(b))
+
+		if err != nil {
+			t.Errorf("%s: readMessage: %v", tt.name, err)
+			continue
+		}
+
+		if got.Serial != 3 || got.Path != m.Path || got.Interface != m.Interface || got.Member != m.Member || got.Destination != m.Destination {
+			t.Errorf("%s: header read back as %+v", tt.name, got)
+		}
+
+		if len(got.Body) != 0 || len(tt.want) != 0 {
+			if !reflect.DeepEqual(got.Body, tt.want) {
+				t.Errorf("%s: body read back as %#v, want %#v", tt.name, got.Body, tt.want)
+			}
+		}
+	}
+}
+
+func TestMarshalRefusesWrongValues(t *testing.T) {
+	tests := []struct {
+		name string
+		body []interface{}
+	}{
+		{"int without a size", []interface{}{1}},
+		{"struct", []interface{}{struct{}{}}},
+		{"variant not matching its signature", []interface{}{Variant{Signature: "s", Value: int32(1)}}},
+	}
+
+	for _, tt := range tests {
+		m := &Message{Type: TypeSignal, Serial: 1, Path: "/", Interface: "org.test", Member: "Changed", Body: tt.body}
+
+		if _, err := m.marshal(); err == nil {
+			t.Errorf("%s: marshal should fail", tt.name)
+		}
+	}
+}
+
+func TestReadMessageTruncated(t *testing.T) {
+	m := &Message{Type: TypeSignal, Serial: 1, Path: "/", Interface: "org.test", Member: "Changed", Body: []interface{}{"value"}}
+	b, err := m.marshal()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{0, 10, 16, len(b) - 1} {
+		if _, err := readMessage(bytes.NewReader(b[:n])); err == nil {
+			t.Errorf("reading %d of %d bytes should fail", n, len(b))
+		}
+	}
+}
+
+func TestReadMessageBigEndian(t *testing.T) {
+	// A signal /a org.b.C with the string "hi", as a big-endian peer sends it
+	b := []byte{
+		'B', TypeSignal, 0, 1, 0, 0, 0, 7, 0, 0, 0, 1, 0, 0, 0, 0x37,
+		1, 1, 'o', 0, 0, 0, 0, 2, '/', 'a', 0, 0, 0, 0, 0, 0,
+		2, 1, 's', 0, 0, 0, 0, 5, 'o', 'r', 'g', '.', 'b', 0, 0, 0,
+		3, 1, 's', 0, 0, 0, 0, 1, 'C', 0, 0, 0, 0, 0, 0, 0,
+		8, 1, 'g', 0, 1, 's', 0, 0,
+		0, 0, 0, 2, 'h', 'i', 0,
+	}
+
+	m, err := readMessage(bytes.NewReader(b))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Path != "/a" || m.Interface != "org.b" || m.Member != "C" || !reflect.DeepEqual(m.Body, []interface{}{"hi"}) {
+		t.Errorf("read %+v", m)
+	}
+}
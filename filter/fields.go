@@ -0,0 +1,113 @@
+package filter
+
+import (
+	"strconv"
+	"time"
+)
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindStrings
+	kindNumber
+	kindDuration
+	kindBool
+)
+
+func (k kind) String() string {
+	switch k {
+	case kindString, kindStrings:
+		return "text"
+	case kindNumber:
+		return "number"
+	case kindDuration:
+		return "duration"
+	case kindBool:
+		return "true/false"
+	}
+
+	return "unknown"
+}
+
+type field struct {
+	kind kind
+	// get returns the value of the field for the item or nil when it isn't known
+	get func(i *Item) interface{}
+}
+
+var fields = map[string]field{
+	"name": {kind: kindString, get: func(i *Item) interface{} {
+		return i.Track.Name
+	}},
+	"artist": {kind: kindStrings, get: func(i *Item) interface{} {
+		var names []string
+		for _, a := range i.Track.Artists {
+			names = append(names, a.Name)
+		}
+		return names
+	}},
+	"album": {kind: kindString, get: func(i *Item) interface{} {
+		if i.Track.Album == nil {
+			return nil
+		}
+		return i.Track.Album.Name
+	}},
+	"uri": {kind: kindString, get: func(i *Item) interface{} {
+		return i.Track.URI
+	}},
+	"source": {kind: kindString, get: func(i *Item) interface{} {
+		return i.Source
+	}},
+	"genre": {kind: kindStrings, get: func(i *Item) interface{} {
+		return i.Genres
+	}},
+	"duration": {kind: kindDuration, get: func(i *Item) interface{} {
+		return time.Duration(i.Track.DurationMs) * time.Millisecond
+	}},
+	"age": {kind: kindDuration, get: func(i *Item) interface{} {
+		if i.AddedAt == nil {
+			return nil
+		}
+		return time.Since(*i.AddedAt)
+	}},
+	"popularity": {kind: kindNumber, get: func(i *Item) interface{} {
+		return float64(i.Track.Popularity)
+	}},
+	"track_number": {kind: kindNumber, get: func(i *Item) interface{} {
+		return float64(i.Track.TrackNumber)
+	}},
+	"disc_number": {kind: kindNumber, get: func(i *Item) interface{} {
+		return float64(i.Track.DiscNumber)
+	}},
+	"year": {kind: kindNumber, get: func(i *Item) interface{} {
+		if i.Track.Album == nil || len(i.Track.Album.ReleaseDate) < 4 {
+			return nil
+		}
+
+		y, err := strconv.Atoi(i.Track.Album.ReleaseDate[:4])
+
+		if err != nil {
+			return nil
+		}
+
+		return float64(y)
+	}},
+	"explicit": {kind: kindBool, get: func(i *Item) interface{} {
+		return i.Track.Explicit
+	}},
+}
+
+// operators lists the comparison operators allowed for each kind of field
+var operators = map[kind][]string{
+	kindString:   {"==", "!=", "~", "!~"},
+	kindStrings:  {"==", "!=", "~", "!~"},
+	kindNumber:   {"==", "!=", "<", "<=", ">", ">="},
+	kindDuration: {"==", "!=", "<", "<=", ">", ">="},
+	kindBool:     {"==", "!="},
+}
+
+// Fields returns the names of the fields that can be used in an expression
+func Fields() []string {
+	return []string{"name", "artist", "album", "uri", "source", "genre", "duration", "age", "popularity", "track_number", "disc_number", "year", "explicit"}
+}
@@ -0,0 +1,189 @@
+// Package filter implements the small expression language used to select tracks for smart playlists
+//
+// An expression is made of comparisons joined with && (and), || (or) and ! (not), for example:
+//
+//	age < 30d && genre ~ "house" && duration < 5m && !explicit
+//
+// Strings are compared case-insensitively, ~ and !~ test whether a string contains another and fields that hold
+// several values (artist, genre) match when any of their values does.
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/firstlane/baton/api"
+)
+
+// The Item struct describes a track along with the extra information that can be used in a filter expression
+type Item struct {
+	Track   *api.FullTrack
+	AddedAt *time.Time
+	Genres  []string
+	Source  string
+}
+
+// Expr is a parsed filter expression
+type Expr struct {
+	root   node
+	fields map[string]bool
+}
+
+// Parse parses a filter expression, an empty expression matches every track
+func Parse(s string) (*Expr, error) {
+	p := &parser{lex: newLexer(s), fields: make(map[string]bool)}
+
+	err := p.next()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind == tokEOF {
+		return &Expr{root: trueNode{}, fields: p.fields}, nil
+	}
+
+	n, err := p.parseOr()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", p.tok, p.tok.pos)
+	}
+
+	return &Expr{root: n, fields: p.fields}, nil
+}
+
+// Match reports whether the item satisfies the expression
+func (e *Expr) Match(i *Item) bool {
+	return e.root.eval(i)
+}
+
+// Uses reports whether the expression references the given field, this lets callers skip expensive lookups such as genres
+func (e *Expr) Uses(field string) bool {
+	return e.fields[field]
+}
+
+type node interface {
+	eval(i *Item) bool
+}
+
+type trueNode struct{}
+
+func (trueNode) eval(i *Item) bool {
+	return true
+}
+
+type andNode struct {
+	left, right node
+}
+
+func (n andNode) eval(i *Item) bool {
+	return n.left.eval(i) && n.right.eval(i)
+}
+
+type orNode struct {
+	left, right node
+}
+
+func (n orNode) eval(i *Item) bool {
+	return n.left.eval(i) || n.right.eval(i)
+}
+
+type notNode struct {
+	n node
+}
+
+func (n notNode) eval(i *Item) bool {
+	return !n.n.eval(i)
+}
+
+type boolNode struct {
+	f field
+}
+
+func (n boolNode) eval(i *Item) bool {
+	v, ok := n.f.get(i).(bool)
+	return ok && v
+}
+
+type compareNode struct {
+	f     field
+	op    string
+	value interface{}
+}
+
+func (n compareNode) eval(i *Item) bool {
+	switch v := n.f.get(i).(type) {
+	case string:
+		return compareString(v, n.op, n.value.(string))
+	case []string:
+		if n.op == "!=" || n.op == "!~" {
+			for _, s := range v {
+				if !compareString(s, n.op, n.value.(string)) {
+					return false
+				}
+			}
+			return true
+		}
+
+		for _, s := range v {
+			if compareString(s, n.op, n.value.(string)) {
+				return true
+			}
+		}
+		return false
+	case float64:
+		return compareNumber(v, n.op, n.value.(float64))
+	case time.Duration:
+		return compareNumber(float64(v), n.op, float64(n.value.(time.Duration)))
+	case bool:
+		if n.op == "==" {
+			return v == n.value.(bool)
+		}
+		return v != n.value.(bool)
+	}
+
+	// The field isn't known for this item (ex. age of a track that was never added to the library)
+	return false
+}
+
+func compareString(a, op, b string) bool {
+	a = strings.ToLower(a)
+	b = strings.ToLower(b)
+
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "~":
+		return strings.Contains(a, b)
+	case "!~":
+		return !strings.Contains(a, b)
+	}
+
+	return false
+}
+
+func compareNumber(a float64, op string, b float64) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+
+	return false
+}
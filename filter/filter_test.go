@@ -0,0 +1,200 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firstlane/baton/api"
+)
+
+func TestLexer(t *testing.T) {
+	tests := []struct {
+		in      string
+		kinds   []tokenKind
+		texts   []string
+		wantErr string
+	}{
+		{"age<30d&&!explicit", []tokenKind{tokIdent, tokOp, tokDuration, tokAnd, tokNot, tokIdent}, []string{"age", "<", "30d", "&&", "!", "explicit"}, ""},
+		{"a = b", []tokenKind{tokIdent, tokOp, tokIdent}, []string{"a", "==", "b"}, ""},
+		{"x != 1 || y !~ 'z'", []tokenKind{tokIdent, tokOp, tokNumber, tokOr, tokIdent, tokOp, tokString}, []string{"x", "!=", "1", "||", "y", "!~", "'z'"}, ""},
+		{"a >= 2.5 AND b <= 3:30", []tokenKind{tokIdent, tokOp, tokNumber, tokAnd, tokIdent, tokOp, tokDuration}, []string{"a", ">=", "2.5", "AND", "b", "<=", "3:30"}, ""},
+		{"not (a or b)", []tokenKind{tokNot, tokLParen, tokIdent, tokOr, tokIdent, tokRParen}, []string{"not", "(", "a", "or", "b", ")"}, ""},
+		{"artist == 2Pac", []tokenKind{tokIdent, tokOp, tokIdent}, []string{"artist", "==", "2Pac"}, ""},
+		{`name == "say \"hi\""`, []tokenKind{tokIdent, tokOp, tokString}, []string{"name", "==", `"say \"hi\""`}, ""},
+		{"", nil, nil, ""},
+		{"a & b", nil, nil, "unexpected character '&' at position 2"},
+		{"name == 'open", nil, nil, "unterminated string starting at position 8"},
+		{"duration < 1:2:3:4", nil, nil, "invalid number or duration '1:2:3:4' at position 11"},
+		{"year == 1.2.3", nil, nil, "invalid number or duration '1.2.3' at position 8"},
+	}
+
+	for _, tt := range tests {
+		l := newLexer(tt.in)
+		var kinds []tokenKind
+		var texts []string
+		var err error
+
+		for {
+			var tok token
+
+			if tok, err = l.next(); err != nil || tok.kind == tokEOF {
+				break
+			}
+
+			kinds = append(kinds, tok.kind)
+			texts = append(texts, tok.text)
+		}
+
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("%q: error %v, want %q", tt.in, err, tt.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+
+		if len(kinds) != len(tt.kinds) || strings.Join(texts, " ") != strings.Join(tt.texts, " ") {
+			t.Errorf("%q: lexed %q, want %q", tt.in, texts, tt.texts)
+			continue
+		}
+
+		for k := range kinds {
+			if kinds[k] != tt.kinds[k] {
+				t.Errorf("%q: token %s has kind %d, want %d", tt.in, texts[k], kinds[k], tt.kinds[k])
+			}
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr string
+	}{
+		{"colour == red", "unknown field 'colour'"},
+		{"name", "expected a comparison after 'name' but found end of expression"},
+		{"name ==", "expected a text value but found end of expression for field 'name'"},
+		{"name < x", "operator '<' can't be used with text field 'name'"},
+		{"explicit ~ true", "operator '~' can't be used with true/false field 'explicit'"},
+		{"explicit == maybe", "expected a true/false value but found 'maybe' for field 'explicit'"},
+		{"duration < 5", "duration '5' needs a unit (ex. 30s, 5m, 2d) for field 'duration'"},
+		{"popularity > 5m", "expected a number value but found '5m' for field 'popularity'"},
+		{"(name == x", "expected ')' but found end of expression at position 10"},
+		{"name == x)", "unexpected ')' at position 9"},
+		{"name == x name == y", "unexpected 'name' at position 10"},
+		{"&& explicit", "expected a field name but found '&&' at position 0"},
+		{"explicit ||", "expected a field name but found end of expression at position 11"},
+		{"2Pac == artist", "unknown field '2Pac'"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.in)
+
+		if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+			t.Errorf("Parse(%q) error %v, want %q", tt.in, err, tt.wantErr)
+		}
+	}
+}
+
+func TestUses(t *testing.T) {
+	e, err := Parse("GENRE ~ house || (age < 30d && !explicit)")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{"genre", "age", "explicit"} {
+		if !e.Uses(f) {
+			t.Errorf("the expression uses %s", f)
+		}
+	}
+
+	if e.Uses("name") {
+		t.Errorf("the expression doesn't use name")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	added := time.Now().Add(-10 * 24 * time.Hour)
+	item := &Item{
+		Track: &api.FullTrack{
+			Name:        "Digital Love",
+			URI:         "spotify:track:b",
+			Artists:     []api.SimpleArtist{{Name: "Daft Punk"}, {Name: "Romanthony"}},
+			Album:       &api.SimpleAlbum{Name: "1989", ReleaseDate: "2001-03-12"},
+			DurationMs:  301000,
+			Popularity:  72,
+			TrackNumber: 3,
+			DiscNumber:  1,
+		},
+		AddedAt: &added,
+		Genres:  []string{"French House", "Electro"},
+		Source:  "saved",
+	}
+	bare := &Item{Track: &api.FullTrack{Name: "Untitled", Explicit: true}}
+
+	tests := []struct {
+		expr string
+		item *Item
+		want bool
+	}{
+		{"", item, true},
+		{"name == 'digital love'", item, true},
+		{"name = DIGITAL", item, false},
+		{"name ~ digital", item, true},
+		{"name !~ digital", item, false},
+		{"artist == romanthony", item, true},
+		{"artist != romanthony", item, false},
+		{"artist != 'justice'", item, true},
+		{"artist ~ punk", item, true},
+		{"artist !~ punk", item, false},
+		{"genre ~ house", item, true},
+		{"genre == house", item, false},
+		{"album == 1989", item, true},
+		{"album == 1989 || album == 1990", bare, false},
+		{"uri == 'spotify:track:b'", item, true},
+		{"source == saved", item, true},
+		{"duration > 5m", item, true},
+		{"duration <= 5m", item, false},
+		{"duration > 0", item, true},
+		{"duration == 5:01", item, true},
+		{"age < 30d", item, true},
+		{"age > 1w", item, true},
+		{"age < 30d", bare, false},
+		{"!(age < 30d)", bare, true},
+		{"popularity >= 72", item, true},
+		{"popularity > 72", item, false},
+		{"track_number == 3 && disc_number == 1", item, true},
+		{"year == 2001", item, true},
+		{"year > 2000", bare, false},
+		{"explicit", item, false},
+		{"explicit", bare, true},
+		{"!explicit", item, true},
+		{"explicit == false", item, true},
+		{"explicit != true", bare, false},
+		{"not explicit and name ~ love", item, true},
+		{"name ~ x || name ~ love && explicit", item, false},
+		{"(name ~ x || name ~ love) && !explicit", item, true},
+		{"name ~ love || name ~ x && explicit", item, true},
+		{"!!explicit", bare, true},
+	}
+
+	for _, tt := range tests {
+		e, err := Parse(tt.expr)
+
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+
+		if got := e.Match(tt.item); got != tt.want {
+			t.Errorf("%q matched %s: %v, want %v", tt.expr, tt.item.Track.Name, got, tt.want)
+		}
+	}
+}
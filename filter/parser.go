@@ -0,0 +1,358 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/firstlane/baton/utils"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDuration
+	tokOp
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	pos   int
+	value interface{}
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+type lexer struct {
+	input []rune
+	pos   int
+}
+
+func newLexer(s string) *lexer {
+	return &lexer{input: []rune(s)}
+}
+
+func (l *lexer) peek(offset int) rune {
+	if l.pos+offset < len(l.input) {
+		return l.input[l.pos+offset]
+	}
+
+	return 0
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.input) && unicode.IsSpace(l.input[l.pos]) {
+		l.pos++
+	}
+
+	start := l.pos
+
+	if l.pos >= len(l.input) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+
+	c := l.input[l.pos]
+
+	switch {
+	case c == '(':
+		l.pos++
+		return token{kind: tokLParen, text: "(", pos: start}, nil
+	case c == ')':
+		l.pos++
+		return token{kind: tokRParen, text: ")", pos: start}, nil
+	case c == '&' && l.peek(1) == '&':
+		l.pos += 2
+		return token{kind: tokAnd, text: "&&", pos: start}, nil
+	case c == '|' && l.peek(1) == '|':
+		l.pos += 2
+		return token{kind: tokOr, text: "||", pos: start}, nil
+	case c == '!' && l.peek(1) == '~', c == '!' && l.peek(1) == '=',
+		c == '=' && l.peek(1) == '=', c == '<' && l.peek(1) == '=', c == '>' && l.peek(1) == '=':
+		l.pos += 2
+		return token{kind: tokOp, text: string(l.input[start:l.pos]), pos: start}, nil
+	case c == '!':
+		l.pos++
+		return token{kind: tokNot, text: "!", pos: start}, nil
+	case c == '<', c == '>', c == '~':
+		l.pos++
+		return token{kind: tokOp, text: string(c), pos: start}, nil
+	case c == '=':
+		// Allow a single = as a friendlier spelling of ==
+		l.pos++
+		return token{kind: tokOp, text: "==", pos: start}, nil
+	case c == '"' || c == '\'':
+		return l.lexString(c)
+	case unicode.IsDigit(c):
+		return l.lexNumber()
+	case unicode.IsLetter(c) || c == '_':
+		for l.pos < len(l.input) && (unicode.IsLetter(l.input[l.pos]) || unicode.IsDigit(l.input[l.pos]) || l.input[l.pos] == '_') {
+			l.pos++
+		}
+
+		text := string(l.input[start:l.pos])
+
+		switch strings.ToLower(text) {
+		case "and":
+			return token{kind: tokAnd, text: text, pos: start}, nil
+		case "or":
+			return token{kind: tokOr, text: text, pos: start}, nil
+		case "not":
+			return token{kind: tokNot, text: text, pos: start}, nil
+		}
+
+		return token{kind: tokIdent, text: text, pos: start}, nil
+	}
+
+	return token{}, fmt.Errorf("unexpected character '%c' at position %d", c, start)
+}
+
+func (l *lexer) lexString(quote rune) (token, error) {
+	start := l.pos
+	var sb strings.Builder
+
+	l.pos++
+
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		l.pos++
+
+		if c == quote {
+			return token{kind: tokString, text: string(l.input[start:l.pos]), pos: start, value: sb.String()}, nil
+		}
+
+		if c == '\\' && l.pos < len(l.input) {
+			c = l.input[l.pos]
+			l.pos++
+		}
+
+		sb.WriteRune(c)
+	}
+
+	return token{}, fmt.Errorf("unterminated string starting at position %d", start)
+}
+
+func (l *lexer) lexNumber() (token, error) {
+	start := l.pos
+
+	for l.pos < len(l.input) && (unicode.IsDigit(l.input[l.pos]) || unicode.IsLetter(l.input[l.pos]) || l.input[l.pos] == '.' || l.input[l.pos] == ':') {
+		l.pos++
+	}
+
+	text := string(l.input[start:l.pos])
+
+	if n, err := strconv.ParseFloat(text, 64); err == nil {
+		return token{kind: tokNumber, text: text, pos: start, value: n}, nil
+	}
+
+	if d, err := utils.ParseDuration(text); err == nil {
+		return token{kind: tokDuration, text: text, pos: start, value: d}, nil
+	}
+
+	// Neither a number nor a duration, it's a word starting with a digit such as the artist 2Pac
+	if strings.ContainsRune(text, ':') || strings.ContainsRune(text, '.') {
+		return token{}, fmt.Errorf("invalid number or duration '%s' at position %d", text, start)
+	}
+
+	return token{kind: tokIdent, text: text, pos: start}, nil
+}
+
+type parser struct {
+	lex    *lexer
+	tok    token
+	fields map[string]bool
+}
+
+func (p *parser) next() (err error) {
+	p.tok, err = p.lex.next()
+	return err
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokOr {
+		if err = p.next(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseAnd()
+
+		if err != nil {
+			return nil, err
+		}
+
+		left = orNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokAnd {
+		if err = p.next(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseUnary()
+
+		if err != nil {
+			return nil, err
+		}
+
+		left = andNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.tok.kind == tokNot {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		n, err := p.parseUnary()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return notNode{n}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	switch p.tok.kind {
+	case tokLParen:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		n, err := p.parseOr()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if p.tok.kind != tokRParen {
+			return nil, fmt.Errorf("expected ')' but found %s at position %d", p.tok, p.tok.pos)
+		}
+
+		return n, p.next()
+	case tokIdent:
+		return p.parseComparison()
+	}
+
+	return nil, fmt.Errorf("expected a field name but found %s at position %d", p.tok, p.tok.pos)
+}
+
+func (p *parser) parseComparison() (node, error) {
+	name := strings.ToLower(p.tok.text)
+	f, ok := fields[name]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown field '%s', available fields are: %s", p.tok.text, strings.Join(Fields(), ", "))
+	}
+
+	p.fields[name] = true
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokOp {
+		if f.kind == kindBool {
+			return boolNode{f}, nil
+		}
+
+		return nil, fmt.Errorf("expected a comparison after '%s' but found %s", name, p.tok)
+	}
+
+	op := p.tok
+
+	if !utils.StringInSlice(op.text, operators[f.kind]) {
+		return nil, fmt.Errorf("operator '%s' can't be used with %s field '%s'", op.text, f.kind, name)
+	}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	value, err := p.literal(f.kind)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s for field '%s'", err, name)
+	}
+
+	return compareNode{f: f, op: op.text, value: value}, p.next()
+}
+
+func (p *parser) literal(k kind) (interface{}, error) {
+	switch k {
+	case kindString, kindStrings:
+		// Numbers are text too when compared with a text field, ex. album == 1989
+		if p.tok.kind == tokString || p.tok.kind == tokIdent || p.tok.kind == tokNumber || p.tok.kind == tokDuration {
+			if p.tok.kind == tokString {
+				return p.tok.value, nil
+			}
+			return p.tok.text, nil
+		}
+	case kindNumber:
+		if p.tok.kind == tokNumber {
+			return p.tok.value, nil
+		}
+	case kindDuration:
+		if p.tok.kind == tokDuration {
+			return p.tok.value, nil
+		}
+
+		if p.tok.kind == tokNumber && p.tok.value.(float64) == 0 {
+			return time.Duration(0), nil
+		}
+	case kindBool:
+		if p.tok.kind == tokIdent {
+			switch strings.ToLower(p.tok.text) {
+			case "true":
+				return true, nil
+			case "false":
+				return false, nil
+			}
+		}
+	}
+
+	if k == kindDuration && p.tok.kind == tokNumber {
+		return nil, fmt.Errorf("duration %s needs a unit (ex. 30s, 5m, 2d)", p.tok)
+	}
+
+	return nil, fmt.Errorf("expected a %s value but found %s", k, p.tok)
+}
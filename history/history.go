@@ -0,0 +1,349 @@
+// Package history implements the local listening history, an append-only file with one JSON entry per played track
+//
+// Spotify only remembers the last 50 played tracks, entries are collected from its recently played endpoint and
+// from `baton watch` and kept forever. An entry is a duplicate of another of the same source for the same track played
+// at the same time. The same play can also be reported by both sources, which don't agree on when it was played, see
+// Entry.Same.
+//
+// The watcher also records tracks that were skipped within 30 seconds, they aren't plays and are left out of
+// queries unless asked for.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/utils"
+)
+
+// Sources of history entries
+const (
+	SourceRecentlyPlayed = "recently-played"
+	SourceWatch          = "watch"
+)
+
+// The Entry struct describes a single play of a track
+type Entry struct {
+	PlayedAt    time.Time `json:"played_at"`
+	TrackURI    string    `json:"track_uri"`
+	Track       string    `json:"track"`
+	Artists     []string  `json:"artists"`
+	ArtistURIs  []string  `json:"artist_uris,omitempty"`
+	Album       string    `json:"album"`
+	DurationMs  int       `json:"duration_ms"`
+	ContextURI  string    `json:"context_uri,omitempty"`
+	ContextType string    `json:"context_type,omitempty"`
+	Source      string    `json:"source"`
+	ListenedMs  int       `json:"listened_ms,omitempty"`
+	Skipped     bool      `json:"skipped,omitempty"`
+}
+
+// NewEntry builds an entry for a track played at the given time
+func NewEntry(t *api.FullTrack, playedAt time.Time, ctx *api.PlayerContext, source string) Entry {
+	e := Entry{
+		PlayedAt:   playedAt.UTC(),
+		TrackURI:   t.URI,
+		Track:      t.Name,
+		DurationMs: t.DurationMs,
+		Source:     source,
+	}
+
+	for _, a := range t.Artists {
+		e.Artists = append(e.Artists, a.Name)
+		e.ArtistURIs = append(e.ArtistURIs, a.URI)
+	}
+
+	if t.Album != nil {
+		e.Album = t.Album.Name
+	}
+
+	if ctx != nil {
+		e.ContextURI = ctx.URI
+		e.ContextType = ctx.Type
+	}
+
+	return e
+}
+
+// Listened returns how long the track was listened to, entries from Spotify don't know it and count the whole track
+func (e Entry) Listened() time.Duration {
+	if e.ListenedMs > 0 {
+		return time.Duration(e.ListenedMs) * time.Millisecond
+	}
+
+	return time.Duration(e.DurationMs) * time.Millisecond
+}
+
+// crossSourceMargin is how far apart Spotify and the watcher may place the same play, it covers the watcher's polling
+const crossSourceMargin = 15 * time.Second
+
+// Same reports whether two entries describe the same play, the same track played at the same time. The watcher records
+// when a play started while Spotify may use the time it ended, a play of the watcher is the same as one from Spotify
+// when either its start or its end is within crossSourceMargin of Spotify's time. Playing a track again right after it
+// ended is another play.
+func (e Entry) Same(o Entry) bool {
+	if e.TrackURI != o.TrackURI || e.Skipped != o.Skipped {
+		return false
+	}
+
+	if e.Source == o.Source {
+		return e.PlayedAt.Equal(o.PlayedAt)
+	}
+
+	w, s := e, o
+
+	if w.Source != SourceWatch {
+		w, s = o, e
+	}
+
+	return within(w.PlayedAt, s.PlayedAt, crossSourceMargin) || within(w.PlayedAt.Add(w.Listened()), s.PlayedAt, crossSourceMargin)
+}
+
+func within(a, b time.Time, margin time.Duration) bool {
+	d := a.Sub(b)
+
+	if d < 0 {
+		d = -d
+	}
+
+	return d <= margin
+}
+
+// Store is the history file
+type Store struct {
+	Path string
+}
+
+// Open returns the store kept in the given file, the file is created on the first append
+func Open(path string) *Store {
+	return &Store{Path: path}
+}
+
+// Load returns every entry of the store sorted by the time they were played
+func (s *Store) Load() ([]Entry, error) {
+	var entries []Entry
+
+	f, err := os.Open(s.Path)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var e Entry
+
+		// A line cut short by a crash shouldn't make the rest of the history unreadable
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].PlayedAt.Before(entries[j].PlayedAt)
+	})
+
+	return entries, scanner.Err()
+}
+
+// dedupeTail is how many of the latest entries new ones are checked against. Spotify only returns the last 50 plays and
+// the watcher records a play when it ends, the duplicate of a new entry is always among the latest ones.
+const dedupeTail = 500
+
+// Append adds the entries that aren't already in the store and returns them
+func (s *Store) Append(entries []Entry) (added []Entry, err error) {
+	err = os.MkdirAll(filepath.Dir(s.Path), 0700)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// `baton watch` and history syncs may append at the same time, each of them must see what the other added
+	unlock, err := utils.LockFile(s.Path + ".lock")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	latest, err := readTail(f, dedupeTail)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if !contains(latest, e) && !contains(added, e) {
+			added = append(added, e)
+		}
+	}
+
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	w := bufio.NewWriter(f)
+
+	for _, e := range added {
+		b, err := json.Marshal(e)
+
+		if err != nil {
+			return nil, err
+		}
+
+		w.Write(b)
+		w.WriteByte('\n')
+	}
+
+	return added, w.Flush()
+}
+
+// readTail returns the last n entries of the file in the order they were written, it reads the file backwards so
+// appending doesn't get slower as the history grows
+func readTail(f *os.File, n int) ([]Entry, error) {
+	fi, err := f.Stat()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+
+	for off := fi.Size(); off > 0 && bytes.Count(buf, []byte{'\n'}) <= n; {
+		start := off - 64*1024
+
+		if start < 0 {
+			start = 0
+		}
+
+		b := make([]byte, off-start)
+
+		if _, err := f.ReadAt(b, start); err != nil {
+			return nil, err
+		}
+
+		buf = append(b, buf...)
+		off = start
+	}
+
+	lines := bytes.Split(buf, []byte{'\n'})
+
+	// The file ends with a newline, the last line is empty
+	if len(lines) > n+1 {
+		lines = lines[len(lines)-n-1:]
+	}
+
+	var entries []Entry
+
+	for _, l := range lines {
+		var e Entry
+
+		// Like in Load a broken line is skipped, so is the first line when the blocks read start in its middle
+		if json.Unmarshal(l, &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+func contains(entries []Entry, e Entry) bool {
+	// Entries are mostly appended in order, looking from the end finds recent duplicates quickly
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Same(e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The Query struct describes which entries to return from Filter, empty fields match every entry but skipped tracks are only returned with Skipped
+type Query struct {
+	Since   time.Time
+	Until   time.Time
+	Artist  string
+	Context string
+	Limit   int
+	Skipped bool
+}
+
+// Match reports whether the entry satisfies the query, Artist and Context match case-insensitive substrings
+func (q Query) Match(e Entry) bool {
+	if e.Skipped && !q.Skipped {
+		return false
+	}
+
+	if !q.Since.IsZero() && e.PlayedAt.Before(q.Since) {
+		return false
+	}
+
+	if !q.Until.IsZero() && !e.PlayedAt.Before(q.Until) {
+		return false
+	}
+
+	if q.Artist != "" && !containsFold(e.Artists, q.Artist) && !containsFold(e.ArtistURIs, q.Artist) {
+		return false
+	}
+
+	if q.Context != "" && !containsFold([]string{e.ContextURI, e.ContextType}, q.Context) {
+		return false
+	}
+
+	return true
+}
+
+// Filter returns the entries matching the query, when Limit is set only the most recent ones are kept
+func Filter(entries []Entry, q Query) []Entry {
+	var res []Entry
+
+	for _, e := range entries {
+		if q.Match(e) {
+			res = append(res, e)
+		}
+	}
+
+	if q.Limit > 0 && len(res) > q.Limit {
+		res = res[len(res)-q.Limit:]
+	}
+
+	return res
+}
+
+func containsFold(values []string, s string) bool {
+	s = strings.ToLower(s)
+
+	for _, v := range values {
+		if strings.Contains(strings.ToLower(v), s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,178 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func entry(uri string, playedAt time.Time, source string) Entry {
+	return Entry{TrackURI: uri, PlayedAt: playedAt, DurationMs: 180000, Source: source}
+}
+
+func TestSame(t *testing.T) {
+	watched := entry("spotify:track:a", t0, SourceWatch)
+	watched.ListenedMs = 180000
+
+	tests := []struct {
+		name string
+		a, b Entry
+		want bool
+	}{
+		{"same play", entry("spotify:track:a", t0, SourceRecentlyPlayed), entry("spotify:track:a", t0, SourceRecentlyPlayed), true},
+		{"other track", entry("spotify:track:a", t0, SourceRecentlyPlayed), entry("spotify:track:b", t0, SourceRecentlyPlayed), false},
+		{"played again right after", entry("spotify:track:a", t0, SourceRecentlyPlayed), entry("spotify:track:a", t0.Add(3*time.Minute), SourceRecentlyPlayed), false},
+		{"played again a second later", entry("spotify:track:a", t0, SourceWatch), entry("spotify:track:a", t0.Add(time.Second), SourceWatch), false},
+		{"spotify at the start of the watched play", watched, entry("spotify:track:a", t0.Add(5*time.Second), SourceRecentlyPlayed), true},
+		{"spotify at the end of the watched play", entry("spotify:track:a", t0.Add(3*time.Minute+10*time.Second), SourceRecentlyPlayed), watched, true},
+		{"spotify in the middle of the watched play", watched, entry("spotify:track:a", t0.Add(90*time.Second), SourceRecentlyPlayed), false},
+		{"skipped and played", watched, func() Entry { e := watched; e.Skipped = true; return e }(), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.Same(tt.b); got != tt.want {
+			t.Errorf("%s: Same = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAppendKeepsRepeatedPlays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	s := Open(filepath.Join(dir, "history.jsonl"))
+	plays := []Entry{
+		entry("spotify:track:a", t0, SourceRecentlyPlayed),
+		entry("spotify:track:a", t0.Add(3*time.Minute), SourceRecentlyPlayed),
+	}
+
+	added, err := s.Append(plays)
+
+	if err != nil || len(added) != 2 {
+		t.Fatalf("Append = %d entries, %v, want 2", len(added), err)
+	}
+
+	// Syncing again adds nothing
+	added, err = s.Append(plays)
+
+	if err != nil || len(added) != 0 {
+		t.Fatalf("second Append = %d entries, %v, want 0", len(added), err)
+	}
+
+	entries, err := s.Load()
+
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Load = %d entries, %v, want 2", len(entries), err)
+	}
+}
+
+func TestAppendChecksTheLatestEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	s := Open(filepath.Join(dir, "history.jsonl"))
+
+	// More entries than a block read from the end of the file holds
+	var plays []Entry
+
+	for k := 0; k < 2*dedupeTail; k++ {
+		plays = append(plays, entry("spotify:track:a", t0.Add(time.Duration(k)*time.Hour), SourceRecentlyPlayed))
+	}
+
+	if _, err := s.Append(plays); err != nil {
+		t.Fatal(err)
+	}
+
+	latest := plays[len(plays)-50:]
+	watched := entry("spotify:track:a", latest[10].PlayedAt.Add(-170*time.Second), SourceWatch)
+	watched.ListenedMs = 170000
+
+	if added, err := s.Append(append([]Entry{watched}, latest...)); err != nil || len(added) != 0 {
+		t.Errorf("Append of the latest plays again = %d entries, %v, want 0", len(added), err)
+	}
+}
+
+func TestConcurrentAppendsAddEntriesOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	var plays []Entry
+
+	for k := 0; k < 50; k++ {
+		plays = append(plays, entry("spotify:track:a", t0.Add(time.Duration(k)*time.Hour), SourceRecentlyPlayed))
+	}
+
+	done := make(chan error)
+
+	// Each append opens the store like separate processes do
+	for k := 0; k < 8; k++ {
+		go func() {
+			_, err := Open(filepath.Join(dir, "history.jsonl")).Append(plays)
+			done <- err
+		}()
+	}
+
+	for k := 0; k < 8; k++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if entries, err := Open(filepath.Join(dir, "history.jsonl")).Load(); err != nil || len(entries) != len(plays) {
+		t.Errorf("Load = %d entries, %v, want %d", len(entries), err, len(plays))
+	}
+}
+
+func TestFilter(t *testing.T) {
+	skipped := entry("spotify:track:b", t0.Add(time.Hour), SourceWatch)
+	skipped.Skipped = true
+
+	entries := []Entry{
+		{TrackURI: "spotify:track:a", PlayedAt: t0, Artists: []string{"Daft Punk"}, ContextType: "album"},
+		skipped,
+		{TrackURI: "spotify:track:c", PlayedAt: t0.Add(2 * time.Hour), Artists: []string{"Air"}, ContextType: "playlist"},
+	}
+
+	tests := []struct {
+		name string
+		q    Query
+		want int
+	}{
+		{"everything but skips", Query{}, 2},
+		{"with skips", Query{Skipped: true}, 3},
+		{"artist", Query{Artist: "daft"}, 1},
+		{"context", Query{Context: "playlist"}, 1},
+		{"since", Query{Since: t0.Add(time.Minute)}, 1},
+		{"until is exclusive", Query{Until: t0.Add(2 * time.Hour)}, 1},
+		{"limit keeps the latest", Query{Limit: 1}, 1},
+	}
+
+	for _, tt := range tests {
+		if got := Filter(entries, tt.q); len(got) != tt.want {
+			t.Errorf("%s: got %d entries, want %d", tt.name, len(got), tt.want)
+		}
+	}
+
+	if got := Filter(entries, Query{Limit: 1}); got[0].TrackURI != "spotify:track:c" {
+		t.Errorf("limit kept %s, want the latest play", got[0].TrackURI)
+	}
+}
@@ -0,0 +1,265 @@
+package history
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// The Count struct describes how often an artist, track, album or genre shows up in the history
+type Count struct {
+	Name       string `json:"name"`
+	URI        string `json:"uri,omitempty"`
+	Plays      int    `json:"plays"`
+	ListenedMs int64  `json:"listened_ms"`
+}
+
+// The Heatmap struct describes the time spent listening for every hour of every weekday, Minutes[0] is Sunday like time.Weekday
+type Heatmap struct {
+	Minutes [7][24]int `json:"minutes"`
+}
+
+// The Streak struct describes consecutive days with at least one play
+type Streak struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	Days  int    `json:"days"`
+}
+
+// counter accumulates Counts by key while remembering the order keys were first seen so ties are stable
+type counter struct {
+	counts map[string]*Count
+	keys   []string
+}
+
+func newCounter() *counter {
+	return &counter{counts: make(map[string]*Count)}
+}
+
+func (c *counter) add(key, name, uri string, listened time.Duration) {
+	if key == "" {
+		return
+	}
+
+	n, ok := c.counts[key]
+
+	if !ok {
+		n = &Count{Name: name, URI: uri}
+		c.counts[key] = n
+		c.keys = append(c.keys, key)
+	}
+
+	n.Plays++
+	n.ListenedMs += int64(listened / time.Millisecond)
+}
+
+// top returns the n most played entries, n <= 0 returns all of them
+func (c *counter) top(n int) []Count {
+	res := make([]Count, 0, len(c.keys))
+
+	for _, k := range c.keys {
+		res = append(res, *c.counts[k])
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Plays != res[j].Plays {
+			return res[i].Plays > res[j].Plays
+		}
+		return res[i].ListenedMs > res[j].ListenedMs
+	})
+
+	if n > 0 && len(res) > n {
+		res = res[:n]
+	}
+
+	return res
+}
+
+// TopArtists returns the n most played artists, a track by several artists counts for each of them
+func TopArtists(entries []Entry, n int) []Count {
+	c := newCounter()
+
+	for _, e := range entries {
+		if e.Skipped {
+			continue
+		}
+
+		seen := make(map[string]bool)
+
+		for k, a := range e.Artists {
+			// An artist credited twice on the same track still played once
+			if seen[strings.ToLower(a)] {
+				continue
+			}
+
+			seen[strings.ToLower(a)] = true
+			uri := ""
+
+			if k < len(e.ArtistURIs) {
+				uri = e.ArtistURIs[k]
+			}
+
+			c.add(strings.ToLower(a), a, uri, e.Listened())
+		}
+	}
+
+	return c.top(n)
+}
+
+// TopTracks returns the n most played tracks
+func TopTracks(entries []Entry, n int) []Count {
+	c := newCounter()
+
+	for _, e := range entries {
+		if !e.Skipped {
+			c.add(e.TrackURI, strings.Join(e.Artists, ", ")+" - "+e.Track, e.TrackURI, e.Listened())
+		}
+	}
+
+	return c.top(n)
+}
+
+// TopAlbums returns the n most played albums
+func TopAlbums(entries []Entry, n int) []Count {
+	c := newCounter()
+
+	for _, e := range entries {
+		if e.Skipped || e.Album == "" {
+			continue
+		}
+
+		artist := ""
+
+		if len(e.Artists) > 0 {
+			artist = e.Artists[0]
+		}
+
+		c.add(strings.ToLower(artist+"\x00"+e.Album), artist+" - "+e.Album, "", e.Listened())
+	}
+
+	return c.top(n)
+}
+
+// MostSkipped returns the n tracks skipped most often, Plays holds the number of skips
+func MostSkipped(entries []Entry, n int) []Count {
+	c := newCounter()
+
+	for _, e := range entries {
+		if e.Skipped {
+			c.add(e.TrackURI, strings.Join(e.Artists, ", ")+" - "+e.Track, e.TrackURI, e.Listened())
+		}
+	}
+
+	return c.top(n)
+}
+
+// TopGenres returns the n genres listened to the most, genres maps artist URIs to their genres and a play counts once for every genre of its artists
+func TopGenres(entries []Entry, genres map[string][]string, n int) []Count {
+	c := newCounter()
+
+	for _, e := range entries {
+		if e.Skipped {
+			continue
+		}
+
+		seen := make(map[string]bool)
+
+		for _, uri := range e.ArtistURIs {
+			for _, g := range genres[uri] {
+				if !seen[g] {
+					seen[g] = true
+					c.add(g, g, "", e.Listened())
+				}
+			}
+		}
+	}
+
+	return c.top(n)
+}
+
+// ListeningHeatmap adds up the minutes listened for every weekday and hour in the given time zone
+func ListeningHeatmap(entries []Entry, loc *time.Location) Heatmap {
+	var h Heatmap
+	var ms [7][24]int64
+
+	for _, e := range entries {
+		if e.Skipped {
+			continue
+		}
+
+		t := e.PlayedAt.In(loc)
+		ms[t.Weekday()][t.Hour()] += int64(e.Listened() / time.Millisecond)
+	}
+
+	for d := range ms {
+		for hr := range ms[d] {
+			h.Minutes[d][hr] = int((ms[d][hr] + 30000) / 60000)
+		}
+	}
+
+	return h
+}
+
+// ListeningPerDay returns the minutes listened on each day (formatted as 2006-01-02 in the given time zone)
+func ListeningPerDay(entries []Entry, loc *time.Location) map[string]int {
+	ms := make(map[string]int64)
+
+	for _, e := range entries {
+		if !e.Skipped {
+			ms[e.PlayedAt.In(loc).Format("2006-01-02")] += int64(e.Listened() / time.Millisecond)
+		}
+	}
+
+	days := make(map[string]int)
+
+	for d, v := range ms {
+		days[d] = int((v + 30000) / 60000)
+	}
+
+	return days
+}
+
+// Streaks returns the longest run of consecutive days with plays and the run that ends today (or yesterday, today isn't over yet)
+func Streaks(entries []Entry, loc *time.Location, now time.Time) (current, longest Streak) {
+	var days []time.Time
+	seen := make(map[string]bool)
+
+	for _, e := range entries {
+		t := e.PlayedAt.In(loc)
+		k := t.Format("2006-01-02")
+
+		if !e.Skipped && !seen[k] {
+			seen[k] = true
+			days = append(days, time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc))
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+
+	var run Streak
+
+	for k, d := range days {
+		// Adding a day by date avoids miscounting days that are 23 or 25 hours long
+		if k > 0 && days[k-1].AddDate(0, 0, 1).Equal(d) {
+			run.Days++
+			run.End = d.Format("2006-01-02")
+		} else {
+			run = Streak{Start: d.Format("2006-01-02"), End: d.Format("2006-01-02"), Days: 1}
+		}
+
+		if run.Days > longest.Days {
+			longest = run
+		}
+	}
+
+	today := now.In(loc).Format("2006-01-02")
+	yesterday := now.In(loc).AddDate(0, 0, -1).Format("2006-01-02")
+
+	if run.End == today || run.End == yesterday {
+		current = run
+	}
+
+	return current, longest
+}
@@ -0,0 +1,176 @@
+package history
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// play builds an entry for a track by the given artists, artist URIs are derived from the names
+func play(uri, track, album string, playedAt time.Time, artists ...string) Entry {
+	e := entry(uri, playedAt, SourceWatch)
+	e.Track, e.Album, e.Artists = track, album, artists
+
+	for _, a := range artists {
+		e.ArtistURIs = append(e.ArtistURIs, "spotify:artist:"+a)
+	}
+
+	return e
+}
+
+func skip(e Entry) Entry {
+	e.Skipped = true
+	e.ListenedMs = 10000
+	return e
+}
+
+func counts(c []Count) string {
+	res := ""
+
+	for _, n := range c {
+		res += fmt.Sprintf("%s:%d:%d ", n.Name, n.Plays, n.ListenedMs/1000)
+	}
+
+	return res
+}
+
+func TestTopCounts(t *testing.T) {
+	short := play("spotify:track:c", "C", "Y", t0.Add(2*time.Hour), "Bo")
+	short.ListenedMs = 60000
+
+	entries := []Entry{
+		play("spotify:track:a", "A", "X", t0, "Al", "Bo"),
+		play("spotify:track:a", "A", "X", t0.Add(time.Hour), "al", "Bo"),
+		short,
+		play("spotify:track:b", "B", "X", t0.Add(3*time.Hour), "Cy", "Cy"),
+		play("spotify:track:d", "D", "", t0.Add(4*time.Hour), "Cy"),
+		skip(play("spotify:track:c", "C", "Y", t0.Add(5*time.Hour), "Bo")),
+		skip(play("spotify:track:c", "C", "Y", t0.Add(6*time.Hour), "Bo")),
+		skip(play("spotify:track:b", "B", "X", t0.Add(7*time.Hour), "Cy")),
+	}
+
+	tests := []struct {
+		name string
+		got  []Count
+		want string
+	}{
+		{"artists", TopArtists(entries, 0), "Bo:3:420 Al:2:360 Cy:2:360 "},
+		{"artists limited", TopArtists(entries, 1), "Bo:3:420 "},
+		{"tracks", TopTracks(entries, 0), "Al, Bo - A:2:360 Cy, Cy - B:1:180 Cy - D:1:180 Bo - C:1:60 "},
+		{"albums", TopAlbums(entries, 0), "Al - X:2:360 Cy - X:1:180 Bo - Y:1:60 "},
+		{"skipped", MostSkipped(entries, 0), "Bo - C:2:20 Cy - B:1:10 "},
+		{"nothing", TopTracks(nil, 5), ""},
+	}
+
+	for _, tt := range tests {
+		if got := counts(tt.got); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if top := TopArtists(entries, 0); top[0].URI != "spotify:artist:Bo" || top[1].URI != "spotify:artist:Al" {
+		t.Errorf("artist URIs are %q and %q", top[0].URI, top[1].URI)
+	}
+}
+
+func TestTopGenres(t *testing.T) {
+	genres := map[string][]string{
+		"spotify:artist:Al": {"house", "disco"},
+		"spotify:artist:Bo": {"house"},
+	}
+
+	entries := []Entry{
+		play("spotify:track:a", "A", "X", t0, "Al", "Bo"),
+		play("spotify:track:b", "B", "X", t0, "Bo"),
+		play("spotify:track:c", "C", "X", t0, "Cy"),
+		skip(play("spotify:track:d", "D", "X", t0, "Al")),
+	}
+
+	if got, want := counts(TopGenres(entries, genres, 0)), "house:2:360 disco:1:180 "; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestListeningHeatmapAndPerDay(t *testing.T) {
+	loc := time.FixedZone("UTC-5", -5*60*60)
+	half := entry("spotify:track:b", t0.Add(time.Hour), SourceWatch)
+	half.ListenedMs = 29000
+
+	// t0 is Thursday 12:00 UTC, 7:00 in loc
+	entries := []Entry{
+		entry("spotify:track:a", t0, SourceWatch),
+		entry("spotify:track:a", t0.Add(3*time.Minute), SourceWatch),
+		half,
+		skip(entry("spotify:track:c", t0, SourceWatch)),
+		entry("spotify:track:a", time.Date(2026, 10, 2, 3, 0, 0, 0, time.UTC), SourceWatch),
+	}
+
+	h := ListeningHeatmap(entries, loc)
+	total := 0
+
+	for d := range h.Minutes {
+		for hr := range h.Minutes[d] {
+			total += h.Minutes[d][hr]
+		}
+	}
+
+	if h.Minutes[time.Thursday][7] != 6 || h.Minutes[time.Thursday][8] != 0 || h.Minutes[time.Thursday][22] != 3 || total != 9 {
+		t.Errorf("heatmap has %d minutes on Thursday at 7, %d at 8, %d at 22 and %d in all", h.Minutes[time.Thursday][7], h.Minutes[time.Thursday][8], h.Minutes[time.Thursday][22], total)
+	}
+
+	days := ListeningPerDay(entries, loc)
+
+	if len(days) != 1 || days["2026-10-01"] != 9 {
+		t.Errorf("minutes per day are %v", days)
+	}
+}
+
+func TestStreaks(t *testing.T) {
+	loc := time.UTC
+	day := func(d int) Entry {
+		return entry("spotify:track:a", time.Date(2026, 10, d, 20, 0, 0, 0, loc), SourceWatch)
+	}
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, loc)
+
+	tests := []struct {
+		name             string
+		entries          []Entry
+		current, longest Streak
+	}{
+		{"no plays", nil, Streak{}, Streak{}},
+		{"played today", []Entry{day(19)}, Streak{"2026-10-19", "2026-10-19", 1}, Streak{"2026-10-19", "2026-10-19", 1}},
+		{"played until yesterday", []Entry{day(16), day(18), day(17), day(17)}, Streak{"2026-10-16", "2026-10-18", 3}, Streak{"2026-10-16", "2026-10-18", 3}},
+		{"broken streak", []Entry{day(10), day(11), day(12), day(17)}, Streak{}, Streak{"2026-10-10", "2026-10-12", 3}},
+		{"longest first", []Entry{day(1), day(2), day(3), day(18), day(19)}, Streak{"2026-10-18", "2026-10-19", 2}, Streak{"2026-10-01", "2026-10-03", 3}},
+		{"skips don't count", []Entry{day(17), skip(day(18)), day(19)}, Streak{"2026-10-19", "2026-10-19", 1}, Streak{"2026-10-17", "2026-10-17", 1}},
+	}
+
+	for _, tt := range tests {
+		current, longest := Streaks(tt.entries, loc, now)
+
+		if current != tt.current || longest != tt.longest {
+			t.Errorf("%s: got %+v and %+v, want %+v and %+v", tt.name, current, longest, tt.current, tt.longest)
+		}
+	}
+}
+
+func TestStreaksAcrossDaylightSaving(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Paris")
+
+	if err != nil {
+		t.Skip("the time zone database isn't available")
+	}
+
+	// Clocks go back on October 25 2026 in Paris, that day is 25 hours long
+	var entries []Entry
+
+	for d := 24; d <= 26; d++ {
+		entries = append(entries, entry("spotify:track:a", time.Date(2026, 10, d, 23, 30, 0, 0, loc), SourceWatch))
+	}
+
+	current, longest := Streaks(entries, loc, time.Date(2026, 10, 27, 8, 0, 0, 0, loc))
+
+	if want := (Streak{"2026-10-24", "2026-10-26", 3}); current != want || longest != want {
+		t.Errorf("got %+v and %+v, want %+v", current, longest, want)
+	}
+}
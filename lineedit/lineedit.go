@@ -0,0 +1,478 @@
+// Package lineedit reads lines from a terminal with editing, history and completion, a minimal readline
+//
+// The line scrolls horizontally when it doesn't fit the terminal. When the input isn't a terminal lines are read
+// as they are, without a prompt.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	runewidth "github.com/mattn/go-runewidth"
+)
+
+// ErrInterrupted is returned by ReadLine when Ctrl-C is pressed
+var ErrInterrupted = errors.New("interrupted")
+
+// CompleteFunc returns the candidates for the word that ends at the cursor and where that word starts in the line, a
+// candidate replaces the whole word
+type CompleteFunc func(line []rune, pos int) (candidates []string, start int)
+
+// Keys that aren't runes
+const (
+	keyUnknown = -(iota + 1)
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyWordLeft
+	keyWordRight
+)
+
+// Control characters
+const (
+	ctrlA     = 1
+	ctrlB     = 2
+	ctrlC     = 3
+	ctrlD     = 4
+	ctrlE     = 5
+	ctrlF     = 6
+	ctrlH     = 8
+	tab       = 9
+	ctrlK     = 11
+	ctrlL     = 12
+	enter     = 13
+	ctrlN     = 14
+	ctrlP     = 16
+	ctrlU     = 21
+	ctrlW     = 23
+	esc       = 27
+	backspace = 127
+)
+
+// Editor reads lines from In and draws them on Out
+type Editor struct {
+	In       *os.File
+	Out      io.Writer
+	Complete CompleteFunc
+	// History holds the previous lines, oldest first
+	History    []string
+	MaxHistory int
+
+	r *bufio.Reader
+}
+
+// The line struct describes the line being edited
+type line struct {
+	prompt  string
+	buf     []rune
+	pos     int
+	offset  int
+	history int
+	// saved keeps the new line while browsing the history
+	saved   []rune
+	lastTab bool
+}
+
+// New returns an editor for the given terminal
+func New(in *os.File, out io.Writer) *Editor {
+	return &Editor{In: in, Out: out, MaxHistory: 1000, r: bufio.NewReader(in)}
+}
+
+// AddHistory appends a line to the history, empty lines and repeats of the last line are left out
+func (e *Editor) AddHistory(l string) {
+	if strings.TrimSpace(l) == "" || (len(e.History) > 0 && e.History[len(e.History)-1] == l) {
+		return
+	}
+
+	e.History = append(e.History, l)
+
+	if e.MaxHistory > 0 && len(e.History) > e.MaxHistory {
+		e.History = e.History[len(e.History)-e.MaxHistory:]
+	}
+}
+
+// ReadLine shows the prompt and returns the line that was entered, it returns io.EOF for Ctrl-D on an empty line and
+// ErrInterrupted for Ctrl-C
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(e.In)
+
+	if err != nil {
+		return e.readPlain()
+	}
+
+	defer restore()
+
+	return e.edit(prompt)
+}
+
+// edit reads keys until the line is entered, the terminal is already in raw mode
+func (e *Editor) edit(prompt string) (string, error) {
+	l := &line{prompt: prompt, history: len(e.History)}
+	e.refresh(l)
+
+	for {
+		k, err := e.readKey()
+
+		if err != nil {
+			return "", err
+		}
+
+		tabbed := false
+
+		switch k {
+		case enter, '\n':
+			fmt.Fprint(e.Out, "\r\n")
+			return string(l.buf), nil
+		case ctrlC:
+			fmt.Fprint(e.Out, "^C\r\n")
+			return "", ErrInterrupted
+		case ctrlD:
+			if len(l.buf) == 0 {
+				fmt.Fprint(e.Out, "\r\n")
+				return "", io.EOF
+			}
+
+			l.delete(l.pos, l.pos+1)
+		case keyDelete:
+			l.delete(l.pos, l.pos+1)
+		case backspace, ctrlH:
+			if l.pos > 0 {
+				l.delete(l.pos-1, l.pos)
+			}
+		case ctrlA, keyHome:
+			l.pos = 0
+		case ctrlE, keyEnd:
+			l.pos = len(l.buf)
+		case ctrlB, keyLeft:
+			if l.pos > 0 {
+				l.pos--
+			}
+		case ctrlF, keyRight:
+			if l.pos < len(l.buf) {
+				l.pos++
+			}
+		case keyWordLeft:
+			l.pos = l.wordStart()
+		case keyWordRight:
+			l.pos = l.wordEnd()
+		case ctrlK:
+			l.delete(l.pos, len(l.buf))
+		case ctrlU:
+			l.delete(0, l.pos)
+		case ctrlW:
+			l.delete(l.wordStart(), l.pos)
+		case ctrlL:
+			fmt.Fprint(e.Out, "\x1b[H\x1b[2J")
+		case ctrlP, keyUp:
+			e.browse(l, -1)
+		case ctrlN, keyDown:
+			e.browse(l, 1)
+		case tab:
+			tabbed = true
+			e.complete(l)
+		default:
+			if k >= ' ' {
+				l.insert([]rune{rune(k)})
+			}
+		}
+
+		l.lastTab = tabbed
+		e.refresh(l)
+	}
+}
+
+// readPlain reads a line when the input isn't a terminal
+func (e *Editor) readPlain() (string, error) {
+	s, err := e.r.ReadString('\n')
+
+	if err != nil && (err != io.EOF || s == "") {
+		return "", err
+	}
+
+	return strings.TrimRight(s, "\r\n"), nil
+}
+
+// readKey reads a key press, escape sequences of the arrow, home, end and delete keys are decoded
+func (e *Editor) readKey() (int, error) {
+	r, _, err := e.r.ReadRune()
+
+	if err != nil || r != esc {
+		return int(r), err
+	}
+
+	// A lone escape key isn't followed by anything
+	if e.r.Buffered() == 0 {
+		return keyUnknown, nil
+	}
+
+	r, _, err = e.r.ReadRune()
+
+	if err != nil {
+		return 0, err
+	}
+
+	switch r {
+	case 'b':
+		return keyWordLeft, nil
+	case 'f':
+		return keyWordRight, nil
+	case '[', 'O':
+	default:
+		return keyUnknown, nil
+	}
+
+	var params []rune
+
+	for {
+		r, _, err = e.r.ReadRune()
+
+		if err != nil {
+			return 0, err
+		}
+
+		if r >= 0x40 && r <= 0x7e {
+			break
+		}
+
+		params = append(params, r)
+	}
+
+	switch r {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	case '~':
+		switch string(params) {
+		case "1", "7":
+			return keyHome, nil
+		case "4", "8":
+			return keyEnd, nil
+		case "3":
+			return keyDelete, nil
+		}
+	}
+
+	return keyUnknown, nil
+}
+
+func (l *line) insert(rs []rune) {
+	buf := append([]rune{}, l.buf[:l.pos]...)
+	buf = append(buf, rs...)
+	l.buf = append(buf, l.buf[l.pos:]...)
+	l.pos += len(rs)
+}
+
+func (l *line) delete(from, to int) {
+	if to > len(l.buf) {
+		to = len(l.buf)
+	}
+
+	if from >= to {
+		return
+	}
+
+	l.buf = append(l.buf[:from:from], l.buf[to:]...)
+
+	if l.pos > to {
+		l.pos -= to - from
+	} else if l.pos > from {
+		l.pos = from
+	}
+}
+
+// wordStart returns the start of the word before the cursor
+func (l *line) wordStart() int {
+	i := l.pos
+
+	for i > 0 && l.buf[i-1] == ' ' {
+		i--
+	}
+
+	for i > 0 && l.buf[i-1] != ' ' {
+		i--
+	}
+
+	return i
+}
+
+// wordEnd returns the end of the word after the cursor
+func (l *line) wordEnd() int {
+	i := l.pos
+
+	for i < len(l.buf) && l.buf[i] == ' ' {
+		i++
+	}
+
+	for i < len(l.buf) && l.buf[i] != ' ' {
+		i++
+	}
+
+	return i
+}
+
+// browse moves through the history, dir is -1 for older lines and 1 for newer ones
+func (e *Editor) browse(l *line, dir int) {
+	i := l.history + dir
+
+	if i < 0 || i > len(e.History) {
+		return
+	}
+
+	if l.history == len(e.History) {
+		l.saved = l.buf
+	}
+
+	l.history = i
+
+	if i == len(e.History) {
+		l.buf = l.saved
+	} else {
+		l.buf = []rune(e.History[i])
+	}
+
+	l.pos = len(l.buf)
+}
+
+// complete replaces the word at the cursor with the only candidate or the prefix all candidates share, a second
+// tab lists the candidates
+func (e *Editor) complete(l *line) {
+	if e.Complete == nil {
+		return
+	}
+
+	candidates, start := e.Complete(l.buf, l.pos)
+
+	if len(candidates) == 0 || start < 0 || start > l.pos {
+		fmt.Fprint(e.Out, "\a")
+		return
+	}
+
+	word := l.buf[start:l.pos]
+
+	if len(candidates) == 1 {
+		l.delete(start, l.pos)
+		l.insert([]rune(candidates[0] + " "))
+		return
+	}
+
+	prefix := []rune(candidates[0])
+
+	for _, c := range candidates[1:] {
+		rs := []rune(c)
+		n := 0
+
+		for n < len(prefix) && n < len(rs) && prefix[n] == rs[n] {
+			n++
+		}
+
+		prefix = prefix[:n]
+	}
+
+	if len(prefix) > len(word) {
+		l.delete(start, l.pos)
+		l.insert(prefix)
+		return
+	}
+
+	if !l.lastTab {
+		fmt.Fprint(e.Out, "\a")
+		return
+	}
+
+	e.list(candidates)
+}
+
+// list prints the candidates in columns below the line
+func (e *Editor) list(candidates []string) {
+	width := terminalWidth(e.In)
+	cell := 0
+
+	for _, c := range candidates {
+		if w := runewidth.StringWidth(c) + 2; w > cell {
+			cell = w
+		}
+	}
+
+	cols := width / cell
+
+	if cols < 1 {
+		cols = 1
+	}
+
+	rows := (len(candidates) + cols - 1) / cols
+
+	fmt.Fprint(e.Out, "\r\n")
+
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			if i := c*rows + r; i < len(candidates) {
+				fmt.Fprint(e.Out, runewidth.FillRight(candidates[i], cell))
+			}
+		}
+
+		fmt.Fprint(e.Out, "\r\n")
+	}
+}
+
+// refresh redraws the line, it scrolls so the cursor stays visible
+func (e *Editor) refresh(l *line) {
+	width := terminalWidth(e.In)
+	promptWidth := runewidth.StringWidth(l.prompt)
+	avail := width - promptWidth - 1
+
+	if avail < 1 {
+		avail = 1
+	}
+
+	if l.offset > l.pos {
+		l.offset = l.pos
+	}
+
+	for l.offset < l.pos && runewidth.StringWidth(string(l.buf[l.offset:l.pos])) > avail {
+		l.offset++
+	}
+
+	end := l.offset
+	used := 0
+
+	for end < len(l.buf) {
+		w := runewidth.RuneWidth(l.buf[end])
+
+		if used+w > avail {
+			break
+		}
+
+		used += w
+		end++
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("\r")
+	sb.WriteString(l.prompt)
+	sb.WriteString(string(l.buf[l.offset:end]))
+	sb.WriteString("\x1b[K\r")
+
+	if col := promptWidth + runewidth.StringWidth(string(l.buf[l.offset:l.pos])); col > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dC", col)
+	}
+
+	fmt.Fprint(e.Out, sb.String())
+}
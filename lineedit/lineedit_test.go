@@ -0,0 +1,199 @@
+package lineedit
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// editor reads the keys from a string instead of a terminal
+func editor(keys string) (*Editor, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &Editor{Out: out, MaxHistory: 1000, r: bufio.NewReader(strings.NewReader(keys))}, out
+}
+
+func TestEditing(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"typing", "play\r", "play"},
+		{"newline", "play\n", "play"},
+		{"backspace", "playy\x7f\r", "play"},
+		{"insert after moving left", "pay\x1b[D\x1b[Dl\r", "play"},
+		{"ctrl-b and ctrl-f", "py\x02\x02\x06l\r", "ply"},
+		{"home and end keys", "lay\x1b[Hp\x1b[F!\r", "play!"},
+		{"home and end escapes", "lay\x1b[1~p\x1b[4~!\r", "play!"},
+		{"delete key", "xplay\x01\x1b[3~\r", "play"},
+		{"ctrl-d deletes under the cursor", "xplay\x01\x04\r", "play"},
+		{"ctrl-k", "play artist\x01\x06\x06\x06\x06\x0b\r", "play"},
+		{"ctrl-u", "pause play\x1b[D\x1b[D\x1b[D\x1b[D\x15\r", "play"},
+		{"ctrl-w", "play artist daft\x17muse\r", "play artist muse"},
+		{"ctrl-w over spaces", "play artist  \x17\r", "play "},
+		{"word left and right", "play daft\x1bb\x1bbx\x1bf\x1bfy\r", "xplay dafty"},
+		{"unknown escape", "pl\x1b[Zay\r", "play"},
+		{"wide runes", "日本\x1b[Dx\r", "日x本"},
+	}
+
+	for _, tt := range tests {
+		e, _ := editor(tt.keys)
+
+		if got, err := e.edit("> "); err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestInterruptAndEOF(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want error
+	}{
+		{"ctrl-c", "play\x03", ErrInterrupted},
+		{"ctrl-d on an empty line", "\x04", io.EOF},
+		{"end of the input", "pla", io.EOF},
+	}
+
+	for _, tt := range tests {
+		e, _ := editor(tt.keys)
+
+		if _, err := e.edit(""); err != tt.want {
+			t.Errorf("%s: returned %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"previous line", "\x1b[A\r", "next"},
+		{"older line", "\x1b[A\x10\r", "play"},
+		{"past the oldest", "\x1b[A\x1b[A\x1b[A\x1b[A\r", "play"},
+		{"back to the new line", "vol\x1b[A\x1b[A\x1b[B\x0e\r", "vol"},
+		{"edit a previous line", "\x1b[A\x1b[A\x7f\x7f\x7f\x7fpause\r", "pause"},
+	}
+
+	for _, tt := range tests {
+		e, _ := editor(tt.keys)
+		e.History = []string{"play", "next"}
+
+		if got, err := e.edit(""); err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+
+		if len(e.History) != 2 || e.History[0] != "play" || e.History[1] != "next" {
+			t.Errorf("%s: browsing changed the history to %q", tt.name, e.History)
+		}
+	}
+}
+
+func TestAddHistory(t *testing.T) {
+	e, _ := editor("")
+	e.MaxHistory = 3
+
+	for _, l := range []string{"play", "", "  ", "next", "next", "pause", "play"} {
+		e.AddHistory(l)
+	}
+
+	if got := strings.Join(e.History, ","); got != "next,pause,play" {
+		t.Errorf("got the history %q", got)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	words := []string{"pause", "play", "playlist"}
+
+	// Completes the last word against the words above
+	complete := func(line []rune, pos int) ([]string, int) {
+		start := strings.LastIndex(string(line[:pos]), " ") + 1
+		word := string(line[start:pos])
+		var res []string
+
+		for _, w := range words {
+			if strings.HasPrefix(w, word) {
+				res = append(res, w)
+			}
+		}
+
+		return res, start
+	}
+
+	tests := []struct {
+		name   string
+		keys   string
+		want   string
+		listed bool
+	}{
+		{"only candidate", "help pau\t\r", "help pause ", false},
+		{"shared prefix", "pl\t\r", "play", false},
+		{"no candidate", "x\t\r", "x", false},
+		{"first tab on ambiguous word", "p\t\r", "p", false},
+		{"second tab lists", "p\t\t\r", "p", true},
+		{"tabs apart don't list", "p\tx\x7f\t\r", "p", false},
+	}
+
+	for _, tt := range tests {
+		e, out := editor(tt.keys)
+		e.Complete = complete
+
+		got, err := e.edit("")
+
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+
+		if listed := strings.Contains(out.String(), "playlist  "); listed != tt.listed {
+			t.Errorf("%s: listed the candidates %v, want %v", tt.name, listed, tt.listed)
+		}
+	}
+}
+
+func TestReadLineWithoutTerminal(t *testing.T) {
+	r, w, err := os.Pipe()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.Close()
+
+	w.WriteString("play\r\nnext")
+	w.Close()
+
+	e := New(r, ioutil.Discard)
+
+	for _, want := range []string{"play", "next"} {
+		if got, err := e.ReadLine("> "); err != nil || got != want {
+			t.Errorf("got %q, %v, want %q", got, err, want)
+		}
+	}
+
+	if _, err := e.ReadLine("> "); err != io.EOF {
+		t.Errorf("the end of the input returned %v", err)
+	}
+}
+
+func TestRefreshScrolls(t *testing.T) {
+	e, out := editor(strings.Repeat("a", 100) + "b\r")
+
+	if _, err := e.edit("> "); err != nil {
+		t.Fatal(err)
+	}
+
+	// The last redraw shows the end of the line within the 80 columns
+	draws := strings.Split(out.String(), "\r> ")
+	last := draws[len(draws)-1]
+
+	if i := strings.Index(last, "\x1b[K"); i < 0 || !strings.HasSuffix(last[:i], "ab") || i > 80-2 {
+		t.Errorf("the last redraw was %q", last)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package lineedit
+
+import (
+	"errors"
+	"os"
+)
+
+// makeRaw isn't implemented on this platform, lines are read without editing
+func makeRaw(f *os.File) (restore func(), err error) {
+	return nil, errors.New("raw mode isn't supported on this platform")
+}
+
+func terminalWidth(f *os.File) int {
+	return 80
+}
@@ -0,0 +1,1162 @@
+package mpd
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/firstlane/baton/api"
+)
+
+// The tags clients can search for and that songs are described with
+var tagTypes = []string{"Artist", "AlbumArtist", "Album", "Title", "Track", "Disc", "Date"}
+
+// filterTags holds the tags of tagTypes lower-cased for search and find, with any that matches every tag and file
+// that matches the uri
+var filterTags = map[string]bool{
+	"artist": true, "albumartist": true, "album": true, "title": true, "track": true, "disc": true, "date": true,
+	"any": true, "file": true,
+}
+
+// commandHandler runs a command, args holds the arguments after the command name
+type commandHandler func(s *Server, res *response, args []string) error
+
+var commands map[string]commandHandler
+
+func init() {
+	commands = map[string]commandHandler{
+		"ping":               func(s *Server, res *response, args []string) error { return nil },
+		"status":             cmdStatus,
+		"currentsong":        cmdCurrentSong,
+		"stats":              cmdStats,
+		"play":               cmdPlay,
+		"playid":             cmdPlayID,
+		"pause":              cmdPause,
+		"stop":               cmdStop,
+		"next":               cmdNext,
+		"previous":           cmdPrevious,
+		"seek":               cmdSeek,
+		"seekid":             cmdSeekID,
+		"seekcur":            cmdSeekCur,
+		"setvol":             cmdSetVol,
+		"volume":             cmdVolume,
+		"getvol":             cmdGetVol,
+		"random":             cmdRandom,
+		"repeat":             cmdRepeat,
+		"single":             cmdSingle,
+		"consume":            cmdConsume,
+		"playlistinfo":       cmdPlaylistInfo,
+		"playlistid":         cmdPlaylistID,
+		"plchanges":          cmdPlChanges,
+		"plchangesposid":     cmdPlChangesPosID,
+		"add":                cmdAdd,
+		"addid":              cmdAddID,
+		"search":             cmdSearch(false, false),
+		"find":               cmdSearch(true, false),
+		"searchadd":          cmdSearch(false, true),
+		"findadd":            cmdSearch(true, true),
+		"listplaylists":      cmdListPlaylists,
+		"listplaylist":       cmdListPlaylist(false),
+		"listplaylistinfo":   cmdListPlaylist(true),
+		"load":               cmdLoad,
+		"lsinfo":             cmdListPlaylists,
+		"list":               cmdList,
+		"tagtypes":           cmdTagTypes,
+		"outputs":            cmdOutputs,
+		"urlhandlers":        func(s *Server, res *response, args []string) error { res.field("handler", "spotify:"); return nil },
+		"decoders":           func(s *Server, res *response, args []string) error { return nil },
+		"channels":           func(s *Server, res *response, args []string) error { return nil },
+		"readmessages":       func(s *Server, res *response, args []string) error { return nil },
+		"replay_gain_status": func(s *Server, res *response, args []string) error { res.field("replay_gain_mode", "off"); return nil },
+		"commands":           cmdCommands,
+		"notcommands":        func(s *Server, res *response, args []string) error { return nil },
+		"clear":              cmdUnsupported,
+		"delete":             cmdUnsupported,
+		"deleteid":           cmdUnsupported,
+		"move":               cmdUnsupported,
+		"moveid":             cmdUnsupported,
+		"shuffle":            cmdUnsupported,
+	}
+}
+
+// command runs a single command, the password command is the only one allowed before authenticating
+func (s *Server) command(c *client, res *response, args []string) error {
+	name := args[0]
+
+	if name == "password" {
+		if len(args) != 2 || subtle.ConstantTimeCompare([]byte(args[1]), []byte(s.Password)) != 1 {
+			return newAck(ackErrorPassword, "incorrect password")
+		}
+
+		c.authed = true
+
+		return nil
+	}
+
+	if !c.authed {
+		return newAck(ackErrorPermission, "you don't have permission for \"%s\"", name)
+	}
+
+	h, ok := commands[name]
+
+	if !ok {
+		return newAck(ackErrorUnknown, "unknown command \"%s\"", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return h(s, res, args[1:])
+}
+
+func cmdUnsupported(s *Server, res *response, args []string) error {
+	return newAck(ackErrorPermission, "Spotify doesn't allow changing the queue this way")
+}
+
+func cmdCommands(s *Server, res *response, args []string) error {
+	var names []string
+
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	names = append(names, "close", "idle", "noidle", "password", "command_list_begin", "command_list_ok_begin", "command_list_end")
+	sort.Strings(names)
+
+	for _, name := range names {
+		res.field("command", name)
+	}
+
+	return nil
+}
+
+func cmdTagTypes(s *Server, res *response, args []string) error {
+	// Tag selection (tagtypes clear, enable, ...) is accepted but songs are always described with every tag
+	if len(args) > 0 {
+		return nil
+	}
+
+	for _, t := range tagTypes {
+		res.field("tagtype", t)
+	}
+
+	return nil
+}
+
+func cmdOutputs(s *Server, res *response, args []string) error {
+	ps, ok, err := s.playerState()
+
+	if err != nil {
+		return err
+	}
+
+	name := "Spotify"
+
+	if ok && ps.Device != nil {
+		name = ps.Device.Name
+	}
+
+	res.field("outputid", 0)
+	res.field("outputname", name)
+	res.field("plugin", "spotify")
+	res.field("outputenabled", boolInt(ok))
+
+	return nil
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func parseInt(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+
+	if err != nil {
+		return 0, newAck(ackErrorArg, "Integer expected: %s", s)
+	}
+
+	return n, nil
+}
+
+func parseBool(s string) (bool, error) {
+	switch s {
+	case "0":
+		return false, nil
+	case "1":
+		return true, nil
+	}
+
+	return false, newAck(ackErrorArg, "Boolean (0/1) expected: %s", s)
+}
+
+// parseSeconds parses a time in seconds, fractions are allowed
+func parseSeconds(s string) (time.Duration, error) {
+	f, err := strconv.ParseFloat(s, 64)
+
+	if err != nil {
+		return 0, newAck(ackErrorArg, "Number expected: %s", s)
+	}
+
+	return time.Duration(f * float64(time.Second)), nil
+}
+
+func writeSong(res *response, t api.FullTrack, pos int) {
+	res.field("file", t.URI)
+
+	for _, a := range t.Artists {
+		res.field("Artist", a.Name)
+	}
+
+	if t.Album != nil {
+		for _, a := range t.Album.Artists {
+			res.field("AlbumArtist", a.Name)
+		}
+
+		res.field("Album", t.Album.Name)
+
+		if t.Album.ReleaseDate != "" {
+			res.field("Date", t.Album.ReleaseDate)
+		}
+	}
+
+	res.field("Title", t.Name)
+	res.field("Track", t.TrackNumber)
+	res.field("Disc", t.DiscNumber)
+	res.field("Time", t.DurationMs/1000)
+	res.field("duration", fmt.Sprintf("%.3f", float64(t.DurationMs)/1000))
+
+	if pos >= 0 {
+		res.field("Pos", pos)
+		res.field("Id", pos+1)
+	}
+}
+
+func cmdStatus(s *Server, res *response, args []string) error {
+	ps, ok, err := s.playerState()
+
+	if err != nil {
+		return err
+	}
+
+	songs, err := s.songs()
+
+	if err != nil {
+		return err
+	}
+
+	volume := -1
+
+	if ok && ps.Device != nil {
+		volume = ps.Device.VolumePercent
+	}
+
+	res.field("volume", volume)
+	res.field("repeat", boolInt(ps.RepeatState == "context" || ps.RepeatState == "track"))
+	res.field("random", boolInt(ps.ShuffleState))
+	res.field("single", boolInt(ps.RepeatState == "track"))
+	res.field("consume", 0)
+	res.field("playlist", s.version)
+	res.field("playlistlength", len(songs))
+
+	state := "stop"
+
+	if ok && ps.Item != nil {
+		state = "pause"
+
+		if ps.IsPlaying {
+			state = "play"
+		}
+	}
+
+	res.field("state", state)
+
+	if state == "stop" {
+		return nil
+	}
+
+	res.field("song", 0)
+	res.field("songid", 1)
+
+	if len(songs) > 1 {
+		res.field("nextsong", 1)
+		res.field("nextsongid", 2)
+	}
+
+	res.field("time", fmt.Sprintf("%d:%d", ps.ProgressMs/1000, ps.Item.DurationMs/1000))
+	res.field("elapsed", fmt.Sprintf("%.3f", float64(ps.ProgressMs)/1000))
+	res.field("duration", fmt.Sprintf("%.3f", float64(ps.Item.DurationMs)/1000))
+
+	return nil
+}
+
+func cmdCurrentSong(s *Server, res *response, args []string) error {
+	ps, ok, err := s.playerState()
+
+	if err != nil {
+		return err
+	}
+
+	if ok && ps.Item != nil {
+		writeSong(res, *ps.Item, 0)
+	}
+
+	return nil
+}
+
+func cmdStats(s *Server, res *response, args []string) error {
+	res.field("uptime", int(time.Since(s.startedAt)/time.Second))
+	res.field("playtime", 0)
+	res.field("artists", 0)
+	res.field("albums", 0)
+	res.field("songs", 0)
+	res.field("db_playtime", 0)
+
+	return nil
+}
+
+// playPosition starts the song at the given position of the queue, songs after the current one are reached by skipping to them
+func (s *Server) playPosition(pos int) error {
+	if pos < 0 {
+		return s.Backend.StartPlayback(&api.PlayerOptions{})
+	}
+
+	songs, err := s.songs()
+
+	if err != nil {
+		return err
+	}
+
+	if pos >= len(songs) {
+		return newAck(ackErrorArg, "Bad song index")
+	}
+
+	for i := 0; i < pos; i++ {
+		if err := s.Backend.SkipToNext(nil); err != nil {
+			return err
+		}
+	}
+
+	if pos == 0 {
+		return s.Backend.StartPlayback(&api.PlayerOptions{})
+	}
+
+	return nil
+}
+
+func cmdPlay(s *Server, res *response, args []string) error {
+	pos := -1
+
+	if len(args) > 0 {
+		var err error
+
+		if pos, err = parseInt(args[0]); err != nil {
+			return err
+		}
+	}
+
+	defer s.invalidate()
+
+	return s.playPosition(pos)
+}
+
+func cmdPlayID(s *Server, res *response, args []string) error {
+	pos := -1
+
+	if len(args) > 0 {
+		id, err := parseInt(args[0])
+
+		if err != nil {
+			return err
+		}
+
+		pos = id - 1
+	}
+
+	defer s.invalidate()
+
+	return s.playPosition(pos)
+}
+
+func cmdPause(s *Server, res *response, args []string) error {
+	ps, _, err := s.playerState()
+
+	if err != nil {
+		return err
+	}
+
+	pause := ps.IsPlaying
+
+	if len(args) > 0 {
+		if pause, err = parseBool(args[0]); err != nil {
+			return err
+		}
+	}
+
+	defer s.invalidate()
+
+	if pause {
+		return s.Backend.PausePlayback(nil)
+	}
+
+	return s.Backend.StartPlayback(&api.PlayerOptions{})
+}
+
+func cmdStop(s *Server, res *response, args []string) error {
+	defer s.invalidate()
+
+	return s.Backend.PausePlayback(nil)
+}
+
+func cmdNext(s *Server, res *response, args []string) error {
+	defer s.invalidate()
+
+	return s.Backend.SkipToNext(nil)
+}
+
+func cmdPrevious(s *Server, res *response, args []string) error {
+	defer s.invalidate()
+
+	return s.Backend.SkipToPrevious(nil)
+}
+
+// seekTo moves within the current song, only the song at position 0 can be seeked since Spotify can only seek the current track
+func (s *Server) seekTo(pos int, d time.Duration) error {
+	if pos != 0 {
+		return newAck(ackErrorArg, "only the current song can be seeked")
+	}
+
+	if d < 0 {
+		d = 0
+	}
+
+	defer s.invalidate()
+
+	return s.Backend.SeekToPosition(int(d/time.Millisecond), nil)
+}
+
+func cmdSeek(s *Server, res *response, args []string) error {
+	if len(args) != 2 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"seek\"")
+	}
+
+	pos, err := parseInt(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	d, err := parseSeconds(args[1])
+
+	if err != nil {
+		return err
+	}
+
+	return s.seekTo(pos, d)
+}
+
+func cmdSeekID(s *Server, res *response, args []string) error {
+	if len(args) != 2 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"seekid\"")
+	}
+
+	id, err := parseInt(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	d, err := parseSeconds(args[1])
+
+	if err != nil {
+		return err
+	}
+
+	return s.seekTo(id-1, d)
+}
+
+func cmdSeekCur(s *Server, res *response, args []string) error {
+	if len(args) != 1 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"seekcur\"")
+	}
+
+	d, err := parseSeconds(strings.TrimPrefix(args[0], "+"))
+
+	if err != nil {
+		return err
+	}
+
+	// A sign makes the time relative to the current position
+	if strings.HasPrefix(args[0], "+") || strings.HasPrefix(args[0], "-") {
+		ps, _, err := s.playerState()
+
+		if err != nil {
+			return err
+		}
+
+		d += time.Duration(ps.ProgressMs) * time.Millisecond
+	}
+
+	return s.seekTo(0, d)
+}
+
+func (s *Server) setVolume(v int) error {
+	if v < 0 {
+		v = 0
+	}
+
+	if v > 100 {
+		v = 100
+	}
+
+	defer s.invalidate()
+
+	return s.Backend.SetVolume(v, nil)
+}
+
+func cmdSetVol(s *Server, res *response, args []string) error {
+	if len(args) != 1 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"setvol\"")
+	}
+
+	v, err := parseInt(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	return s.setVolume(v)
+}
+
+func cmdVolume(s *Server, res *response, args []string) error {
+	if len(args) != 1 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"volume\"")
+	}
+
+	change, err := parseInt(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	ps, ok, err := s.playerState()
+
+	if err != nil {
+		return err
+	}
+
+	if !ok || ps.Device == nil {
+		return newAck(ackErrorSystem, "no active device")
+	}
+
+	return s.setVolume(ps.Device.VolumePercent + change)
+}
+
+func cmdGetVol(s *Server, res *response, args []string) error {
+	ps, ok, err := s.playerState()
+
+	if err != nil {
+		return err
+	}
+
+	if ok && ps.Device != nil {
+		res.field("volume", ps.Device.VolumePercent)
+	}
+
+	return nil
+}
+
+func cmdRandom(s *Server, res *response, args []string) error {
+	if len(args) != 1 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"random\"")
+	}
+
+	on, err := parseBool(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	defer s.invalidate()
+
+	return s.Backend.ToggleShuffle(on, nil)
+}
+
+func cmdRepeat(s *Server, res *response, args []string) error {
+	if len(args) != 1 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"repeat\"")
+	}
+
+	on, err := parseBool(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	state := "off"
+
+	if on {
+		state = "context"
+	}
+
+	defer s.invalidate()
+
+	return s.Backend.SetRepeatMode(state, nil)
+}
+
+func cmdSingle(s *Server, res *response, args []string) error {
+	if len(args) != 1 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"single\"")
+	}
+
+	on, err := parseBool(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	ps, _, err := s.playerState()
+
+	if err != nil {
+		return err
+	}
+
+	// Spotify repeats a single track instead of stopping after it, turning single off goes back to the repeat mode it came from
+	state := "off"
+
+	switch {
+	case on:
+		state = "track"
+	case ps.RepeatState == "context":
+		state = "context"
+	}
+
+	defer s.invalidate()
+
+	return s.Backend.SetRepeatMode(state, nil)
+}
+
+func cmdConsume(s *Server, res *response, args []string) error {
+	if len(args) == 1 && args[0] == "0" {
+		return nil
+	}
+
+	return newAck(ackErrorPermission, "Spotify doesn't support consume mode")
+}
+
+// songRange parses the optional POS or START:END argument of the playlist commands
+func songRange(args []string, n int) (start, end int, err error) {
+	if len(args) == 0 {
+		return 0, n, nil
+	}
+
+	parts := strings.SplitN(args[0], ":", 2)
+
+	if start, err = parseInt(parts[0]); err != nil {
+		return 0, 0, err
+	}
+
+	end = start + 1
+
+	if len(parts) == 2 {
+		end = n
+
+		if parts[1] != "" {
+			if end, err = parseInt(parts[1]); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	if end > n {
+		end = n
+	}
+
+	if start < 0 || (start >= n && len(parts) == 1) {
+		return 0, 0, newAck(ackErrorArg, "Bad song index")
+	}
+
+	if start > end {
+		start = end
+	}
+
+	return start, end, nil
+}
+
+func cmdPlaylistInfo(s *Server, res *response, args []string) error {
+	songs, err := s.songs()
+
+	if err != nil {
+		return err
+	}
+
+	start, end, err := songRange(args, len(songs))
+
+	if err != nil {
+		return err
+	}
+
+	for i := start; i < end; i++ {
+		writeSong(res, songs[i], i)
+	}
+
+	return nil
+}
+
+func cmdPlaylistID(s *Server, res *response, args []string) error {
+	songs, err := s.songs()
+
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return cmdPlaylistInfo(s, res, nil)
+	}
+
+	id, err := parseInt(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	if id < 1 || id > len(songs) {
+		return newAck(ackErrorNoExist, "No such song")
+	}
+
+	writeSong(res, songs[id-1], id-1)
+
+	return nil
+}
+
+// cmdPlChanges lists the whole queue when it changed since the client's version, the server doesn't know which songs changed
+func cmdPlChanges(s *Server, res *response, args []string) error {
+	songs, err := s.songs()
+
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		if v, err := parseInt(args[0]); err == nil && v == s.version {
+			return nil
+		}
+	}
+
+	for i, t := range songs {
+		writeSong(res, t, i)
+	}
+
+	return nil
+}
+
+func cmdPlChangesPosID(s *Server, res *response, args []string) error {
+	songs, err := s.songs()
+
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		if v, err := parseInt(args[0]); err == nil && v == s.version {
+			return nil
+		}
+	}
+
+	for i := range songs {
+		res.field("cpos", i)
+		res.field("Id", i+1)
+	}
+
+	return nil
+}
+
+func (s *Server) addToQueue(uri string) error {
+	if !strings.HasPrefix(uri, "spotify:track:") && !strings.HasPrefix(uri, "spotify:episode:") {
+		return newAck(ackErrorNoExist, "only spotify:track: and spotify:episode: URIs can be added")
+	}
+
+	defer s.invalidate()
+
+	s.version++
+
+	return s.Backend.AddToQueue(uri, nil)
+}
+
+func cmdAdd(s *Server, res *response, args []string) error {
+	if len(args) != 1 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"add\"")
+	}
+
+	return s.addToQueue(args[0])
+}
+
+func cmdAddID(s *Server, res *response, args []string) error {
+	if len(args) < 1 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"addid\"")
+	}
+
+	songs, err := s.songs()
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.addToQueue(args[0]); err != nil {
+		return err
+	}
+
+	res.field("Id", len(songs)+1)
+
+	return nil
+}
+
+func cmdListPlaylists(s *Server, res *response, args []string) error {
+	playlists, err := s.Backend.Playlists()
+
+	if err != nil {
+		return err
+	}
+
+	for _, p := range playlists {
+		res.field("playlist", p.Name)
+	}
+
+	return nil
+}
+
+func (s *Server) findPlaylist(name string) (api.SimplePlaylist, error) {
+	playlists, err := s.Backend.Playlists()
+
+	if err != nil {
+		return api.SimplePlaylist{}, err
+	}
+
+	for _, p := range playlists {
+		if p.Name == name || p.URI == name {
+			return p, nil
+		}
+	}
+
+	return api.SimplePlaylist{}, newAck(ackErrorNoExist, "No such playlist")
+}
+
+func cmdListPlaylist(info bool) commandHandler {
+	return func(s *Server, res *response, args []string) error {
+		if len(args) != 1 {
+			return newAck(ackErrorArg, "wrong number of arguments")
+		}
+
+		p, err := s.findPlaylist(args[0])
+
+		if err != nil {
+			return err
+		}
+
+		tracks, err := s.Backend.PlaylistTracks(p)
+
+		if err != nil {
+			return err
+		}
+
+		for _, t := range tracks {
+			if info {
+				writeSong(res, t, -1)
+			} else {
+				res.field("file", t.URI)
+			}
+		}
+
+		return nil
+	}
+}
+
+// cmdLoad plays a playlist, Spotify replaces the queue's context instead of appending to it
+func cmdLoad(s *Server, res *response, args []string) error {
+	if len(args) < 1 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"load\"")
+	}
+
+	p, err := s.findPlaylist(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	defer s.invalidate()
+
+	return s.Backend.StartPlayback(&api.PlayerOptions{ContextURI: p.URI})
+}
+
+// The filter struct describes a tag constraint of search and find
+type filter struct {
+	tag   string
+	value string
+}
+
+// parseFilters reads the tag/value pairs of the old syntax or a filter expression such as ((artist == 'x') AND (album == 'y'))
+func parseFilters(args []string) ([]filter, error) {
+	if len(args) == 1 && strings.HasPrefix(args[0], "(") {
+		var filters []filter
+		expr := strings.TrimSpace(args[0])
+
+		for _, part := range strings.Split(expr, " AND ") {
+			part = strings.Trim(strings.TrimSpace(part), "()")
+			fields, err := splitFilterExpression(part)
+
+			if err != nil {
+				return nil, err
+			}
+
+			filters = append(filters, fields)
+		}
+
+		return filters, nil
+	}
+
+	if len(args) == 0 || len(args)%2 != 0 {
+		return nil, newAck(ackErrorArg, "incorrect arguments")
+	}
+
+	var filters []filter
+
+	for i := 0; i < len(args); i += 2 {
+		filters = append(filters, filter{tag: strings.ToLower(args[i]), value: args[i+1]})
+	}
+
+	return filters, nil
+}
+
+// splitFilterExpression parses a single `tag == 'value'` or `tag contains 'value'` comparison
+func splitFilterExpression(expr string) (filter, error) {
+	for _, op := range []string{" == ", " contains ", " =~ "} {
+		if i := strings.Index(expr, op); i > 0 {
+			value := strings.TrimSpace(expr[i+len(op):])
+			value = strings.Trim(value, "'\"")
+			value = strings.Replace(value, "\\'", "'", -1)
+			value = strings.Replace(value, "\\\"", "\"", -1)
+
+			return filter{tag: strings.ToLower(strings.TrimSpace(expr[:i])), value: value}, nil
+		}
+	}
+
+	return filter{}, newAck(ackErrorArg, "unsupported filter expression: %s", expr)
+}
+
+// searchQuery builds the Spotify search query for the filters
+func searchQuery(filters []filter) string {
+	var terms []string
+
+	for _, f := range filters {
+		v := strings.Replace(f.value, "\"", "", -1)
+
+		switch f.tag {
+		case "artist", "albumartist":
+			terms = append(terms, "artist:\""+v+"\"")
+		case "album":
+			terms = append(terms, "album:\""+v+"\"")
+		case "title":
+			terms = append(terms, "track:\""+v+"\"")
+		case "date":
+			// Spotify only searches by year, the rest of the date is compared with the results
+			if len(v) >= 4 {
+				if _, err := strconv.Atoi(v[:4]); err == nil {
+					terms = append(terms, "year:"+v[:4])
+				}
+			}
+		case "track", "disc":
+			// Numbers aren't worth searching for, they're compared with the results
+		default:
+			terms = append(terms, v)
+		}
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// tagValues returns the values of a tag for a track, any covers every tag
+func tagValues(t api.FullTrack, tag string) []string {
+	var values []string
+
+	if tag == "artist" || tag == "any" {
+		for _, a := range t.Artists {
+			values = append(values, a.Name)
+		}
+	}
+
+	if t.Album != nil {
+		if tag == "albumartist" || tag == "any" {
+			for _, a := range t.Album.Artists {
+				values = append(values, a.Name)
+			}
+		}
+
+		if tag == "album" || tag == "any" {
+			values = append(values, t.Album.Name)
+		}
+
+		if (tag == "date" || tag == "any") && t.Album.ReleaseDate != "" {
+			values = append(values, t.Album.ReleaseDate)
+		}
+	}
+
+	if tag == "title" || tag == "any" {
+		values = append(values, t.Name)
+	}
+
+	if tag == "track" || tag == "any" {
+		values = append(values, strconv.Itoa(t.TrackNumber))
+	}
+
+	if tag == "disc" || tag == "any" {
+		values = append(values, strconv.Itoa(t.DiscNumber))
+	}
+
+	if tag == "file" || tag == "any" {
+		values = append(values, t.URI)
+	}
+
+	return values
+}
+
+// matches checks a track against the filters, find compares whole values and search looks for case-insensitive substrings
+func matches(t api.FullTrack, filters []filter, exact bool) bool {
+	for _, f := range filters {
+		found := false
+
+		for _, v := range tagValues(t, f.tag) {
+			if (exact && v == f.value) || (!exact && strings.Contains(strings.ToLower(v), strings.ToLower(f.value))) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func cmdSearch(exact, add bool) commandHandler {
+	return func(s *Server, res *response, args []string) error {
+		tracks, err := s.searchTracks(args, exact)
+
+		if err != nil {
+			return err
+		}
+
+		for _, t := range tracks {
+			if add {
+				if err := s.addToQueue(t.URI); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			writeSong(res, t, -1)
+		}
+
+		return nil
+	}
+}
+
+// searchTracks searches Spotify for the tracks matching the filters given as arguments
+func (s *Server) searchTracks(args []string, exact bool) ([]api.FullTrack, error) {
+	filters, err := parseFilters(args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range filters {
+		if !filterTags[f.tag] {
+			return nil, newAck(ackErrorArg, "unknown tag type: %s", f.tag)
+		}
+	}
+
+	query := searchQuery(filters)
+
+	if query == "" {
+		return nil, newAck(ackErrorArg, "Spotify can't be searched by track or disc number alone, add another tag")
+	}
+
+	tracks, err := s.Backend.Search(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var res []api.FullTrack
+
+	for _, t := range tracks {
+		if matches(t, filters, exact) {
+			res = append(res, t)
+		}
+	}
+
+	return res, nil
+}
+
+// cmdList lists the values of a tag among the tracks matching the filters, ex. the albums of an artist. Spotify can't
+// list every value of a tag so a filter is required, grouping is ignored.
+func cmdList(s *Server, res *response, args []string) error {
+	if len(args) == 0 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"list\"")
+	}
+
+	tag := strings.ToLower(args[0])
+	name := tag
+
+	for _, t := range tagTypes {
+		if strings.ToLower(t) == tag {
+			name = t
+		}
+	}
+
+	if !filterTags[tag] || tag == "any" {
+		return newAck(ackErrorArg, "unknown tag type: %s", args[0])
+	}
+
+	filters := args[1:]
+
+	for len(filters) >= 2 && strings.ToLower(filters[len(filters)-2]) == "group" {
+		filters = filters[:len(filters)-2]
+	}
+
+	// Old clients give the artist alone to list its albums
+	if tag == "album" && len(filters) == 1 && !strings.HasPrefix(filters[0], "(") {
+		filters = []string{"artist", filters[0]}
+	}
+
+	if len(filters) == 0 {
+		return newAck(ackErrorArg, "Spotify can't list every %s, add a filter such as list %s artist <name>", tag, tag)
+	}
+
+	tracks, err := s.searchTracks(filters, true)
+
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+
+	for _, t := range tracks {
+		for _, v := range tagValues(t, tag) {
+			if !seen[v] {
+				seen[v] = true
+				res.field(name, v)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package mpd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Version is the protocol version announced to clients
+const Version = "0.21.0"
+
+// Error codes sent in ACK responses
+const (
+	ackErrorNotList    = 1
+	ackErrorArg        = 2
+	ackErrorPassword   = 3
+	ackErrorPermission = 4
+	ackErrorUnknown    = 5
+	ackErrorNoExist    = 50
+	ackErrorSystem     = 52
+)
+
+// The ackError struct describes a failed command, it's sent to the client as an ACK line
+type ackError struct {
+	code    int
+	message string
+}
+
+func (e *ackError) Error() string {
+	return e.message
+}
+
+func newAck(code int, format string, a ...interface{}) error {
+	return &ackError{code: code, message: fmt.Sprintf(format, a...)}
+}
+
+// formatAck builds the ACK line for a command that failed at position index of a command list
+func formatAck(err error, index int, command string) string {
+	e, ok := err.(*ackError)
+
+	if !ok {
+		e = &ackError{code: ackErrorSystem, message: err.Error()}
+	}
+
+	return fmt.Sprintf("ACK [%d@%d] {%s} %s\n", e.code, index, command, strings.Replace(e.message, "\n", " ", -1))
+}
+
+// splitArgs splits a command line into the command and its arguments, arguments may be quoted with double quotes and use backslash escapes
+func splitArgs(line string) (args []string, err error) {
+	var cur bytes.Buffer
+	inQuotes := false
+	quoted := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case inQuotes && c == '\\':
+			i++
+
+			if i == len(line) {
+				return nil, newAck(ackErrorArg, "unterminated escape")
+			}
+
+			cur.WriteByte(line[i])
+		case c == '"':
+			inQuotes = !inQuotes
+			quoted = true
+		case !inQuotes && (c == ' ' || c == '\t'):
+			if cur.Len() > 0 || quoted {
+				args = append(args, cur.String())
+				cur.Reset()
+				quoted = false
+			}
+		default:
+			cur.WriteByte(c)
+		}
+	}
+
+	if inQuotes {
+		return nil, newAck(ackErrorArg, "missing closing '\"'")
+	}
+
+	if cur.Len() > 0 || quoted {
+		args = append(args, cur.String())
+	}
+
+	return args, nil
+}
+
+// response collects the lines written by a command, they're only sent once the whole command or command list succeeded
+type response struct {
+	bytes.Buffer
+}
+
+func (r *response) field(key string, value interface{}) {
+	fmt.Fprintf(&r.Buffer, "%s: %v\n", key, value)
+}
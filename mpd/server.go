@@ -0,0 +1,442 @@
+// Package mpd implements enough of the Music Player Daemon protocol for MPD clients to control Spotify
+//
+// The MPD queue is made of the track that is playing followed by Spotify's queue, so the current song is always at
+// position 0 and song IDs are positions plus one. Spotify can't remove tracks from its queue, commands that would
+// need to (clear, delete, move) are refused.
+package mpd
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/utils"
+	"github.com/firstlane/baton/watcher"
+)
+
+// The Backend interface describes what the server needs from Spotify
+type Backend interface {
+	GetPlayerState(opts *api.Options) (api.PlayerState, error)
+	StartPlayback(opts *api.PlayerOptions) error
+	PausePlayback(opts *api.Options) error
+	SkipToNext(opts *api.Options) error
+	SkipToPrevious(opts *api.Options) error
+	SeekToPosition(pos int, opts *api.Options) error
+	SetVolume(vol int, opts *api.Options) error
+	SetRepeatMode(state string, opts *api.Options) error
+	ToggleShuffle(state bool, opts *api.Options) error
+	AddToQueue(uri string, opts *api.Options) error
+	GetQueue() (api.Queue, error)
+	// Search returns the tracks matching a Spotify search query
+	Search(query string) ([]api.FullTrack, error)
+	// Playlists returns the user's playlists
+	Playlists() ([]api.SimplePlaylist, error)
+	// PlaylistTracks returns the tracks of a playlist
+	PlaylistTracks(p api.SimplePlaylist) ([]api.FullTrack, error)
+}
+
+// Subsystems reported by idle
+const (
+	subsystemPlayer   = "player"
+	subsystemMixer    = "mixer"
+	subsystemOptions  = "options"
+	subsystemPlaylist = "playlist"
+	subsystemOutput   = "output"
+)
+
+// stateTTL is how long a player state is reused, clients ask for status and currentsong several times a second
+const stateTTL = time.Second
+
+// queueTTL is how long the queue is reused while the same track plays
+const queueTTL = 10 * time.Second
+
+// idlePollInterval is how often Spotify is polled for changes while clients are connected
+const idlePollInterval = 2 * time.Second
+
+// Server answers MPD clients
+type Server struct {
+	Backend Backend
+	// Password, when set, has to be sent with the password command before any other command
+	Password string
+
+	// mu also protects the caches below
+	mu        api.CallLock
+	state     api.PlayerState
+	noDevice  bool
+	stateAt   time.Time
+	queue     []api.FullTrack
+	queueAt   time.Time
+	queueURI  string
+	version   int
+	lastURI   string
+	startedAt time.Time
+
+	clientsMu sync.Mutex
+	clients   map[*client]bool
+	wake      chan bool
+}
+
+// The client struct describes a connection and the subsystems that changed since it last asked
+type client struct {
+	changed map[string]bool
+	notify  chan bool
+	authed  bool
+}
+
+// NewServer returns a server answering with the given backend
+func NewServer(b Backend) *Server {
+	return &Server{
+		Backend:   b,
+		clients:   make(map[*client]bool),
+		wake:      make(chan bool, 1),
+		startedAt: time.Now(),
+		version:   1,
+	}
+}
+
+// Serve accepts connections until the listener is closed
+func (s *Server) Serve(l net.Listener) error {
+	go s.watch()
+
+	for {
+		c, err := l.Accept()
+
+		if err != nil {
+			return err
+		}
+
+		go s.serveConn(c)
+	}
+}
+
+// playerState returns the player state fetched within the last second, ok is false when nothing is playing on any device
+func (s *Server) playerState() (ps api.PlayerState, ok bool, err error) {
+	if time.Since(s.stateAt) < stateTTL {
+		return s.state, !s.noDevice, nil
+	}
+
+	ps, err = s.Backend.GetPlayerState(nil)
+
+	if err != nil && err != api.ErrNoActiveDevice {
+		return ps, false, err
+	}
+
+	s.state = ps
+	s.noDevice = err != nil
+	s.stateAt = time.Now()
+
+	uri := ""
+
+	if !s.noDevice && ps.Item != nil {
+		uri = ps.Item.URI
+	}
+
+	// The queue is rebuilt around the current track, a new track means a new playlist version
+	if uri != s.lastURI {
+		s.lastURI = uri
+		s.version++
+	}
+
+	return s.state, !s.noDevice, nil
+}
+
+// songs returns the MPD queue: the current track followed by Spotify's queue
+func (s *Server) songs() ([]api.FullTrack, error) {
+	ps, ok, err := s.playerState()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok || ps.Item == nil {
+		return nil, nil
+	}
+
+	if s.queueURI == ps.Item.URI && time.Since(s.queueAt) < queueTTL {
+		return s.queue, nil
+	}
+
+	q, err := s.Backend.GetQueue()
+
+	if err == api.ErrNoActiveDevice {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	songs := []api.FullTrack{*ps.Item}
+	songs = append(songs, q.Queue...)
+
+	s.queue = songs
+	s.queueAt = time.Now()
+	s.queueURI = ps.Item.URI
+
+	return songs, nil
+}
+
+// invalidate drops the caches after a command changed the player and wakes the watcher so idle clients hear about it
+func (s *Server) invalidate() {
+	s.stateAt = time.Time{}
+	s.queueAt = time.Time{}
+	s.wakeWatcher()
+}
+
+// wakeWatcher makes the watcher poll Spotify now instead of waiting for the next interval
+func (s *Server) wakeWatcher() {
+	select {
+	case s.wake <- true:
+	default:
+	}
+}
+
+// notify records the changed subsystems for every client and wakes the idle ones
+func (s *Server) notify(subsystems []string) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+
+	for c := range s.clients {
+		for _, sub := range subsystems {
+			c.changed[sub] = true
+		}
+
+		select {
+		case c.notify <- true:
+		default:
+		}
+	}
+}
+
+// eventSubsystems maps watcher events to the idle subsystems they change
+var eventSubsystems = map[string][]string{
+	watcher.TrackChanged:   {subsystemPlayer, subsystemPlaylist},
+	watcher.Paused:         {subsystemPlayer},
+	watcher.Resumed:        {subsystemPlayer},
+	watcher.Stopped:        {subsystemPlayer},
+	watcher.DeviceChanged:  {subsystemOutput, subsystemMixer},
+	watcher.VolumeChanged:  {subsystemMixer},
+	watcher.ShuffleChanged: {subsystemOptions},
+	watcher.RepeatChanged:  {subsystemOptions},
+	watcher.ContextChanged: {subsystemPlaylist},
+}
+
+// watch polls Spotify while clients are connected and turns changes into idle notifications
+func (s *Server) watch() {
+	var w watcher.Watcher
+
+	for {
+		select {
+		case <-s.wake:
+			s.mu.Settle()
+		case <-time.After(idlePollInterval):
+		}
+
+		s.clientsMu.Lock()
+		n := len(s.clients)
+		s.clientsMu.Unlock()
+
+		// Without clients nobody is told about changes, the next client starts from a new snapshot
+		if n == 0 {
+			w = watcher.Watcher{}
+			continue
+		}
+
+		s.mu.Lock()
+		s.stateAt = time.Time{}
+		ps, ok, err := s.playerState()
+		s.mu.Unlock()
+
+		if err != nil {
+			continue
+		}
+
+		var cur *api.PlayerState
+
+		if ok {
+			cur = &ps
+		}
+
+		var subsystems []string
+
+		for _, e := range w.Update(cur, time.Now()) {
+			subsystems = append(subsystems, eventSubsystems[e.Type]...)
+		}
+
+		if len(subsystems) > 0 {
+			s.notify(subsystems)
+		}
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	c := &client{changed: make(map[string]bool), notify: make(chan bool, 1), authed: s.Password == ""}
+
+	s.clientsMu.Lock()
+	s.clients[c] = true
+	s.clientsMu.Unlock()
+
+	// Take a snapshot to compare later changes with
+	s.wakeWatcher()
+
+	defer func() {
+		s.clientsMu.Lock()
+		delete(s.clients, c)
+		s.clientsMu.Unlock()
+	}()
+
+	lines := make(chan string)
+	done := make(chan bool)
+	defer close(done)
+
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(conn)
+
+		for scanner.Scan() {
+			select {
+			case lines <- strings.TrimRight(scanner.Text(), "\r"):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("OK MPD " + Version + "\n")
+	w.Flush()
+
+	for line := range lines {
+		var list []string
+		listOK := false
+
+		switch line {
+		case "command_list_begin", "command_list_ok_begin":
+			listOK = line == "command_list_ok_begin"
+
+			for l := range lines {
+				if l == "command_list_end" {
+					break
+				}
+
+				list = append(list, l)
+			}
+		default:
+			args, err := splitArgs(line)
+
+			if err != nil {
+				w.WriteString(formatAck(err, 0, ""))
+				w.Flush()
+				continue
+			}
+
+			if len(args) > 0 && args[0] == "idle" {
+				if !s.idle(c, args[1:], lines, w) {
+					return
+				}
+
+				continue
+			}
+
+			if len(args) > 0 && args[0] == "close" {
+				return
+			}
+
+			// A noidle that crossed the end of an idle has nothing to cancel, MPD doesn't answer it
+			if len(args) > 0 && args[0] == "noidle" {
+				continue
+			}
+
+			list = []string{line}
+		}
+
+		w.WriteString(s.run(c, list, listOK))
+		w.Flush()
+	}
+}
+
+// run executes the commands of a command list and returns the complete response, the list stops at the first failure
+func (s *Server) run(c *client, list []string, listOK bool) string {
+	var res response
+
+	for k, line := range list {
+		args, err := splitArgs(line)
+
+		if err == nil && len(args) == 0 {
+			err = newAck(ackErrorUnknown, "No command given")
+		}
+
+		name := ""
+
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		if err == nil {
+			err = s.command(c, &res, args)
+		}
+
+		if err != nil {
+			return res.String() + formatAck(err, k, name)
+		}
+
+		if listOK {
+			res.WriteString("list_OK\n")
+		}
+	}
+
+	return res.String() + "OK\n"
+}
+
+// idle waits until one of the subsystems changes or the client sends noidle, it returns false when the client went away
+func (s *Server) idle(c *client, subsystems []string, lines <-chan string, w *bufio.Writer) bool {
+	for {
+		s.clientsMu.Lock()
+
+		var changed []string
+
+		for sub := range c.changed {
+			if len(subsystems) == 0 || utils.StringInSlice(sub, subsystems) {
+				changed = append(changed, sub)
+				delete(c.changed, sub)
+			}
+		}
+
+		s.clientsMu.Unlock()
+
+		if len(changed) > 0 {
+			for _, sub := range changed {
+				w.WriteString("changed: " + sub + "\n")
+			}
+
+			w.WriteString("OK\n")
+			w.Flush()
+
+			return true
+		}
+
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return false
+			}
+
+			// Only noidle is allowed while idle
+			if line != "noidle" {
+				return false
+			}
+
+			w.WriteString("OK\n")
+			w.Flush()
+
+			return true
+		case <-c.notify:
+		}
+	}
+}
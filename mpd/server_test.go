@@ -0,0 +1,184 @@
+package mpd
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firstlane/baton/api"
+)
+
+// searchBackend answers searches with the same tracks, the other calls aren't made by the tests
+type searchBackend struct {
+	Backend
+	tracks []api.FullTrack
+	query  string
+}
+
+func (b *searchBackend) Search(query string) ([]api.FullTrack, error) {
+	b.query = query
+	return b.tracks, nil
+}
+
+// dialServer connects a client to the server through a pipe and reads the greeting
+func dialServer(t *testing.T, s *Server) (net.Conn, *bufio.Reader) {
+	server, conn := net.Pipe()
+	go s.serveConn(server)
+
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	r := bufio.NewReader(conn)
+
+	if line, err := r.ReadString('\n'); err != nil || !strings.HasPrefix(line, "OK MPD ") {
+		t.Fatalf("greeting %q, %v", line, err)
+	}
+
+	return conn, r
+}
+
+// exchange sends a command and returns the lines of the response up to its OK or ACK
+func exchange(t *testing.T, conn net.Conn, r *bufio.Reader, command string) []string {
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Write([]byte(command + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+
+	for {
+		line, err := r.ReadString('\n')
+
+		if err != nil {
+			t.Fatalf("%s: %v after %q", command, err, lines)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		lines = append(lines, line)
+
+		if line == "OK" || strings.HasPrefix(line, "ACK ") {
+			return lines
+		}
+	}
+}
+
+func TestNoidleOutsideIdleIsIgnored(t *testing.T) {
+	conn, r := dialServer(t, NewServer(&searchBackend{}))
+
+	// The only answer is the one to ping
+	if got := exchange(t, conn, r, "noidle\nping"); len(got) != 1 || got[0] != "OK" {
+		t.Errorf("noidle then ping answered %q", got)
+	}
+}
+
+func TestFindAndSearchTags(t *testing.T) {
+	album := &api.SimpleAlbum{Name: "Discovery", ReleaseDate: "2001-03-12"}
+	b := &searchBackend{tracks: []api.FullTrack{
+		{URI: "spotify:track:a", Name: "One More Time", TrackNumber: 1, DiscNumber: 1, Album: album},
+		{URI: "spotify:track:b", Name: "Digital Love", TrackNumber: 3, DiscNumber: 1, Album: album},
+		{URI: "spotify:track:c", Name: "Da Funk", TrackNumber: 3, DiscNumber: 1, Album: &api.SimpleAlbum{Name: "Homework", ReleaseDate: "1997"}},
+	}}
+	conn, r := dialServer(t, NewServer(b))
+
+	tests := []struct {
+		command string
+		query   string
+		files   []string
+		ack     string
+	}{
+		{`find track 3 album Discovery`, `album:"Discovery"`, []string{"spotify:track:b"}, ""},
+		{`find "((Track == '3') AND (Date == '2001-03-12'))"`, "year:2001", []string{"spotify:track:b"}, ""},
+		{`find track 3`, "", nil, "track or disc number alone"},
+		{`find disc 1 album Homework`, `album:"Homework"`, []string{"spotify:track:c"}, ""},
+		{`find date 1997`, "year:1997", []string{"spotify:track:c"}, ""},
+		{`search date 2001`, "year:2001", []string{"spotify:track:a", "spotify:track:b"}, ""},
+		{`search title love`, `track:"love"`, []string{"spotify:track:b"}, ""},
+		{`search any funk`, "funk", []string{"spotify:track:c"}, ""},
+		{`find tit love`, "", nil, "unknown tag type: tit"},
+		{`find "artist album" x`, "", nil, "unknown tag type: artist album"},
+		{`find composer x`, "", nil, "unknown tag type: composer"},
+	}
+
+	for _, tt := range tests {
+		b.query = ""
+		lines := exchange(t, conn, r, tt.command)
+		last := lines[len(lines)-1]
+
+		if tt.ack != "" {
+			if !strings.HasPrefix(last, "ACK ") || !strings.Contains(last, tt.ack) {
+				t.Errorf("%s: answered %q, want an ACK for %q", tt.command, last, tt.ack)
+			}
+
+			continue
+		}
+
+		var files []string
+
+		for _, l := range lines {
+			if strings.HasPrefix(l, "file: ") {
+				files = append(files, strings.TrimPrefix(l, "file: "))
+			}
+		}
+
+		if last != "OK" || strings.Join(files, " ") != strings.Join(tt.files, " ") {
+			t.Errorf("%s: found %q ending with %q, want %q", tt.command, files, last, tt.files)
+		}
+
+		if b.query != tt.query {
+			t.Errorf("%s: searched Spotify for %q, want %q", tt.command, b.query, tt.query)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	daft := []api.SimpleArtist{{Name: "Daft Punk"}}
+	b := &searchBackend{tracks: []api.FullTrack{
+		{URI: "spotify:track:a", Name: "One More Time", Artists: daft, Album: &api.SimpleAlbum{Name: "Discovery"}},
+		{URI: "spotify:track:b", Name: "Digital Love", Artists: daft, Album: &api.SimpleAlbum{Name: "Discovery"}},
+		{URI: "spotify:track:c", Name: "Da Funk", Artists: daft, Album: &api.SimpleAlbum{Name: "Homework"}},
+		{URI: "spotify:track:d", Name: "Daft Punk Is Playing at My House", Artists: []api.SimpleArtist{{Name: "LCD Soundsystem"}}, Album: &api.SimpleAlbum{Name: "LCD Soundsystem"}},
+	}}
+	conn, r := dialServer(t, NewServer(b))
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{`list album artist "Daft Punk"`, "Album: Discovery,Album: Homework,OK"},
+		{`list album "Daft Punk"`, "Album: Discovery,Album: Homework,OK"},
+		{`list Album "(artist == 'Daft Punk')" group date`, "Album: Discovery,Album: Homework,OK"},
+		{`list title album Homework`, "Title: Da Funk,OK"},
+		{`list albumartist album Homework`, "OK"},
+		{`list artist`, "ACK [2@0] {list} Spotify can't list every artist, add a filter such as list artist artist <name>"},
+		{`list composer artist x`, "ACK [2@0] {list} unknown tag type: composer"},
+		{`list`, `ACK [2@0] {list} wrong number of arguments for "list"`},
+	}
+
+	for _, tt := range tests {
+		if got := strings.Join(exchange(t, conn, r, tt.command), ","); got != tt.want {
+			t.Errorf("%s: answered %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestPassword(t *testing.T) {
+	s := NewServer(&searchBackend{})
+	s.Password = "secret"
+	conn, r := dialServer(t, s)
+
+	for _, tt := range []struct{ command, want string }{
+		{"ping", `ACK [4@0] {ping} you don't have permission for "ping"`},
+		{"password secre", "ACK [3@0] {password} incorrect password"},
+		{"password secrets", "ACK [3@0] {password} incorrect password"},
+		{"password secret", "OK"},
+		{"ping", "OK"},
+	} {
+		if got := strings.Join(exchange(t, conn, r, tt.command), ","); got != tt.want {
+			t.Errorf("%s: answered %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
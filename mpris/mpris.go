@@ -0,0 +1,708 @@
+// Package mpris offers the Spotify player on D-Bus through the MPRIS interfaces so desktop media keys and widgets can
+// control it
+//
+// See https://specifications.freedesktop.org/mpris-spec/latest/ for the interfaces.
+package mpris
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/dbus"
+	"github.com/firstlane/baton/watcher"
+)
+
+// Names of the object and interfaces
+const (
+	objectPath          dbus.ObjectPath = "/org/mpris/MediaPlayer2"
+	rootInterface                       = "org.mpris.MediaPlayer2"
+	playerInterface                     = "org.mpris.MediaPlayer2.Player"
+	propertiesInterface                 = "org.freedesktop.DBus.Properties"
+	introspectInterface                 = "org.freedesktop.DBus.Introspectable"
+)
+
+// noTrack is the track id reported when nothing is playing
+const noTrack dbus.ObjectPath = "/org/mpris/MediaPlayer2/TrackList/NoTrack"
+
+// stateTTL is how long a player state is reused, widgets read several properties in a row
+const stateTTL = time.Second
+
+// pollInterval is how often Spotify is polled for changes to signal
+const pollInterval = 2 * time.Second
+
+// seekTolerance is how far the progress can drift from what's expected before it counts as a seek
+const seekTolerance = 2 * time.Second
+
+// The Backend interface describes what the service needs from Spotify
+type Backend interface {
+	GetPlayerState(opts *api.Options) (api.PlayerState, error)
+	StartPlayback(opts *api.PlayerOptions) error
+	PausePlayback(opts *api.Options) error
+	SkipToNext(opts *api.Options) error
+	SkipToPrevious(opts *api.Options) error
+	SeekToPosition(pos int, opts *api.Options) error
+	SetVolume(vol int, opts *api.Options) error
+	SetRepeatMode(state string, opts *api.Options) error
+	ToggleShuffle(state bool, opts *api.Options) error
+}
+
+// Service answers the MPRIS method calls and signals the changes of the player
+type Service struct {
+	Backend Backend
+	conn    *dbus.Conn
+
+	// mu also protects the cached state
+	mu       api.CallLock
+	state    api.PlayerState
+	noDevice bool
+	stateAt  time.Time
+	wake     chan bool
+}
+
+// NewService returns a service answering on the connection with the given backend
+func NewService(conn *dbus.Conn, b Backend) *Service {
+	return &Service{Backend: b, conn: conn, wake: make(chan bool, 1)}
+}
+
+// Serve takes the org.mpris.MediaPlayer2.<name> bus name and answers calls until the connection closes
+func (s *Service) Serve(name string) error {
+	if err := s.conn.RequestName(rootInterface + "." + name); err != nil {
+		return err
+	}
+
+	go s.watch()
+
+	return s.conn.Serve(s.handle)
+}
+
+// playerState returns the player state fetched within the last second, ok is false when nothing is playing on any device
+func (s *Service) playerState() (ps api.PlayerState, ok bool, err error) {
+	if time.Since(s.stateAt) < stateTTL {
+		return s.state, !s.noDevice, nil
+	}
+
+	ps, err = s.Backend.GetPlayerState(nil)
+
+	if err != nil && err != api.ErrNoActiveDevice {
+		return ps, false, err
+	}
+
+	s.state = ps
+	s.noDevice = err != nil
+	s.stateAt = time.Now()
+
+	return s.state, !s.noDevice, nil
+}
+
+// position returns the progress of the current track in microseconds, it moves on from the last poll while playing
+func (s *Service) position(ps api.PlayerState) int64 {
+	pos := time.Duration(ps.ProgressMs) * time.Millisecond
+
+	if ps.IsPlaying {
+		pos += time.Since(s.stateAt)
+	}
+
+	if ps.Item != nil && pos > time.Duration(ps.Item.DurationMs)*time.Millisecond {
+		pos = time.Duration(ps.Item.DurationMs) * time.Millisecond
+	}
+
+	return int64(pos / time.Microsecond)
+}
+
+// invalidate drops the cached state after a call changed the player and wakes the watcher to signal the change
+func (s *Service) invalidate() {
+	s.stateAt = time.Time{}
+
+	select {
+	case s.wake <- true:
+	default:
+	}
+}
+
+// trackID returns the MPRIS track id of a track, object paths only allow letters, digits and underscores
+func trackID(t *api.FullTrack) dbus.ObjectPath {
+	if t == nil || t.ID == "" {
+		return noTrack
+	}
+
+	return dbus.ObjectPath("/org/mpris/MediaPlayer2/baton/track/" + t.ID)
+}
+
+func artistNames(artists []api.SimpleArtist) []string {
+	names := []string{}
+
+	for _, a := range artists {
+		names = append(names, a.Name)
+	}
+
+	return names
+}
+
+// metadata describes the current track with the xesam and mpris fields
+func metadata(ps api.PlayerState, ok bool) map[string]dbus.Variant {
+	m := map[string]dbus.Variant{}
+
+	if !ok || ps.Item == nil {
+		m["mpris:trackid"] = dbus.Variant{Value: noTrack}
+		return m
+	}
+
+	t := ps.Item
+	m["mpris:trackid"] = dbus.Variant{Value: trackID(t)}
+	m["mpris:length"] = dbus.Variant{Value: int64(t.DurationMs) * 1000}
+	m["xesam:title"] = dbus.Variant{Value: t.Name}
+	m["xesam:artist"] = dbus.Variant{Value: artistNames(t.Artists)}
+	m["xesam:trackNumber"] = dbus.Variant{Value: int32(t.TrackNumber)}
+	m["xesam:discNumber"] = dbus.Variant{Value: int32(t.DiscNumber)}
+
+	if u := t.ExternalUrls["spotify"]; u != "" {
+		m["xesam:url"] = dbus.Variant{Value: u}
+	}
+
+	if t.Album != nil {
+		m["xesam:album"] = dbus.Variant{Value: t.Album.Name}
+		m["xesam:albumArtist"] = dbus.Variant{Value: artistNames(t.Album.Artists)}
+
+		if len(t.Album.Images) > 0 {
+			m["mpris:artUrl"] = dbus.Variant{Value: t.Album.Images[0].URL}
+		}
+	}
+
+	return m
+}
+
+func playbackStatus(ps api.PlayerState, ok bool) string {
+	switch {
+	case !ok || ps.Item == nil:
+		return "Stopped"
+	case ps.IsPlaying:
+		return "Playing"
+	}
+
+	return "Paused"
+}
+
+// Loop statuses for Spotify's repeat modes
+var loopStatuses = map[string]string{
+	"off":     "None",
+	"track":   "Track",
+	"context": "Playlist",
+}
+
+func volume(ps api.PlayerState, ok bool) float64 {
+	if !ok || ps.Device == nil {
+		return 0
+	}
+
+	return float64(ps.Device.VolumePercent) / 100
+}
+
+// rootProperties returns the properties of the org.mpris.MediaPlayer2 interface
+func rootProperties() map[string]dbus.Variant {
+	return map[string]dbus.Variant{
+		"CanQuit":             {Value: false},
+		"CanRaise":            {Value: false},
+		"HasTrackList":        {Value: false},
+		"Identity":            {Value: "Baton"},
+		"SupportedUriSchemes": {Value: []string{"spotify"}},
+		"SupportedMimeTypes":  {Value: []string{}},
+	}
+}
+
+// playerProperties returns the properties of the org.mpris.MediaPlayer2.Player interface
+func (s *Service) playerProperties() (map[string]dbus.Variant, error) {
+	ps, ok, err := s.playerState()
+
+	if err != nil {
+		return nil, err
+	}
+
+	loop := loopStatuses[ps.RepeatState]
+
+	if loop == "" {
+		loop = "None"
+	}
+
+	hasTrack := ok && ps.Item != nil
+
+	return map[string]dbus.Variant{
+		"PlaybackStatus": {Value: playbackStatus(ps, ok)},
+		"LoopStatus":     {Value: loop},
+		"Rate":           {Value: 1.0},
+		"MinimumRate":    {Value: 1.0},
+		"MaximumRate":    {Value: 1.0},
+		"Shuffle":        {Value: ps.ShuffleState},
+		"Metadata":       {Value: metadata(ps, ok)},
+		"Volume":         {Value: volume(ps, ok)},
+		"Position":       {Value: s.position(ps)},
+		"CanGoNext":      {Value: true},
+		"CanGoPrevious":  {Value: true},
+		"CanPlay":        {Value: true},
+		"CanPause":       {Value: true},
+		"CanSeek":        {Value: hasTrack},
+		"CanControl":     {Value: true},
+	}, nil
+}
+
+func (s *Service) properties(iface string) (map[string]dbus.Variant, error) {
+	switch iface {
+	case rootInterface:
+		return rootProperties(), nil
+	case playerInterface:
+		return s.playerProperties()
+	}
+
+	return nil, dbus.NewError(dbus.ErrorUnknownInterface, "no interface %s", iface)
+}
+
+func stringArg(m *dbus.Message, i int) (string, error) {
+	if i < len(m.Body) {
+		if v, ok := m.Body[i].(string); ok {
+			return v, nil
+		}
+	}
+
+	return "", dbus.NewError(dbus.ErrorInvalidArgs, "argument %d of %s must be a string", i+1, m.Member)
+}
+
+func int64Arg(m *dbus.Message, i int) (int64, error) {
+	if i < len(m.Body) {
+		if v, ok := m.Body[i].(int64); ok {
+			return v, nil
+		}
+	}
+
+	return 0, dbus.NewError(dbus.ErrorInvalidArgs, "argument %d of %s must be a 64-bit integer", i+1, m.Member)
+}
+
+// handle answers a method call
+func (s *Service) handle(m *dbus.Message) ([]interface{}, error) {
+	if m.Interface == introspectInterface && m.Member == "Introspect" {
+		return []interface{}{introspect(m.Path)}, nil
+	}
+
+	if m.Path != objectPath {
+		return nil, dbus.NewError(dbus.ErrorUnknownObject, "no object %s", m.Path)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch m.Interface {
+	case propertiesInterface:
+		return s.handleProperties(m)
+	case rootInterface:
+		// Baton has no window to raise and nothing to quit, CanRaise and CanQuit say so
+		if m.Member == "Raise" || m.Member == "Quit" {
+			return nil, nil
+		}
+	case playerInterface, "":
+		return nil, s.handlePlayer(m)
+	}
+
+	return nil, dbus.NewError(dbus.ErrorUnknownMethod, "no method %s.%s", m.Interface, m.Member)
+}
+
+func (s *Service) handleProperties(m *dbus.Message) ([]interface{}, error) {
+	iface, err := stringArg(m, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch m.Member {
+	case "GetAll":
+		props, err := s.properties(iface)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []interface{}{props}, nil
+	case "Get":
+		name, err := stringArg(m, 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		props, err := s.properties(iface)
+
+		if err != nil {
+			return nil, err
+		}
+
+		v, ok := props[name]
+
+		if !ok {
+			return nil, dbus.NewError(dbus.ErrorUnknownProperty, "no property %s", name)
+		}
+
+		return []interface{}{v}, nil
+	case "Set":
+		name, err := stringArg(m, 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var v dbus.Variant
+
+		if len(m.Body) > 2 {
+			v, _ = m.Body[2].(dbus.Variant)
+		}
+
+		return nil, s.setProperty(iface, name, v.Value)
+	}
+
+	return nil, dbus.NewError(dbus.ErrorUnknownMethod, "no method %s.%s", m.Interface, m.Member)
+}
+
+// setProperty changes one of the writable properties: LoopStatus, Shuffle and Volume
+func (s *Service) setProperty(iface, name string, value interface{}) error {
+	props, err := s.properties(iface)
+
+	if err != nil {
+		return err
+	}
+
+	if _, ok := props[name]; !ok {
+		return dbus.NewError(dbus.ErrorUnknownProperty, "no property %s", name)
+	}
+
+	invalid := dbus.NewError(dbus.ErrorInvalidArgs, "wrong value for %s", name)
+
+	if iface != playerInterface {
+		return dbus.NewError(dbus.ErrorPropertyReadOnly, "%s is read-only", name)
+	}
+
+	defer s.invalidate()
+
+	switch name {
+	case "LoopStatus":
+		v, _ := value.(string)
+
+		for mode, status := range loopStatuses {
+			if status == v {
+				return s.Backend.SetRepeatMode(mode, nil)
+			}
+		}
+
+		return invalid
+	case "Shuffle":
+		v, ok := value.(bool)
+
+		if !ok {
+			return invalid
+		}
+
+		return s.Backend.ToggleShuffle(v, nil)
+	case "Volume":
+		v, ok := value.(float64)
+
+		if !ok {
+			return invalid
+		}
+
+		v = math.Max(0, math.Min(1, v))
+
+		return s.Backend.SetVolume(int(math.Round(v*100)), nil)
+	case "Rate":
+		// Spotify only plays at normal speed
+		if v, ok := value.(float64); !ok || v != 1 {
+			return invalid
+		}
+
+		return nil
+	}
+
+	return dbus.NewError(dbus.ErrorPropertyReadOnly, "%s is read-only", name)
+}
+
+func (s *Service) handlePlayer(m *dbus.Message) error {
+	switch m.Member {
+	case "Next":
+		defer s.invalidate()
+		return s.Backend.SkipToNext(nil)
+	case "Previous":
+		defer s.invalidate()
+		return s.Backend.SkipToPrevious(nil)
+	case "Pause", "Stop":
+		defer s.invalidate()
+		return s.Backend.PausePlayback(nil)
+	case "Play":
+		defer s.invalidate()
+		return s.Backend.StartPlayback(&api.PlayerOptions{})
+	case "PlayPause":
+		ps, _, err := s.playerState()
+
+		if err != nil {
+			return err
+		}
+
+		defer s.invalidate()
+
+		if ps.IsPlaying {
+			return s.Backend.PausePlayback(nil)
+		}
+
+		return s.Backend.StartPlayback(&api.PlayerOptions{})
+	case "Seek":
+		offset, err := int64Arg(m, 0)
+
+		if err != nil {
+			return err
+		}
+
+		ps, ok, err := s.playerState()
+
+		if err != nil || !ok || ps.Item == nil {
+			return err
+		}
+
+		pos := s.position(ps) + offset
+
+		defer s.invalidate()
+
+		// Seeking past the end moves to the next track as the specification asks
+		if pos >= int64(ps.Item.DurationMs)*1000 {
+			return s.Backend.SkipToNext(nil)
+		}
+
+		if pos < 0 {
+			pos = 0
+		}
+
+		return s.Backend.SeekToPosition(int(pos/1000), nil)
+	case "SetPosition":
+		var id dbus.ObjectPath
+
+		if len(m.Body) > 0 {
+			id, _ = m.Body[0].(dbus.ObjectPath)
+		}
+
+		pos, err := int64Arg(m, 1)
+
+		if err != nil {
+			return err
+		}
+
+		ps, ok, err := s.playerState()
+
+		if err != nil {
+			return err
+		}
+
+		// A position for a track that's no longer playing or outside of it is ignored
+		if !ok || trackID(ps.Item) != id || pos < 0 || pos > int64(ps.Item.DurationMs)*1000 {
+			return nil
+		}
+
+		defer s.invalidate()
+
+		return s.Backend.SeekToPosition(int(pos/1000), nil)
+	case "OpenUri":
+		uri, err := stringArg(m, 0)
+
+		if err != nil {
+			return err
+		}
+
+		if !strings.HasPrefix(uri, "spotify:") {
+			return dbus.NewError(dbus.ErrorInvalidArgs, "only spotify: URIs can be opened")
+		}
+
+		opts := &api.PlayerOptions{ContextURI: uri}
+
+		if strings.HasPrefix(uri, "spotify:track:") || strings.HasPrefix(uri, "spotify:episode:") {
+			opts = &api.PlayerOptions{URIs: []string{uri}}
+		}
+
+		defer s.invalidate()
+
+		return s.Backend.StartPlayback(opts)
+	}
+
+	return dbus.NewError(dbus.ErrorUnknownMethod, "no method %s.%s", m.Interface, m.Member)
+}
+
+// Player properties that change with each watcher event
+var eventProperties = map[string][]string{
+	watcher.TrackChanged:   {"Metadata", "CanSeek", "PlaybackStatus"},
+	watcher.Paused:         {"PlaybackStatus"},
+	watcher.Resumed:        {"PlaybackStatus"},
+	watcher.Stopped:        {"PlaybackStatus", "Metadata", "CanSeek"},
+	watcher.DeviceChanged:  {"Volume", "PlaybackStatus"},
+	watcher.VolumeChanged:  {"Volume"},
+	watcher.ShuffleChanged: {"Shuffle"},
+	watcher.RepeatChanged:  {"LoopStatus"},
+}
+
+// seeked reports whether the progress jumped between two polls of the same track
+func seeked(prev, cur api.PlayerState, elapsed time.Duration) bool {
+	if prev.Item == nil || cur.Item == nil || prev.Item.URI != cur.Item.URI {
+		return false
+	}
+
+	expected := time.Duration(prev.ProgressMs) * time.Millisecond
+
+	if prev.IsPlaying {
+		expected += elapsed
+	}
+
+	d := time.Duration(cur.ProgressMs)*time.Millisecond - expected
+
+	return d > seekTolerance || d < -seekTolerance
+}
+
+// watch polls Spotify and emits PropertiesChanged and Seeked for the changes
+func (s *Service) watch() {
+	var w watcher.Watcher
+	var prev api.PlayerState
+	var prevAt time.Time
+
+	for {
+		s.mu.Lock()
+		s.stateAt = time.Time{}
+		ps, ok, err := s.playerState()
+		props, _ := s.playerProperties()
+		s.mu.Unlock()
+
+		if err == nil {
+			var cur *api.PlayerState
+
+			if ok {
+				cur = &ps
+			}
+
+			changed := map[string]dbus.Variant{}
+
+			for _, e := range w.Update(cur, time.Now()) {
+				for _, name := range eventProperties[e.Type] {
+					changed[name] = props[name]
+				}
+			}
+
+			if len(changed) > 0 {
+				if s.conn.Emit(objectPath, propertiesInterface, "PropertiesChanged", playerInterface, changed, []string{}) == dbus.ErrClosed {
+					return
+				}
+			}
+
+			if ok && !prevAt.IsZero() && seeked(prev, ps, time.Since(prevAt)) {
+				s.conn.Emit(objectPath, playerInterface, "Seeked", props["Position"].Value)
+			}
+
+			prev, prevAt = ps, time.Now()
+
+			if !ok {
+				prevAt = time.Time{}
+			}
+		}
+
+		select {
+		case <-s.wake:
+			s.mu.Settle()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// introspect describes the objects, parents of the player object list it as a child so tools can find it
+func introspect(path dbus.ObjectPath) string {
+	const header = `<!DOCTYPE node PUBLIC "-//freedesktop//DTD D-BUS Object Introspection 1.0//EN" "http://www.freedesktop.org/standards/dbus/1.0/introspect.dtd">` + "\n"
+
+	if path == objectPath {
+		return header + introspectionXML
+	}
+
+	p := strings.TrimSuffix(string(path), "/") + "/"
+
+	if strings.HasPrefix(string(objectPath), p) {
+		child := strings.SplitN(strings.TrimPrefix(string(objectPath), p), "/", 2)[0]
+		return header + `<node><node name="` + child + `"/></node>` + "\n"
+	}
+
+	return header + "<node/>\n"
+}
+
+const introspectionXML = `<node>
+  <interface name="org.freedesktop.DBus.Introspectable">
+    <method name="Introspect">
+      <arg name="data" type="s" direction="out"/>
+    </method>
+  </interface>
+  <interface name="org.freedesktop.DBus.Peer">
+    <method name="Ping"/>
+  </interface>
+  <interface name="org.freedesktop.DBus.Properties">
+    <method name="Get">
+      <arg name="interface_name" type="s" direction="in"/>
+      <arg name="property_name" type="s" direction="in"/>
+      <arg name="value" type="v" direction="out"/>
+    </method>
+    <method name="GetAll">
+      <arg name="interface_name" type="s" direction="in"/>
+      <arg name="properties" type="a{sv}" direction="out"/>
+    </method>
+    <method name="Set">
+      <arg name="interface_name" type="s" direction="in"/>
+      <arg name="property_name" type="s" direction="in"/>
+      <arg name="value" type="v" direction="in"/>
+    </method>
+    <signal name="PropertiesChanged">
+      <arg name="interface_name" type="s"/>
+      <arg name="changed_properties" type="a{sv}"/>
+      <arg name="invalidated_properties" type="as"/>
+    </signal>
+  </interface>
+  <interface name="org.mpris.MediaPlayer2">
+    <method name="Raise"/>
+    <method name="Quit"/>
+    <property name="CanQuit" type="b" access="read"/>
+    <property name="CanRaise" type="b" access="read"/>
+    <property name="HasTrackList" type="b" access="read"/>
+    <property name="Identity" type="s" access="read"/>
+    <property name="SupportedUriSchemes" type="as" access="read"/>
+    <property name="SupportedMimeTypes" type="as" access="read"/>
+  </interface>
+  <interface name="org.mpris.MediaPlayer2.Player">
+    <method name="Next"/>
+    <method name="Previous"/>
+    <method name="Pause"/>
+    <method name="PlayPause"/>
+    <method name="Stop"/>
+    <method name="Play"/>
+    <method name="Seek">
+      <arg name="Offset" type="x" direction="in"/>
+    </method>
+    <method name="SetPosition">
+      <arg name="TrackId" type="o" direction="in"/>
+      <arg name="Position" type="x" direction="in"/>
+    </method>
+    <method name="OpenUri">
+      <arg name="Uri" type="s" direction="in"/>
+    </method>
+    <signal name="Seeked">
+      <arg name="Position" type="x"/>
+    </signal>
+    <property name="PlaybackStatus" type="s" access="read"/>
+    <property name="LoopStatus" type="s" access="readwrite"/>
+    <property name="Rate" type="d" access="readwrite"/>
+    <property name="Shuffle" type="b" access="readwrite"/>
+    <property name="Metadata" type="a{sv}" access="read"/>
+    <property name="Volume" type="d" access="readwrite"/>
+    <property name="Position" type="x" access="read"/>
+    <property name="MinimumRate" type="d" access="read"/>
+    <property name="MaximumRate" type="d" access="read"/>
+    <property name="CanGoNext" type="b" access="read"/>
+    <property name="CanGoPrevious" type="b" access="read"/>
+    <property name="CanPlay" type="b" access="read"/>
+    <property name="CanPause" type="b" access="read"/>
+    <property name="CanSeek" type="b" access="read"/>
+    <property name="CanControl" type="b" access="read"/>
+  </interface>
+</node>
+`
@@ -0,0 +1,365 @@
+package mpris
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/dbus"
+)
+
+// fakeBackend plays the given state and records the calls made to it
+type fakeBackend struct {
+	mu    sync.Mutex
+	state api.PlayerState
+	err   error
+	calls []string
+}
+
+func (b *fakeBackend) record(format string, a ...interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.calls = append(b.calls, fmt.Sprintf(format, a...))
+
+	return nil
+}
+
+func (b *fakeBackend) recorded() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]string(nil), b.calls...)
+}
+
+func (b *fakeBackend) GetPlayerState(opts *api.Options) (api.PlayerState, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state, b.err
+}
+
+func (b *fakeBackend) StartPlayback(opts *api.PlayerOptions) error {
+	return b.record("play %s%s", opts.ContextURI, strings.Join(opts.URIs, ","))
+}
+
+func (b *fakeBackend) PausePlayback(opts *api.Options) error {
+	return b.record("pause")
+}
+
+func (b *fakeBackend) SkipToNext(opts *api.Options) error {
+	return b.record("next")
+}
+
+func (b *fakeBackend) SkipToPrevious(opts *api.Options) error {
+	return b.record("previous")
+}
+
+func (b *fakeBackend) SeekToPosition(pos int, opts *api.Options) error {
+	return b.record("seek %d", pos)
+}
+
+func (b *fakeBackend) SetVolume(vol int, opts *api.Options) error {
+	return b.record("volume %d", vol)
+}
+
+func (b *fakeBackend) SetRepeatMode(state string, opts *api.Options) error {
+	return b.record("repeat %s", state)
+}
+
+func (b *fakeBackend) ToggleShuffle(state bool, opts *api.Options) error {
+	return b.record("shuffle %v", state)
+}
+
+func playing() api.PlayerState {
+	return api.PlayerState{
+		Device:     &api.Device{ID: "d1", Name: "Kitchen", VolumePercent: 40},
+		IsPlaying:  true,
+		ProgressMs: 60000,
+		Item: &api.FullTrack{
+			ID:         "t1",
+			Name:       "Digital Love",
+			DurationMs: 300000,
+			Artists:    []api.SimpleArtist{{Name: "Daft Punk"}},
+			Album:      &api.SimpleAlbum{Name: "Discovery", Images: []api.Image{{URL: "https://i.scdn.co/a"}}},
+		},
+	}
+}
+
+func call(iface, member string, body ...interface{}) *dbus.Message {
+	return &dbus.Message{Type: dbus.TypeMethodCall, Path: objectPath, Interface: iface, Member: member, Body: body}
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   api.PlayerState
+		m       *dbus.Message
+		calls   []string
+		errName string
+	}{
+		{"next", playing(), call(playerInterface, "Next"), []string{"next"}, ""},
+		{"play pause while playing", playing(), call(playerInterface, "PlayPause"), []string{"pause"}, ""},
+		{"play pause while paused", api.PlayerState{}, call(playerInterface, "PlayPause"), []string{"play "}, ""},
+		{"seek forward", playing(), call(playerInterface, "Seek", int64(10000000)), []string{"seek 70000"}, ""},
+		{"seek past the end goes to the next track", playing(), call(playerInterface, "Seek", int64(300000000)), []string{"next"}, ""},
+		{"seek before the start", playing(), call(playerInterface, "Seek", int64(-90000000)), []string{"seek 0"}, ""},
+		{"set position", playing(), call(playerInterface, "SetPosition", dbus.ObjectPath("/org/mpris/MediaPlayer2/baton/track/t1"), int64(5000000)), []string{"seek 5000"}, ""},
+		{"set position of another track", playing(), call(playerInterface, "SetPosition", dbus.ObjectPath("/org/mpris/MediaPlayer2/baton/track/t2"), int64(5000000)), nil, ""},
+		{"open a track", playing(), call(playerInterface, "OpenUri", "spotify:track:t2"), []string{"play spotify:track:t2"}, ""},
+		{"open an album", playing(), call(playerInterface, "OpenUri", "spotify:album:a1"), []string{"play spotify:album:a1"}, ""},
+		{"open a web page", playing(), call(playerInterface, "OpenUri", "https://example.com"), nil, dbus.ErrorInvalidArgs},
+		{"seek without an offset", playing(), call(playerInterface, "Seek"), nil, dbus.ErrorInvalidArgs},
+		{"set the volume", playing(), call(propertiesInterface, "Set", playerInterface, "Volume", dbus.Variant{Value: 0.255}), []string{"volume 26"}, ""},
+		{"set the volume above the maximum", playing(), call(propertiesInterface, "Set", playerInterface, "Volume", dbus.Variant{Value: 1.5}), []string{"volume 100"}, ""},
+		{"set the loop status", playing(), call(propertiesInterface, "Set", playerInterface, "LoopStatus", dbus.Variant{Value: "Playlist"}), []string{"repeat context"}, ""},
+		{"set a wrong loop status", playing(), call(propertiesInterface, "Set", playerInterface, "LoopStatus", dbus.Variant{Value: "All"}), nil, dbus.ErrorInvalidArgs},
+		{"set shuffle", playing(), call(propertiesInterface, "Set", playerInterface, "Shuffle", dbus.Variant{Value: true}), []string{"shuffle true"}, ""},
+		{"set a read-only property", playing(), call(propertiesInterface, "Set", playerInterface, "CanPlay", dbus.Variant{Value: false}), nil, dbus.ErrorPropertyReadOnly},
+		{"get an unknown property", playing(), call(propertiesInterface, "Get", playerInterface, "Lyrics"), nil, dbus.ErrorUnknownProperty},
+		{"unknown interface", playing(), call("org.example", "Do"), nil, dbus.ErrorUnknownMethod},
+		{"unknown object", playing(), &dbus.Message{Path: "/other", Interface: playerInterface, Member: "Next"}, nil, dbus.ErrorUnknownObject},
+	}
+
+	for _, tt := range tests {
+		b := &fakeBackend{state: tt.state}
+		s := NewService(nil, b)
+		_, err := s.handle(tt.m)
+
+		if tt.errName != "" {
+			if e, ok := err.(*dbus.Error); !ok || e.Name != tt.errName {
+				t.Errorf("%s: got error %v, want %s", tt.name, err, tt.errName)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+
+		if got := b.recorded(); !reflect.DeepEqual(got, tt.calls) {
+			t.Errorf("%s: backend calls %q, want %q", tt.name, got, tt.calls)
+		}
+	}
+}
+
+func TestPlayerProperties(t *testing.T) {
+	ps := playing()
+	ps.RepeatState = "track"
+	s := NewService(nil, &fakeBackend{state: ps})
+	props, err := s.playerProperties()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]interface{}{"PlaybackStatus": "Playing", "LoopStatus": "Track", "Volume": 0.4, "CanSeek": true} {
+		if got := props[name].Value; got != want {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+
+	if pos := props["Position"].Value.(int64); pos < 60000000 || pos > 61000000 {
+		t.Errorf("Position = %d, want about a minute", pos)
+	}
+
+	md := props["Metadata"].Value.(map[string]dbus.Variant)
+
+	if md["mpris:trackid"].Value != dbus.ObjectPath("/org/mpris/MediaPlayer2/baton/track/t1") || md["xesam:title"].Value != "Digital Love" || md["mpris:length"].Value != int64(300000000) {
+		t.Errorf("Metadata = %v", md)
+	}
+
+	// Nothing playing anywhere
+	s = NewService(nil, &fakeBackend{err: api.ErrNoActiveDevice})
+
+	if props, err = s.playerProperties(); err != nil {
+		t.Fatal(err)
+	}
+
+	md = props["Metadata"].Value.(map[string]dbus.Variant)
+
+	if props["PlaybackStatus"].Value != "Stopped" || md["mpris:trackid"].Value != noTrack || len(md) != 1 {
+		t.Errorf("without a device got %v and %v", props["PlaybackStatus"].Value, md)
+	}
+}
+
+func TestSeeked(t *testing.T) {
+	track := &api.FullTrack{URI: "spotify:track:a"}
+	other := &api.FullTrack{URI: "spotify:track:b"}
+
+	tests := []struct {
+		name      string
+		prev, cur api.PlayerState
+		elapsed   time.Duration
+		want      bool
+	}{
+		{"played on", api.PlayerState{Item: track, IsPlaying: true, ProgressMs: 1000}, api.PlayerState{Item: track, ProgressMs: 3000}, 2 * time.Second, false},
+		{"jumped ahead", api.PlayerState{Item: track, IsPlaying: true, ProgressMs: 1000}, api.PlayerState{Item: track, ProgressMs: 60000}, 2 * time.Second, true},
+		{"jumped back while paused", api.PlayerState{Item: track, ProgressMs: 60000}, api.PlayerState{Item: track, ProgressMs: 0}, 2 * time.Second, true},
+		{"paused in place", api.PlayerState{Item: track, ProgressMs: 60000}, api.PlayerState{Item: track, ProgressMs: 60000}, 2 * time.Second, false},
+		{"other track", api.PlayerState{Item: track, ProgressMs: 60000}, api.PlayerState{Item: other, ProgressMs: 0}, 2 * time.Second, false},
+		{"nothing before", api.PlayerState{}, api.PlayerState{Item: track, ProgressMs: 60000}, 2 * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		if got := seeked(tt.prev, tt.cur, tt.elapsed); got != tt.want {
+			t.Errorf("%s: seeked = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIntrospect(t *testing.T) {
+	if x := introspect(objectPath); !strings.Contains(x, `<interface name="org.mpris.MediaPlayer2.Player">`) {
+		t.Errorf("the player object doesn't describe its interfaces")
+	}
+
+	if x := introspect("/org/mpris"); !strings.Contains(x, `<node name="MediaPlayer2"/>`) {
+		t.Errorf("the parent of the player object doesn't list it: %s", x)
+	}
+
+	if x := introspect("/"); !strings.Contains(x, `<node name="org"/>`) {
+		t.Errorf("the root doesn't lead to the player object: %s", x)
+	}
+}
+
+// busConfig is the configuration of a private bus where anyone can own names, like the session bus
+const busConfig = `<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startBus runs a private dbus-daemon for the test and returns its address, the test is skipped without dbus-daemon
+func startBus(t *testing.T) string {
+	daemon, err := exec.LookPath("dbus-daemon")
+
+	if err != nil {
+		t.Skip("dbus-daemon isn't installed")
+	}
+
+	dir, err := ioutil.TempDir("", "mpris")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := filepath.Join(dir, "bus.conf")
+
+	if err := ioutil.WriteFile(cfg, []byte(fmt.Sprintf(busConfig, filepath.Join(dir, "bus"))), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+cfg, "--nofork", "--print-address=1")
+	out, err := cmd.StdoutPipe()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		os.RemoveAll(dir)
+	})
+
+	address, err := bufio.NewReader(out).ReadString('\n')
+
+	if err != nil {
+		t.Fatalf("dbus-daemon didn't start: %v", err)
+	}
+
+	return strings.TrimSpace(address)
+}
+
+func TestServeOnPrivateBus(t *testing.T) {
+	address := startBus(t)
+	server, err := dbus.Dial(address)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer server.Close()
+
+	client, err := dbus.Dial(address)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer client.Close()
+
+	b := &fakeBackend{state: playing()}
+	go NewService(server, b).Serve("baton")
+
+	// Serve takes the name in the background
+	name := rootInterface + ".baton"
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		reply, err := client.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "NameHasOwner", name)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if reply[0] == true {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("%s wasn't taken", name)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	reply, err := client.Call(name, objectPath, propertiesInterface, "GetAll", playerInterface)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props, _ := reply[0].(map[interface{}]interface{})
+	status, _ := props["PlaybackStatus"].(dbus.Variant)
+	md, _ := props["Metadata"].(dbus.Variant)
+	artists, _ := md.Value.(map[interface{}]interface{})["xesam:artist"].(dbus.Variant)
+
+	if status.Value != "Playing" || !reflect.DeepEqual(artists.Value, []interface{}{"Daft Punk"}) {
+		t.Errorf("GetAll replied %v", props)
+	}
+
+	if _, err := client.Call(name, objectPath, playerInterface, "Next"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Call(name, objectPath, propertiesInterface, "Set", playerInterface, "Volume", dbus.Variant{Value: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := b.recorded(); !reflect.DeepEqual(got, []string{"next", "volume 50"}) {
+		t.Errorf("backend calls %q", got)
+	}
+
+	_, err = client.Call(name, objectPath, playerInterface, "Rewind")
+
+	if e, ok := err.(*dbus.Error); !ok || e.Name != dbus.ErrorUnknownMethod {
+		t.Errorf("an unknown method returned %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package scrobble
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LastFMURL is the Last.fm API endpoint used when no URL is configured
+const LastFMURL = "https://ws.audioscrobbler.com/2.0/"
+
+// LastFMAuthURL is the page where users grant an API account access to their Last.fm profile
+const LastFMAuthURL = "https://www.last.fm/api/auth/"
+
+// The LastFM struct describes an account on Last.fm or a server implementing its scrobbling API (ex. Libre.fm or Maloja)
+type LastFM struct {
+	URL        string
+	APIKey     string
+	Secret     string
+	SessionKey string
+	Client     *http.Client
+}
+
+// Name identifies Last.fm in the queue
+func (l *LastFM) Name() string {
+	return "lastfm"
+}
+
+// sign adds the api_sig parameter, the md5 of every parameter sorted by name followed by the shared secret
+func (l *LastFM) sign(params url.Values) {
+	var keys []string
+
+	for k := range params {
+		if k != "format" && k != "callback" {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	var b strings.Builder
+
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteString(params.Get(k))
+	}
+
+	b.WriteString(l.Secret)
+
+	sum := md5.Sum([]byte(b.String()))
+	params.Set("api_sig", hex.EncodeToString(sum[:]))
+}
+
+// call makes a signed request to the API and decodes the response into d
+func (l *LastFM) call(method string, params url.Values, d interface{}) error {
+	params.Set("method", method)
+	params.Set("api_key", l.APIKey)
+	l.sign(params)
+	params.Set("format", "json")
+
+	u := l.URL
+
+	if u == "" {
+		u = LastFMURL
+	}
+
+	client := l.Client
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.PostForm(u, params)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return readError(l.Name(), res)
+	}
+
+	// Errors are sometimes reported with a 200 status, look for them before decoding the result
+	var body json.RawMessage
+
+	err = json.NewDecoder(res.Body).Decode(&body)
+
+	if err != nil {
+		return err
+	}
+
+	var failed struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+
+	if json.Unmarshal(body, &failed) == nil && failed.Error != 0 {
+		return &Error{Service: l.Name(), Status: res.StatusCode, Code: failed.Error, Message: failed.Message}
+	}
+
+	if d != nil {
+		return json.Unmarshal(body, d)
+	}
+
+	return nil
+}
+
+// GetToken returns a request token the user has to authorize at AuthURL before calling GetSession
+func (l *LastFM) GetToken() (string, error) {
+	var res struct {
+		Token string `json:"token"`
+	}
+
+	err := l.call("auth.getToken", url.Values{}, &res)
+
+	return res.Token, err
+}
+
+// AuthURL returns the page where the user authorizes the token, base defaults to LastFMAuthURL
+func (l *LastFM) AuthURL(base, token string) string {
+	if base == "" {
+		base = LastFMAuthURL
+	}
+
+	return base + "?" + url.Values{"api_key": {l.APIKey}, "token": {token}}.Encode()
+}
+
+// GetSession exchanges an authorized token for a session key, session keys don't expire
+func (l *LastFM) GetSession(token string) (user, key string, err error) {
+	var res struct {
+		Session struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
+		} `json:"session"`
+	}
+
+	err = l.call("auth.getSession", url.Values{"token": {token}}, &res)
+
+	return res.Session.Name, res.Session.Key, err
+}
+
+// NowPlaying tells Last.fm which track started playing
+func (l *LastFM) NowPlaying(t Listen) error {
+	params := url.Values{
+		"artist": {t.Artist},
+		"track":  {t.Track},
+		"sk":     {l.SessionKey},
+	}
+
+	if t.Album != "" {
+		params.Set("album", t.Album)
+	}
+
+	if t.DurationMs > 0 {
+		params.Set("duration", strconv.Itoa(t.DurationMs/1000))
+	}
+
+	return l.call("track.updateNowPlaying", params, nil)
+}
+
+// Scrobble submits up to MaxBatch played tracks
+func (l *LastFM) Scrobble(listens []Listen) error {
+	params := url.Values{"sk": {l.SessionKey}}
+
+	for k, t := range listens {
+		i := "[" + strconv.Itoa(k) + "]"
+
+		params.Set("artist"+i, t.Artist)
+		params.Set("track"+i, t.Track)
+		params.Set("timestamp"+i, strconv.FormatInt(t.StartedAt.Unix(), 10))
+
+		if t.Album != "" {
+			params.Set("album"+i, t.Album)
+		}
+
+		if t.DurationMs > 0 {
+			params.Set("duration"+i, strconv.Itoa(t.DurationMs/1000))
+		}
+	}
+
+	return l.call("track.scrobble", params, nil)
+}
@@ -0,0 +1,138 @@
+package scrobble
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ListenBrainzURL is the ListenBrainz API root used when no URL is configured
+const ListenBrainzURL = "https://api.listenbrainz.org"
+
+// The ListenBrainz struct describes an account on ListenBrainz or a server implementing its API (ex. Maloja)
+type ListenBrainz struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+type listenBrainzPayload struct {
+	ListenType string               `json:"listen_type"`
+	Payload    []listenBrainzListen `json:"payload"`
+}
+
+type listenBrainzListen struct {
+	ListenedAt    int64                `json:"listened_at,omitempty"`
+	TrackMetadata listenBrainzMetadata `json:"track_metadata"`
+}
+
+type listenBrainzMetadata struct {
+	ArtistName     string                 `json:"artist_name"`
+	TrackName      string                 `json:"track_name"`
+	ReleaseName    string                 `json:"release_name,omitempty"`
+	AdditionalInfo map[string]interface{} `json:"additional_info"`
+}
+
+// Name identifies ListenBrainz in the queue
+func (l *ListenBrainz) Name() string {
+	return "listenbrainz"
+}
+
+func newListenBrainzListen(t Listen, timestamp bool) listenBrainzListen {
+	info := map[string]interface{}{
+		"media_player":      "Spotify",
+		"submission_client": "baton",
+		"music_service":     "spotify.com",
+	}
+
+	if t.DurationMs > 0 {
+		info["duration_ms"] = t.DurationMs
+	}
+
+	if strings.HasPrefix(t.TrackURI, "spotify:track:") {
+		info["spotify_id"] = "https://open.spotify.com/track/" + strings.TrimPrefix(t.TrackURI, "spotify:track:")
+	}
+
+	l := listenBrainzListen{
+		TrackMetadata: listenBrainzMetadata{
+			ArtistName:     t.Artist,
+			TrackName:      t.Track,
+			ReleaseName:    t.Album,
+			AdditionalInfo: info,
+		},
+	}
+
+	// Now playing submissions must not have a timestamp
+	if timestamp {
+		l.ListenedAt = t.StartedAt.Unix()
+	}
+
+	return l
+}
+
+func (l *ListenBrainz) submit(p listenBrainzPayload) error {
+	b, err := json.Marshal(p)
+
+	if err != nil {
+		return err
+	}
+
+	u := l.URL
+
+	if u == "" {
+		u = ListenBrainzURL
+	}
+
+	r, err := http.NewRequest("POST", strings.TrimSuffix(u, "/")+"/1/submit-listens", bytes.NewReader(b))
+
+	if err != nil {
+		return err
+	}
+
+	r.Header.Set("Authorization", "Token "+l.Token)
+	r.Header.Set("Content-Type", "application/json")
+
+	client := l.Client
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(r)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return readError(l.Name(), res)
+	}
+
+	return nil
+}
+
+// NowPlaying tells ListenBrainz which track started playing
+func (l *ListenBrainz) NowPlaying(t Listen) error {
+	return l.submit(listenBrainzPayload{
+		ListenType: "playing_now",
+		Payload:    []listenBrainzListen{newListenBrainzListen(t, false)},
+	})
+}
+
+// Scrobble submits played tracks, a single listen and several listens use different listen types
+func (l *ListenBrainz) Scrobble(listens []Listen) error {
+	p := listenBrainzPayload{ListenType: "single"}
+
+	if len(listens) > 1 {
+		p.ListenType = "import"
+	}
+
+	for _, t := range listens {
+		p.Payload = append(p.Payload, newListenBrainzListen(t, true))
+	}
+
+	return l.submit(p)
+}
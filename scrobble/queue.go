@@ -0,0 +1,274 @@
+package scrobble
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/firstlane/baton/utils"
+)
+
+// The Item struct describes a listen waiting to be submitted to a service
+type Item struct {
+	Service   string    `json:"service"`
+	Listen    Listen    `json:"listen"`
+	QueuedAt  time.Time `json:"queued_at"`
+	Attempts  int       `json:"attempts,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+func (i Item) key() string {
+	return i.Service + "\x00" + i.Listen.TrackURI + "\x00" + i.Listen.Track + "\x00" + i.Listen.StartedAt.Format(time.RFC3339Nano)
+}
+
+// The FlushResult struct describes what happened to the queued listens during a Flush
+type FlushResult struct {
+	Sent     int     `json:"sent"`
+	Rejected []Item  `json:"rejected,omitempty"`
+	Pending  int     `json:"pending"`
+	Errors   []error `json:"-"`
+}
+
+// Queue is the file holding listens that haven't been submitted yet, one JSON item per line
+//
+// Items are appended so a watcher and a manual flush can share the file, Flush rewrites it with the items that are
+// still pending including the ones added while it was submitting. They can run in different processes, the file is
+// appended to and rewritten under a lock on Path.lock and flushes are run one at a time under a lock on Path.flush so
+// no listen is submitted twice.
+type Queue struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// OpenQueue returns the queue kept in the given file, the file is created when the first listen is added
+func OpenQueue(path string) *Queue {
+	return &Queue{Path: path}
+}
+
+// Load returns every queued item, oldest first
+func (q *Queue) Load() ([]Item, error) {
+	var items []Item
+
+	f, err := os.Open(q.Path)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		var i Item
+
+		// A line cut short by a crash shouldn't block the rest of the queue
+		if json.Unmarshal(scanner.Bytes(), &i) == nil {
+			items = append(items, i)
+		}
+	}
+
+	return items, scanner.Err()
+}
+
+// lock takes the lock of the queue with the given suffix, it's shared with the other processes using the queue
+func (q *Queue) lock(suffix string) (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(q.Path), 0700); err != nil {
+		return nil, err
+	}
+
+	return utils.LockFile(q.Path + suffix)
+}
+
+// Add queues the listen once for every service
+func (q *Queue) Add(services []string, l Listen) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	unlock, err := q.lock(".lock")
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	f, err := os.OpenFile(q.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	now := time.Now().UTC()
+	w := bufio.NewWriter(f)
+
+	for _, s := range services {
+		b, err := json.Marshal(Item{Service: s, Listen: l, QueuedAt: now})
+
+		if err != nil {
+			return err
+		}
+
+		w.Write(b)
+		w.WriteByte('\n')
+	}
+
+	return w.Flush()
+}
+
+// Flush submits the queued listens to their services in batches, a service that fails keeps its listens for the
+// next flush unless it rejected them. Listens for services that aren't given are left alone.
+func (q *Queue) Flush(scrobblers []Scrobbler) (res FlushResult, err error) {
+	unlockFlush, err := q.lock(".flush")
+
+	if err != nil {
+		return res, err
+	}
+
+	defer unlockFlush()
+
+	items, err := q.Load()
+
+	if err != nil {
+		return res, err
+	}
+
+	done := make(map[string]bool)
+	failed := make(map[string]string)
+
+	for _, s := range scrobblers {
+		var batch []Item
+
+		for _, i := range items {
+			if i.Service == s.Name() {
+				batch = append(batch, i)
+			}
+		}
+
+		for len(batch) > 0 {
+			n := len(batch)
+
+			if n > MaxBatch {
+				n = MaxBatch
+			}
+
+			var listens []Listen
+
+			for _, i := range batch[:n] {
+				listens = append(listens, i.Listen)
+			}
+
+			err := s.Scrobble(listens)
+
+			if e, ok := err.(*Error); ok && e.Rejected() {
+				for _, i := range batch[:n] {
+					i.Attempts++
+					i.LastError = err.Error()
+					res.Rejected = append(res.Rejected, i)
+					done[i.key()] = true
+				}
+			} else if err != nil {
+				// Later batches would fail the same way, try again on the next flush
+				for _, i := range batch {
+					failed[i.key()] = err.Error()
+				}
+
+				res.Errors = append(res.Errors, err)
+				break
+			} else {
+				for _, i := range batch[:n] {
+					done[i.key()] = true
+				}
+
+				res.Sent += n
+			}
+
+			batch = batch[n:]
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	unlock, err := q.lock(".lock")
+
+	if err != nil {
+		return res, err
+	}
+
+	defer unlock()
+
+	// Reload so listens queued while submitting aren't lost
+	items, err = q.Load()
+
+	if err != nil {
+		return res, err
+	}
+
+	var pending []Item
+
+	for _, i := range items {
+		if done[i.key()] {
+			continue
+		}
+
+		if msg, ok := failed[i.key()]; ok {
+			i.Attempts++
+			i.LastError = msg
+		}
+
+		pending = append(pending, i)
+	}
+
+	res.Pending = len(pending)
+
+	if len(done) == 0 && len(failed) == 0 {
+		return res, nil
+	}
+
+	return res, q.write(pending)
+}
+
+// write replaces the queue with the items, through a temporary file so a crash never leaves it half written
+func (q *Queue) write(items []Item) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(q.Path), "scrobble_queue")
+
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+
+	for _, i := range items {
+		b, err := json.Marshal(i)
+
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+
+		w.Write(b)
+		w.WriteByte('\n')
+	}
+
+	err = w.Flush()
+	tmp.Close()
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), q.Path)
+}
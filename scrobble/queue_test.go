@@ -0,0 +1,156 @@
+package scrobble
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingScrobbler records the listens it's given, slowly enough for other processes to queue listens meanwhile
+type countingScrobbler struct {
+	seen map[string]int
+}
+
+func (s *countingScrobbler) Name() string {
+	return "test"
+}
+
+func (s *countingScrobbler) NowPlaying(l Listen) error {
+	return nil
+}
+
+func (s *countingScrobbler) Scrobble(listens []Listen) error {
+	time.Sleep(time.Millisecond)
+
+	for _, l := range listens {
+		s.seen[l.Track]++
+	}
+
+	return nil
+}
+
+// TestHelperQueueProcess adds listens to the queue from another process for TestQueueSharedBetweenProcesses
+func TestHelperQueueProcess(t *testing.T) {
+	path := os.Getenv("SCROBBLE_QUEUE")
+
+	if path == "" {
+		t.Skip("only run by TestQueueSharedBetweenProcesses")
+	}
+
+	n, _ := strconv.Atoi(os.Getenv("SCROBBLE_LISTENS"))
+	q := OpenQueue(path)
+
+	for i := 0; i < n; i++ {
+		l := Listen{Track: fmt.Sprintf("%s-%d", os.Getenv("SCROBBLE_PREFIX"), i), StartedAt: time.Unix(int64(i), 0)}
+
+		if err := q.Add([]string{"test"}, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestQueueSharedBetweenProcesses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scrobble")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "queue.jsonl")
+	const listens = 300
+	var adders []*exec.Cmd
+
+	for _, prefix := range []string{"a", "b"} {
+		c := exec.Command(os.Args[0], "-test.run=TestHelperQueueProcess")
+		c.Env = append(os.Environ(), "SCROBBLE_QUEUE="+path, "SCROBBLE_PREFIX="+prefix, "SCROBBLE_LISTENS="+strconv.Itoa(listens))
+		c.Stderr = os.Stderr
+
+		if err := c.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		adders = append(adders, c)
+	}
+
+	added := make(chan error, 1)
+
+	go func() {
+		for _, c := range adders {
+			if err := c.Wait(); err != nil {
+				added <- err
+				return
+			}
+		}
+
+		added <- nil
+	}()
+
+	// Two flushers run alongside the adders, each through its own Queue so only the file locks keep them apart
+	scrobblers := []*countingScrobbler{{seen: make(map[string]int)}, {seen: make(map[string]int)}}
+	stop := make(chan struct{})
+	errs := make(chan error, len(scrobblers))
+	var wg sync.WaitGroup
+
+	for _, s := range scrobblers {
+		wg.Add(1)
+
+		go func(s *countingScrobbler) {
+			defer wg.Done()
+
+			for {
+				if _, err := OpenQueue(path).Flush([]Scrobbler{s}); err != nil {
+					errs <- err
+					return
+				}
+
+				select {
+				case <-stop:
+					return
+				default:
+				}
+			}
+		}(s)
+	}
+
+	err = <-added
+	close(stop)
+	wg.Wait()
+	close(errs)
+
+	if err != nil {
+		t.Fatalf("adding listens failed: %s", err)
+	}
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	flusher, other := scrobblers[0], scrobblers[1]
+	res, err := OpenQueue(path).Flush([]Scrobbler{flusher})
+
+	if err != nil || res.Pending != 0 {
+		t.Fatalf("last flush left %d pending, %v", res.Pending, err)
+	}
+
+	for track, n := range other.seen {
+		flusher.seen[track] += n
+	}
+
+	if len(flusher.seen) != 2*listens {
+		t.Errorf("%d listens were submitted, want %d", len(flusher.seen), 2*listens)
+	}
+
+	for track, n := range flusher.seen {
+		if n != 1 {
+			t.Errorf("%s was submitted %d times", track, n)
+		}
+	}
+}
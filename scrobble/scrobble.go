@@ -0,0 +1,142 @@
+// Package scrobble submits played tracks to Last.fm compatible services and ListenBrainz
+//
+// Scrobbles go through a queue kept on disk so listens aren't lost while a service is unreachable, they are retried
+// until the service accepts or rejects them. "Now playing" notifications are only useful while the track plays and
+// are never queued.
+package scrobble
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/firstlane/baton/api"
+)
+
+// MinDuration is the length below which tracks are never scrobbled
+const MinDuration = 30 * time.Second
+
+// MaxPlayed is how long a track has to be played to be scrobbled even when it's shorter than half the track
+const MaxPlayed = 4 * time.Minute
+
+// The Listen struct describes a track that was played, or is playing for now playing notifications
+type Listen struct {
+	Artist     string    `json:"artist"`
+	Track      string    `json:"track"`
+	Album      string    `json:"album,omitempty"`
+	DurationMs int       `json:"duration_ms"`
+	TrackURI   string    `json:"track_uri,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// NewListen builds a listen for a track started at the given time, only the first artist is submitted like Spotify's own scrobbler does
+func NewListen(t *api.FullTrack, startedAt time.Time) Listen {
+	l := Listen{
+		Track:      t.Name,
+		DurationMs: t.DurationMs,
+		TrackURI:   t.URI,
+		StartedAt:  startedAt.UTC(),
+	}
+
+	if len(t.Artists) > 0 {
+		l.Artist = t.Artists[0].Name
+	}
+
+	if t.Album != nil {
+		l.Album = t.Album.Name
+	}
+
+	return l
+}
+
+// Eligible reports whether a track of the given length played for the given time should be scrobbled: it has to be
+// longer than 30 seconds and played for half its length or 4 minutes, whichever comes first
+func Eligible(duration, played time.Duration) bool {
+	if duration <= MinDuration {
+		return false
+	}
+
+	needed := duration / 2
+
+	if needed > MaxPlayed {
+		needed = MaxPlayed
+	}
+
+	return played >= needed
+}
+
+// The Scrobbler interface is implemented by the services listens are submitted to
+type Scrobbler interface {
+	// Name identifies the service in the queue and in messages
+	Name() string
+	// NowPlaying tells the service which track started playing
+	NowPlaying(l Listen) error
+	// Scrobble submits played tracks, at most MaxBatch of them at a time
+	Scrobble(listens []Listen) error
+}
+
+// MaxBatch is the largest number of listens submitted in one request, Last.fm doesn't accept more
+const MaxBatch = 50
+
+// The Error struct describes an error response from a scrobbling service
+type Error struct {
+	Service string `json:"service"`
+	Status  int    `json:"status"`
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+
+	if e.Code != 0 {
+		return fmt.Sprintf("%s: %d %s (error %d)", e.Service, e.Status, msg, e.Code)
+	}
+
+	return fmt.Sprintf("%s: %d %s", e.Service, e.Status, msg)
+}
+
+// lastfmInvalidParameters is the Last.fm error code for a request the service will never accept
+const lastfmInvalidParameters = 6
+
+// Rejected reports whether the service refused the listens themselves, retrying won't help and they're dropped from
+// the queue. Authentication failures and outages aren't rejections, the listens are kept until they can be sent.
+func (e *Error) Rejected() bool {
+	if e.Code != 0 {
+		return e.Code == lastfmInvalidParameters
+	}
+
+	return e.Status == http.StatusBadRequest || e.Status == http.StatusRequestEntityTooLarge
+}
+
+// readError builds an Error from a failed response, Last.fm and ListenBrainz both describe errors with a code and a message
+func readError(service string, res *http.Response) *Error {
+	var body struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+		Code    int             `json:"code"`
+	}
+
+	e := &Error{Service: service, Status: res.StatusCode}
+
+	if json.NewDecoder(res.Body).Decode(&body) != nil {
+		return e
+	}
+
+	// Last.fm sends the numeric code in "error" and the text in "message", ListenBrainz sends the text in "error"
+	if json.Unmarshal(body.Error, &e.Code) != nil {
+		json.Unmarshal(body.Error, &e.Message)
+	} else {
+		e.Message = body.Message
+	}
+
+	e.Message = strings.TrimSpace(e.Message)
+
+	return e
+}
@@ -0,0 +1,59 @@
+package scrobble
+
+import (
+	"testing"
+	"time"
+
+	"github.com/firstlane/baton/api"
+)
+
+func TestEligible(t *testing.T) {
+	tests := []struct {
+		name             string
+		duration, played time.Duration
+		want             bool
+	}{
+		{"unknown length", 0, time.Minute, false},
+		{"30 seconds", 30 * time.Second, 30 * time.Second, false},
+		{"31 seconds played half", 31 * time.Second, 15500 * time.Millisecond, true},
+		{"31 seconds played less than half", 31 * time.Second, 15 * time.Second, false},
+		{"3 minutes played half", 3 * time.Minute, 90 * time.Second, true},
+		{"3 minutes played almost half", 3 * time.Minute, 89 * time.Second, false},
+		{"8 minutes played 4", 8 * time.Minute, 4 * time.Minute, true},
+		{"20 minutes played 4", 20 * time.Minute, 4 * time.Minute, true},
+		{"20 minutes played almost 4", 20 * time.Minute, 4*time.Minute - time.Second, false},
+		{"nothing played", 3 * time.Minute, 0, false},
+		{"played longer than the track", 3 * time.Minute, 10 * time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		if got := Eligible(tt.duration, tt.played); got != tt.want {
+			t.Errorf("%s: Eligible = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewListen(t *testing.T) {
+	started := time.Date(2026, 10, 19, 14, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+
+	tests := []struct {
+		name  string
+		track *api.FullTrack
+		want  Listen
+	}{
+		{"full track",
+			&api.FullTrack{Name: "Digital Love", URI: "spotify:track:b", DurationMs: 301000, Artists: []api.SimpleArtist{{Name: "Daft Punk"}, {Name: "Romanthony"}}, Album: &api.SimpleAlbum{Name: "Discovery"}},
+			Listen{Artist: "Daft Punk", Track: "Digital Love", Album: "Discovery", DurationMs: 301000, TrackURI: "spotify:track:b", StartedAt: started.UTC()},
+		},
+		{"local file without album or artist",
+			&api.FullTrack{Name: "Demo", URI: "spotify:local:::Demo:60", DurationMs: 60000},
+			Listen{Track: "Demo", DurationMs: 60000, TrackURI: "spotify:local:::Demo:60", StartedAt: started.UTC()},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := NewListen(tt.track, started); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
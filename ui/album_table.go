@@ -132,3 +132,7 @@ func (a *AlbumTable) handleSaveKey(selectedIndex int) error {
 	}
 	return nil
 }
+
+func (a *AlbumTable) handleVolumeKey(selectedIndex int, up bool) error {
+	return nil
+}
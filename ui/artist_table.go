@@ -106,3 +106,7 @@ func (a *ArtistTable) newTableFromSelection(selectedIndex int) (Table, error) {
 func (a *ArtistTable) handleSaveKey(selectedIndex int) error {
 	return nil
 }
+
+func (a *ArtistTable) handleVolumeKey(selectedIndex int, up bool) error {
+	return nil
+}
@@ -0,0 +1,158 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/firstlane/baton/api"
+	"github.com/firstlane/baton/utils"
+	"github.com/jroimartin/gocui"
+)
+
+// DeviceTable implements the Table interface for the playback devices, choosing one transfers playback to it
+type DeviceTable struct {
+	devices    []api.Device
+	play       bool
+	volumeStep int
+}
+
+// NewDeviceTable creates a new instance of DeviceTable
+// play tells whether playback starts on the chosen device and volumeStep is how much the volume keys change its volume
+func NewDeviceTable(devices []api.Device, play bool, volumeStep int) *DeviceTable {
+	return &DeviceTable{
+		devices:    devices,
+		play:       play,
+		volumeStep: volumeStep,
+	}
+}
+
+func (d *DeviceTable) getColumnWidths(maxX int) map[string]int {
+	m := make(map[string]int)
+	m["type"] = maxX / 6
+	m["volume"] = maxX / 8
+	m["active"] = maxX / 10
+	m["restricted"] = maxX / 6
+	m["name"] = maxX - m["type"] - m["volume"] - m["active"] - m["restricted"]
+
+	return m
+}
+
+func (d *DeviceTable) renderHeader(v *gocui.View, maxX int) {
+	columnWidths := d.getColumnWidths(maxX)
+
+	nameHeader := utils.LeftPaddedString("NAME", columnWidths["name"], 2)
+	typeHeader := utils.LeftPaddedString("TYPE", columnWidths["type"], 2)
+	volumeHeader := utils.LeftPaddedString("VOLUME", columnWidths["volume"], 2)
+	activeHeader := utils.LeftPaddedString("ACTIVE", columnWidths["active"], 2)
+	restrictedHeader := utils.LeftPaddedString("RESTRICTED", columnWidths["restricted"], 2)
+
+	fmt.Fprintf(v, "\u001b[1m%s[0m\n", utils.LeftPaddedString("DEVICES", maxX, 2))
+	fmt.Fprintf(v, "\u001b[1m%s %s %s %s %s\u001b[0m\n", nameHeader, typeHeader, volumeHeader, activeHeader, restrictedHeader)
+}
+
+func (d *DeviceTable) render(v *gocui.View, maxX int) {
+	columnWidths := d.getColumnWidths(maxX)
+
+	for _, device := range d.devices {
+		name := utils.LeftPaddedString(device.Name, columnWidths["name"], 2)
+		deviceType := utils.LeftPaddedString(device.Type, columnWidths["type"], 2)
+		volume := utils.LeftPaddedString(strconv.Itoa(device.VolumePercent)+"%", columnWidths["volume"], 2)
+		active := utils.LeftPaddedString(strconv.FormatBool(device.IsActive), columnWidths["active"], 2)
+		restricted := utils.LeftPaddedString(strconv.FormatBool(device.IsRestricted), columnWidths["restricted"], 2)
+
+		fmt.Fprintf(v, "\n%s %s %s %s %s", name, deviceType, volume, active, restricted)
+	}
+}
+
+func (d *DeviceTable) renderFooter(v *gocui.View, maxX int) {
+	fmt.Fprintf(v, "\u001b[1m%s\u001b[0m\n", utils.LeftPaddedString(fmt.Sprintf("Showing %d devices", len(d.devices)), maxX, 2))
+}
+
+func (d *DeviceTable) getTableLength() int {
+	return len(d.devices)
+}
+
+// loadNextRecords fetches the devices again, there's only one page of them but they come and go
+func (d *DeviceTable) loadNextRecords() error {
+	devices, err := api.GetDevices()
+
+	if err != nil {
+		return err
+	}
+
+	d.devices = devices
+
+	return nil
+}
+
+func (d *DeviceTable) playSelected(selectedIndex int) (string, error) {
+	// Refreshing may leave no devices
+	if selectedIndex >= len(d.devices) {
+		return "", nil
+	}
+
+	device := d.devices[selectedIndex]
+	transferOptions := api.TransferOptions{
+		DeviceIDs: []string{device.ID},
+		Play:      d.play,
+	}
+
+	err := api.TransferPlayback(&transferOptions)
+
+	if err != nil {
+		return "", err
+	}
+
+	for i := range d.devices {
+		d.devices[i].IsActive = i == selectedIndex
+	}
+
+	chosenItem := fmt.Sprintf("Transferred playback to %s '%s'\n", device.Type, device.Name)
+
+	return chosenItem, nil
+}
+
+func (d *DeviceTable) newTableFromSelection(selectedIndex int) (Table, error) {
+	return nil, nil
+}
+
+func (d *DeviceTable) handleSaveKey(selectedIndex int) error {
+	return nil
+}
+
+func (d *DeviceTable) handleVolumeKey(selectedIndex int, up bool) error {
+	if selectedIndex >= len(d.devices) {
+		return nil
+	}
+
+	device := &d.devices[selectedIndex]
+
+	// The web api can't change the volume of these devices, the key does nothing rather than closing the picker with an error
+	if device.IsRestricted || device.Type == "CastVideo" || device.Type == "Phone" {
+		return nil
+	}
+
+	volume := device.VolumePercent - d.volumeStep
+
+	if up {
+		volume = device.VolumePercent + d.volumeStep
+	}
+
+	if volume < 0 {
+		volume = 0
+	}
+
+	if volume > 100 {
+		volume = 100
+	}
+
+	err := api.SetVolume(volume, &api.Options{DeviceID: device.ID})
+
+	if err != nil {
+		return err
+	}
+
+	device.VolumePercent = volume
+
+	return nil
+}
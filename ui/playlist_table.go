@@ -127,3 +127,7 @@ func (p *PlaylistTable) newTableFromSelection(selectedIndex int) (Table, error)
 func (p *PlaylistTable) handleSaveKey(selectedIndex int) error {
 	return nil
 }
+
+func (p *PlaylistTable) handleVolumeKey(selectedIndex int, up bool) error {
+	return nil
+}
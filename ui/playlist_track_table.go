@@ -132,3 +132,7 @@ func (t *PlaylistTrackTable) handleSaveKey(selectedIndex int) error {
 	}
 	return nil
 }
+
+func (t *PlaylistTrackTable) handleVolumeKey(selectedIndex int, up bool) error {
+	return nil
+}
@@ -122,3 +122,7 @@ func (a *SavedAlbumTable) handleSaveKey(selectedIndex int) error {
 
 	return nil
 }
+
+func (a *SavedAlbumTable) handleVolumeKey(selectedIndex int, up bool) error {
+	return nil
+}
@@ -174,3 +174,7 @@ func (t *SavedTrackTable) handleSaveKey(currentIndex int) error {
 
 	return nil
 }
+
+func (t *SavedTrackTable) handleVolumeKey(selectedIndex int, up bool) error {
+	return nil
+}
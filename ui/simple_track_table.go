@@ -133,3 +133,7 @@ func (t *SimpleTrackTable) handleSaveKey(selectedIndex int) error {
 	}
 	return nil
 }
+
+func (t *SimpleTrackTable) handleVolumeKey(selectedIndex int, up bool) error {
+	return nil
+}
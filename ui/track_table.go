@@ -151,3 +151,7 @@ func (t *TrackTable) handleSaveKey(selectedIndex int) error {
 	}
 	return nil
 }
+
+func (t *TrackTable) handleVolumeKey(selectedIndex int, up bool) error {
+	return nil
+}
@@ -3,9 +3,13 @@ package ui
 import (
 	"fmt"
 
+	"github.com/firstlane/baton/api"
 	"github.com/jroimartin/gocui"
 )
 
+// seekStepMs is how far the seek keys move in the current track
+const seekStepMs = 10000
+
 // The Table interface describes the logic necessary to draw, update, and respond to a list of table items with subtables
 type Table interface {
 	render(v *gocui.View, maxX int)
@@ -16,6 +20,7 @@ type Table interface {
 	playSelected(selectedIndex int) (string, error)
 	newTableFromSelection(selectedIndex int) (Table, error)
 	handleSaveKey(selectedIndex int) error
+	handleVolumeKey(selectedIndex int, up bool) error
 }
 
 var (
@@ -68,6 +73,14 @@ func saveSelected(g *gocui.Gui, v *gocui.View) error {
 	return err
 }
 
+func volumeUpSelected(g *gocui.Gui, v *gocui.View) error {
+	return currentTable.handleVolumeKey(getSelectedY(v), true)
+}
+
+func volumeDownSelected(g *gocui.Gui, v *gocui.View) error {
+	return currentTable.handleVolumeKey(getSelectedY(v), false)
+}
+
 func playSelectedAndExit(g *gocui.Gui, v *gocui.View) error {
 	y := getSelectedY(v)
 	selected, err := currentTable.playSelected(y)
@@ -122,6 +135,26 @@ func popTable(g *gocui.Gui, v *gocui.View) error {
 	return nil
 }
 
+// seekBy moves the current track by offsetMs, kept within the track. The seek keys don't report failures, an error
+// returned to gocui would close the browser.
+func seekBy(offsetMs int) error {
+	ps, err := api.GetPlayerState(&api.Options{})
+
+	if err == nil && ps.Item != nil {
+		api.SeekToPosition(ps.ClampPosition(ps.ProgressMs+offsetMs), &api.Options{})
+	}
+
+	return nil
+}
+
+func seekForward(g *gocui.Gui, v *gocui.View) error {
+	return seekBy(seekStepMs)
+}
+
+func seekBackward(g *gocui.Gui, v *gocui.View) error {
+	return seekBy(-seekStepMs)
+}
+
 func loadNextRecords(g *gocui.Gui, v *gocui.View) error {
 	return currentTable.loadNextRecords()
 }
@@ -186,7 +219,11 @@ func layout(g *gocui.Gui) error {
 		v.Frame = false
 		v.BgColor = gocui.ColorBlue
 
-		fmt.Fprintf(v, "[q] Quit [h] Go back [j] Down [k] Up [l] Go forward [m] Load Additional [s] Save selected song/album to library [p] Play [enter] Play and Exit")
+		if _, ok := currentTable.(*DeviceTable); ok {
+			fmt.Fprintf(v, "[q] Quit [j] Down [k] Up [m] Refresh [+] Volume up [-] Volume down [p] Transfer [enter] Transfer and Exit")
+		} else {
+			fmt.Fprintf(v, "[q] Quit [h] Go back [j] Down [k] Up [l] Go forward [m] Load Additional [s] Save selected song/album to library [p] Play [enter] Play and Exit [,] Rewind 10s [.] Forward 10s")
+		}
 	}
 
 	return nil
@@ -206,6 +243,10 @@ func keybindings(g *gocui.Gui) error {
 	err = g.SetKeybinding("table", gocui.KeyEnter, gocui.ModNone, playSelectedAndExit)
 	err = g.SetKeybinding("table", 'm', gocui.ModNone, loadNextRecords)
 	err = g.SetKeybinding("table", 's', gocui.ModNone, saveSelected)
+	err = g.SetKeybinding("table", ',', gocui.ModNone, seekBackward)
+	err = g.SetKeybinding("table", '.', gocui.ModNone, seekForward)
+	err = g.SetKeybinding("table", '+', gocui.ModNone, volumeUpSelected)
+	err = g.SetKeybinding("table", '-', gocui.ModNone, volumeDownSelected)
 
 	if err != nil {
 		return err
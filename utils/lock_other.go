@@ -0,0 +1,38 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package utils
+
+import (
+	"os"
+	"time"
+)
+
+// staleLock is how old a lock file must be to be taken as left behind by a process that crashed
+const staleLock = 10 * time.Minute
+
+// LockFile takes an exclusive lock shared with other processes by creating the file, it waits while the file exists
+func LockFile(path string) (unlock func(), err error) {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+
+		if err == nil {
+			f.Close()
+
+			return func() {
+				os.Remove(path)
+			}, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
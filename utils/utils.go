@@ -1,8 +1,15 @@
 package utils
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -48,3 +55,210 @@ func LeftPaddedString(value string, maxValueLength, padAmount int) string {
 
 	return value
 }
+
+// UpdateJSONFile reads the JSON object stored in file, passes it to update and writes the result back
+// Keys that update doesn't touch are preserved, which lets Baton store its own settings next to anything the user added by hand
+func UpdateJSONFile(file string, update func(m map[string]interface{})) error {
+	m := make(map[string]interface{})
+
+	b, err := ioutil.ReadFile(file)
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if len(b) > 0 {
+		err = json.Unmarshal(b, &m)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	update(m)
+
+	b, err = json.MarshalIndent(m, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(file, b)
+}
+
+// WriteFileAtomic replaces the content of file through a temporary file renamed over it, so other processes reading
+// the file never see it half written. An existing file keeps its permissions.
+func WriteFileAtomic(file string, b []byte) error {
+	mode := os.FileMode(0644)
+
+	if fi, err := os.Stat(file); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".tmp")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(b)
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// SetJSONValue sets the value at the dot separated key within the JSON object stored in file, creating any intermediate objects
+// Passing a nil value removes the key instead
+func SetJSONValue(file, key string, value interface{}) error {
+	return UpdateJSONFile(file, func(m map[string]interface{}) {
+		path := strings.Split(key, ".")
+
+		for _, p := range path[:len(path)-1] {
+			p = existingKey(m, p)
+			next, ok := m[p].(map[string]interface{})
+
+			if !ok {
+				next = make(map[string]interface{})
+				m[p] = next
+			}
+
+			m = next
+		}
+
+		last := existingKey(m, path[len(path)-1])
+
+		if value == nil {
+			delete(m, last)
+		} else {
+			m[last] = value
+		}
+	})
+}
+
+// existingKey returns the key in m that matches k ignoring case, config keys are case insensitive so this avoids writing duplicates
+func existingKey(m map[string]interface{}, k string) string {
+	for key := range m {
+		if strings.EqualFold(key, k) {
+			return key
+		}
+	}
+
+	return k
+}
+
+// ParseDuration parses durations such as 90s, 4m30s, 30d or 2w as well as mm:ss and hh:mm:ss, a bare number is a
+// number of seconds
+func ParseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration '%s'", s)
+	}
+
+	if strings.Contains(s, ":") {
+		parts := strings.Split(s, ":")
+
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("invalid duration '%s'", s)
+		}
+
+		var d time.Duration
+
+		for k, p := range parts {
+			n, err := strconv.ParseUint(p, 10, 32)
+
+			// Only the leading part may go past 59, 90:00 is as clear as 1:30:00
+			if err != nil || (k > 0 && n > 59) {
+				return 0, fmt.Errorf("invalid duration '%s'", s)
+			}
+
+			d = d*60 + time.Duration(n)
+		}
+
+		return d * time.Second, nil
+	}
+
+	units := map[string]time.Duration{
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+		"w":  7 * 24 * time.Hour,
+	}
+
+	var d time.Duration
+	rest := s
+
+	for rest != "" {
+		i := 0
+		for i < len(rest) && (rest[i] == '.' || (rest[i] >= '0' && rest[i] <= '9')) {
+			i++
+		}
+
+		j := i
+		for j < len(rest) && (rest[j] < '0' || rest[j] > '9') && rest[j] != '.' {
+			j++
+		}
+
+		n, err := strconv.ParseFloat(rest[:i], 64)
+		unit, ok := units[strings.ToLower(rest[i:j])]
+
+		if i == len(s) {
+			unit, ok = time.Second, true
+		}
+
+		if err != nil || !ok || float64(d)+n*float64(unit) >= math.MaxInt64 {
+			return 0, fmt.Errorf("invalid duration '%s'", s)
+		}
+
+		d += time.Duration(n * float64(unit))
+		rest = rest[j:]
+	}
+
+	return d, nil
+}
+
+// ParseTime parses a point in time given on the command line, either a date (2006-01-02), a date and time (2006-01-02 15:04 or RFC 3339), today, yesterday or a duration ago such as 7d
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch strings.ToLower(s) {
+	case "today":
+		return midnight, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	}
+
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	d, err := ParseDuration(s)
+
+	// A bare number could as well be meant as days, a duration ago must say its unit
+	if err != nil || strings.Trim(s, "0123456789.") == "" {
+		return time.Time{}, fmt.Errorf("invalid time '%s', use a date like 2006-01-02, today, yesterday or a duration ago like 7d", s)
+	}
+
+	return now.Add(-d), nil
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"90s", 90 * time.Second, false},
+		{"4m30s", 4*time.Minute + 30*time.Second, false},
+		{"1.5h", 90 * time.Minute, false},
+		{"250ms", 250 * time.Millisecond, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"30D", 30 * 24 * time.Hour, false},
+		{"3:30", 3*time.Minute + 30*time.Second, false},
+		{"1:02:03", time.Hour + 2*time.Minute + 3*time.Second, false},
+		{"90:00", 90 * time.Minute, false},
+		{"0:00", 0, false},
+		{"", 0, true},
+		{"5", 5 * time.Second, false},
+		{"1.5", 1500 * time.Millisecond, false},
+		{"5 ", 0, true},
+		{"m", 0, true},
+		{"5x", 0, true},
+		{"-5s", 0, true},
+		{"1.2.3s", 0, true},
+		{":30", 0, true},
+		{"3:", 0, true},
+		{"1:2:3:4", 0, true},
+		{"3:75", 0, true},
+		{"-1:30", 0, true},
+		{"+1:30", 0, true},
+		{"99999999999999w", 0, true},
+		{"200000w", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	now := time.Date(2026, 10, 19, 15, 30, 0, 0, loc)
+
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"today", time.Date(2026, 10, 19, 0, 0, 0, 0, loc), false},
+		{"Yesterday", time.Date(2026, 10, 18, 0, 0, 0, 0, loc), false},
+		{"2026-01-02", time.Date(2026, 1, 2, 0, 0, 0, 0, loc), false},
+		{"2026-01-02 15:04", time.Date(2026, 1, 2, 15, 4, 0, 0, loc), false},
+		{"2026-01-02T15:04", time.Date(2026, 1, 2, 15, 4, 0, 0, loc), false},
+		{"2026-01-02T15:04:05Z", time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC), false},
+		{"7d", now.AddDate(0, 0, -7), false},
+		{"1:30:00", now.Add(-90 * time.Minute), false},
+		{"", time.Time{}, true},
+		{"2026-13-01", time.Time{}, true},
+		{"last week", time.Time{}, true},
+		{"7", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in, now)
+
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q) = %v, %v, want %v (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
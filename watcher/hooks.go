@@ -0,0 +1,108 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/firstlane/baton/api"
+)
+
+// HookTimeout is how long a hook may run before it's killed
+var HookTimeout = 30 * time.Second
+
+// Env returns the environment variables describing the event, the BATON_PREVIOUS_ variables describe the state before it
+func (e Event) Env() []string {
+	env := []string{"BATON_EVENT=" + e.Type, "BATON_TIME=" + e.Time.Format(time.RFC3339)}
+	env = append(env, stateEnv("BATON_", e.Current)...)
+	env = append(env, stateEnv("BATON_PREVIOUS_", e.Previous)...)
+
+	return env
+}
+
+func stateEnv(prefix string, ps *api.PlayerState) []string {
+	if ps == nil {
+		return nil
+	}
+
+	vars := map[string]string{
+		"IS_PLAYING":  strconv.FormatBool(ps.IsPlaying),
+		"PROGRESS_MS": strconv.Itoa(ps.ProgressMs),
+		"SHUFFLE":     strconv.FormatBool(ps.ShuffleState),
+		"REPEAT":      ps.RepeatState,
+	}
+
+	if ps.Item != nil {
+		var artists []string
+
+		for _, a := range ps.Item.Artists {
+			artists = append(artists, a.Name)
+		}
+
+		vars["TRACK"] = ps.Item.Name
+		vars["TRACK_URI"] = ps.Item.URI
+		vars["ARTIST"] = strings.Join(artists, ", ")
+		vars["DURATION_MS"] = strconv.Itoa(ps.Item.DurationMs)
+
+		if ps.Item.Album != nil {
+			vars["ALBUM"] = ps.Item.Album.Name
+		}
+	}
+
+	if ps.Device != nil {
+		vars["DEVICE"] = ps.Device.Name
+		vars["DEVICE_ID"] = ps.Device.ID
+		vars["DEVICE_TYPE"] = ps.Device.Type
+		vars["VOLUME"] = strconv.Itoa(ps.Device.VolumePercent)
+	}
+
+	if ps.Context != nil {
+		vars["CONTEXT"] = ps.Context.URI
+		vars["CONTEXT_TYPE"] = ps.Context.Type
+	}
+
+	var env []string
+
+	for k, v := range vars {
+		env = append(env, prefix+k+"="+v)
+	}
+
+	return env
+}
+
+// RunHook runs command with the system shell, the event is described by environment variables and written to stdin as JSON
+func RunHook(command string, e Event) (output []byte, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), HookTimeout)
+	defer cancel()
+
+	var c *exec.Cmd
+
+	if runtime.GOOS == "windows" {
+		c = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		c = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	in, err := json.Marshal(e)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+
+	c.Env = append(os.Environ(), e.Env()...)
+	c.Stdin = bytes.NewReader(in)
+	c.Stdout = &out
+	c.Stderr = &out
+
+	err = c.Run()
+
+	return out.Bytes(), err
+}
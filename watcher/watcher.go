@@ -0,0 +1,267 @@
+// Package watcher turns successive snapshots of the Spotify player state into events such as a track change or a pause
+//
+// A nil snapshot means nothing is playing on any device. The first snapshot never produces events, there is nothing
+// to compare it with.
+package watcher
+
+import (
+	"time"
+
+	"github.com/firstlane/baton/api"
+)
+
+// The event types produced by Diff
+const (
+	TrackChanged   = "track_changed"
+	Paused         = "paused"
+	Resumed        = "resumed"
+	Stopped        = "stopped"
+	DeviceChanged  = "device_changed"
+	VolumeChanged  = "volume_changed"
+	ShuffleChanged = "shuffle_changed"
+	RepeatChanged  = "repeat_changed"
+	ContextChanged = "context_changed"
+)
+
+// Types lists every event type in the order Diff reports them
+var Types = []string{
+	DeviceChanged,
+	ContextChanged,
+	TrackChanged,
+	Paused,
+	Resumed,
+	Stopped,
+	VolumeChanged,
+	ShuffleChanged,
+	RepeatChanged,
+}
+
+// The Event struct describes a change between two player state snapshots. Played describes the play of the previous
+// track ended by a track_changed or stopped event, only Watcher knows it.
+type Event struct {
+	Type     string           `json:"event"`
+	Time     time.Time        `json:"time"`
+	Previous *api.PlayerState `json:"previous"`
+	Current  *api.PlayerState `json:"current"`
+	Played   *Play            `json:"played,omitempty"`
+}
+
+// The Play struct describes one play of a track, ListenedMs only counts the time it was actually playing, not the
+// parts skipped by seeking
+type Play struct {
+	StartedAt  time.Time `json:"started_at"`
+	ListenedMs int       `json:"listened_ms"`
+}
+
+// Diff compares two snapshots and returns the events that explain the difference between them
+func Diff(prev, cur *api.PlayerState, now time.Time) []Event {
+	return diff(prev, cur, now, false)
+}
+
+// diff is Diff, replayed tells that the same track started over (repeat one) which the snapshots alone can't show
+func diff(prev, cur *api.PlayerState, now time.Time, replayed bool) []Event {
+	var events []Event
+
+	add := func(t string) {
+		events = append(events, Event{Type: t, Time: now, Previous: prev, Current: cur})
+	}
+
+	if prev == nil && cur == nil {
+		return nil
+	}
+
+	if cur == nil {
+		add(Stopped)
+		return events
+	}
+
+	// Starting from nothing, whatever the new snapshot holds is a change
+	if prev == nil {
+		if deviceID(cur) != "" {
+			add(DeviceChanged)
+		}
+
+		if contextURI(cur) != "" {
+			add(ContextChanged)
+		}
+
+		if trackURI(cur) != "" {
+			add(TrackChanged)
+		}
+
+		if cur.IsPlaying {
+			add(Resumed)
+		}
+
+		return events
+	}
+
+	if deviceID(prev) != deviceID(cur) {
+		add(DeviceChanged)
+	}
+
+	if contextURI(prev) != contextURI(cur) {
+		add(ContextChanged)
+	}
+
+	if trackURI(prev) != trackURI(cur) || replayed {
+		add(TrackChanged)
+	}
+
+	if prev.IsPlaying && !cur.IsPlaying {
+		add(Paused)
+	}
+
+	if !prev.IsPlaying && cur.IsPlaying {
+		add(Resumed)
+	}
+
+	// A volume change is only meaningful on the same device
+	if deviceID(prev) == deviceID(cur) && volume(prev) != volume(cur) {
+		add(VolumeChanged)
+	}
+
+	if prev.ShuffleState != cur.ShuffleState {
+		add(ShuffleChanged)
+	}
+
+	if prev.RepeatState != cur.RepeatState {
+		add(RepeatChanged)
+	}
+
+	return events
+}
+
+// replaySlack is how far from the end of a track the position may jump back to the start and still count as the track
+// being played again rather than a seek, it covers the delay between Spotify's position and the time of the snapshot
+const replaySlack = 2 * time.Second
+
+// Watcher remembers the last snapshot it was given so callers only have to pass new snapshots, between snapshots it
+// adds up how long the current track was listened to
+type Watcher struct {
+	last     *api.PlayerState
+	lastTime time.Time
+	play     Play
+	started  bool
+}
+
+// Update records a new snapshot and returns the events since the previous one
+func (w *Watcher) Update(cur *api.PlayerState, now time.Time) []Event {
+	prev, elapsed := w.last, now.Sub(w.lastTime)
+	w.last, w.lastTime = cur, now
+
+	if !w.started {
+		w.started = true
+		w.play = newPlay(cur, now, -1)
+		return nil
+	}
+
+	replayed := false
+
+	if prev != nil && trackURI(prev) != "" {
+		same := trackURI(cur) == trackURI(prev)
+		before, remaining := progress(prev), duration(prev)-progress(prev)
+
+		switch {
+		case same && progress(cur) >= before && (prev.IsPlaying || cur.IsPlaying):
+			// The position doesn't move while paused, moving further than the time that passed means part of the
+			// track was skipped by seeking
+			w.listen(minDuration(progress(cur)-before, elapsed))
+		case same && prev.IsPlaying && elapsed+replaySlack >= remaining && progress(cur) <= elapsed+replaySlack-remaining:
+			w.listen(minDuration(remaining, elapsed))
+			replayed = true
+		case !same && prev.IsPlaying && trackURI(cur) != "":
+			// The next track's position tells when the previous one stopped
+			w.listen(minDuration(remaining, elapsed-progress(cur)))
+		}
+	}
+
+	events := diff(prev, cur, now, replayed)
+
+	for k := range events {
+		if events[k].Type == TrackChanged || events[k].Type == Stopped {
+			played := w.play
+			events[k].Played = &played
+		}
+	}
+
+	if trackURI(prev) != trackURI(cur) || replayed {
+		w.play = newPlay(cur, now, elapsed)
+	}
+
+	return events
+}
+
+func (w *Watcher) listen(d time.Duration) {
+	if d > 0 {
+		w.play.ListenedMs += int(d / time.Millisecond)
+	}
+}
+
+// newPlay starts the play of the snapshot's track, the time it played before the snapshot is its position, up to
+// elapsed since the last snapshot (negative when there was none)
+func newPlay(ps *api.PlayerState, now time.Time, elapsed time.Duration) Play {
+	if ps == nil || ps.Item == nil {
+		return Play{}
+	}
+
+	listened := progress(ps)
+
+	if elapsed >= 0 {
+		listened = minDuration(listened, elapsed)
+	}
+
+	return Play{StartedAt: now.Add(-progress(ps)), ListenedMs: int(listened / time.Millisecond)}
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func progress(ps *api.PlayerState) time.Duration {
+	return time.Duration(ps.ProgressMs) * time.Millisecond
+}
+
+func duration(ps *api.PlayerState) time.Duration {
+	if ps.Item == nil {
+		return 0
+	}
+
+	return time.Duration(ps.Item.DurationMs) * time.Millisecond
+}
+
+func trackURI(ps *api.PlayerState) string {
+	if ps == nil || ps.Item == nil {
+		return ""
+	}
+
+	return ps.Item.URI
+}
+
+func deviceID(ps *api.PlayerState) string {
+	if ps.Device == nil {
+		return ""
+	}
+
+	return ps.Device.ID
+}
+
+func volume(ps *api.PlayerState) int {
+	if ps.Device == nil {
+		return 0
+	}
+
+	return ps.Device.VolumePercent
+}
+
+func contextURI(ps *api.PlayerState) string {
+	if ps.Context == nil {
+		return ""
+	}
+
+	return ps.Context.URI
+}
@@ -0,0 +1,217 @@
+package watcher
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firstlane/baton/api"
+)
+
+// state builds a snapshot, an empty device, context or track leaves it out
+func state(device string, volume int, context, track string, playing bool) *api.PlayerState {
+	ps := &api.PlayerState{IsPlaying: playing}
+
+	if device != "" {
+		ps.Device = &api.Device{ID: device, VolumePercent: volume}
+	}
+
+	if context != "" {
+		ps.Context = &api.PlayerContext{URI: context}
+	}
+
+	if track != "" {
+		ps.Item = &api.FullTrack{URI: track}
+	}
+
+	return ps
+}
+
+func types(events []Event) string {
+	var res []string
+
+	for _, e := range events {
+		res = append(res, e.Type)
+	}
+
+	return strings.Join(res, " ")
+}
+
+func TestDiff(t *testing.T) {
+	playing := state("phone", 50, "spotify:album:x", "spotify:track:a", true)
+	shuffled := state("phone", 50, "spotify:album:x", "spotify:track:a", true)
+	shuffled.ShuffleState = true
+	repeating := state("phone", 50, "spotify:album:x", "spotify:track:a", true)
+	repeating.RepeatState = "track"
+
+	tests := []struct {
+		name      string
+		prev, cur *api.PlayerState
+		want      string
+	}{
+		{"nothing playing", nil, nil, ""},
+		{"same state", playing, state("phone", 50, "spotify:album:x", "spotify:track:a", true), ""},
+		{"started playing", nil, playing, "device_changed context_changed track_changed resumed"},
+		{"started paused without a context", nil, state("phone", 50, "", "spotify:track:a", false), "device_changed track_changed"},
+		{"stopped", playing, nil, "stopped"},
+		{"paused", playing, state("phone", 50, "spotify:album:x", "spotify:track:a", false), "paused"},
+		{"resumed", state("phone", 50, "spotify:album:x", "spotify:track:a", false), playing, "resumed"},
+		{"next track", playing, state("phone", 50, "spotify:album:x", "spotify:track:b", true), "track_changed"},
+		{"track ended", playing, state("phone", 50, "spotify:album:x", "", false), "track_changed paused"},
+		{"other album", playing, state("phone", 50, "spotify:album:y", "spotify:track:b", true), "context_changed track_changed"},
+		{"context dropped", playing, state("phone", 50, "", "spotify:track:a", true), "context_changed"},
+		{"volume", playing, state("phone", 70, "spotify:album:x", "spotify:track:a", true), "volume_changed"},
+		{"transferred with another volume", playing, state("laptop", 70, "spotify:album:x", "spotify:track:a", true), "device_changed"},
+		{"shuffle", playing, shuffled, "shuffle_changed"},
+		{"repeat", playing, repeating, "repeat_changed"},
+		{"everything", shuffled, state("laptop", 10, "spotify:album:y", "spotify:track:b", false), "device_changed context_changed track_changed paused shuffle_changed"},
+	}
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	for _, tt := range tests {
+		events := Diff(tt.prev, tt.cur, now)
+
+		if got := types(events); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+
+		for _, e := range events {
+			if e.Previous != tt.prev || e.Current != tt.cur || !e.Time.Equal(now) {
+				t.Errorf("%s: %s doesn't describe the snapshots it was given", tt.name, e.Type)
+			}
+		}
+	}
+}
+
+func TestDiffFollowsTypesOrder(t *testing.T) {
+	order := make(map[string]int)
+
+	for k, typ := range Types {
+		order[typ] = k
+	}
+
+	prev := state("phone", 50, "spotify:album:x", "spotify:track:a", true)
+	cur := state("laptop", 10, "spotify:album:y", "spotify:track:b", false)
+	cur.ShuffleState, cur.RepeatState = true, "context"
+
+	for _, pair := range [][2]*api.PlayerState{{prev, cur}, {nil, prev}} {
+		events := Diff(pair[0], pair[1], time.Now())
+
+		for k := 1; k < len(events); k++ {
+			if order[events[k-1].Type] > order[events[k].Type] {
+				t.Errorf("%s came before %s", events[k-1].Type, events[k].Type)
+			}
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	a := state("phone", 50, "", "spotify:track:a", true)
+	b := state("phone", 50, "", "spotify:track:b", true)
+
+	tests := []struct {
+		cur  *api.PlayerState
+		want string
+	}{
+		{a, ""},
+		{a, ""},
+		{b, "track_changed"},
+		{nil, "stopped"},
+		{nil, ""},
+		{a, "device_changed track_changed resumed"},
+	}
+
+	var w Watcher
+
+	for k, tt := range tests {
+		if got := types(w.Update(tt.cur, time.Now())); got != tt.want {
+			t.Errorf("update %d: got %q, want %q", k, got, tt.want)
+		}
+	}
+
+	// Nothing playing when the watcher starts is still a first snapshot without events
+	var stopped Watcher
+
+	if got := types(stopped.Update(nil, time.Now())); got != "" {
+		t.Errorf("the first snapshot produced %q", got)
+	}
+
+	if got := types(stopped.Update(a, time.Now())); got != "device_changed track_changed resumed" {
+		t.Errorf("playing after starting stopped produced %q", got)
+	}
+}
+
+// at is a 200 second track at the given position
+func at(track string, seconds int, playing bool) *api.PlayerState {
+	ps := state("phone", 50, "", track, playing)
+	ps.ProgressMs = seconds * 1000
+	ps.Item.DurationMs = 200000
+
+	return ps
+}
+
+func TestWatcherCountsListenedTime(t *testing.T) {
+	t0 := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	type snapshot struct {
+		second int
+		ps     *api.PlayerState
+	}
+
+	tests := []struct {
+		name      string
+		snapshots []snapshot
+		want      string
+		played    *Play
+	}{
+		{"played through",
+			[]snapshot{{0, at("a", 0, true)}, {100, at("a", 100, true)}, {190, at("a", 190, true)}, {200, at("b", 5, true)}},
+			"track_changed", &Play{StartedAt: t0, ListenedMs: 195000}},
+		{"seeked to the end and skipped",
+			[]snapshot{{0, at("a", 0, true)}, {10, at("a", 10, true)}, {20, at("a", 180, true)}, {25, at("b", 0, true)}},
+			"track_changed", &Play{StartedAt: t0, ListenedMs: 25000}},
+		{"seeked back",
+			[]snapshot{{0, at("a", 0, true)}, {10, at("a", 10, true)}, {20, at("a", 2, true)}},
+			"", nil},
+		{"played again with repeat one",
+			[]snapshot{{0, at("a", 0, true)}, {190, at("a", 190, true)}, {200, at("a", 2, true)}},
+			"track_changed", &Play{StartedAt: t0, ListenedMs: 200000}},
+		{"paused and resumed",
+			[]snapshot{{0, at("a", 0, true)}, {10, at("a", 10, false)}, {100, at("a", 10, false)}, {110, at("a", 20, true)}, {120, nil}},
+			"stopped", &Play{StartedAt: t0, ListenedMs: 20000}},
+		{"skipped while paused",
+			[]snapshot{{0, at("a", 0, true)}, {10, at("a", 10, false)}, {100, at("b", 0, false)}},
+			"track_changed", &Play{StartedAt: t0, ListenedMs: 10000}},
+		{"first seen in the middle",
+			[]snapshot{{60, at("a", 60, true)}, {70, at("b", 0, true)}},
+			"track_changed", &Play{StartedAt: t0, ListenedMs: 70000}},
+		{"replay counted from its start",
+			[]snapshot{{0, at("a", 0, true)}, {190, at("a", 190, true)}, {200, at("a", 2, true)}, {250, at("a", 52, true)}, {260, at("b", 0, true)}},
+			"track_changed", &Play{StartedAt: t0.Add(198 * time.Second), ListenedMs: 62000}},
+	}
+
+	for _, tt := range tests {
+		var w Watcher
+		var events []Event
+
+		for _, s := range tt.snapshots {
+			events = w.Update(s.ps, t0.Add(time.Duration(s.second)*time.Second))
+		}
+
+		if got := types(events); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+			continue
+		}
+
+		for _, e := range events {
+			if e.Played == nil || tt.played == nil {
+				if e.Played != tt.played {
+					t.Errorf("%s: %s describes the play %+v, want %+v", tt.name, e.Type, e.Played, tt.played)
+				}
+			} else if !e.Played.StartedAt.Equal(tt.played.StartedAt) || e.Played.ListenedMs != tt.played.ListenedMs {
+				t.Errorf("%s: %s describes the play %+v, want %+v", tt.name, e.Type, *e.Played, *tt.played)
+			}
+		}
+	}
+}